@@ -4,7 +4,9 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/samber/do"
 	"github.com/serroba/web-demo-go/internal/container"
@@ -15,11 +17,17 @@ import (
 func main() {
 	opts := &container.Options{
 		RedisAddr:        getEnv("REDIS_ADDR", "localhost:6379"),
+		Storage:          getEnv("STORAGE", container.StoragePostgres),
 		DatabaseURL:      getEnv("DATABASE_URL", ""),
 		LogFormat:        getEnv("LOG_FORMAT", "console"),
 		TopicURLCreated:  getEnv("TOPIC_URL_CREATED", "url.created"),
 		TopicURLAccessed: getEnv("TOPIC_URL_ACCESSED", "url.accessed"),
 		ConsumerGroup:    getEnv("CONSUMER_GROUP", "analytics"),
+		CacheTTL:         getDurationEnv("CACHE_TTL", time.Hour),
+		CacheRedis:       true,
+		MetadataGroup:    getEnv("METADATA_CONSUMER_GROUP", "metadata"),
+		MetadataTimeout:  getDurationEnv("METADATA_TIMEOUT", 5*time.Second),
+		MetadataMaxBytes: getInt64Env("METADATA_MAX_BYTES", 1<<20),
 	}
 
 	injector := do.New()
@@ -28,6 +36,9 @@ func main() {
 	container.RedisPackage(injector)
 	container.PostgresPackage(injector)
 	container.AnalyticsStorePackage(injector)
+	container.RepositoryPackage(injector)
+	container.AuditPackage(injector)
+	container.LinkMetadataPackage(injector)
 	container.ConsumerGroupPackage(injector)
 
 	logger := do.MustInvoke[*zap.Logger](injector)
@@ -61,3 +72,19 @@ func getEnv(key, defaultValue string) string {
 
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+
+	return defaultValue
+}
+
+func getInt64Env(key string, defaultValue int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return v
+	}
+
+	return defaultValue
+}
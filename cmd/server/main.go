@@ -1,26 +1,53 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/humacli"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/samber/do"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/codegrowth"
 	"github.com/serroba/web-demo-go/internal/container"
+	"github.com/serroba/web-demo-go/internal/retention"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/serroba/web-demo-go/internal/transfer"
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+// errNotPostgres is returned by commands maintaining data only stored in PostgreSQL.
+var errNotPostgres = errors.New("this command requires postgres storage")
+
+// errNotSharded is returned by commands maintaining the shards of sharded storage.
+var errNotSharded = errors.New("this command requires sharded storage")
+
 func registerPackages(injector *do.Injector, options *container.Options) {
 	do.ProvideValue(injector, options)
 	container.LoggerPackage(injector)
 	container.RedisPackage(injector)
 	container.PostgresPackage(injector)
+	container.PostgresReplicasPackage(injector)
 	container.RepositoryPackage(injector)
+	container.ReservedWordsPackage(injector)
+	container.CodeGrowthPackage(injector)
+	container.AnalyticsStorePackage(injector)
+	container.AuthPackage(injector)
+	container.AuditPackage(injector)
+	container.BlocklistPackage(injector)
+	container.QuotaPackage(injector)
+	container.RetentionPackage(injector)
+	container.TransferPackage(injector)
 	container.RateLimitPackage(injector)
 	container.PublisherGroupPackage(injector)
 	container.HTTPPackage(injector)
@@ -35,12 +62,16 @@ func main() {
 
 		var server *http.Server
 
+		jobsCtx, stopJobs := context.WithCancel(context.Background())
+
 		hooks.OnStart(func() {
 			router := do.MustInvoke[*chi.Mux](injector)
 
 			// Invoke API to trigger route registration
 			_ = do.MustInvoke[huma.API](injector)
 
+			startJobs(jobsCtx, injector, options)
+
 			server = &http.Server{
 				Addr:              fmt.Sprintf(":%d", options.Port),
 				Handler:           router,
@@ -56,6 +87,7 @@ func main() {
 
 		hooks.OnStop(func() {
 			logger.Info("shutting down")
+			stopJobs()
 
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
@@ -74,5 +106,481 @@ func main() {
 		})
 	})
 
+	cli.Root().AddCommand(apiKeyCommand(), workspaceCommand(), linksCommand())
+
 	cli.Run()
 }
+
+// startJobs starts the enabled background jobs, which run until ctx is cancelled.
+func startJobs(ctx context.Context, injector *do.Injector, options *container.Options) {
+	if options.PurgeInterval > 0 {
+		purger := do.MustInvoke[*retention.Purger](injector)
+		go purger.Run(ctx, options.PurgeInterval)
+	}
+
+	if options.Storage == container.StoragePostgres && options.DatabaseReplicas != "" {
+		replicas := do.MustInvoke[*container.PostgresReplicas](injector)
+		go replicas.Run(ctx, options.ReplicaInterval)
+	}
+
+	if options.CodeCountInterval > 0 {
+		codes := do.MustInvoke[*codegrowth.Generator](injector)
+		go codes.Run(ctx, options.CodeCountInterval)
+	}
+
+	if cached, ok := do.MustInvoke[shortener.Repository](injector).(*store.CachedRepository); ok {
+		go cached.Listen(ctx)
+	}
+}
+
+// apiKeyCommand returns the command group for managing API keys.
+func apiKeyCommand() *cobra.Command {
+	var (
+		owner, workspace, name string
+		roles                  []string
+	)
+
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print its secret",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, _ []string, options *container.Options) {
+			if err := createAPIKey(cmd.Context(), options, owner, workspace, name, roles); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to create api key:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	create.Flags().StringVar(&owner, "owner", "", "Owner ID the key authenticates as")
+	create.Flags().StringVar(&workspace, "workspace", auth.DefaultWorkspace, "Workspace the key is bound to")
+	create.Flags().StringVar(&name, "name", "", "Human-readable key name")
+	create.Flags().StringSliceVar(&roles, "role", []string{string(auth.RoleCreator)},
+		"Role granted to the key (viewer, creator, admin)")
+	_ = create.MarkFlagRequired("owner")
+
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys",
+	}
+	cmd.AddCommand(create)
+
+	return cmd
+}
+
+// createAPIKey stores a new API key for owner and prints the secret.
+// The secret is shown only once; just its hash is persisted.
+func createAPIKey(
+	ctx context.Context, options *container.Options, owner, workspace, name string, roleNames []string,
+) error {
+	roles, err := parseRoles(roleNames)
+	if err != nil {
+		return err
+	}
+
+	injector, err := authInjector(options)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = injector.Shutdown() }()
+
+	keys, err := do.Invoke[auth.KeyStore](injector)
+	if err != nil {
+		return err
+	}
+
+	secret, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	key := &auth.APIKey{
+		ID:          uuid.NewString(),
+		OwnerID:     owner,
+		WorkspaceID: workspace,
+		Name:        name,
+		Hash:        auth.HashAPIKey(secret),
+		Roles:       roles,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := keys.Create(ctx, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("id:        %s\nowner:     %s\nworkspace: %s\nroles:     %v\nsecret:    %s\n",
+		key.ID, key.OwnerID, key.WorkspaceID, roleNames, secret)
+
+	return nil
+}
+
+// workspaceCommand returns the command group for managing workspaces and their members.
+func workspaceCommand() *cobra.Command {
+	var id, name string
+
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a workspace",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, _ []string, options *container.Options) {
+			workspace := &auth.Workspace{ID: id, Name: name, CreatedAt: time.Now()}
+
+			err := withWorkspaces(options, func(workspaces auth.WorkspaceStore) error {
+				return workspaces.CreateWorkspace(cmd.Context(), workspace)
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "failed to create workspace:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	create.Flags().StringVar(&id, "id", "", "Workspace ID")
+	create.Flags().StringVar(&name, "name", "", "Human-readable workspace name")
+	_ = create.MarkFlagRequired("id")
+
+	var (
+		workspace, user string
+		roles           []string
+	)
+
+	add := &cobra.Command{
+		Use:   "add-member",
+		Short: "Add a user to a workspace, or change their roles",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, _ []string, options *container.Options) {
+			if err := addMember(cmd.Context(), options, workspace, user, roles); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to add member:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	add.Flags().StringVar(&workspace, "workspace", "", "Workspace ID")
+	add.Flags().StringVar(&user, "user", "", "User ID, as found in the SSO owner claim")
+	add.Flags().StringSliceVar(&roles, "role", []string{string(auth.RoleCreator)},
+		"Role granted within the workspace (viewer, creator, admin)")
+	_ = add.MarkFlagRequired("workspace")
+	_ = add.MarkFlagRequired("user")
+
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Manage workspaces",
+	}
+	cmd.AddCommand(create, add)
+
+	return cmd
+}
+
+// addMember grants user roles within workspace.
+func addMember(ctx context.Context, options *container.Options, workspace, user string, roleNames []string) error {
+	roles, err := parseRoles(roleNames)
+	if err != nil {
+		return err
+	}
+
+	return withWorkspaces(options, func(workspaces auth.WorkspaceStore) error {
+		return workspaces.AddMember(ctx, &auth.Member{
+			WorkspaceID: workspace,
+			UserID:      user,
+			Roles:       roles,
+			CreatedAt:   time.Now(),
+		})
+	})
+}
+
+// withWorkspaces runs fn with the workspace store.
+func withWorkspaces(options *container.Options, fn func(auth.WorkspaceStore) error) error {
+	injector, err := authInjector(options)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = injector.Shutdown() }()
+
+	workspaces, err := do.Invoke[auth.WorkspaceStore](injector)
+	if err != nil {
+		return err
+	}
+
+	return fn(workspaces)
+}
+
+// linksCommand returns the command group for maintaining stored links.
+func linksCommand() *cobra.Command {
+	backfill := &cobra.Command{
+		Use:   "backfill-hashes",
+		Short: "Record the URL hash of links created without one, for reverse lookups",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, _ []string, options *container.Options) {
+			if err := backfillHashes(cmd.Context(), options); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to backfill url hashes:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	reshard := &cobra.Command{
+		Use:   "reshard",
+		Short: "Move links to the shard of their code, after shards were added",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, _ []string, options *container.Options) {
+			if err := reshardLinks(cmd.Context(), options); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to reshard links:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	cmd := &cobra.Command{
+		Use:   "links",
+		Short: "Maintain stored links",
+	}
+	cmd.AddCommand(backfill, reshard, exportCommand(), importCommand())
+
+	return cmd
+}
+
+// exportCommand returns the command exporting links.
+func exportCommand() *cobra.Command {
+	var format, workspace, output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export live links with their metadata as CSV or JSONL",
+		Run: humacli.WithOptions(func(cmd *cobra.Command, _ []string, options *container.Options) {
+			query := shortener.ListQuery{WorkspaceID: workspace}
+			if err := exportLinks(cmd.Context(), options, transfer.Format(format), query, output); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to export links:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	cmd.Flags().StringVar(&format, "format", string(transfer.FormatJSONL), "Export format (csv, jsonl)")
+	cmd.Flags().StringVar(&workspace, "workspace", "", "Only export links of this workspace")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "File to write, - for stdout")
+
+	return cmd
+}
+
+// exportLinks writes the links matching query to output and prints how many were exported.
+func exportLinks(
+	ctx context.Context, options *container.Options, format transfer.Format, query shortener.ListQuery, output string,
+) error {
+	injector := do.New()
+	do.ProvideValue(injector, options)
+	container.RedisPackage(injector)
+	container.PostgresPackage(injector)
+	container.RepositoryPackage(injector)
+
+	defer func() { _ = injector.Shutdown() }()
+
+	lister, err := do.Invoke[shortener.Lister](injector)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if output != "-" {
+		if out, err = os.Create(filepath.Clean(output)); err != nil {
+			return err
+		}
+
+		defer func() { _ = out.Close() }()
+	}
+
+	w := bufio.NewWriter(out)
+
+	enc, err := transfer.NewEncoder(w, format)
+	if err != nil {
+		return err
+	}
+
+	exported, err := transfer.Export(ctx, lister, query, enc)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d links\n", exported)
+
+	return nil
+}
+
+// importCommand returns the command importing links.
+func importCommand() *cobra.Command {
+	var (
+		format, conflict       string
+		dryRun, suppressEvents bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import links from CSV or JSONL, read from stdin without a file",
+		Args:  cobra.MaximumNArgs(1),
+		Run: humacli.WithOptions(func(cmd *cobra.Command, args []string, options *container.Options) {
+			policy, err := transfer.ParseConflictPolicy(conflict)
+			if err == nil {
+				err = importLinks(cmd.Context(), options, args, transfer.Format(format), transfer.Options{
+					Conflict:       policy,
+					DryRun:         dryRun,
+					SuppressEvents: suppressEvents,
+					Progress:       printImportReport,
+				})
+			}
+
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "failed to import links:", err)
+				os.Exit(1)
+			}
+		}),
+	}
+
+	cmd.Flags().StringVar(&format, "format", string(transfer.FormatJSONL), "Import format (csv, jsonl)")
+	cmd.Flags().StringVar(&conflict, "conflict", string(transfer.ConflictFail),
+		"What to do with a code that is taken (skip, overwrite, fail)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate and report without storing anything")
+	cmd.Flags().BoolVar(&suppressEvents, "suppress-events", false, "Do not publish url.created for imported links")
+
+	return cmd
+}
+
+// importLinks imports the links of the file in args, or of stdin, and prints the report.
+func importLinks(
+	ctx context.Context, options *container.Options, args []string, format transfer.Format, opts transfer.Options,
+) error {
+	in := os.Stdin
+
+	if len(args) > 0 && args[0] != "-" {
+		file, err := os.Open(filepath.Clean(args[0]))
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = file.Close() }()
+
+		in = file
+	}
+
+	dec, err := transfer.NewDecoder(bufio.NewReader(in), format)
+	if err != nil {
+		return err
+	}
+
+	injector := do.New()
+	registerPackages(injector, options)
+
+	defer func() { _ = injector.Shutdown() }()
+
+	// Registering the routes reserves their first segment, which codes may not use
+	if _, err := do.Invoke[huma.API](injector); err != nil {
+		return err
+	}
+
+	importer, err := do.Invoke[*transfer.Importer](injector)
+	if err != nil {
+		return err
+	}
+
+	report, err := importer.Import(ctx, dec, opts)
+	printImportReport(*report)
+
+	for _, e := range report.Errors {
+		fmt.Fprintf(os.Stderr, "line %d %s: %s\n", e.Line, e.Code, e.Message)
+	}
+
+	return err
+}
+
+// printImportReport prints the tallies of an import so far.
+func printImportReport(r transfer.Report) {
+	fmt.Fprintf(os.Stderr, "%d rows: %d created, %d overwritten, %d skipped, %d demoted, %d failed\n",
+		r.Rows, r.Created, r.Overwritten, r.Skipped, r.Demoted, r.Failed)
+}
+
+// backfillHashes records the URL hash of links created without one and prints how many were updated.
+func backfillHashes(ctx context.Context, options *container.Options) error {
+	if options.Storage != container.StoragePostgres {
+		return errNotPostgres
+	}
+
+	injector := do.New()
+	do.ProvideValue(injector, options)
+	container.PostgresPackage(injector)
+
+	defer func() { _ = injector.Shutdown() }()
+
+	pool, err := do.Invoke[*container.PostgresPool](injector)
+	if err != nil {
+		return err
+	}
+
+	updated, err := store.NewPostgresStore(pool.Pool).BackfillURLHashes(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("updated %d links\n", updated)
+
+	return nil
+}
+
+// reshardLinks moves links stored on another shard than that of their code to
+// it, and prints how many were moved.
+func reshardLinks(ctx context.Context, options *container.Options) error {
+	if options.StorageShards == "" {
+		return errNotSharded
+	}
+
+	injector := do.New()
+	do.ProvideValue(injector, options)
+	container.RepositoryPackage(injector)
+
+	defer func() { _ = injector.Shutdown() }()
+
+	links, err := do.Invoke[container.LinkStore](injector)
+	if err != nil {
+		return err
+	}
+
+	sharded, ok := links.(*container.ShardedStore)
+	if !ok {
+		return errNotSharded
+	}
+
+	stats, err := sharded.Rebalance(ctx)
+	fmt.Printf("moved %d links, %d of them demoted to token links\n", stats.Moved, stats.Demoted)
+
+	return err
+}
+
+// authInjector returns an injector with just the auth stores, for management commands.
+// Other storages keep API keys and workspaces in the memory of each server.
+func authInjector(options *container.Options) (*do.Injector, error) {
+	if options.Storage != container.StoragePostgres {
+		return nil, errNotPostgres
+	}
+
+	injector := do.New()
+	do.ProvideValue(injector, options)
+	container.PostgresPackage(injector)
+	container.AuthPackage(injector)
+
+	return injector, nil
+}
+
+// parseRoles validates role names given on the command line.
+func parseRoles(names []string) ([]auth.Role, error) {
+	roles := make([]auth.Role, 0, len(names))
+
+	for _, n := range names {
+		role, ok := auth.ParseRole(n)
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q", n)
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
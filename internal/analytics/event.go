@@ -8,6 +8,7 @@ type URLCreatedEvent struct {
 	OriginalURL string    `json:"originalUrl"`
 	URLHash     string    `json:"urlHash,omitempty"`
 	Strategy    string    `json:"strategy"`
+	WorkspaceID string    `json:"workspaceId"`
 	CreatedAt   time.Time `json:"createdAt"`
 	ClientIP    string    `json:"clientIp"`
 	UserAgent   string    `json:"userAgent"`
@@ -15,9 +16,10 @@ type URLCreatedEvent struct {
 
 // URLAccessedEvent represents an event emitted when a short URL is accessed.
 type URLAccessedEvent struct {
-	Code       string    `json:"code"`
-	AccessedAt time.Time `json:"accessedAt"`
-	ClientIP   string    `json:"clientIp"`
-	UserAgent  string    `json:"userAgent"`
-	Referrer   string    `json:"referrer,omitempty"`
+	Code        string    `json:"code"`
+	WorkspaceID string    `json:"workspaceId"`
+	AccessedAt  time.Time `json:"accessedAt"`
+	ClientIP    string    `json:"clientIp"`
+	UserAgent   string    `json:"userAgent"`
+	Referrer    string    `json:"referrer,omitempty"`
 }
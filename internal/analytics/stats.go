@@ -0,0 +1,27 @@
+package analytics
+
+import (
+	"context"
+	"time"
+)
+
+// DailyClicks is the number of accesses of a short URL on one day (UTC).
+type DailyClicks struct {
+	Day    time.Time
+	Clicks int64
+}
+
+// LinkStats summarizes the accesses of a short URL over a period.
+type LinkStats struct {
+	Code           string
+	Since          time.Time
+	TotalClicks    int64
+	UniqueVisitors int64
+	LastAccessedAt *time.Time
+	Daily          []DailyClicks
+}
+
+// StatsReader reads aggregated analytics.
+type StatsReader interface {
+	LinkStats(ctx context.Context, code string, since time.Time) (*LinkStats, error)
+}
@@ -7,3 +7,8 @@ type Store interface {
 	SaveURLCreated(ctx context.Context, event *URLCreatedEvent) error
 	SaveURLAccessed(ctx context.Context, event *URLAccessedEvent) error
 }
+
+// EventDeleter deletes the events recorded for a short URL.
+type EventDeleter interface {
+	DeleteEvents(ctx context.Context, code string) error
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/analytics"
 	"go.uber.org/zap"
@@ -22,6 +23,7 @@ func (n *Noop) SaveURLCreated(_ context.Context, event *analytics.URLCreatedEven
 		zap.String("code", event.Code),
 		zap.String("originalUrl", event.OriginalURL),
 		zap.String("strategy", event.Strategy),
+		zap.String("workspaceId", event.WorkspaceID),
 		zap.Time("createdAt", event.CreatedAt),
 	)
 
@@ -31,9 +33,20 @@ func (n *Noop) SaveURLCreated(_ context.Context, event *analytics.URLCreatedEven
 func (n *Noop) SaveURLAccessed(_ context.Context, event *analytics.URLAccessedEvent) error {
 	n.logger.Info("url accessed event received",
 		zap.String("code", event.Code),
+		zap.String("workspaceId", event.WorkspaceID),
 		zap.Time("accessedAt", event.AccessedAt),
 		zap.String("referrer", event.Referrer),
 	)
 
 	return nil
 }
+
+// LinkStats returns empty statistics; the no-op store keeps no events.
+func (n *Noop) LinkStats(_ context.Context, code string, since time.Time) (*analytics.LinkStats, error) {
+	return &analytics.LinkStats{Code: code, Since: since}, nil
+}
+
+// DeleteEvents does nothing; the no-op store keeps no events.
+func (n *Noop) DeleteEvents(_ context.Context, _ string) error {
+	return nil
+}
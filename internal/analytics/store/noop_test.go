@@ -51,3 +51,21 @@ func TestNoop_SaveURLAccessed(t *testing.T) {
 
 	require.NoError(t, err)
 }
+
+func TestNoop_LinkStats(t *testing.T) {
+	noop := store.NewNoop(zap.NewNop())
+	since := time.Now().Add(-24 * time.Hour)
+
+	stats, err := noop.LinkStats(context.Background(), "abc123", since)
+
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", stats.Code)
+	assert.Equal(t, since, stats.Since)
+	assert.Zero(t, stats.TotalClicks)
+}
+
+func TestNoop_DeleteEvents(t *testing.T) {
+	noop := store.NewNoop(zap.NewNop())
+
+	require.NoError(t, noop.DeleteEvents(context.Background(), "abc123"))
+}
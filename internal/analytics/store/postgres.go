@@ -3,7 +3,9 @@ package store
 import (
 	"context"
 	"net"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/serroba/web-demo-go/internal/analytics"
 )
@@ -20,8 +22,10 @@ func NewPostgres(pool *pgxpool.Pool) *Postgres {
 
 func (p *Postgres) SaveURLCreated(ctx context.Context, event *analytics.URLCreatedEvent) error {
 	query := `
-		INSERT INTO url_created_events (code, original_url, url_hash, strategy, created_at, client_ip, user_agent)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO url_created_events (
+			code, original_url, url_hash, strategy, workspace_id, created_at, client_ip, user_agent
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
 	_, err := p.pool.Exec(ctx, query,
@@ -29,6 +33,7 @@ func (p *Postgres) SaveURLCreated(ctx context.Context, event *analytics.URLCreat
 		event.OriginalURL,
 		nullableString(event.URLHash),
 		event.Strategy,
+		event.WorkspaceID,
 		event.CreatedAt,
 		parseIP(event.ClientIP),
 		nullableString(event.UserAgent),
@@ -39,12 +44,13 @@ func (p *Postgres) SaveURLCreated(ctx context.Context, event *analytics.URLCreat
 
 func (p *Postgres) SaveURLAccessed(ctx context.Context, event *analytics.URLAccessedEvent) error {
 	query := `
-		INSERT INTO url_accessed_events (code, accessed_at, client_ip, user_agent, referrer)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO url_accessed_events (code, workspace_id, accessed_at, client_ip, user_agent, referrer)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
 
 	_, err := p.pool.Exec(ctx, query,
 		event.Code,
+		event.WorkspaceID,
 		event.AccessedAt,
 		parseIP(event.ClientIP),
 		nullableString(event.UserAgent),
@@ -54,6 +60,59 @@ func (p *Postgres) SaveURLAccessed(ctx context.Context, event *analytics.URLAcce
 	return err
 }
 
+// DeleteEvents removes every creation and access event of the short URL with code.
+func (p *Postgres) DeleteEvents(ctx context.Context, code string) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM url_created_events WHERE code = $1`, code)
+	batch.Queue(`DELETE FROM url_accessed_events WHERE code = $1`, code)
+
+	return p.pool.SendBatch(ctx, batch).Close()
+}
+
+func (p *Postgres) LinkStats(ctx context.Context, code string, since time.Time) (*analytics.LinkStats, error) {
+	stats := &analytics.LinkStats{Code: code, Since: since}
+
+	summary := `
+		SELECT count(*), count(DISTINCT client_ip), max(accessed_at)
+		FROM url_accessed_events
+		WHERE code = $1 AND accessed_at >= $2
+	`
+
+	err := p.pool.QueryRow(ctx, summary, code, since).Scan(
+		&stats.TotalClicks,
+		&stats.UniqueVisitors,
+		&stats.LastAccessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	daily := `
+		SELECT time_bucket(INTERVAL '1 day', accessed_at) AS day, count(*)
+		FROM url_accessed_events
+		WHERE code = $1 AND accessed_at >= $2
+		GROUP BY day
+		ORDER BY day
+	`
+
+	rows, err := p.pool.Query(ctx, daily, code, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d analytics.DailyClicks
+		if err := rows.Scan(&d.Day, &d.Clicks); err != nil {
+			return nil, err
+		}
+
+		stats.Daily = append(stats.Daily, d)
+	}
+
+	return stats, rows.Err()
+}
+
 func nullableString(s string) *string {
 	if s == "" {
 		return nil
@@ -70,5 +129,8 @@ func parseIP(s string) net.IP {
 	return net.ParseIP(s)
 }
 
-// Compile-time check.
-var _ analytics.Store = (*Postgres)(nil)
+// Compile-time checks.
+var (
+	_ analytics.Store       = (*Postgres)(nil)
+	_ analytics.StatsReader = (*Postgres)(nil)
+)
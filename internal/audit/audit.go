@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/requestid"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// Action is a recorded change.
+type Action string
+
+const (
+	// ActionLinkCreated records a new link.
+	ActionLinkCreated Action = "link.created"
+	// ActionLinkUpdated records a change to a link's destination or metadata.
+	ActionLinkUpdated Action = "link.updated"
+	// ActionLinkDisabled records an admin takedown.
+	ActionLinkDisabled Action = "link.disabled"
+	// ActionLinkEnabled records a takedown being lifted.
+	ActionLinkEnabled Action = "link.enabled"
+	// ActionLinkDeleted records a link being moved to the trash.
+	ActionLinkDeleted Action = "link.deleted"
+	// ActionLinkRestored records a deleted link being restored.
+	ActionLinkRestored Action = "link.restored"
+	// ActionLinkPurged records a deleted link being removed for good.
+	ActionLinkPurged Action = "link.purged"
+	// ActionDomainBlocked records a domain added to, or changed in, the blocklist.
+	ActionDomainBlocked Action = "domain.blocked"
+	// ActionDomainUnblocked records a domain removed from the blocklist.
+	ActionDomainUnblocked Action = "domain.unblocked"
+)
+
+// ErrNotRecorded is returned for a change that was undone because the audit log
+// could not record it.
+var ErrNotRecorded = errors.New("change could not be recorded in the audit log")
+
+// Entry is an append-only record of a change.
+type Entry struct {
+	ID     int64
+	Action Action
+	// Target is the code of the link, or the blocklisted domain, that changed.
+	Target      string
+	WorkspaceID string
+	// Actor is the owner ID of the principal that made the change. It is empty
+	// for anonymous requests and for background jobs, which have no RequestID either.
+	Actor     string
+	KeyID     string
+	RequestID string
+	Before    map[string]any // nil for creations
+	After     map[string]any // nil for deletions
+	CreatedAt time.Time
+}
+
+// Store persists audit entries. Entries are never updated or deleted.
+type Store interface {
+	Append(ctx context.Context, entry *Entry) error
+	// LinkHistory returns up to limit entries of actions on the link with code
+	// made within a workspace, newest first.
+	LinkHistory(ctx context.Context, workspaceID, code string, limit int) ([]*Entry, error)
+}
+
+// Log records changes, attributing them to the principal and request that made them.
+type Log struct {
+	store  Store
+	logger *zap.Logger
+}
+
+// NewLog creates a new audit log backed by store.
+func NewLog(store Store, logger *zap.Logger) *Log {
+	return &Log{store: store, logger: logger}
+}
+
+// Record appends entry after filling in its actor, request ID and timestamp. It
+// returns an error wrapping ErrNotRecorded if the entry could not be appended.
+func (l *Log) Record(ctx context.Context, entry *Entry) error {
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		entry.Actor = p.OwnerID
+		entry.KeyID = p.KeyID
+	}
+
+	entry.RequestID = requestid.FromContext(ctx)
+	entry.CreatedAt = time.Now()
+
+	if err := l.store.Append(ctx, entry); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotRecorded, err)
+	}
+
+	return nil
+}
+
+// recordOrUndo records the change entry describes, which has already been
+// made, or undoes it, so that no change goes unaudited. Failures to undo it are
+// logged, as nothing else can be done about them.
+func (l *Log) recordOrUndo(ctx context.Context, entry *Entry, undo func(context.Context) error) error {
+	err := l.Record(ctx, entry)
+	if err == nil {
+		return nil
+	}
+
+	// The change is undone even if the request that made it was cancelled
+	if undoErr := undo(context.WithoutCancel(ctx)); undoErr != nil {
+		l.logger.Error("failed to undo unaudited change",
+			zap.String("action", string(entry.Action)),
+			zap.String("target", entry.Target),
+			zap.String("requestId", entry.RequestID),
+			zap.NamedError("auditError", err),
+			zap.Error(undoErr),
+		)
+	}
+
+	return err
+}
+
+// LinkHistory returns up to limit changes of the link with code made within a
+// workspace, newest first.
+func (l *Log) LinkHistory(ctx context.Context, workspaceID, code string, limit int) ([]*Entry, error) {
+	return l.store.LinkHistory(ctx, workspaceID, code, limit)
+}
+
+// LinkSnapshot returns the audited fields of a link.
+func LinkSnapshot(u *shortener.ShortURL) map[string]any {
+	snapshot := map[string]any{
+		"originalUrl": u.OriginalURL,
+		"strategy":    u.Strategy,
+		"ownerId":     u.OwnerID,
+		"title":       u.Title,
+		"description": u.Description,
+		"imageUrl":    u.ImageURL,
+		"tags":        u.Tags,
+		"notes":       u.Notes,
+	}
+
+	if u.DisabledAt != nil {
+		snapshot["disabledAt"] = u.DisabledAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if u.DeletedAt != nil {
+		snapshot["deletedAt"] = u.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return snapshot
+}
+
+// DomainSnapshot returns the audited fields of a blocklist entry.
+func DomainSnapshot(e *blocklist.Entry) map[string]any {
+	return map[string]any{
+		"domain":    e.Domain,
+		"reason":    e.Reason,
+		"createdBy": e.CreatedBy,
+	}
+}
@@ -0,0 +1,89 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+	"github.com/serroba/web-demo-go/internal/audit/store"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/requestid"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type failingStore struct {
+	audit.Store
+}
+
+func (failingStore) Append(_ context.Context, _ *audit.Entry) error {
+	return assert.AnError
+}
+
+// requestContext returns the context of a request made by alice with an API key.
+func requestContext() context.Context {
+	ctx := auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+		OwnerID: "alice", KeyID: "key-1", WorkspaceID: "team-a",
+	})
+
+	return requestid.NewContext(ctx, "req-1")
+}
+
+func TestLog(t *testing.T) {
+	t.Run("attributes entries to the principal and request", func(t *testing.T) {
+		log := audit.NewLog(store.NewMemory(), zap.NewNop())
+
+		require.NoError(t, log.Record(requestContext(), &audit.Entry{
+			Action: audit.ActionLinkCreated, Target: "abc", WorkspaceID: "team-a",
+		}))
+
+		history, err := log.LinkHistory(context.Background(), "team-a", "abc", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "alice", history[0].Actor)
+		assert.Equal(t, "key-1", history[0].KeyID)
+		assert.Equal(t, "req-1", history[0].RequestID)
+		assert.WithinDuration(t, time.Now(), history[0].CreatedAt, time.Minute)
+	})
+
+	t.Run("leaves background changes unattributed", func(t *testing.T) {
+		log := audit.NewLog(store.NewMemory(), zap.NewNop())
+
+		require.NoError(t, log.Record(context.Background(), &audit.Entry{Action: audit.ActionLinkUpdated, Target: "abc"}))
+
+		history, err := log.LinkHistory(context.Background(), "", "abc", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Empty(t, history[0].Actor)
+		assert.Empty(t, history[0].RequestID)
+	})
+
+	t.Run("returns store errors", func(t *testing.T) {
+		log := audit.NewLog(failingStore{}, zap.NewNop())
+
+		err := log.Record(context.Background(), &audit.Entry{Action: audit.ActionLinkCreated, Target: "abc"})
+		require.ErrorIs(t, err, audit.ErrNotRecorded)
+		require.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestLinkSnapshot(t *testing.T) {
+	disabledAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	snapshot := audit.LinkSnapshot(&shortener.ShortURL{
+		OriginalURL: "https://example.com",
+		Strategy:    "token",
+		OwnerID:     "alice",
+		Metadata:    shortener.Metadata{Tags: []string{"a"}},
+		DisabledAt:  &disabledAt,
+	})
+
+	assert.Equal(t, "https://example.com", snapshot["originalUrl"])
+	assert.Equal(t, "alice", snapshot["ownerId"])
+	assert.Equal(t, []string{"a"}, snapshot["tags"])
+	assert.Equal(t, "2026-10-18T12:00:00Z", snapshot["disabledAt"])
+	assert.NotContains(t, audit.LinkSnapshot(&shortener.ShortURL{}), "disabledAt")
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+)
+
+// BlocklistStore wraps a blocklist.Store, recording every blocklist edit in the audit log.
+// An edit the log cannot record is undone, and fails with an error wrapping ErrNotRecorded.
+type BlocklistStore struct {
+	blocklist.Store
+
+	log *Log
+}
+
+// NewBlocklistStore creates a new auditing blocklist store decorator.
+func NewBlocklistStore(store blocklist.Store, log *Log) *BlocklistStore {
+	return &BlocklistStore{Store: store, log: log}
+}
+
+// Add blocks a domain and records the entry it replaced, if any.
+func (s *BlocklistStore) Add(ctx context.Context, entry *blocklist.Entry) error {
+	entries, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	before := find(entries, entry.Domain)
+
+	if err := s.Store.Add(ctx, entry); err != nil {
+		return err
+	}
+
+	return s.log.recordOrUndo(ctx, &Entry{
+		Action:      ActionDomainBlocked,
+		Target:      entry.Domain,
+		WorkspaceID: auth.WorkspaceIDFromContext(ctx),
+		Before:      snapshot(before),
+		After:       DomainSnapshot(entry),
+	}, func(ctx context.Context) error {
+		if before == nil {
+			return s.Store.Remove(ctx, entry.Domain)
+		}
+
+		return s.Store.Add(ctx, before)
+	})
+}
+
+// Remove unblocks a domain and records the removed entry.
+func (s *BlocklistStore) Remove(ctx context.Context, domain string) error {
+	entries, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	before := find(entries, domain)
+
+	if err := s.Store.Remove(ctx, domain); err != nil {
+		return err
+	}
+
+	return s.log.recordOrUndo(ctx, &Entry{
+		Action:      ActionDomainUnblocked,
+		Target:      domain,
+		WorkspaceID: auth.WorkspaceIDFromContext(ctx),
+		Before:      snapshot(before),
+	}, func(ctx context.Context) error {
+		if before == nil {
+			return nil
+		}
+
+		return s.Store.Add(ctx, before)
+	})
+}
+
+// find returns the entry of domain among entries, or nil if it is not blocked.
+func find(entries []*blocklist.Entry, domain string) *blocklist.Entry {
+	for _, e := range entries {
+		if e.Domain == domain {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// snapshot returns the snapshot of a blocklist entry, or nil for none.
+func snapshot(e *blocklist.Entry) map[string]any {
+	if e == nil {
+		return nil
+	}
+
+	return DomainSnapshot(e)
+}
+
+// Compile-time check.
+var _ blocklist.Store = (*BlocklistStore)(nil)
@@ -0,0 +1,89 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	blockliststore "github.com/serroba/web-demo-go/internal/blocklist/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingStore keeps appended entries so tests can inspect any action.
+type recordingStore struct {
+	audit.Store
+
+	entries []*audit.Entry
+}
+
+func (s *recordingStore) Append(_ context.Context, entry *audit.Entry) error {
+	s.entries = append(s.entries, entry)
+
+	return nil
+}
+
+type failingListStore struct {
+	blocklist.Store
+}
+
+func (failingListStore) List(_ context.Context) ([]*blocklist.Entry, error) {
+	return nil, assert.AnError
+}
+
+func TestBlocklistStore(t *testing.T) {
+	ctx := requestContext()
+
+	t.Run("records blocklist edits", func(t *testing.T) {
+		recorded := &recordingStore{}
+		s := audit.NewBlocklistStore(blockliststore.NewMemory(), audit.NewLog(recorded, zap.NewNop()))
+
+		require.NoError(t, s.Add(ctx, &blocklist.Entry{Domain: "evil.example", Reason: "spam"}))
+		require.NoError(t, s.Add(ctx, &blocklist.Entry{Domain: "evil.example", Reason: "phishing"}))
+		require.NoError(t, s.Remove(ctx, "evil.example"))
+
+		require.Len(t, recorded.entries, 3)
+
+		added, changed, removed := recorded.entries[0], recorded.entries[1], recorded.entries[2]
+		assert.Equal(t, audit.ActionDomainBlocked, added.Action)
+		assert.Equal(t, "evil.example", added.Target)
+		assert.Equal(t, "alice", added.Actor)
+		assert.Nil(t, added.Before)
+		assert.Equal(t, "spam", changed.Before["reason"])
+		assert.Equal(t, "phishing", changed.After["reason"])
+		assert.Equal(t, audit.ActionDomainUnblocked, removed.Action)
+		assert.Equal(t, "phishing", removed.Before["reason"])
+		assert.Nil(t, removed.After)
+	})
+
+	t.Run("records nothing for failed edits", func(t *testing.T) {
+		recorded := &recordingStore{}
+		s := audit.NewBlocklistStore(blockliststore.NewMemory(), audit.NewLog(recorded, zap.NewNop()))
+
+		require.ErrorIs(t, s.Remove(ctx, "unknown.example"), blocklist.ErrNotFound)
+
+		failing := audit.NewBlocklistStore(failingListStore{}, audit.NewLog(recorded, zap.NewNop()))
+		require.Error(t, failing.Add(ctx, &blocklist.Entry{Domain: "evil.example"}))
+		require.Error(t, failing.Remove(ctx, "evil.example"))
+
+		assert.Empty(t, recorded.entries)
+	})
+	t.Run("undoes edits it cannot record", func(t *testing.T) {
+		list := blockliststore.NewMemory()
+		require.NoError(t, list.Add(ctx, &blocklist.Entry{Domain: "evil.example", Reason: "spam"}))
+
+		s := audit.NewBlocklistStore(list, audit.NewLog(failingStore{}, zap.NewNop()))
+
+		require.ErrorIs(t, s.Add(ctx, &blocklist.Entry{Domain: "bad.example"}), audit.ErrNotRecorded)
+		require.ErrorIs(t, s.Add(ctx, &blocklist.Entry{Domain: "evil.example", Reason: "phishing"}), audit.ErrNotRecorded)
+		require.ErrorIs(t, s.Remove(ctx, "evil.example"), audit.ErrNotRecorded)
+
+		entries, err := list.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "evil.example", entries[0].Domain)
+		assert.Equal(t, "spam", entries[0].Reason)
+	})
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// Repository wraps a shortener.Repository, recording every create, update and delete in the audit log.
+// A change the log cannot record is undone, and fails with an error wrapping ErrNotRecorded.
+type Repository struct {
+	shortener.Repository
+
+	log *Log
+}
+
+// NewRepository creates a new auditing repository decorator.
+func NewRepository(repo shortener.Repository, log *Log) *Repository {
+	return &Repository{Repository: repo, log: log}
+}
+
+// Save stores a short URL and records its creation.
+func (r *Repository) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
+	if err := r.Repository.Save(ctx, shortURL); err != nil {
+		return err
+	}
+
+	return r.log.recordOrUndo(ctx, &Entry{
+		Action:      ActionLinkCreated,
+		Target:      string(shortURL.Code),
+		WorkspaceID: shortURL.WorkspaceID,
+		After:       LinkSnapshot(shortURL),
+	}, func(ctx context.Context) error {
+		return r.Repository.Delete(ctx, shortURL.Code)
+	})
+}
+
+// SaveOrGet stores a short URL unless an equivalent one exists, and records
+// its creation if it was stored.
+func (r *Repository) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	stored, created, err := r.Repository.SaveOrGet(ctx, shortURL)
+	if err != nil || !created {
+		return stored, created, err
+	}
+
+	err = r.log.recordOrUndo(ctx, &Entry{
+		Action:      ActionLinkCreated,
+		Target:      string(stored.Code),
+		WorkspaceID: stored.WorkspaceID,
+		After:       LinkSnapshot(stored),
+	}, func(ctx context.Context) error {
+		return r.Repository.Delete(ctx, stored.Code)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return stored, true, nil
+}
+
+// Update replaces a short URL and records the change. Takedowns and their
+// reversal are recorded as ActionLinkDisabled and ActionLinkEnabled, and
+// deletions and restores as ActionLinkDeleted and ActionLinkRestored. The link
+// is recorded, and restored if the log fails, as last stored, not as cached.
+func (r *Repository) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	before, err := r.GetByCode(shortener.ContextWithLatestReads(ctx), shortURL.Code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Repository.Update(ctx, shortURL); err != nil {
+		return err
+	}
+
+	action := ActionLinkUpdated
+
+	switch {
+	case before.DeletedAt == nil && shortURL.DeletedAt != nil:
+		action = ActionLinkDeleted
+	case before.DeletedAt != nil && shortURL.DeletedAt == nil:
+		action = ActionLinkRestored
+	case before.DisabledAt == nil && shortURL.DisabledAt != nil:
+		action = ActionLinkDisabled
+	case before.DisabledAt != nil && shortURL.DisabledAt == nil:
+		action = ActionLinkEnabled
+	}
+
+	return r.log.recordOrUndo(ctx, &Entry{
+		Action:      action,
+		Target:      string(shortURL.Code),
+		WorkspaceID: before.WorkspaceID,
+		Before:      LinkSnapshot(before),
+		After:       LinkSnapshot(shortURL),
+	}, func(ctx context.Context) error {
+		return r.Repository.Update(ctx, before)
+	})
+}
+
+// Delete removes a short URL for good and records its purge.
+func (r *Repository) Delete(ctx context.Context, code shortener.Code) error {
+	before, err := r.GetByCode(shortener.ContextWithLatestReads(ctx), code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Repository.Delete(ctx, code); err != nil {
+		return err
+	}
+
+	return r.log.recordOrUndo(ctx, &Entry{
+		Action:      ActionLinkPurged,
+		Target:      string(code),
+		WorkspaceID: before.WorkspaceID,
+		Before:      LinkSnapshot(before),
+	}, func(ctx context.Context) error {
+		return r.Repository.Save(ctx, before)
+	})
+}
+
+// Compile-time check.
+var _ shortener.Repository = (*Repository)(nil)
@@ -0,0 +1,213 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+	auditstore "github.com/serroba/web-demo-go/internal/audit/store"
+	"github.com/serroba/web-demo-go/internal/cache"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type failingWriteRepository struct {
+	shortener.Repository
+}
+
+func (failingWriteRepository) Save(_ context.Context, _ *shortener.ShortURL) error {
+	return assert.AnError
+}
+
+func (failingWriteRepository) Update(_ context.Context, _ *shortener.ShortURL) error {
+	return assert.AnError
+}
+
+func (failingWriteRepository) Delete(_ context.Context, _ shortener.Code) error {
+	return assert.AnError
+}
+
+func TestRepository(t *testing.T) {
+	ctx := requestContext()
+
+	setup := func(inner shortener.Repository) (*audit.Repository, *audit.Log) {
+		log := audit.NewLog(auditstore.NewMemory(), zap.NewNop())
+
+		return audit.NewRepository(inner, log), log
+	}
+
+	actions := func(t *testing.T, log *audit.Log, code string) []audit.Action {
+		t.Helper()
+
+		history, err := log.LinkHistory(context.Background(), "team-a", code, 10)
+		require.NoError(t, err)
+
+		var actions []audit.Action
+		for _, e := range history {
+			actions = append(actions, e.Action)
+		}
+
+		return actions
+	}
+
+	t.Run("records the life of a link", func(t *testing.T) {
+		repo, log := setup(store.NewMemoryStore())
+		link := &shortener.ShortURL{Code: "abc", OriginalURL: "https://example.com", WorkspaceID: "team-a"}
+
+		require.NoError(t, repo.Save(ctx, link))
+
+		updated := *link
+		updated.OriginalURL = "https://example.org"
+		require.NoError(t, repo.Update(ctx, &updated))
+
+		// Stores may hand out shared pointers, so each change works on a copy
+		disabled := updated
+		disabledAt := time.Now()
+		disabled.DisabledAt = &disabledAt
+		require.NoError(t, repo.Update(ctx, &disabled))
+
+		enabled := disabled
+		enabled.DisabledAt = nil
+		require.NoError(t, repo.Update(ctx, &enabled))
+
+		deleted := enabled
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+		require.NoError(t, repo.Update(ctx, &deleted))
+
+		restored := deleted
+		restored.DeletedAt = nil
+		require.NoError(t, repo.Update(ctx, &restored))
+
+		deletedAgain := restored
+		deletedAgain.DeletedAt = &deletedAt
+		require.NoError(t, repo.Update(ctx, &deletedAgain))
+		require.NoError(t, repo.Delete(ctx, "abc"))
+
+		assert.Equal(t, []audit.Action{
+			audit.ActionLinkPurged,
+			audit.ActionLinkDeleted,
+			audit.ActionLinkRestored,
+			audit.ActionLinkDeleted,
+			audit.ActionLinkEnabled,
+			audit.ActionLinkDisabled,
+			audit.ActionLinkUpdated,
+			audit.ActionLinkCreated,
+		}, actions(t, log, "abc"))
+
+		history, err := log.LinkHistory(context.Background(), "team-a", "abc", 10)
+		require.NoError(t, err)
+
+		change := history[6]
+		assert.Equal(t, "team-a", change.WorkspaceID)
+		assert.Equal(t, "https://example.com", change.Before["originalUrl"])
+		assert.Equal(t, "https://example.org", change.After["originalUrl"])
+		assert.Nil(t, history[0].After)
+		assert.Contains(t, history[1].After, "deletedAt")
+		assert.Nil(t, history[7].Before)
+	})
+
+	t.Run("records creation only for links it stored", func(t *testing.T) {
+		repo, log := setup(store.NewMemoryStore())
+		hashLink := func(code string) *shortener.ShortURL {
+			return &shortener.ShortURL{
+				Code:        shortener.Code(code),
+				URLHash:     "somehash",
+				Strategy:    shortener.StrategyHash,
+				WorkspaceID: "team-a",
+			}
+		}
+
+		_, _, err := repo.SaveOrGet(ctx, hashLink("abc"))
+		require.NoError(t, err)
+
+		_, created, err := repo.SaveOrGet(ctx, hashLink("def"))
+		require.NoError(t, err)
+		assert.False(t, created)
+
+		assert.Equal(t, []audit.Action{audit.ActionLinkCreated}, actions(t, log, "abc"))
+		assert.Empty(t, actions(t, log, "def"))
+	})
+
+	t.Run("records nothing for failed changes", func(t *testing.T) {
+		inner := store.NewMemoryStore()
+		require.NoError(t, inner.Save(ctx, &shortener.ShortURL{Code: "abc", OriginalURL: "https://example.com"}))
+
+		repo, log := setup(failingWriteRepository{inner})
+
+		require.Error(t, repo.Save(ctx, &shortener.ShortURL{Code: "def"}))
+		require.Error(t, repo.Update(ctx, &shortener.ShortURL{Code: "abc"}))
+		require.Error(t, repo.Delete(ctx, "abc"))
+
+		assert.Empty(t, actions(t, log, "abc"))
+		assert.Empty(t, actions(t, log, "def"))
+	})
+
+	t.Run("undoes changes it cannot record", func(t *testing.T) {
+		inner := store.NewMemoryStore()
+		link := &shortener.ShortURL{Code: "abc", OriginalURL: "https://example.com", WorkspaceID: "team-a"}
+		require.NoError(t, inner.Save(ctx, link))
+
+		repo := audit.NewRepository(inner, audit.NewLog(failingStore{}, zap.NewNop()))
+
+		require.ErrorIs(t, repo.Save(ctx, &shortener.ShortURL{Code: "def"}), audit.ErrNotRecorded)
+
+		_, err := inner.GetByCode(ctx, "def")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		_, _, err = repo.SaveOrGet(ctx, &shortener.ShortURL{Code: "ghi", URLHash: "somehash"})
+		require.ErrorIs(t, err, audit.ErrNotRecorded)
+
+		_, err = inner.GetByCode(ctx, "ghi")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		updated := *link
+		updated.OriginalURL = "https://example.org"
+		require.ErrorIs(t, repo.Update(ctx, &updated), audit.ErrNotRecorded)
+
+		found, err := inner.GetByCode(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", found.OriginalURL)
+
+		require.ErrorIs(t, repo.Delete(ctx, "abc"), audit.ErrNotRecorded)
+
+		found, err = inner.GetByCode(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", found.OriginalURL)
+	})
+
+	t.Run("records links as stored, not as cached", func(t *testing.T) {
+		inner := store.NewMemoryStore()
+		link := func(title string) *shortener.ShortURL {
+			return &shortener.ShortURL{Code: "abc", Metadata: shortener.Metadata{Title: title}, WorkspaceID: "team-a"}
+		}
+
+		require.NoError(t, inner.Save(ctx, link("cached")))
+
+		cached := store.NewCachedRepository(inner, cache.New(10), store.CachedConfig{})
+		_, err := cached.GetByCode(ctx, "abc")
+		require.NoError(t, err)
+
+		// Another server changes the link, leaving this one's cache stale
+		require.NoError(t, inner.Update(ctx, link("stored")))
+
+		repo, log := setup(cached)
+		require.NoError(t, repo.Update(ctx, link("new")))
+
+		history, err := log.LinkHistory(context.Background(), "team-a", "abc", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "stored", history[0].Before["title"])
+	})
+
+	t.Run("returns ErrNotFound for unknown links", func(t *testing.T) {
+		repo, _ := setup(store.NewMemoryStore())
+
+		require.ErrorIs(t, repo.Update(ctx, &shortener.ShortURL{Code: "missing"}), shortener.ErrNotFound)
+		require.ErrorIs(t, repo.Delete(ctx, "missing"), shortener.ErrNotFound)
+	})
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+)
+
+// File is an implementation of audit.Store appending entries to a local file,
+// one JSON line each, for deployments storing links in files. Entries are
+// served from memory, loaded from the file when it is opened.
+type File struct {
+	*Memory
+
+	file *os.File
+}
+
+// NewFile opens the audit file at path, creating it if needed, and loads its
+// entries. A last entry torn by a crash is dropped.
+func NewFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Memory: NewMemory(), file: file}
+
+	valid, err := f.load()
+	if err == nil {
+		// A torn entry is dropped, so the next one starts on its own line
+		err = file.Truncate(valid)
+	}
+
+	if err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// Append writes an entry to the file, and syncs it, before serving it.
+func (f *File) Append(_ context.Context, entry *audit.Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := *entry
+	stored.ID = int64(len(f.entries) + 1)
+
+	line, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+
+	f.entries = append(f.entries, &stored)
+	entry.ID = stored.ID
+
+	return nil
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+// load reads the entries of the file, and returns the size of those complete.
+func (f *File) load() (int64, error) {
+	reader := bufio.NewReader(f.file)
+
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Whatever follows the last newline was torn by a crash
+			return valid, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		var entry audit.Entry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil {
+			return 0, fmt.Errorf("entry at byte %d: %w", valid, err)
+		}
+
+		f.entries = append(f.entries, &entry)
+		valid += int64(len(line))
+	}
+}
+
+// Compile-time check.
+var _ audit.Store = (*File)(nil)
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+	"github.com/serroba/web-demo-go/internal/audit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("keeps entries across reopens", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+		s, err := store.NewFile(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Append(ctx, &audit.Entry{Action: audit.ActionLinkCreated, Target: "abc"}))
+		require.NoError(t, s.Append(ctx, &audit.Entry{
+			Action: audit.ActionLinkUpdated,
+			Target: "abc",
+			After:  map[string]any{"url": "https://example.com"},
+		}))
+		require.NoError(t, s.Close())
+
+		reopened, err := store.NewFile(path)
+		require.NoError(t, err)
+
+		defer func() { _ = reopened.Close() }()
+
+		require.NoError(t, reopened.Append(ctx, &audit.Entry{Action: audit.ActionLinkDeleted, Target: "abc"}))
+
+		history, err := reopened.LinkHistory(ctx, "", "abc", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, int64(3), history[0].ID)
+		assert.Equal(t, audit.ActionLinkUpdated, history[1].Action)
+		assert.Equal(t, map[string]any{"url": "https://example.com"}, history[1].After)
+	})
+
+	t.Run("drops an entry torn by a crash", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+		s, err := store.NewFile(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Append(ctx, &audit.Entry{Action: audit.ActionLinkCreated, Target: "abc"}))
+		require.NoError(t, s.Close())
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"Action":"link.upd`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		reopened, err := store.NewFile(path)
+		require.NoError(t, err)
+		require.NoError(t, reopened.Append(ctx, &audit.Entry{Action: audit.ActionLinkDeleted, Target: "abc"}))
+		require.NoError(t, reopened.Close())
+
+		reopened, err = store.NewFile(path)
+		require.NoError(t, err)
+
+		defer func() { _ = reopened.Close() }()
+
+		history, err := reopened.LinkHistory(ctx, "", "abc", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, audit.ActionLinkDeleted, history[0].Action)
+	})
+}
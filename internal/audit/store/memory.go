@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+)
+
+// Memory is an in-memory implementation of audit.Store.
+type Memory struct {
+	mu      sync.RWMutex
+	entries []*audit.Entry
+}
+
+// NewMemory creates a new in-memory audit store.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Append(_ context.Context, entry *audit.Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *entry
+	stored.ID = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, &stored)
+	entry.ID = stored.ID
+
+	return nil
+}
+
+func (m *Memory) LinkHistory(_ context.Context, workspaceID, code string, limit int) ([]*audit.Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var history []*audit.Entry
+
+	for i := len(m.entries) - 1; i >= 0 && len(history) < limit; i-- {
+		e := m.entries[i]
+		if e.Target == code && e.WorkspaceID == workspaceID && strings.HasPrefix(string(e.Action), "link.") {
+			history = append(history, e)
+		}
+	}
+
+	return history, nil
+}
+
+// Compile-time check.
+var _ audit.Store = (*Memory)(nil)
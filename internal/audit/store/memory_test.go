@@ -0,0 +1,64 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+	"github.com/serroba/web-demo-go/internal/audit/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns link history newest first", func(t *testing.T) {
+		s := store.NewMemory()
+
+		for _, e := range []*audit.Entry{
+			{Action: audit.ActionLinkCreated, Target: "abc", WorkspaceID: "team-a"},
+			{Action: audit.ActionLinkCreated, Target: "def", WorkspaceID: "team-a"},
+			{Action: audit.ActionDomainBlocked, Target: "abc"},
+			{Action: audit.ActionLinkUpdated, Target: "abc", WorkspaceID: "team-a"},
+		} {
+			require.NoError(t, s.Append(ctx, e))
+		}
+
+		history, err := s.LinkHistory(ctx, "team-a", "abc", 10)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, audit.ActionLinkUpdated, history[0].Action)
+		assert.Equal(t, int64(4), history[0].ID)
+		assert.Equal(t, audit.ActionLinkCreated, history[1].Action)
+	})
+
+	t.Run("limits history", func(t *testing.T) {
+		s := store.NewMemory()
+
+		for range 3 {
+			require.NoError(t, s.Append(ctx, &audit.Entry{Action: audit.ActionLinkUpdated, Target: "abc"}))
+		}
+
+		history, err := s.LinkHistory(ctx, "", "abc", 2)
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("limits history of the workspace only", func(t *testing.T) {
+		s := store.NewMemory()
+
+		require.NoError(t, s.Append(ctx, &audit.Entry{Action: audit.ActionLinkCreated, Target: "abc", WorkspaceID: "team-a"}))
+
+		for range 3 {
+			require.NoError(t, s.Append(ctx, &audit.Entry{
+				Action: audit.ActionLinkUpdated, Target: "abc", WorkspaceID: "team-b",
+			}))
+		}
+
+		history, err := s.LinkHistory(ctx, "team-a", "abc", 2)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, audit.ActionLinkCreated, history[0].Action)
+	})
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/serroba/web-demo-go/internal/audit"
+)
+
+// Postgres persists audit entries in the append-only link_audit table.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres creates a new PostgreSQL audit store.
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+func (p *Postgres) Append(ctx context.Context, entry *audit.Entry) error {
+	query := `
+		INSERT INTO link_audit (action, target, workspace_id, actor, key_id, request_id, before, after, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	return p.pool.QueryRow(ctx, query,
+		string(entry.Action),
+		entry.Target,
+		entry.WorkspaceID,
+		entry.Actor,
+		entry.KeyID,
+		entry.RequestID,
+		entry.Before,
+		entry.After,
+		entry.CreatedAt,
+	).Scan(&entry.ID)
+}
+
+func (p *Postgres) LinkHistory(ctx context.Context, workspaceID, code string, limit int) ([]*audit.Entry, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, action, target, workspace_id, actor, key_id, request_id, before, after, created_at
+		FROM link_audit
+		WHERE target = $1 AND workspace_id = $2 AND action LIKE 'link.%'
+		ORDER BY id DESC
+		LIMIT $3
+	`, code, workspaceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*audit.Entry
+
+	for rows.Next() {
+		var (
+			e      audit.Entry
+			action string
+		)
+
+		if err := rows.Scan(&e.ID, &action, &e.Target, &e.WorkspaceID, &e.Actor, &e.KeyID, &e.RequestID,
+			&e.Before, &e.After, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		e.Action = audit.Action(action)
+		history = append(history, &e)
+	}
+
+	return history, rows.Err()
+}
+
+// Compile-time check.
+var _ audit.Store = (*Postgres)(nil)
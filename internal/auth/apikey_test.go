@@ -0,0 +1,124 @@
+package auth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/auth/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingKeyStore struct {
+	err error
+}
+
+func (f failingKeyStore) Create(_ context.Context, _ *auth.APIKey) error {
+	return f.err
+}
+
+func (f failingKeyStore) GetByHash(_ context.Context, _ string) (*auth.APIKey, error) {
+	return nil, f.err
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	t.Run("generates unique prefixed keys", func(t *testing.T) {
+		first, err := auth.GenerateAPIKey()
+		require.NoError(t, err)
+
+		second, err := auth.GenerateAPIKey()
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(first, auth.APIKeyPrefix))
+		assert.NotEqual(t, first, second)
+	})
+}
+
+func TestHashAPIKey(t *testing.T) {
+	t.Run("is deterministic hex sha256", func(t *testing.T) {
+		hash := auth.HashAPIKey("sk_test")
+
+		assert.Len(t, hash, 64)
+		assert.Equal(t, hash, auth.HashAPIKey("sk_test"))
+		assert.NotEqual(t, hash, auth.HashAPIKey("sk_other"))
+	})
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	newAuthenticator := func(t *testing.T, revoked bool) (*auth.APIKeyAuthenticator, string) {
+		t.Helper()
+
+		secret, err := auth.GenerateAPIKey()
+		require.NoError(t, err)
+
+		key := &auth.APIKey{
+			ID:          "key-1",
+			OwnerID:     "alice",
+			WorkspaceID: "team-a",
+			Hash:        auth.HashAPIKey(secret),
+			Roles:       []auth.Role{auth.RoleViewer},
+			CreatedAt:   time.Now(),
+		}
+
+		if revoked {
+			now := time.Now()
+			key.RevokedAt = &now
+		}
+
+		keys := store.NewMemory()
+		require.NoError(t, keys.Create(context.Background(), key))
+
+		return auth.NewAPIKeyAuthenticator(keys), secret
+	}
+
+	t.Run("resolves valid key to principal", func(t *testing.T) {
+		authenticator, secret := newAuthenticator(t, false)
+
+		principal, err := authenticator.Authenticate(context.Background(), secret)
+
+		require.NoError(t, err)
+		assert.Equal(t, &auth.Principal{
+			OwnerID:     "alice",
+			KeyID:       "key-1",
+			WorkspaceID: "team-a",
+			Roles:       []auth.Role{auth.RoleViewer},
+		}, principal)
+	})
+
+	t.Run("rejects revoked key", func(t *testing.T) {
+		authenticator, secret := newAuthenticator(t, true)
+
+		_, err := authenticator.Authenticate(context.Background(), secret)
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects unknown key", func(t *testing.T) {
+		authenticator, _ := newAuthenticator(t, false)
+
+		_, err := authenticator.Authenticate(context.Background(), "sk_unknown")
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects credential without prefix", func(t *testing.T) {
+		authenticator, secret := newAuthenticator(t, false)
+
+		_, err := authenticator.Authenticate(context.Background(), strings.TrimPrefix(secret, auth.APIKeyPrefix))
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("returns store errors", func(t *testing.T) {
+		storeErr := assert.AnError
+		authenticator := auth.NewAPIKeyAuthenticator(failingKeyStore{err: storeErr})
+
+		_, err := authenticator.Authenticate(context.Background(), "sk_any")
+
+		require.ErrorIs(t, err, storeErr)
+		assert.NotErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
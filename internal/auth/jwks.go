@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxJWKSBytes caps the size of a JWKS document.
+const maxJWKSBytes = 1 << 20
+
+// jwksTimeout bounds a JWKS reload, which requests waiting for it share.
+const jwksTimeout = 10 * time.Second
+
+// ErrUnknownKey is returned when a JWKS has no key with the requested ID.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySource resolves the public key used to sign a token.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// jwk is a single JSON Web Key. Only the fields needed for RSA and EC public keys are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS decodes a JWKS document into public keys indexed by key ID.
+// Keys that are not signing keys or use unsupported types are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", k.Kid, err)
+		}
+
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey converts the JWK to a Go public key, or nil for unsupported key types.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent out of range")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, nil
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+// JWKSCache loads a JWKS from a file or URL and caches its keys.
+//
+// Keys are reloaded every refresh interval. A token signed with an unknown key ID
+// triggers an early reload, at most once a minute (or once per refresh interval if shorter),
+// so rotated keys are picked up without waiting for the next scheduled refresh.
+// Concurrent requests share a single reload, during which keys already loaded
+// keep being served.
+type JWKSCache struct {
+	load       func(ctx context.Context) ([]byte, error)
+	refresh    time.Duration
+	minRefresh time.Duration
+	now        func() time.Time
+	reloads    singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	checkedAt time.Time
+}
+
+// NewJWKSCache creates a JWKS cache for location, which is either an http(s) URL or a file path.
+func NewJWKSCache(location string, refresh time.Duration) *JWKSCache {
+	return &JWKSCache{
+		load:       jwksLoader(location),
+		refresh:    refresh,
+		minRefresh: min(refresh, time.Minute),
+		now:        time.Now,
+	}
+}
+
+// Key returns the public key with the given ID, reloading the JWKS when it is stale
+// or does not contain the key.
+func (c *JWKSCache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	stale := c.keys == nil || now.Sub(c.fetchedAt) >= c.refresh
+	key, found := c.lookup(kid)
+	due := stale || now.Sub(c.checkedAt) >= c.minRefresh
+	c.mu.Unlock()
+
+	if found && !stale {
+		return key, nil
+	}
+
+	if due {
+		if err := c.reload(ctx); err != nil {
+			c.mu.Lock()
+			loaded := c.keys != nil
+			c.mu.Unlock()
+
+			if !loaded {
+				return nil, err
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// lookup finds a key by ID. Tokens without a key ID match a set with a single key.
+// It must be called with mu held.
+func (c *JWKSCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+
+	key, ok := c.keys[kid]
+
+	return key, ok
+}
+
+// reload fetches the JWKS without holding mu, once for concurrent callers, and
+// swaps the keys in. On failure previously loaded keys are kept.
+func (c *JWKSCache) reload(ctx context.Context) error {
+	results := c.reloads.DoChan("", func() (any, error) {
+		now := c.now()
+
+		c.mu.Lock()
+		c.checkedAt = now
+		c.mu.Unlock()
+
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksTimeout)
+		defer cancel()
+
+		data, err := c.load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := ParseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.keys = keys
+		c.fetchedAt = now
+		c.mu.Unlock()
+
+		return keys, nil
+	})
+
+	select {
+	case result := <-results:
+		return result.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func jwksLoader(location string) func(ctx context.Context) ([]byte, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		client := &http.Client{Timeout: jwksTimeout}
+
+		return func(ctx context.Context) ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("unexpected status fetching jwks: %d", resp.StatusCode)
+			}
+
+			return io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+		}
+	}
+
+	path := strings.TrimPrefix(location, "file://")
+
+	return func(_ context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
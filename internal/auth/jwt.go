@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// JWTConfig controls how bearer JWTs are validated and mapped to a principal.
+type JWTConfig struct {
+	// Issuer is the required "iss" claim. Empty disables the check.
+	Issuer string
+	// Audience must appear in the "aud" claim. It is required, so that tokens
+	// issued for other services are not accepted: none are if it is empty.
+	Audience string
+	// OwnerClaim names the claim used as the owner ID. Defaults to "sub".
+	OwnerClaim string
+	// RolesClaim names the claim holding the caller's roles, as an array or a space-separated string.
+	// Defaults to "roles". Unknown role names are ignored.
+	RolesClaim string
+	// DefaultRoles are granted when a token carries no known roles.
+	DefaultRoles []Role
+	// Leeway is the clock skew tolerated when checking "exp" and "nbf".
+	Leeway time.Duration
+}
+
+// JWTAuthenticator authenticates requests carrying a JWT signed by a key in a JWKS.
+type JWTAuthenticator struct {
+	keys KeySource
+	cfg  JWTConfig
+	now  func() time.Time
+}
+
+// NewJWTAuthenticator creates a new JWT authenticator.
+func NewJWTAuthenticator(keys KeySource, cfg JWTConfig) *JWTAuthenticator {
+	if cfg.OwnerClaim == "" {
+		cfg.OwnerClaim = "sub"
+	}
+
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+
+	return &JWTAuthenticator{keys: keys, cfg: cfg, now: time.Now}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Authenticate validates a compact JWS token and maps its claims to a principal.
+// Any problem with the token itself is reported as ErrInvalidCredentials.
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	parts := strings.Split(credential, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalid("malformed header")
+	}
+
+	key, err := a.keys.Key(ctx, header.Kid)
+	if err != nil {
+		if errors.Is(err, ErrUnknownKey) {
+			return nil, invalid("unknown signing key")
+		}
+
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalid("malformed claims")
+	}
+
+	if err := a.validateClaims(claims); err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	owner, _ := claims[a.cfg.OwnerClaim].(string)
+	if owner == "" {
+		return nil, invalid("missing owner claim " + a.cfg.OwnerClaim)
+	}
+
+	return &Principal{
+		OwnerID:     owner,
+		WorkspaceID: DefaultWorkspace,
+		Roles:       a.roles(claims[a.cfg.RolesClaim]),
+	}, nil
+}
+
+// roles maps the roles claim to known roles, falling back to the configured defaults.
+func (a *JWTAuthenticator) roles(claim any) []Role {
+	var roles []Role
+
+	for _, name := range stringsClaim(claim) {
+		if role, ok := ParseRole(name); ok {
+			roles = append(roles, role)
+		}
+	}
+
+	if len(roles) == 0 {
+		return slices.Clone(a.cfg.DefaultRoles)
+	}
+
+	return roles
+}
+
+func (a *JWTAuthenticator) validateClaims(claims map[string]any) error {
+	now := a.now()
+
+	exp, ok := numericClaim(claims["exp"])
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+
+	if now.After(exp.Add(a.cfg.Leeway)) {
+		return errors.New("token expired")
+	}
+
+	if nbf, ok := numericClaim(claims["nbf"]); ok && now.Add(a.cfg.Leeway).Before(nbf) {
+		return errors.New("token not yet valid")
+	}
+
+	if a.cfg.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.cfg.Issuer {
+			return errors.New("unexpected issuer")
+		}
+	}
+
+	if a.cfg.Audience == "" || !slices.Contains(stringsClaim(claims["aud"]), a.cfg.Audience) {
+		return errors.New("unexpected audience")
+	}
+
+	return nil
+}
+
+// verifySignature checks a JWS signature. Only asymmetric algorithms are accepted,
+// and the key type must match the algorithm.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	var hash crypto.Hash
+
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return errors.New("algorithm does not match key type")
+		}
+
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return errors.New("algorithm does not match key type")
+		}
+
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func numericClaim(v any) (time.Time, bool) {
+	n, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(n), 0), true
+}
+
+// stringsClaim reads a claim that is either a string array or a space-separated string.
+func stringsClaim(v any) []string {
+	switch c := v.(type) {
+	case string:
+		return strings.Fields(c)
+	case []any:
+		values := make([]string, 0, len(c))
+
+		for _, item := range c {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+func invalid(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCredentials, reason)
+}
@@ -0,0 +1,471 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSigner signs tokens with a locally generated key and publishes it as a JWK.
+type testSigner struct {
+	kid string
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newRSASigner(t *testing.T, kid string) *testSigner {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return &testSigner{kid: kid, rsa: key}
+}
+
+func newECSigner(t *testing.T, kid string) *testSigner {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return &testSigner{kid: kid, ec: key}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s *testSigner) jwk() map[string]string {
+	if s.rsa != nil {
+		return map[string]string{
+			"kty": "RSA",
+			"kid": s.kid,
+			"use": "sig",
+			"n":   b64(s.rsa.N.Bytes()),
+			"e":   b64(big.NewInt(int64(s.rsa.E)).Bytes()),
+		}
+	}
+
+	return map[string]string{
+		"kty": "EC",
+		"kid": s.kid,
+		"crv": "P-256",
+		"x":   b64(s.ec.X.FillBytes(make([]byte, 32))),
+		"y":   b64(s.ec.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func (s *testSigner) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	alg := "RS256"
+	if s.ec != nil {
+		alg = "ES256"
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": s.kid, "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+
+	if s.rsa != nil {
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	} else {
+		r, ss, err := ecdsa.Sign(rand.Reader, s.ec, digest[:])
+		require.NoError(t, err)
+
+		sig = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+	}
+
+	return input + "." + b64(sig)
+}
+
+func jwksDocument(t *testing.T, signers ...*testSigner) []byte {
+	t.Helper()
+
+	keys := make([]map[string]string, 0, len(signers))
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+
+	doc, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+
+	return doc
+}
+
+func writeJWKS(t *testing.T, signers ...*testSigner) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, signers...), 0o600))
+
+	return path
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "alice",
+		"iss":   "https://sso.example.com",
+		"aud":   []string{"url-shortener"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"creator", "admin"},
+	}
+}
+
+func newJWTAuthenticator(path string) *auth.JWTAuthenticator {
+	return auth.NewJWTAuthenticator(auth.NewJWKSCache(path, time.Hour), auth.JWTConfig{
+		Issuer:   "https://sso.example.com",
+		Audience: "url-shortener",
+	})
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	signer := newRSASigner(t, "key-1")
+	authenticator := newJWTAuthenticator(writeJWKS(t, signer))
+
+	t.Run("maps claims to principal", func(t *testing.T) {
+		principal, err := authenticator.Authenticate(context.Background(), signer.sign(t, validClaims()))
+
+		require.NoError(t, err)
+		assert.Equal(t, "alice", principal.OwnerID)
+		assert.Equal(t, auth.DefaultWorkspace, principal.WorkspaceID)
+		assert.Equal(t, []auth.Role{auth.RoleCreator, auth.RoleAdmin}, principal.Roles)
+		assert.True(t, principal.HasRole(auth.RoleAdmin))
+		assert.False(t, principal.HasRole(auth.RoleViewer))
+	})
+
+	t.Run("accepts ES256 tokens and space-separated roles", func(t *testing.T) {
+		ecSigner := newECSigner(t, "ec-1")
+		ecAuthenticator := newJWTAuthenticator(writeJWKS(t, ecSigner))
+
+		claims := validClaims()
+		claims["roles"] = "viewer creator"
+		claims["aud"] = "url-shortener"
+
+		principal, err := ecAuthenticator.Authenticate(context.Background(), ecSigner.sign(t, claims))
+
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleViewer, auth.RoleCreator}, principal.Roles)
+	})
+
+	t.Run("uses configured claims", func(t *testing.T) {
+		custom := auth.NewJWTAuthenticator(auth.NewJWKSCache(writeJWKS(t, signer), time.Hour), auth.JWTConfig{
+			Audience:   "url-shortener",
+			OwnerClaim: "email",
+			RolesClaim: "groups",
+		})
+
+		claims := validClaims()
+		claims["email"] = "alice@example.com"
+		claims["groups"] = []string{"admin"}
+
+		principal, err := custom.Authenticate(context.Background(), signer.sign(t, claims))
+
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.com", principal.OwnerID)
+		assert.Equal(t, []auth.Role{auth.RoleAdmin}, principal.Roles)
+	})
+
+	t.Run("grants default roles when none are known", func(t *testing.T) {
+		defaults := auth.NewJWTAuthenticator(auth.NewJWKSCache(writeJWKS(t, signer), time.Hour), auth.JWTConfig{
+			Audience:     "url-shortener",
+			DefaultRoles: []auth.Role{auth.RoleViewer},
+		})
+
+		claims := validClaims()
+		claims["roles"] = []string{"superuser"}
+
+		principal, err := defaults.Authenticate(context.Background(), signer.sign(t, claims))
+
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleViewer}, principal.Roles)
+	})
+
+	invalid := map[string]func(map[string]any){
+		"expired":          func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"missing exp":      func(c map[string]any) { delete(c, "exp") },
+		"not yet valid":    func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"wrong issuer":     func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"wrong audience":   func(c map[string]any) { c["aud"] = "other" },
+		"missing owner":    func(c map[string]any) { delete(c, "sub") },
+		"non-string owner": func(c map[string]any) { c["sub"] = 42 },
+	}
+
+	for name, mutate := range invalid {
+		t.Run("rejects "+name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+
+			_, err := authenticator.Authenticate(context.Background(), signer.sign(t, claims))
+
+			require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("rejects every token without an audience configured", func(t *testing.T) {
+		anyAudience := auth.NewJWTAuthenticator(auth.NewJWKSCache(writeJWKS(t, signer), time.Hour), auth.JWTConfig{})
+
+		_, err := anyAudience.Authenticate(context.Background(), signer.sign(t, validClaims()))
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects token signed by another key", func(t *testing.T) {
+		other := newRSASigner(t, "key-1")
+
+		_, err := authenticator.Authenticate(context.Background(), other.sign(t, validClaims()))
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects tampered claims", func(t *testing.T) {
+		token := signer.sign(t, validClaims())
+		parts := strings.Split(token, ".")
+
+		claims := validClaims()
+		claims["sub"] = "mallory"
+		payload, _ := json.Marshal(claims)
+
+		_, err := authenticator.Authenticate(context.Background(), parts[0]+"."+b64(payload)+"."+parts[2])
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects unsigned tokens", func(t *testing.T) {
+		header := b64([]byte(`{"alg":"none","kid":"key-1"}`))
+		payload, _ := json.Marshal(validClaims())
+
+		_, err := authenticator.Authenticate(context.Background(), header+"."+b64(payload)+".")
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects algorithm that does not match key", func(t *testing.T) {
+		header := b64([]byte(`{"alg":"ES256","kid":"key-1"}`))
+		payload, _ := json.Marshal(validClaims())
+
+		_, err := authenticator.Authenticate(context.Background(), header+"."+b64(payload)+"."+b64(make([]byte, 64)))
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+
+	t.Run("rejects malformed tokens", func(t *testing.T) {
+		for _, token := range []string{"sk_apikey", "a.b", "!!.e30.sig", "e30.e30.!!"} {
+			_, err := authenticator.Authenticate(context.Background(), token)
+
+			require.ErrorIs(t, err, auth.ErrInvalidCredentials, token)
+		}
+	})
+
+	t.Run("rejects unknown key id", func(t *testing.T) {
+		_, err := authenticator.Authenticate(context.Background(), newRSASigner(t, "key-2").sign(t, validClaims()))
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
+
+func TestJWKSCache(t *testing.T) {
+	t.Run("picks up rotated keys from url", func(t *testing.T) {
+		oldKey := newRSASigner(t, "old")
+		newKey := newRSASigner(t, "new")
+
+		var (
+			doc   atomic.Value
+			loads atomic.Int32
+		)
+
+		doc.Store(jwksDocument(t, oldKey))
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			loads.Add(1)
+			_, _ = w.Write(doc.Load().([]byte))
+		}))
+		defer server.Close()
+
+		cache := auth.NewJWKSCache(server.URL, 100*time.Millisecond)
+
+		_, err := cache.Key(context.Background(), "old")
+		require.NoError(t, err)
+
+		_, err = cache.Key(context.Background(), "old")
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), loads.Load(), "cached keys should not be reloaded")
+
+		doc.Store(jwksDocument(t, oldKey, newKey))
+
+		// Unknown key IDs do not reload more often than the minimum interval
+		_, err = cache.Key(context.Background(), "new")
+		require.ErrorIs(t, err, auth.ErrUnknownKey)
+		assert.Equal(t, int32(1), loads.Load())
+
+		time.Sleep(150 * time.Millisecond)
+
+		key, err := cache.Key(context.Background(), "new")
+		require.NoError(t, err)
+		assert.Equal(t, &newKey.rsa.PublicKey, key)
+		assert.Equal(t, int32(2), loads.Load())
+	})
+
+	t.Run("shares one reload between concurrent requests", func(t *testing.T) {
+		signer := newRSASigner(t, "key-1")
+		release := make(chan struct{})
+
+		var loads atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			loads.Add(1)
+			<-release
+			_, _ = w.Write(jwksDocument(t, signer))
+		}))
+		defer server.Close()
+
+		cache := auth.NewJWKSCache(server.URL, time.Hour)
+
+		var wg sync.WaitGroup
+
+		for range 5 {
+			wg.Go(func() {
+				_, err := cache.Key(context.Background(), "key-1")
+				assert.NoError(t, err)
+			})
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), loads.Load())
+	})
+
+	t.Run("keeps cached keys when reload fails", func(t *testing.T) {
+		signer := newRSASigner(t, "key-1")
+		path := writeJWKS(t, signer)
+		cache := auth.NewJWKSCache(path, 10*time.Millisecond)
+
+		_, err := cache.Key(context.Background(), "key-1")
+		require.NoError(t, err)
+
+		require.NoError(t, os.Remove(path))
+		time.Sleep(20 * time.Millisecond)
+
+		key, err := cache.Key(context.Background(), "key-1")
+		require.NoError(t, err)
+		assert.Equal(t, &signer.rsa.PublicKey, key)
+	})
+
+	t.Run("returns load error without cached keys", func(t *testing.T) {
+		cache := auth.NewJWKSCache(filepath.Join(t.TempDir(), "missing.json"), time.Hour)
+
+		_, err := cache.Key(context.Background(), "any")
+
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, auth.ErrUnknownKey)
+	})
+
+	t.Run("returns error for non-200 url", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		_, err := auth.NewJWKSCache(server.URL, time.Hour).Key(context.Background(), "any")
+
+		require.Error(t, err)
+	})
+
+	t.Run("matches single key when token has no kid", func(t *testing.T) {
+		signer := newRSASigner(t, "only")
+
+		key, err := auth.NewJWKSCache(writeJWKS(t, signer), time.Hour).Key(context.Background(), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, &signer.rsa.PublicKey, key)
+	})
+}
+
+func TestParseJWKS(t *testing.T) {
+	t.Run("skips encryption and unsupported keys", func(t *testing.T) {
+		keys, err := auth.ParseJWKS([]byte(`{"keys":[
+			{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},
+			{"kty":"oct","kid":"hmac","k":"c2VjcmV0"},
+			{"kty":"EC","kid":"p521","crv":"P-521","x":"AQ","y":"AQ"}
+		]}`))
+
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		for _, doc := range []string{
+			`not json`,
+			`{"keys":[{"kty":"RSA","kid":"a","n":"","e":"AQAB"}]}`,
+			`{"keys":[{"kty":"RSA","kid":"a","n":"AQAB","e":"!!"}]}`,
+			`{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+		} {
+			_, err := auth.ParseJWKS([]byte(doc))
+
+			require.Error(t, err, doc)
+		}
+	})
+}
+
+func TestChainAuthenticator(t *testing.T) {
+	signer := newRSASigner(t, "key-1")
+	jwtAuth := newJWTAuthenticator(writeJWKS(t, signer))
+
+	t.Run("falls through invalid credentials", func(t *testing.T) {
+		chain := auth.NewChainAuthenticator(failingKeyStoreAuthenticator(auth.ErrInvalidCredentials), jwtAuth)
+
+		principal, err := chain.Authenticate(context.Background(), signer.sign(t, validClaims()))
+
+		require.NoError(t, err)
+		assert.Equal(t, "alice", principal.OwnerID)
+	})
+
+	t.Run("stops on other errors", func(t *testing.T) {
+		chain := auth.NewChainAuthenticator(failingKeyStoreAuthenticator(assert.AnError), jwtAuth)
+
+		_, err := chain.Authenticate(context.Background(), "sk_key")
+
+		require.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("rejects when nothing matches", func(t *testing.T) {
+		chain := auth.NewChainAuthenticator(jwtAuth)
+
+		_, err := chain.Authenticate(context.Background(), "sk_key")
+
+		require.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	})
+}
+
+// failingKeyStoreAuthenticator returns an API key authenticator whose store fails with err.
+func failingKeyStoreAuthenticator(err error) auth.Authenticator {
+	return auth.NewAPIKeyAuthenticator(failingKeyStore{err: err})
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Principal identifies the authenticated caller of a request.
+type Principal struct {
+	// OwnerID is the identifier recorded as the owner of links the caller creates.
+	OwnerID string
+	// KeyID is the API key used to authenticate, if any.
+	KeyID string
+	// WorkspaceID is the workspace the caller acts within; empty means DefaultWorkspace.
+	WorkspaceID string
+	// Roles are the roles granted to the caller within the workspace.
+	Roles []Role
+}
+
+// HasRole reports whether the principal was granted role.
+func (p *Principal) HasRole(role Role) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal adds the authenticated principal to the context.
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal, or nil for anonymous requests.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalKey{}).(*Principal); ok {
+		return p
+	}
+
+	return nil
+}
+
+// OwnerIDFromContext returns the owner ID of the authenticated principal,
+// or an empty string for anonymous requests.
+func OwnerIDFromContext(ctx context.Context) string {
+	if p := PrincipalFromContext(ctx); p != nil {
+		return p.OwnerID
+	}
+
+	return ""
+}
+
+// Authenticator resolves a credential presented with a request to a principal.
+// It returns ErrInvalidCredentials when the credential is not valid.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (*Principal, error)
+}
+
+// ChainAuthenticator tries several authenticators in order.
+type ChainAuthenticator struct {
+	authenticators []Authenticator
+}
+
+// NewChainAuthenticator creates an authenticator that accepts a credential
+// if any of the given authenticators does.
+func NewChainAuthenticator(authenticators ...Authenticator) *ChainAuthenticator {
+	return &ChainAuthenticator{authenticators: authenticators}
+}
+
+// Authenticate returns the first principal resolved by the chain. ErrInvalidCredentials
+// moves on to the next authenticator; any other error stops the chain.
+func (c *ChainAuthenticator) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	for _, a := range c.authenticators {
+		principal, err := a.Authenticate(ctx, credential)
+		if err == nil {
+			return principal, nil
+		}
+
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return nil, err
+		}
+	}
+
+	return nil, ErrInvalidCredentials
+}
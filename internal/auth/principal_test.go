@@ -0,0 +1,24 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalContext(t *testing.T) {
+	t.Run("round-trips principal", func(t *testing.T) {
+		principal := &auth.Principal{OwnerID: "alice", KeyID: "key-1"}
+		ctx := auth.ContextWithPrincipal(context.Background(), principal)
+
+		assert.Equal(t, principal, auth.PrincipalFromContext(ctx))
+		assert.Equal(t, "alice", auth.OwnerIDFromContext(ctx))
+	})
+
+	t.Run("anonymous context has no principal", func(t *testing.T) {
+		assert.Nil(t, auth.PrincipalFromContext(context.Background()))
+		assert.Empty(t, auth.OwnerIDFromContext(context.Background()))
+	})
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"slices"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Role is a named set of permissions granted to a principal.
+type Role string
+
+const (
+	// RoleViewer can list and inspect the links of its workspace.
+	RoleViewer Role = "viewer"
+	// RoleCreator can also create links, and edit and delete its own.
+	RoleCreator Role = "creator"
+	// RoleAdmin can manage any link of its workspace. Admins of DefaultWorkspace
+	// can also take down links of any workspace and manage the blocklist.
+	RoleAdmin Role = "admin"
+)
+
+// Permission is an action that an operation requires.
+type Permission string
+
+const (
+	// PermissionReadLinks allows listing links and reading their details and stats.
+	PermissionReadLinks Permission = "links:read"
+	// PermissionWriteLinks allows creating, updating and deleting links.
+	PermissionWriteLinks Permission = "links:write"
+	// PermissionAdmin allows administrative operations on the workspace's links.
+	PermissionAdmin Permission = "admin"
+)
+
+// rolePermissions maps each role to the permissions it grants.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:  {PermissionReadLinks},
+	RoleCreator: {PermissionReadLinks, PermissionWriteLinks},
+	RoleAdmin:   {PermissionReadLinks, PermissionWriteLinks, PermissionAdmin},
+}
+
+// ParseRole validates a role name.
+func ParseRole(name string) (Role, bool) {
+	role := Role(name)
+	_, ok := rolePermissions[role]
+
+	return role, ok
+}
+
+// Can reports whether any of the principal's roles grants perm.
+// A nil principal has no permissions.
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MetadataKey is the key used to store access requirements in operation metadata.
+const MetadataKey = "access"
+
+// OperationAccess defines the permission an operation requires.
+// This can be attached to Huma operations via the Metadata field;
+// operations without it are public.
+type OperationAccess struct {
+	// Permission is required from authenticated callers.
+	Permission Permission
+
+	// AllowAnonymous lets unauthenticated requests through. Authenticated
+	// callers must still hold Permission.
+	AllowAnonymous bool
+
+	// Global marks operations that affect every workspace. Permission must then
+	// be held within DefaultWorkspace, so workspace admins cannot use them.
+	Global bool
+}
+
+// GetOperationAccess extracts the OperationAccess from operation metadata, if present.
+func GetOperationAccess(ctx huma.Context) *OperationAccess {
+	op := ctx.Operation()
+	if op == nil || op.Metadata == nil {
+		return nil
+	}
+
+	access, ok := op.Metadata[MetadataKey].(OperationAccess)
+	if !ok {
+		return nil
+	}
+
+	return &access
+}
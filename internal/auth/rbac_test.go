@@ -0,0 +1,48 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipal_Can(t *testing.T) {
+	tests := []struct {
+		role  auth.Role
+		read  bool
+		write bool
+		admin bool
+	}{
+		{auth.RoleViewer, true, false, false},
+		{auth.RoleCreator, true, true, false},
+		{auth.RoleAdmin, true, true, true},
+		{auth.Role("unknown"), false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			p := &auth.Principal{Roles: []auth.Role{tt.role}}
+
+			assert.Equal(t, tt.read, p.Can(auth.PermissionReadLinks))
+			assert.Equal(t, tt.write, p.Can(auth.PermissionWriteLinks))
+			assert.Equal(t, tt.admin, p.Can(auth.PermissionAdmin))
+		})
+	}
+
+	t.Run("nil principal has no permissions", func(t *testing.T) {
+		var p *auth.Principal
+
+		assert.False(t, p.Can(auth.PermissionReadLinks))
+		assert.False(t, p.HasRole(auth.RoleViewer))
+	})
+}
+
+func TestParseRole(t *testing.T) {
+	role, ok := auth.ParseRole("admin")
+	assert.True(t, ok)
+	assert.Equal(t, auth.RoleAdmin, role)
+
+	_, ok = auth.ParseRole("root")
+	assert.False(t, ok)
+}
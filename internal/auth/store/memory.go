@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+)
+
+// Memory is an in-memory implementation of auth.KeyStore.
+type Memory struct {
+	mu   sync.RWMutex
+	keys map[string]*auth.APIKey // hash -> key
+}
+
+// NewMemory creates a new in-memory key store.
+func NewMemory() *Memory {
+	return &Memory{keys: make(map[string]*auth.APIKey)}
+}
+
+func (m *Memory) Create(_ context.Context, key *auth.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key.Hash] = key
+
+	return nil
+}
+
+func (m *Memory) GetByHash(_ context.Context, hash string) (*auth.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[hash]
+	if !ok {
+		return nil, auth.ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// Compile-time check.
+var _ auth.KeyStore = (*Memory)(nil)
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/auth/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	t.Run("stores and retrieves by hash", func(t *testing.T) {
+		keys := store.NewMemory()
+		key := &auth.APIKey{ID: "key-1", OwnerID: "alice", Hash: "abc"}
+
+		require.NoError(t, keys.Create(context.Background(), key))
+
+		got, err := keys.GetByHash(context.Background(), "abc")
+
+		require.NoError(t, err)
+		assert.Equal(t, key, got)
+	})
+
+	t.Run("returns ErrKeyNotFound for unknown hash", func(t *testing.T) {
+		_, err := store.NewMemory().GetByHash(context.Background(), "missing")
+
+		require.ErrorIs(t, err, auth.ErrKeyNotFound)
+	})
+}
+
+func TestMemoryWorkspaces(t *testing.T) {
+	t.Run("contains the default workspace", func(t *testing.T) {
+		workspaces := store.NewMemoryWorkspaces()
+		member := &auth.Member{WorkspaceID: auth.DefaultWorkspace, UserID: "alice", Roles: []auth.Role{auth.RoleAdmin}}
+
+		require.NoError(t, workspaces.AddMember(context.Background(), member))
+
+		got, err := workspaces.GetMember(context.Background(), auth.DefaultWorkspace, "alice")
+
+		require.NoError(t, err)
+		assert.Equal(t, member, got)
+	})
+
+	t.Run("replaces roles of existing members", func(t *testing.T) {
+		workspaces := store.NewMemoryWorkspaces()
+		require.NoError(t, workspaces.CreateWorkspace(context.Background(), &auth.Workspace{ID: "team-a"}))
+
+		for _, role := range []auth.Role{auth.RoleViewer, auth.RoleCreator} {
+			require.NoError(t, workspaces.AddMember(context.Background(), &auth.Member{
+				WorkspaceID: "team-a",
+				UserID:      "alice",
+				Roles:       []auth.Role{role},
+			}))
+		}
+
+		got, err := workspaces.GetMember(context.Background(), "team-a", "alice")
+
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleCreator}, got.Roles)
+	})
+
+	t.Run("rejects members of unknown workspaces", func(t *testing.T) {
+		err := store.NewMemoryWorkspaces().AddMember(context.Background(), &auth.Member{WorkspaceID: "nope", UserID: "a"})
+
+		require.ErrorIs(t, err, auth.ErrWorkspaceNotFound)
+	})
+
+	t.Run("returns ErrNotMember for unknown members", func(t *testing.T) {
+		_, err := store.NewMemoryWorkspaces().GetMember(context.Background(), auth.DefaultWorkspace, "bob")
+
+		require.ErrorIs(t, err, auth.ErrNotMember)
+	})
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+)
+
+// memberKey identifies a membership.
+type memberKey struct {
+	workspaceID string
+	userID      string
+}
+
+// MemoryWorkspaces is an in-memory implementation of auth.WorkspaceStore.
+type MemoryWorkspaces struct {
+	mu         sync.RWMutex
+	workspaces map[string]*auth.Workspace
+	members    map[memberKey]*auth.Member
+}
+
+// NewMemoryWorkspaces creates a new in-memory workspace store containing the default workspace.
+func NewMemoryWorkspaces() *MemoryWorkspaces {
+	return &MemoryWorkspaces{
+		workspaces: map[string]*auth.Workspace{
+			auth.DefaultWorkspace: {ID: auth.DefaultWorkspace, Name: "Default"},
+		},
+		members: make(map[memberKey]*auth.Member),
+	}
+}
+
+func (m *MemoryWorkspaces) CreateWorkspace(_ context.Context, workspace *auth.Workspace) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.workspaces[workspace.ID] = workspace
+
+	return nil
+}
+
+func (m *MemoryWorkspaces) AddMember(_ context.Context, member *auth.Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workspaces[member.WorkspaceID]; !ok {
+		return auth.ErrWorkspaceNotFound
+	}
+
+	m.members[memberKey{workspaceID: member.WorkspaceID, userID: member.UserID}] = member
+
+	return nil
+}
+
+func (m *MemoryWorkspaces) GetMember(_ context.Context, workspaceID, userID string) (*auth.Member, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	member, ok := m.members[memberKey{workspaceID: workspaceID, userID: userID}]
+	if !ok {
+		return nil, auth.ErrNotMember
+	}
+
+	return member, nil
+}
+
+// Compile-time check.
+var _ auth.WorkspaceStore = (*MemoryWorkspaces)(nil)
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/serroba/web-demo-go/internal/auth"
+)
+
+// Postgres persists API keys in PostgreSQL.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres creates a new PostgreSQL key store.
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+func (p *Postgres) Create(ctx context.Context, key *auth.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, owner_id, workspace_id, name, key_hash, roles, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := p.pool.Exec(ctx, query,
+		key.ID, key.OwnerID, key.WorkspaceID, key.Name, key.Hash, roleNames(key.Roles), key.CreatedAt)
+
+	return err
+}
+
+func (p *Postgres) GetByHash(ctx context.Context, hash string) (*auth.APIKey, error) {
+	query := `
+		SELECT id, owner_id, workspace_id, name, key_hash, roles, created_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	var (
+		key   auth.APIKey
+		roles []string
+	)
+
+	err := p.pool.QueryRow(ctx, query, hash).Scan(
+		&key.ID,
+		&key.OwnerID,
+		&key.WorkspaceID,
+		&key.Name,
+		&key.Hash,
+		&roles,
+		&key.CreatedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, auth.ErrKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	key.Roles = parseRoles(roles)
+
+	return &key, nil
+}
+
+// roleNames converts roles to the TEXT[] representation stored in PostgreSQL.
+func roleNames(roles []auth.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+
+	return names
+}
+
+// parseRoles converts stored role names back to roles.
+func parseRoles(names []string) []auth.Role {
+	roles := make([]auth.Role, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, auth.Role(name))
+	}
+
+	return roles
+}
+
+// Compile-time check.
+var _ auth.KeyStore = (*Postgres)(nil)
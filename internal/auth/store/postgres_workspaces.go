@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/serroba/web-demo-go/internal/auth"
+)
+
+// foreignKeyViolation is the PostgreSQL error code for a missing referenced row.
+const foreignKeyViolation = "23503"
+
+// PostgresWorkspaces persists workspaces and their members in PostgreSQL.
+type PostgresWorkspaces struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresWorkspaces creates a new PostgreSQL workspace store.
+func NewPostgresWorkspaces(pool *pgxpool.Pool) *PostgresWorkspaces {
+	return &PostgresWorkspaces{pool: pool}
+}
+
+func (p *PostgresWorkspaces) CreateWorkspace(ctx context.Context, workspace *auth.Workspace) error {
+	query := `
+		INSERT INTO workspaces (id, name, created_at)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := p.pool.Exec(ctx, query, workspace.ID, workspace.Name, workspace.CreatedAt)
+
+	return err
+}
+
+func (p *PostgresWorkspaces) AddMember(ctx context.Context, member *auth.Member) error {
+	query := `
+		INSERT INTO workspace_members (workspace_id, user_id, roles, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET roles = EXCLUDED.roles
+	`
+
+	_, err := p.pool.Exec(ctx, query, member.WorkspaceID, member.UserID, roleNames(member.Roles), member.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return auth.ErrWorkspaceNotFound
+	}
+
+	return err
+}
+
+func (p *PostgresWorkspaces) GetMember(ctx context.Context, workspaceID, userID string) (*auth.Member, error) {
+	query := `
+		SELECT workspace_id, user_id, roles, created_at
+		FROM workspace_members
+		WHERE workspace_id = $1 AND user_id = $2
+	`
+
+	var (
+		member auth.Member
+		roles  []string
+	)
+
+	err := p.pool.QueryRow(ctx, query, workspaceID, userID).Scan(
+		&member.WorkspaceID,
+		&member.UserID,
+		&roles,
+		&member.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, auth.ErrNotMember
+		}
+
+		return nil, err
+	}
+
+	member.Roles = parseRoles(roles)
+
+	return &member, nil
+}
+
+// Compile-time check.
+var _ auth.WorkspaceStore = (*PostgresWorkspaces)(nil)
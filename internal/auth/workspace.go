@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultWorkspace is the workspace of anonymous callers, of SSO users that have
+// not selected another workspace, and of everything created before workspaces existed.
+const DefaultWorkspace = "default"
+
+var (
+	// ErrWorkspaceNotFound is returned when a workspace does not exist.
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrNotMember is returned when a principal cannot act within a workspace.
+	ErrNotMember = errors.New("not a member of the workspace")
+)
+
+// Workspace is the tenant boundary: links, API keys and analytics belong to exactly one workspace.
+type Workspace struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// Member grants a user roles within a workspace.
+type Member struct {
+	WorkspaceID string
+	UserID      string
+	Roles       []Role
+	CreatedAt   time.Time
+}
+
+// WorkspaceStore persists workspaces and their members.
+type WorkspaceStore interface {
+	CreateWorkspace(ctx context.Context, workspace *Workspace) error
+	// AddMember adds a user to a workspace, replacing the roles of an existing member.
+	// It returns ErrWorkspaceNotFound if the workspace does not exist.
+	AddMember(ctx context.Context, member *Member) error
+	// GetMember returns ErrNotMember if the user does not belong to the workspace.
+	GetMember(ctx context.Context, workspaceID, userID string) (*Member, error)
+}
+
+// WorkspaceIDFromContext returns the workspace the request acts within.
+// Anonymous requests act within DefaultWorkspace.
+func WorkspaceIDFromContext(ctx context.Context) string {
+	return workspaceOf(PrincipalFromContext(ctx))
+}
+
+// SelectWorkspace returns the principal acting within workspaceID.
+//
+// An empty workspaceID, or the principal's current workspace, leaves it unchanged.
+// API keys are bound to the workspace they were created in. Other principals must
+// be members of the workspace, and act with the roles granted by the membership.
+func SelectWorkspace(
+	ctx context.Context, workspaces WorkspaceStore, p *Principal, workspaceID string,
+) (*Principal, error) {
+	if workspaceID == "" || workspaceID == workspaceOf(p) {
+		return p, nil
+	}
+
+	if p.KeyID != "" {
+		return nil, ErrNotMember
+	}
+
+	member, err := workspaces.GetMember(ctx, workspaceID, p.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{OwnerID: p.OwnerID, WorkspaceID: workspaceID, Roles: member.Roles}, nil
+}
+
+func workspaceOf(p *Principal) string {
+	if p == nil || p.WorkspaceID == "" {
+		return DefaultWorkspace
+	}
+
+	return p.WorkspaceID
+}
@@ -0,0 +1,85 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/auth/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWorkspaceStore struct {
+	auth.WorkspaceStore
+}
+
+func (failingWorkspaceStore) GetMember(_ context.Context, _, _ string) (*auth.Member, error) {
+	return nil, errors.New("db down")
+}
+
+func TestWorkspaceIDFromContext(t *testing.T) {
+	t.Run("anonymous requests act within the default workspace", func(t *testing.T) {
+		assert.Equal(t, auth.DefaultWorkspace, auth.WorkspaceIDFromContext(context.Background()))
+	})
+
+	t.Run("returns the principal's workspace", func(t *testing.T) {
+		ctx := auth.ContextWithPrincipal(context.Background(), &auth.Principal{WorkspaceID: "team-a"})
+
+		assert.Equal(t, "team-a", auth.WorkspaceIDFromContext(ctx))
+	})
+}
+
+func TestSelectWorkspace(t *testing.T) {
+	workspaces := store.NewMemoryWorkspaces()
+	require.NoError(t, workspaces.CreateWorkspace(context.Background(), &auth.Workspace{ID: "team-a"}))
+	require.NoError(t, workspaces.AddMember(context.Background(), &auth.Member{
+		WorkspaceID: "team-a",
+		UserID:      "alice",
+		Roles:       []auth.Role{auth.RoleViewer},
+	}))
+
+	user := &auth.Principal{OwnerID: "alice", WorkspaceID: auth.DefaultWorkspace, Roles: []auth.Role{auth.RoleAdmin}}
+
+	t.Run("keeps the current workspace", func(t *testing.T) {
+		for _, id := range []string{"", auth.DefaultWorkspace} {
+			got, err := auth.SelectWorkspace(context.Background(), workspaces, user, id)
+
+			require.NoError(t, err)
+			assert.Same(t, user, got)
+		}
+	})
+
+	t.Run("members act with their workspace roles", func(t *testing.T) {
+		got, err := auth.SelectWorkspace(context.Background(), workspaces, user, "team-a")
+
+		require.NoError(t, err)
+		assert.Equal(t, "team-a", got.WorkspaceID)
+		assert.Equal(t, []auth.Role{auth.RoleViewer}, got.Roles)
+		assert.Equal(t, []auth.Role{auth.RoleAdmin}, user.Roles, "original principal is unchanged")
+	})
+
+	t.Run("rejects non-members", func(t *testing.T) {
+		bob := &auth.Principal{OwnerID: "bob"}
+
+		_, err := auth.SelectWorkspace(context.Background(), workspaces, bob, "team-a")
+
+		require.ErrorIs(t, err, auth.ErrNotMember)
+	})
+
+	t.Run("api keys are bound to their workspace", func(t *testing.T) {
+		key := &auth.Principal{OwnerID: "alice", KeyID: "key-1", WorkspaceID: auth.DefaultWorkspace}
+
+		_, err := auth.SelectWorkspace(context.Background(), workspaces, key, "team-a")
+
+		require.ErrorIs(t, err, auth.ErrNotMember)
+	})
+
+	t.Run("propagates store errors", func(t *testing.T) {
+		_, err := auth.SelectWorkspace(context.Background(), failingWorkspaceStore{}, user, "team-a")
+
+		require.Error(t, err)
+		require.NotErrorIs(t, err, auth.ErrNotMember)
+	})
+}
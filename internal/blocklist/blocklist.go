@@ -0,0 +1,117 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+var (
+	// ErrNotFound is returned when removing a domain that is not blocked.
+	ErrNotFound = errors.New("domain not blocked")
+	// ErrInvalidDomain is returned for values that are not bare host names.
+	ErrInvalidDomain = errors.New("invalid domain")
+)
+
+// Entry is a blocked destination domain. Blocking a domain also blocks its subdomains.
+type Entry struct {
+	Domain    string
+	Reason    string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Store persists blocked domains.
+type Store interface {
+	// Add blocks a domain, replacing any existing entry for it.
+	Add(ctx context.Context, entry *Entry) error
+	// Remove unblocks a domain. It returns ErrNotFound if the domain is not blocked.
+	Remove(ctx context.Context, domain string) error
+	// List returns all blocked domains ordered by domain.
+	List(ctx context.Context) ([]*Entry, error)
+	// AnyBlocked reports whether any of the given domains is blocked.
+	AnyBlocked(ctx context.Context, domains []string) (bool, error)
+}
+
+// Blocklist checks link destinations against blocked domains.
+type Blocklist struct {
+	store Store
+}
+
+// New creates a new blocklist backed by store.
+func New(store Store) *Blocklist {
+	return &Blocklist{store: store}
+}
+
+// IsBlocked reports whether the destination host of rawURL, or any of its parent domains, is blocked.
+func (b *Blocklist) IsBlocked(ctx context.Context, rawURL string) (bool, error) {
+	host := shortener.DestinationDomain(rawURL)
+	if host == "" {
+		return false, nil
+	}
+
+	return b.store.AnyBlocked(ctx, parentDomains(host))
+}
+
+// Add blocks a domain after normalizing it and returns the stored entry.
+func (b *Blocklist) Add(ctx context.Context, entry *Entry) (*Entry, error) {
+	domain, err := NormalizeDomain(entry.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := *entry
+	normalized.Domain = domain
+
+	if err := b.store.Add(ctx, &normalized); err != nil {
+		return nil, err
+	}
+
+	return &normalized, nil
+}
+
+// Remove unblocks a domain.
+func (b *Blocklist) Remove(ctx context.Context, domain string) error {
+	normalized, err := NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	return b.store.Remove(ctx, normalized)
+}
+
+// List returns all blocked domains.
+func (b *Blocklist) List(ctx context.Context) ([]*Entry, error) {
+	return b.store.List(ctx)
+}
+
+// NormalizeDomain lowercases a host name and strips a trailing dot.
+// Values containing a scheme, path, port or whitespace are rejected.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+
+	if domain == "" || strings.ContainsAny(domain, "/:@?# \t") || strings.Contains(domain, "..") {
+		return "", ErrInvalidDomain
+	}
+
+	return domain, nil
+}
+
+// parentDomains returns host followed by each of its parent domains,
+// e.g. "a.b.example.com", "b.example.com", "example.com", "com".
+func parentDomains(host string) []string {
+	domains := []string{host}
+
+	for {
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok || parent == "" {
+			return domains
+		}
+
+		domains = append(domains, parent)
+		host = parent
+	}
+}
@@ -0,0 +1,67 @@
+package blocklist_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/blocklist/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocklist_IsBlocked(t *testing.T) {
+	b := blocklist.New(store.NewMemory())
+	_, err := b.Add(context.Background(), &blocklist.Entry{Domain: "evil.example"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://evil.example/path", true},
+		{"https://EVIL.example:8443/", true},
+		{"https://a.b.evil.example", true},
+		{"https://notevil.example", false},
+		{"https://evil.example.org", false},
+		{"not a url", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			blocked, err := b.IsBlocked(context.Background(), tt.url)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.blocked, blocked)
+		})
+	}
+}
+
+func TestBlocklist_AddRemove(t *testing.T) {
+	t.Run("normalizes domains", func(t *testing.T) {
+		b := blocklist.New(store.NewMemory())
+
+		entry, err := b.Add(context.Background(), &blocklist.Entry{Domain: " Evil.Example. ", Reason: "spam"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "evil.example", entry.Domain)
+
+		entries, err := b.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		require.NoError(t, b.Remove(context.Background(), "EVIL.example"))
+		require.ErrorIs(t, b.Remove(context.Background(), "evil.example"), blocklist.ErrNotFound)
+	})
+
+	t.Run("rejects invalid domains", func(t *testing.T) {
+		b := blocklist.New(store.NewMemory())
+
+		for _, domain := range []string{"", "https://evil.example", "evil.example/path", "evil.example:80", "a..b"} {
+			_, err := b.Add(context.Background(), &blocklist.Entry{Domain: domain})
+			require.ErrorIs(t, err, blocklist.ErrInvalidDomain, domain)
+
+			require.ErrorIs(t, b.Remove(context.Background(), domain), blocklist.ErrInvalidDomain, domain)
+		}
+	})
+}
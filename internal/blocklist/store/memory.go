@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/serroba/web-demo-go/internal/blocklist"
+)
+
+// Memory is an in-memory implementation of blocklist.Store.
+type Memory struct {
+	mu      sync.RWMutex
+	entries map[string]*blocklist.Entry
+}
+
+// NewMemory creates a new in-memory blocklist store.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]*blocklist.Entry)}
+}
+
+func (m *Memory) Add(_ context.Context, entry *blocklist.Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[entry.Domain] = entry
+
+	return nil
+}
+
+func (m *Memory) Remove(_ context.Context, domain string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[domain]; !ok {
+		return blocklist.ErrNotFound
+	}
+
+	delete(m.entries, domain)
+
+	return nil
+}
+
+func (m *Memory) List(_ context.Context) ([]*blocklist.Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]*blocklist.Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+
+	slices.SortFunc(entries, func(a, b *blocklist.Entry) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+
+	return entries, nil
+}
+
+func (m *Memory) AnyBlocked(_ context.Context, domains []string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range domains {
+		if _, ok := m.entries[d]; ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Compile-time check.
+var _ blocklist.Store = (*Memory)(nil)
@@ -0,0 +1,42 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/blocklist/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	t.Run("lists entries ordered by domain", func(t *testing.T) {
+		m := store.NewMemory()
+		require.NoError(t, m.Add(context.Background(), &blocklist.Entry{Domain: "b.example"}))
+		require.NoError(t, m.Add(context.Background(), &blocklist.Entry{Domain: "a.example"}))
+
+		entries, err := m.List(context.Background())
+
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "a.example", entries[0].Domain)
+	})
+
+	t.Run("matches any of the given domains", func(t *testing.T) {
+		m := store.NewMemory()
+		require.NoError(t, m.Add(context.Background(), &blocklist.Entry{Domain: "evil.example"}))
+
+		blocked, err := m.AnyBlocked(context.Background(), []string{"x.evil.example", "evil.example"})
+		require.NoError(t, err)
+		assert.True(t, blocked)
+
+		blocked, err = m.AnyBlocked(context.Background(), []string{"good.example"})
+		require.NoError(t, err)
+		assert.False(t, blocked)
+	})
+
+	t.Run("remove returns ErrNotFound for unknown domains", func(t *testing.T) {
+		require.ErrorIs(t, store.NewMemory().Remove(context.Background(), "x"), blocklist.ErrNotFound)
+	})
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+)
+
+// Postgres persists blocked domains in PostgreSQL.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres creates a new PostgreSQL blocklist store.
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+func (p *Postgres) Add(ctx context.Context, entry *blocklist.Entry) error {
+	query := `
+		INSERT INTO blocked_domains (domain, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (domain) DO UPDATE
+		SET reason = EXCLUDED.reason, created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at
+	`
+
+	_, err := p.pool.Exec(ctx, query, entry.Domain, entry.Reason, entry.CreatedBy, entry.CreatedAt)
+
+	return err
+}
+
+func (p *Postgres) Remove(ctx context.Context, domain string) error {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM blocked_domains WHERE domain = $1`, domain)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return blocklist.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *Postgres) List(ctx context.Context) ([]*blocklist.Entry, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT domain, reason, created_by, created_at
+		FROM blocked_domains
+		ORDER BY domain
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*blocklist.Entry
+
+	for rows.Next() {
+		var e blocklist.Entry
+		if err := rows.Scan(&e.Domain, &e.Reason, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (p *Postgres) AnyBlocked(ctx context.Context, domains []string) (bool, error) {
+	var blocked bool
+
+	err := p.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM blocked_domains WHERE domain = ANY($1))`,
+		domains,
+	).Scan(&blocked)
+
+	return blocked, err
+}
+
+// Compile-time check.
+var _ blocklist.Store = (*Postgres)(nil)
@@ -2,16 +2,23 @@ package cache
 
 import (
 	"sync"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/shortener"
 )
 
 // node represents a doubly linked list node.
 type node struct {
-	key   string
-	value *shortener.ShortURL
-	prev  *node
-	next  *node
+	key     string
+	value   *shortener.ShortURL // nil for a key cached as missing
+	expires time.Time           // zero for values, which do not expire
+	prev    *node
+	next    *node
+}
+
+// expired reports whether a key cached as missing has expired.
+func (n *node) expired() bool {
+	return !n.expires.IsZero() && time.Now().After(n.expires)
 }
 
 // LRU implements a Least Recently Used cache.
@@ -40,19 +47,28 @@ func New(capacity int) *LRU {
 }
 
 // Get retrieves a value from the cache.
-// Returns the value and true if found, nil and false otherwise.
+// Returns the value and true if found, nil and false otherwise. A key cached as
+// missing with AddMissing is found with a nil value until it expires.
 // Accessing an item moves it to the front (most recently used).
 func (c *LRU) Get(key string) (*shortener.ShortURL, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if n, ok := c.items[key]; ok {
-		c.moveToFront(n)
+	n, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if n.expired() {
+		c.detach(n)
+		delete(c.items, key)
 
-		return n.value, true
+		return nil, false
 	}
 
-	return nil, false
+	c.moveToFront(n)
+
+	return n.value, true
 }
 
 // Set adds or updates a value in the cache.
@@ -63,6 +79,7 @@ func (c *LRU) Set(key string, value *shortener.ShortURL) {
 
 	if n, ok := c.items[key]; ok {
 		n.value = value
+		n.expires = time.Time{}
 		c.moveToFront(n)
 
 		return
@@ -79,6 +96,82 @@ func (c *LRU) Set(key string, value *shortener.ShortURL) {
 	c.addToFront(n)
 }
 
+// Add adds a value only if the key is not cached yet, or cached as missing, and
+// reports whether it did. Unlike Set, it never replaces a value written
+// concurrently, nor changes recency.
+func (c *LRU) Add(key string, value *shortener.ShortURL) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n, ok := c.items[key]; ok {
+		if n.value != nil {
+			return false
+		}
+
+		n.value = value
+		n.expires = time.Time{}
+
+		return true
+	}
+
+	if len(c.items) >= c.capacity {
+		c.evictLRU()
+	}
+
+	n := &node{key: key, value: value}
+	c.items[key] = n
+	c.addToFront(n)
+
+	return true
+}
+
+// AddMissing caches a key as missing for ttl, unless it is cached already, and
+// reports whether it did. Set and Add replace it with a value.
+func (c *LRU) AddMissing(key string, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n, ok := c.items[key]; ok {
+		if !n.expired() {
+			return false
+		}
+
+		c.detach(n)
+		delete(c.items, key)
+	}
+
+	if len(c.items) >= c.capacity {
+		c.evictLRU()
+	}
+
+	n := &node{key: key, expires: time.Now().Add(ttl)}
+	c.items[key] = n
+	c.addToFront(n)
+
+	return true
+}
+
+// Delete removes a value from the cache, if present.
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n, ok := c.items[key]; ok {
+		c.detach(n)
+		delete(c.items, key)
+	}
+}
+
+// Clear removes every value from the cache.
+func (c *LRU) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.items)
+	c.head.next = c.tail
+	c.tail.prev = c.head
+}
+
 // Len returns the current number of items in the cache.
 func (c *LRU) Len() int {
 	c.mu.RLock()
@@ -3,6 +3,7 @@ package cache_test
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/cache"
 	"github.com/serroba/web-demo-go/internal/shortener"
@@ -51,6 +52,20 @@ func TestLRU_BasicOperations(t *testing.T) {
 		assert.Equal(t, "https://example2.com", val.OriginalURL)
 	})
 
+	t.Run("add keeps existing key", func(t *testing.T) {
+		c := cache.New(1)
+
+		assert.True(t, c.Add("abc", newShortURL("abc", "https://example1.com")))
+		assert.False(t, c.Add("abc", newShortURL("abc", "https://example2.com")))
+
+		val, ok := c.Get("abc")
+		require.True(t, ok)
+		assert.Equal(t, "https://example1.com", val.OriginalURL)
+
+		assert.True(t, c.Add("def", newShortURL("def", "https://example3.com")), "evicts when full")
+		assert.Equal(t, 1, c.Len())
+	})
+
 	t.Run("len returns correct count", func(t *testing.T) {
 		c := cache.New(10)
 
@@ -62,6 +77,70 @@ func TestLRU_BasicOperations(t *testing.T) {
 		c.Set("b", newShortURL("b", "https://b.com"))
 		assert.Equal(t, 2, c.Len())
 	})
+
+	t.Run("add missing caches a key as missing until it expires", func(t *testing.T) {
+		c := cache.New(10)
+
+		assert.True(t, c.AddMissing("a", time.Minute))
+		assert.False(t, c.AddMissing("a", time.Minute))
+
+		val, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Nil(t, val)
+
+		assert.True(t, c.AddMissing("b", time.Millisecond))
+		time.Sleep(5 * time.Millisecond)
+
+		_, ok = c.Get("b")
+		assert.False(t, ok)
+	})
+
+	t.Run("add missing keeps values, which replace it", func(t *testing.T) {
+		c := cache.New(10)
+		c.Set("a", newShortURL("a", "https://a.com"))
+
+		assert.False(t, c.AddMissing("a", time.Minute))
+
+		c.AddMissing("b", time.Minute)
+		assert.True(t, c.Add("b", newShortURL("b", "https://b.com")))
+
+		c.AddMissing("c", time.Millisecond)
+		c.Set("c", newShortURL("c", "https://c.com"))
+		time.Sleep(5 * time.Millisecond)
+
+		for _, key := range []string{"a", "b", "c"} {
+			val, ok := c.Get(key)
+			require.True(t, ok, key)
+			assert.Equal(t, "https://"+key+".com", val.OriginalURL)
+		}
+	})
+
+	t.Run("delete removes key", func(t *testing.T) {
+		c := cache.New(10)
+		c.Set("a", newShortURL("a", "https://a.com"))
+
+		c.Delete("a")
+		c.Delete("missing")
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("clear removes every key", func(t *testing.T) {
+		c := cache.New(10)
+		c.Set("a", newShortURL("a", "https://a.com"))
+		c.AddMissing("b", time.Minute)
+
+		c.Clear()
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+
+		c.Set("c", newShortURL("c", "https://c.com"))
+		assert.Equal(t, 1, c.Len())
+	})
 }
 
 func TestLRU_Eviction(t *testing.T) {
@@ -0,0 +1,171 @@
+package checkcode
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// Alphabet holds the characters of checked codes: digits and lowercase letters
+// without the easily confused 0, 1, i, l and o.
+const Alphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// MinLength is the length of the shortest checked code, one character and its check.
+const MinLength = 2
+
+// base is the size of Alphabet. As it is prime, the Damm operation below is
+// defined on the integers modulo base.
+const base = len(Alphabet)
+
+// ErrInvalidLength is returned for a code length below MinLength.
+var ErrInvalidLength = errors.New("invalid checked code length")
+
+// New returns a generator of codes of the given length drawn from Alphabet,
+// the last character being a check character over the others. A mistyped
+// character, or two adjacent characters swapped, always fails Valid.
+func New(length int) (shortener.CodeGenerator, error) {
+	if length < MinLength {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLength, length)
+	}
+
+	return func() string {
+		var b strings.Builder
+
+		b.Grow(length)
+
+		for range length - 1 {
+			b.WriteByte(Alphabet[intn(base)])
+		}
+
+		body := b.String()
+
+		return body + string(Alphabet[check(body)])
+	}, nil
+}
+
+// Keyspace returns the number of distinct codes of the given length New generates.
+func Keyspace(length int) float64 {
+	return math.Pow(float64(base), float64(length-1))
+}
+
+// Valid reports whether code is a checked code: made of Alphabet and ending in
+// the check character of the rest.
+func Valid(code string) bool {
+	if len(code) < MinLength {
+		return false
+	}
+
+	interim, ok := digest(code)
+
+	return ok && interim == 0
+}
+
+// Shaped reports whether code is made of Alphabet only, which checked codes
+// are. Codes from other generators, such as word codes, are not.
+func Shaped(code string) bool {
+	if len(code) < MinLength {
+		return false
+	}
+
+	for i := range len(code) {
+		if strings.IndexByte(Alphabet, code[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Scope tells which codes are checked: with checked codes enabled, the codes made
+// of Alphabet longer than LegacyLength, the longest code made before they were.
+// Shorter codes may predate them, and only name a link if it exists.
+type Scope struct {
+	Enabled      bool
+	LegacyLength int
+}
+
+// Mistyped reports whether code is in scope but fails its check, so that no link
+// has it and it can be rejected without being looked up.
+func (s Scope) Mistyped(code string) bool {
+	return s.Enabled && len(code) > s.LegacyLength && Shaped(code) && !Valid(code)
+}
+
+// Suggest returns the only valid code that differs from code by two swapped
+// adjacent characters, the most common typo a check character detects but
+// cannot locate. It reports false if there is no such code, or more than one.
+func Suggest(code string) (string, bool) {
+	if !Shaped(code) {
+		return "", false
+	}
+
+	var (
+		suggestion string
+		found      bool
+	)
+
+	b := []byte(code)
+
+	for i := range len(b) - 1 {
+		if b[i] == b[i+1] {
+			continue
+		}
+
+		b[i], b[i+1] = b[i+1], b[i]
+
+		if candidate := string(b); Valid(candidate) {
+			if found && candidate != suggestion {
+				return "", false
+			}
+
+			suggestion, found = candidate, true
+		}
+
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+
+	return suggestion, found
+}
+
+// check returns the position in Alphabet of the check character for body.
+func check(body string) int {
+	interim, _ := digest(body)
+
+	// op(interim, c) is 0 for c = -2*interim
+	return (base - 2*interim%base) % base
+}
+
+// digest runs the Damm algorithm over code with the operation
+// op(x, y) = 2x + y mod base. Since 2 is neither 0 nor 1 modulo the prime base,
+// op is a totally anti-symmetric quasigroup, so any single substitution or
+// adjacent transposition changes the result. It reports false if code has a
+// character outside Alphabet.
+func digest(code string) (int, bool) {
+	interim := 0
+
+	for i := range len(code) {
+		v := strings.IndexByte(Alphabet, code[i])
+		if v < 0 {
+			return 0, false
+		}
+
+		interim = (2*interim + v) % base
+	}
+
+	return interim, true
+}
+
+// intn returns a uniformly random int in [0, n).
+func intn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+
+	return int(v.Int64())
+}
@@ -0,0 +1,130 @@
+package checkcode_test
+
+import (
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/checkcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("generates valid codes from the alphabet", func(t *testing.T) {
+		generate, err := checkcode.New(8)
+		require.NoError(t, err)
+
+		for range 100 {
+			code := generate()
+			assert.Len(t, code, 8)
+			assert.Regexp(t, `^[2-9a-hjkmnp-z]+$`, code)
+			assert.True(t, checkcode.Valid(code), code)
+		}
+	})
+
+	t.Run("rejects lengths without room for a check character", func(t *testing.T) {
+		_, err := checkcode.New(1)
+		require.ErrorIs(t, err, checkcode.ErrInvalidLength)
+	})
+}
+
+func TestKeyspace(t *testing.T) {
+	// The check character adds no codes
+	assert.InDelta(t, 31.0, checkcode.Keyspace(2), 0)
+	assert.InDelta(t, 31.0*31*31, checkcode.Keyspace(4), 0)
+}
+
+func TestValid(t *testing.T) {
+	generate, err := checkcode.New(8)
+	require.NoError(t, err)
+
+	t.Run("detects every single substitution", func(t *testing.T) {
+		code := generate()
+
+		for i := range len(code) {
+			for _, c := range []byte(checkcode.Alphabet) {
+				if c == code[i] {
+					continue
+				}
+
+				typo := code[:i] + string(c) + code[i+1:]
+				assert.False(t, checkcode.Valid(typo), typo)
+			}
+		}
+	})
+
+	t.Run("detects every adjacent transposition", func(t *testing.T) {
+		for range 100 {
+			code := []byte(generate())
+
+			for i := range len(code) - 1 {
+				if code[i] == code[i+1] {
+					continue
+				}
+
+				code[i], code[i+1] = code[i+1], code[i]
+				assert.False(t, checkcode.Valid(string(code)), string(code))
+				code[i], code[i+1] = code[i+1], code[i]
+			}
+		}
+	})
+
+	t.Run("rejects confusable and foreign characters", func(t *testing.T) {
+		for _, code := range []string{"", "a", "0abc", "abc1", "oabc", "labc", "iabc", "Abcd", "brave-otter-42"} {
+			assert.False(t, checkcode.Valid(code), code)
+		}
+	})
+}
+
+func TestShaped(t *testing.T) {
+	assert.True(t, checkcode.Shaped("abc23xyz"))
+	assert.False(t, checkcode.Shaped("a"))
+	assert.False(t, checkcode.Shaped("abc0"))
+	assert.False(t, checkcode.Shaped("AbCd"))
+	assert.False(t, checkcode.Shaped("brave-otter-42"))
+}
+
+func TestScope_Mistyped(t *testing.T) {
+	generate, err := checkcode.New(9)
+	require.NoError(t, err)
+
+	scope := checkcode.Scope{Enabled: true, LegacyLength: 8}
+
+	assert.False(t, scope.Mistyped(generate()))
+	assert.True(t, scope.Mistyped("fx3kp7qab"))
+	assert.False(t, scope.Mistyped("fx3kp7qa"), "codes as short as legacy ones may predate checked codes")
+	assert.False(t, scope.Mistyped("brave-otter-42"))
+	assert.False(t, checkcode.Scope{LegacyLength: 8}.Mistyped("fx3kp7qab"))
+}
+
+func TestSuggest(t *testing.T) {
+	generate, err := checkcode.New(8)
+	require.NoError(t, err)
+
+	t.Run("undoes a swap of adjacent characters", func(t *testing.T) {
+		suggested := 0
+
+		for range 100 {
+			code := generate()
+			if code[2] == code[3] {
+				continue
+			}
+
+			typo := code[:2] + string(code[3]) + string(code[2]) + code[4:]
+
+			suggestion, ok := checkcode.Suggest(typo)
+			if ok {
+				assert.Equal(t, code, suggestion)
+
+				suggested++
+			}
+		}
+
+		// Another swap may also yield a valid code, leaving the typo ambiguous
+		assert.Greater(t, suggested, 50)
+	})
+
+	t.Run("suggests nothing for codes outside the alphabet", func(t *testing.T) {
+		_, ok := checkcode.Suggest("brave-otter-42")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,159 @@
+package codegrowth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidBounds is returned for code lengths or a threshold that cannot be used.
+var ErrInvalidBounds = errors.New("invalid code growth bounds")
+
+// Counter counts existing codes.
+type Counter interface {
+	// CountCodes returns the number of token and hash codes of each length,
+	// deleted links included, as their codes are still taken.
+	CountCodes(ctx context.Context) (map[int]int64, error)
+}
+
+// Factory returns a generator of codes of the given length.
+type Factory func(length int) (shortener.CodeGenerator, error)
+
+// Keyspace returns the number of distinct codes of the given length.
+type Keyspace func(length int) float64
+
+// Generator generates codes as short as the keyspace allows. It generates codes
+// of the shortest length, from minLength, whose occupancy is below threshold: the
+// chance that a new random code is already taken. The length only ever grows;
+// codes of every length are looked up the same way, so existing codes keep
+// resolving.
+type Generator struct {
+	mu         sync.Mutex
+	generators map[int]shortener.CodeGenerator
+	keyspace   Keyspace
+	threshold  float64
+	maxLength  int
+	length     int
+	counts     map[int]int64
+	counter    Counter
+	logger     *zap.Logger
+}
+
+// NewGenerator creates a generator of codes from minLength to maxLength long.
+// Counts come from counter on Refresh, and are kept up to date with the codes
+// generated in between.
+func NewGenerator(
+	newGenerator Factory,
+	keyspace Keyspace,
+	minLength, maxLength int,
+	threshold float64,
+	counter Counter,
+	logger *zap.Logger,
+) (*Generator, error) {
+	if minLength < 1 || maxLength < minLength || threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("%w: lengths %d to %d, threshold %g", ErrInvalidBounds, minLength, maxLength, threshold)
+	}
+
+	generators := make(map[int]shortener.CodeGenerator, maxLength-minLength+1)
+
+	for length := minLength; length <= maxLength; length++ {
+		generate, err := newGenerator(length)
+		if err != nil {
+			return nil, err
+		}
+
+		generators[length] = generate
+	}
+
+	return &Generator{
+		generators: generators,
+		keyspace:   keyspace,
+		threshold:  threshold,
+		maxLength:  maxLength,
+		length:     minLength,
+		counts:     make(map[int]int64),
+		counter:    counter,
+		logger:     logger,
+	}, nil
+}
+
+// Length returns the length of new codes.
+func (g *Generator) Length() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.length
+}
+
+// Generate returns a new code. It has the shape of a shortener.CodeGenerator.
+func (g *Generator) Generate() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	code := g.generators[g.length]()
+	g.counts[len(code)]++
+	g.grow()
+
+	return code
+}
+
+// Refresh replaces the counts with those of the counter, which include codes
+// generated by other instances.
+func (g *Generator) Refresh(ctx context.Context) error {
+	counts, err := g.counter.CountCodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.counts = counts
+	g.grow()
+
+	return nil
+}
+
+// Run refreshes the counts now and then every interval until ctx is done.
+// Failures are logged and retried on the next tick.
+func (g *Generator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.Refresh(ctx); err != nil && ctx.Err() == nil {
+			g.logger.Error("failed to count codes", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// grow moves to the next length while the current one is too full.
+// It must be called with mu held.
+func (g *Generator) grow() {
+	length := g.length
+
+	for length < g.maxLength && float64(g.counts[length]) >= g.threshold*g.keyspace(length) {
+		length++
+	}
+
+	if length != g.length {
+		g.logger.Info("growing code length",
+			zap.Int("from", g.length),
+			zap.Int("to", length),
+			zap.Int64("codes", g.counts[g.length]),
+		)
+
+		g.length = length
+	}
+}
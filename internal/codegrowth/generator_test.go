@@ -0,0 +1,147 @@
+package codegrowth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/codegrowth"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// repeated generates codes of the given length; only their count matters here.
+func repeated(length int) (shortener.CodeGenerator, error) {
+	return func() string { return strings.Repeat("a", length) }, nil
+}
+
+// tenPerCharacter is the keyspace of a 10 character alphabet.
+func tenPerCharacter(length int) float64 {
+	space := 1.0
+	for range length {
+		space *= 10
+	}
+
+	return space
+}
+
+type fixedCounter struct {
+	counts map[int]int64
+	err    error
+}
+
+func (f *fixedCounter) CountCodes(_ context.Context) (map[int]int64, error) {
+	return f.counts, f.err
+}
+
+func newGenerator(t *testing.T, counter codegrowth.Counter) *codegrowth.Generator {
+	t.Helper()
+
+	g, err := codegrowth.NewGenerator(repeated, tenPerCharacter, 2, 4, 0.1, counter, zap.NewNop())
+	require.NoError(t, err)
+
+	return g
+}
+
+func TestGenerator(t *testing.T) {
+	t.Run("starts at the minimum length", func(t *testing.T) {
+		g := newGenerator(t, &fixedCounter{})
+
+		assert.Equal(t, 2, g.Length())
+		assert.Len(t, g.Generate(), 2)
+	})
+
+	t.Run("grows once the threshold is reached", func(t *testing.T) {
+		g := newGenerator(t, &fixedCounter{})
+
+		// 10% of the 100 two-character codes
+		for range 9 {
+			assert.Len(t, g.Generate(), 2)
+		}
+
+		g.Generate()
+
+		assert.Equal(t, 3, g.Length())
+		assert.Len(t, g.Generate(), 3)
+	})
+
+	t.Run("grows from counted codes", func(t *testing.T) {
+		g := newGenerator(t, &fixedCounter{counts: map[int]int64{2: 50, 3: 100}})
+
+		require.NoError(t, g.Refresh(context.Background()))
+
+		assert.Equal(t, 4, g.Length())
+	})
+
+	t.Run("stops at the maximum length", func(t *testing.T) {
+		g := newGenerator(t, &fixedCounter{counts: map[int]int64{2: 100, 3: 1000, 4: 10000}})
+
+		require.NoError(t, g.Refresh(context.Background()))
+
+		assert.Equal(t, 4, g.Length())
+	})
+
+	t.Run("never shrinks", func(t *testing.T) {
+		counter := &fixedCounter{counts: map[int]int64{2: 10}}
+		g := newGenerator(t, counter)
+		require.NoError(t, g.Refresh(context.Background()))
+
+		counter.counts = map[int]int64{}
+		require.NoError(t, g.Refresh(context.Background()))
+
+		assert.Equal(t, 3, g.Length())
+	})
+
+	t.Run("keeps its length when counting fails", func(t *testing.T) {
+		g := newGenerator(t, &fixedCounter{err: assert.AnError})
+
+		require.ErrorIs(t, g.Refresh(context.Background()), assert.AnError)
+		assert.Equal(t, 2, g.Length())
+	})
+
+	t.Run("counts codes of a store", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		for _, code := range []shortener.Code{"a1", "a2", "a3", "a4", "a5", "a6", "a7", "a8", "a9", "b1"} {
+			require.NoError(t, memStore.Save(context.Background(), &shortener.ShortURL{Code: code}))
+		}
+
+		g := newGenerator(t, memStore)
+		require.NoError(t, g.Refresh(context.Background()))
+
+		assert.Equal(t, 3, g.Length())
+	})
+
+	t.Run("rejects invalid bounds", func(t *testing.T) {
+		for _, bounds := range []struct {
+			minLength, maxLength int
+			threshold            float64
+		}{{0, 4, 0.1}, {4, 2, 0.1}, {2, 4, 0}, {2, 4, 1.5}} {
+			_, err := codegrowth.NewGenerator(
+				repeated, tenPerCharacter, bounds.minLength, bounds.maxLength, bounds.threshold, &fixedCounter{}, zap.NewNop(),
+			)
+			require.ErrorIs(t, err, codegrowth.ErrInvalidBounds)
+		}
+	})
+}
+
+func TestGenerator_Run(t *testing.T) {
+	t.Run("counts codes right away", func(t *testing.T) {
+		g := newGenerator(t, &fixedCounter{counts: map[int]int64{2: 10}})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			g.Run(ctx, time.Hour)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return g.Length() == 3 }, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+	})
+}
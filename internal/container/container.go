@@ -2,11 +2,16 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/danielgtaylor/huma/v2/adapters/humachi"
 	_ "github.com/danielgtaylor/huma/v2/formats/cbor" // CBOR format support for huma
@@ -17,30 +22,132 @@ import (
 	"github.com/samber/do"
 	"github.com/serroba/web-demo-go/internal/analytics"
 	analyticsstore "github.com/serroba/web-demo-go/internal/analytics/store"
+	"github.com/serroba/web-demo-go/internal/audit"
+	auditstore "github.com/serroba/web-demo-go/internal/audit/store"
+	"github.com/serroba/web-demo-go/internal/auth"
+	authstore "github.com/serroba/web-demo-go/internal/auth/store"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	blockliststore "github.com/serroba/web-demo-go/internal/blocklist/store"
 	"github.com/serroba/web-demo-go/internal/cache"
+	"github.com/serroba/web-demo-go/internal/checkcode"
+	"github.com/serroba/web-demo-go/internal/codegrowth"
 	"github.com/serroba/web-demo-go/internal/handlers"
 	"github.com/serroba/web-demo-go/internal/health"
+	"github.com/serroba/web-demo-go/internal/linkmeta"
 	"github.com/serroba/web-demo-go/internal/messaging"
 	"github.com/serroba/web-demo-go/internal/middleware"
+	"github.com/serroba/web-demo-go/internal/quota"
+	quotastore "github.com/serroba/web-demo-go/internal/quota/store"
 	"github.com/serroba/web-demo-go/internal/ratelimit"
 	ratelimitstore "github.com/serroba/web-demo-go/internal/ratelimit/store"
+	"github.com/serroba/web-demo-go/internal/retention"
 	"github.com/serroba/web-demo-go/internal/shortener"
 	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/serroba/web-demo-go/internal/transfer"
+	"github.com/serroba/web-demo-go/internal/wordcode"
 	"go.uber.org/zap"
 )
 
+// auditFileName is the file of the audit log in the directory of file storage.
+const auditFileName = "audit.jsonl"
+
+// nanoidAlphabetSize is the number of characters nanoid codes are drawn from.
+const nanoidAlphabetSize = 64
+
+// cacheRefreshBeta scales how early the Redis cache refreshes links. With 1,
+// the XFetch default, a hot link is refreshed about once before it expires.
+const cacheRefreshBeta = 1
+
+// Storages holding links, selected with the storage option. Other data, such as
+// API keys, the audit log and analytics, is only persisted with postgres; the
+// other storages keep it in memory.
+const (
+	StoragePostgres = "postgres"
+	StorageRedis    = "redis"
+	StorageMemory   = "memory"
+	StorageFile     = "file"
+)
+
+// Transports of the changes of links between the LRU caches of servers, selected
+// with the cache invalidation option. Without it, Redis carries them when links
+// already go through Redis, and PostgreSQL otherwise.
+const (
+	InvalidationRedis    = "redis"
+	InvalidationPostgres = "postgres"
+	InvalidationNone     = "none"
+)
+
+// LinkStore is the source of truth of links: a repository that also serves the
+// queries cache layers do not support.
+type LinkStore interface {
+	shortener.Repository
+	shortener.Lister
+	codegrowth.Counter
+	retention.Tombstones
+}
+
 type Options struct {
-	Port             int           `default:"8888"           help:"Port to listen on" short:"p"`
-	CodeLength       int           `default:"8"              help:"Short code length" short:"c"`
-	RedisAddr        string        `default:"localhost:6379" help:"Redis address"     short:"r"`
-	DatabaseURL      string        `env:"DATABASE_URL"       help:"PostgreSQL URL"    required:""`
-	RateLimitStore   string        `default:"memory"         env:"RATE_LIMIT_STORE"   help:"memory or redis"`
-	CacheSize        int           `default:"1000"           env:"CACHE_SIZE"         help:"LRU cache size (0=off)"`
-	CacheTTL         time.Duration `default:"1h"             env:"CACHE_TTL"          help:"Redis cache TTL"`
-	LogFormat        string        `default:"console"        env:"LOG_FORMAT"         help:"console or json"`
-	TopicURLCreated  string        `default:"url.created"    env:"TOPIC_URL_CREATED"  help:"URL created topic"`
-	TopicURLAccessed string        `default:"url.accessed"   env:"TOPIC_URL_ACCESSED" help:"URL accessed topic"`
-	ConsumerGroup    string        `default:"analytics"      env:"CONSUMER_GROUP"     help:"Consumer group name"`
+	Port              int           `default:"8888"           help:"Port to listen on"    short:"p"`
+	CodeLength        int           `default:"8"              help:"Shortest code length" short:"c"`
+	CodeMaxLength     int           `default:"16"             env:"CODE_MAX_LENGTH"       help:"Max code length"`
+	CodeGrowthPPM     int           `default:"1000"           env:"CODE_GROWTH_PPM"       help:"Growth occupancy (ppm)"`
+	CodeCountInterval time.Duration `default:"10m"            env:"CODE_COUNT_INTERVAL"   help:"Count interval (0=off)"`
+	CheckedCodes      bool          `default:"false"          env:"CHECKED_CODES"         help:"Check character codes"`
+	LegacyCodeLength  int           `default:"0"              env:"LEGACY_CODE_LENGTH"    help:"Longest unchecked code"`
+	ReservedWords     string        `env:"RESERVED_WORDS"     help:"Comma-separated words never used as codes"`
+	WordCodeWords     int           `default:"2"              env:"WORD_CODE_WORDS"       help:"Words per word code"`
+	WordCodeDigits    int           `default:"2"              env:"WORD_CODE_DIGITS"      help:"Digits per word code"`
+	RedisAddr         string        `default:"localhost:6379" help:"Redis address"        short:"r"`
+	Storage           string        `default:"postgres"       env:"STORAGE"               help:"Link storage backend"`
+	StorageDir        string        `default:"data"           env:"STORAGE_DIR"           help:"File storage directory"`
+	StorageSync       bool          `default:"true"           env:"STORAGE_SYNC"          help:"Fsync file storage writes"`
+	StorageSnapshot   int           `default:"10000"          env:"STORAGE_SNAPSHOT"      help:"File changes per snapshot"`
+	StorageShards     string        `env:"STORAGE_SHARDS"     help:"Comma-separated shard addresses"`
+	StorageResharding bool          `default:"false"          env:"STORAGE_RESHARDING"    help:"Search every shard"`
+	DatabaseURL       string        `env:"DATABASE_URL"       help:"PostgreSQL URL"`
+	DatabaseReplicas  string        `env:"DATABASE_REPLICAS"  help:"Comma-separated read replica URLs"`
+	ReplicaMaxLag     time.Duration `default:"5s"             env:"REPLICA_MAX_LAG"       help:"Max replica lag"`
+	ReplicaInterval   time.Duration `default:"10s"            env:"REPLICA_INTERVAL"      help:"Replica check interval"`
+	SaveBatch         int           `default:"0"              env:"SAVE_BATCH"            help:"Saves per insert (0=off)"`
+	SaveBatchWait     time.Duration `default:"5ms"            env:"SAVE_BATCH_WAIT"       help:"Max wait to batch a save"`
+	RateLimitStore    string        `default:"memory"         env:"RATE_LIMIT_STORE"      help:"memory or redis"`
+	CacheSize         int           `default:"1000"           env:"CACHE_SIZE"            help:"LRU cache size (0=off)"`
+	CacheTTL          time.Duration `default:"1h"             env:"CACHE_TTL"             help:"Redis cache TTL"`
+	CacheRedis        bool          `default:"true"           env:"CACHE_REDIS"           help:"Redis cache over postgres"`
+	CacheMissTTL      time.Duration `default:"30s"            env:"CACHE_MISS_TTL"        help:"Unknown code TTL (0=off)"`
+	CacheLockWait     time.Duration `default:"0s"             env:"CACHE_LOCK_WAIT"       help:"Refill lock wait (0=off)"`
+	CacheRefreshes    int           `default:"8"              env:"CACHE_REFRESHES"       help:"Early refreshes (0=off)"`
+	CacheInvalidation string        `env:"CACHE_INVALIDATION" help:"redis, postgres or none (empty=auto)"`
+	LogFormat         string        `default:"console"        env:"LOG_FORMAT"            help:"console or json"`
+	TopicURLCreated   string        `default:"url.created"    env:"TOPIC_URL_CREATED"     help:"URL created topic"`
+	TopicURLAccessed  string        `default:"url.accessed"   env:"TOPIC_URL_ACCESSED"    help:"URL accessed topic"`
+	ConsumerGroup     string        `default:"analytics"      env:"CONSUMER_GROUP"        help:"Consumer group name"`
+
+	// Authentication
+	AllowAnonymousCreate bool          `default:"true"     env:"ALLOW_ANONYMOUS_CREATE" help:"Allow anonymous creates"`
+	JWKSURL              string        `env:"JWKS_URL"     help:"JWKS file or URL (empty=off)"`
+	JWKSRefresh          time.Duration `default:"15m"      env:"JWKS_REFRESH"           help:"JWKS reload interval"`
+	JWTIssuer            string        `env:"JWT_ISSUER"   help:"Required JWT issuer"`
+	JWTAudience          string        `env:"JWT_AUDIENCE" help:"Required JWT audience"`
+	JWTOwnerClaim        string        `default:"sub"      env:"JWT_OWNER_CLAIM"        help:"JWT claim used as owner ID"`
+	JWTRolesClaim        string        `default:"roles"    env:"JWT_ROLES_CLAIM"        help:"JWT claim holding roles"`
+	JWTDefaultRole       string        `default:"creator"  env:"JWT_DEFAULT_ROLE"       help:"Role for JWTs without roles"`
+
+	// Link metadata enrichment (consumer)
+	MetadataGroup    string        `default:"metadata" env:"METADATA_CONSUMER_GROUP" help:"Metadata consumer group name"`
+	MetadataTimeout  time.Duration `default:"5s"       env:"METADATA_TIMEOUT"        help:"Page fetch timeout"`
+	MetadataMaxBytes int64         `default:"1048576"  env:"METADATA_MAX_BYTES"      help:"Max page bytes to read"`
+
+	// Quotas on link creation per workspace and API key (0=unlimited)
+	QuotaActiveLinks     int64         `default:"0"  env:"QUOTA_ACTIVE_LINKS"      help:"Active links per workspace"`
+	QuotaMonthlyLinks    int64         `default:"0"  env:"QUOTA_MONTHLY_LINKS"     help:"Workspace links per month"`
+	QuotaKeyMonthlyLinks int64         `default:"0"  env:"QUOTA_KEY_MONTHLY_LINKS" help:"API key links per month"`
+	QuotaCacheTTL        time.Duration `default:"1m" env:"QUOTA_CACHE_TTL"         help:"Usage cache TTL"`
+
+	// Deleted links: restorable for the window, then purged with their analytics if enabled
+	LinkRestoreWindow time.Duration `default:"720h"  env:"LINK_RESTORE_WINDOW" help:"Deleted link restore window"`
+	PurgeInterval     time.Duration `default:"1h"    env:"PURGE_INTERVAL"      help:"Deleted link purge interval (0=off)"`
+	PurgeAnalytics    bool          `default:"false" env:"PURGE_ANALYTICS"     help:"Purge analytics of purged links"`
 
 	// Rate limit configuration per scope
 	RateLimitGlobalPerDay   int64 `default:"1000000" env:"RATE_LIMIT_GLOBAL_DAY"   help:"Global requests per day"`
@@ -90,6 +197,72 @@ func RedisPackage(i *do.Injector) {
 	})
 }
 
+// FileStore wraps store.FileStore to implement Shutdownable for do.Injector.
+type FileStore struct {
+	*store.FileStore
+}
+
+// Shutdown implements do.Shutdownable.
+func (f *FileStore) Shutdown() error {
+	return f.Close()
+}
+
+// AuditFile wraps auditstore.File to implement Shutdownable for do.Injector.
+type AuditFile struct {
+	*auditstore.File
+}
+
+// Shutdown implements do.Shutdownable.
+func (a *AuditFile) Shutdown() error {
+	return a.Close()
+}
+
+// ShardedStore wraps store.ShardedRepository to implement Shutdownable for do.Injector.
+type ShardedStore struct {
+	*store.ShardedRepository
+
+	closers []func() error
+}
+
+// Shutdown implements do.Shutdownable.
+func (s *ShardedStore) Shutdown() error {
+	var errs []error
+
+	for _, closer := range s.closers {
+		errs = append(errs, closer())
+	}
+
+	// The store is provided under several types, and shut down once for each
+	s.closers = nil
+
+	return errors.Join(errs...)
+}
+
+// LinkCache is the repository of links under the Redis cache layer, if enabled.
+// It implements Shutdownable for do.Injector, and reports the early refreshes of
+// the cache to health checks.
+type LinkCache struct {
+	shortener.Repository
+
+	redis *store.RedisCacheRepository
+}
+
+// Shutdown implements do.Shutdownable.
+func (c *LinkCache) Shutdown() error {
+	if c.redis != nil {
+		return c.redis.Shutdown()
+	}
+
+	return nil
+}
+
+// CacheStats implements health.CacheReporter.
+func (c *LinkCache) CacheStats() health.CacheStats {
+	stats := c.redis.RefreshStats()
+
+	return health.CacheStats{Refreshed: stats.Refreshed, Failed: stats.Failed, Dropped: stats.Dropped}
+}
+
 // PostgresPool wraps pgxpool.Pool to implement Shutdownable for do.Injector.
 type PostgresPool struct {
 	*pgxpool.Pool
@@ -108,6 +281,9 @@ func (p *PostgresPool) Shutdown() error {
 func PostgresPackage(i *do.Injector) {
 	do.Provide(i, func(i *do.Injector) (*PostgresPool, error) {
 		opts := do.MustInvoke[*Options](i)
+		if opts.DatabaseURL == "" {
+			return nil, errors.New("a database URL is required with postgres storage")
+		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -127,26 +303,291 @@ func PostgresPackage(i *do.Injector) {
 	})
 }
 
-// RepositoryPackage provides the URL repository with Redis caching over PostgreSQL.
+// PostgresReplicas wraps the PostgreSQL read replicas to implement Shutdownable for do.Injector.
+type PostgresReplicas struct {
+	*store.Replicas
+
+	pools []*pgxpool.Pool
+}
+
+// Shutdown implements do.Shutdownable.
+func (p *PostgresReplicas) Shutdown() error {
+	for _, pool := range p.pools {
+		pool.Close()
+	}
+
+	return nil
+}
+
+// PostgresReplicasPackage provides the read replicas that redirects look links
+// up on. Replicas are not required to be up at start: they are checked in the
+// background and join the rotation once they answer.
+func PostgresReplicasPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*PostgresReplicas, error) {
+		opts := do.MustInvoke[*Options](i)
+		logger := do.MustInvoke[*zap.Logger](i)
+		replicas := &PostgresReplicas{}
+
+		var dbs []store.Querier
+
+		for _, url := range strings.Split(opts.DatabaseReplicas, ",") {
+			if url = strings.TrimSpace(url); url == "" {
+				continue
+			}
+
+			pool, err := pgxpool.New(context.Background(), url)
+			if err != nil {
+				_ = replicas.Shutdown()
+
+				return nil, err
+			}
+
+			replicas.pools = append(replicas.pools, pool)
+			dbs = append(dbs, pool)
+		}
+
+		replicas.Replicas = store.NewReplicas(opts.ReplicaMaxLag, logger, dbs...)
+
+		return replicas, nil
+	})
+}
+
+// RepositoryPackage provides the URL repository: the link store selected with
+// the storage option, under the enabled cache layers.
 func RepositoryPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (LinkStore, error) {
+		opts := do.MustInvoke[*Options](i)
+
+		if opts.StorageShards != "" {
+			return shardedStore(opts)
+		}
+
+		switch opts.Storage {
+		case StoragePostgres:
+			pool := do.MustInvoke[*PostgresPool](i)
+			if opts.DatabaseReplicas == "" {
+				return store.NewPostgresStore(pool.Pool), nil
+			}
+
+			return store.NewReplicatedPostgresStore(pool.Pool, do.MustInvoke[*PostgresReplicas](i).Replicas), nil
+		case StorageRedis:
+			return store.NewRedisStore(do.MustInvoke[*RedisClient](i).Client), nil
+		case StorageMemory:
+			return store.NewMemoryStore(), nil
+		case StorageFile:
+			fileStore, err := store.NewFileStore(opts.StorageDir, store.FileConfig{
+				Sync:          opts.StorageSync,
+				SnapshotEvery: opts.StorageSnapshot,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return &FileStore{FileStore: fileStore}, nil
+		default:
+			return nil, fmt.Errorf("unknown storage %q", opts.Storage)
+		}
+	})
+
+	do.Provide(i, func(i *do.Injector) (*LinkCache, error) {
+		opts := do.MustInvoke[*Options](i)
+
+		var repo shortener.Repository = do.MustInvoke[LinkStore](i)
+
+		// Optional coalescing of saves, for stores that insert many links at once
+		if batchStore, ok := repo.(store.BatchRepository); ok && opts.SaveBatch > 0 {
+			repo = store.NewBatchingRepository(batchStore, store.BatchConfig{
+				MaxRows: opts.SaveBatch,
+				MaxWait: opts.SaveBatchWait,
+			})
+		}
+
+		// Redis cache layer with configurable TTL, for links read from PostgreSQL
+		if opts.Storage != StoragePostgres || !opts.CacheRedis {
+			return &LinkCache{Repository: repo}, nil
+		}
+
+		redisCache := store.NewRedisCacheRepository(repo, do.MustInvoke[*RedisClient](i).Client, store.RedisCacheConfig{
+			TTL:         opts.CacheTTL,
+			MissTTL:     opts.CacheMissTTL,
+			LockWait:    opts.CacheLockWait,
+			RefreshBeta: cacheRefreshBeta,
+			Refreshes:   opts.CacheRefreshes,
+		})
+
+		return &LinkCache{Repository: redisCache, redis: redisCache}, nil
+	})
+
 	do.Provide(i, func(i *do.Injector) (shortener.Repository, error) {
 		opts := do.MustInvoke[*Options](i)
-		pool := do.MustInvoke[*PostgresPool](i)
-		redisClient := do.MustInvoke[*RedisClient](i)
 
-		// PostgreSQL as source of truth
-		postgresStore := store.NewPostgresStore(pool.Pool)
+		var repo shortener.Repository = do.MustInvoke[*LinkCache](i)
 
-		// Redis cache layer with configurable TTL
-		var repo shortener.Repository = store.NewRedisCacheRepository(postgresStore, redisClient.Client, opts.CacheTTL)
+		// Optional in-memory LRU cache on top, pointless over links already in memory
+		if opts.CacheSize > 0 && !inProcess(opts) {
+			config := store.CachedConfig{MissTTL: opts.CacheMissTTL}
 
-		// Optional in-memory LRU cache on top
-		if opts.CacheSize > 0 {
-			repo = store.NewCachedRepository(repo, cache.New(opts.CacheSize))
+			if cacheInvalidation(opts) != InvalidationNone {
+				bus, err := invalidationBus(i, opts)
+				if err != nil {
+					return nil, err
+				}
+
+				config.Bus = bus
+			}
+
+			repo = store.NewCachedRepository(repo, cache.New(opts.CacheSize), config)
 		}
 
 		return repo, nil
 	})
+
+	// Listings read the source of truth directly; cache layers do not support them.
+	do.Provide(i, func(i *do.Injector) (shortener.Lister, error) {
+		return do.MustInvoke[LinkStore](i), nil
+	})
+}
+
+// invalidationBus returns the bus carrying changes of links between the LRU
+// caches of servers.
+func invalidationBus(i *do.Injector, opts *Options) (store.InvalidationBus, error) {
+	logger := do.MustInvoke[*zap.Logger](i)
+
+	switch invalidation := cacheInvalidation(opts); invalidation {
+	case InvalidationRedis:
+		return store.NewRedisInvalidationBus(do.MustInvoke[*RedisClient](i).Client, logger), nil
+	case InvalidationPostgres:
+		if opts.Storage != StoragePostgres || opts.StorageShards != "" {
+			return nil, errors.New("postgres cache invalidations require unsharded postgres storage")
+		}
+
+		return store.NewPostgresInvalidationBus(do.MustInvoke[*PostgresPool](i).Pool, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown cache invalidation %q", invalidation)
+	}
+}
+
+// cacheInvalidation returns the transport of invalidations: that of the option,
+// or the one needing no other server than those links already go through.
+func cacheInvalidation(opts *Options) string {
+	switch {
+	case opts.CacheInvalidation != "":
+		return opts.CacheInvalidation
+	case opts.Storage == StorageRedis || opts.CacheRedis:
+		return InvalidationRedis
+	case opts.StorageShards == "":
+		return InvalidationPostgres
+	default:
+		return InvalidationNone
+	}
+}
+
+// shardedStore connects to the shards of the shards option: PostgreSQL databases
+// with postgres storage, Redis instances with redis storage.
+func shardedStore(opts *Options) (*ShardedStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sharded := &ShardedStore{}
+
+	var shards []store.Shard
+
+	for _, addr := range strings.Split(opts.StorageShards, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+
+		shard, err := openShard(ctx, opts.Storage, addr, sharded)
+		if err != nil {
+			_ = sharded.Shutdown()
+
+			return nil, fmt.Errorf("shard %d: %w", len(shards), err)
+		}
+
+		shards = append(shards, shard)
+	}
+
+	repo, err := store.NewShardedRepository(shards, store.ShardConfig{
+		Resharding: opts.StorageResharding,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("STORAGE_SHARDS %q: %w", opts.StorageShards, err)
+	}
+
+	sharded.ShardedRepository = repo
+
+	return sharded, nil
+}
+
+// openShard connects to a shard, which sharded closes on shutdown.
+func openShard(ctx context.Context, storage, addr string, sharded *ShardedStore) (store.Shard, error) {
+	switch storage {
+	case StoragePostgres:
+		pool, err := pgxpool.New(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		sharded.closers = append(sharded.closers, func() error {
+			pool.Close()
+
+			return nil
+		})
+
+		if err := pool.Ping(ctx); err != nil {
+			return nil, err
+		}
+
+		return store.NewPostgresStore(pool), nil
+	case StorageRedis:
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		sharded.closers = append(sharded.closers, client.Close)
+
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, err
+		}
+
+		return store.NewRedisStore(client), nil
+	default:
+		return nil, fmt.Errorf("%s storage cannot be sharded", storage)
+	}
+}
+
+// ReservedWordsPackage provides the registry of words that cannot be used as codes:
+// the configured extra words, plus the API routes once they are registered.
+func ReservedWordsPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*shortener.ReservedWords, error) {
+		opts := do.MustInvoke[*Options](i)
+
+		return shortener.NewReservedWords(strings.Split(opts.ReservedWords, ",")...), nil
+	})
+}
+
+// CodeGrowthPackage provides the generator of token and hash codes, which grows
+// their length from CodeLength as the shorter codes run out. Checked codes are
+// longer than the codes made before they were enabled, so that they can be told
+// apart.
+func CodeGrowthPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*codegrowth.Generator, error) {
+		opts := do.MustInvoke[*Options](i)
+		logger := do.MustInvoke[*zap.Logger](i)
+		factory, keyspace := codeFormat(opts)
+
+		minLength := opts.CodeLength
+		if opts.CheckedCodes {
+			minLength = max(minLength, opts.LegacyCodeLength+1)
+		}
+
+		return codegrowth.NewGenerator(
+			factory,
+			keyspace,
+			minLength,
+			max(minLength, opts.CodeMaxLength),
+			float64(opts.CodeGrowthPPM)/1e6,
+			do.MustInvoke[LinkStore](i),
+			logger,
+		)
+	})
 }
 
 // RateLimitPackage provides the rate limit store.
@@ -164,9 +605,15 @@ func RateLimitPackage(i *do.Injector) {
 	})
 }
 
-// PublisherGroupPackage provides the publisher group for event publishing.
+// PublisherGroupPackage provides the publisher group for event publishing. With
+// memory storage, events are published in process, where no consumer reads them.
 func PublisherGroupPackage(i *do.Injector) {
 	do.Provide(i, func(i *do.Injector) (*messaging.PublisherGroup, error) {
+		opts := do.MustInvoke[*Options](i)
+		if inProcess(opts) {
+			return messaging.NewPublisherGroup(gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})), nil
+		}
+
 		redisClient := do.MustInvoke[*RedisClient](i)
 
 		publisher, err := redisstream.NewPublisher(
@@ -184,11 +631,206 @@ func PublisherGroupPackage(i *do.Injector) {
 }
 
 // AnalyticsStorePackage provides the analytics store for persisting events.
+// Events are only kept with postgres storage.
 func AnalyticsStorePackage(i *do.Injector) {
 	do.Provide(i, func(i *do.Injector) (analytics.Store, error) {
-		pool := do.MustInvoke[*PostgresPool](i)
+		return analyticsStore(i), nil
+	})
+
+	do.Provide(i, func(i *do.Injector) (analytics.StatsReader, error) {
+		return analyticsStore(i), nil
+	})
+}
 
-		return analyticsstore.NewPostgres(pool.Pool), nil
+// AuthPackage provides the API key and workspace stores and the request authenticator.
+func AuthPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (auth.KeyStore, error) {
+		if !persistent(i) {
+			return authstore.NewMemory(), nil
+		}
+
+		return authstore.NewPostgres(do.MustInvoke[*PostgresPool](i).Pool), nil
+	})
+
+	do.Provide(i, func(i *do.Injector) (auth.WorkspaceStore, error) {
+		if !persistent(i) {
+			return authstore.NewMemoryWorkspaces(), nil
+		}
+
+		return authstore.NewPostgresWorkspaces(do.MustInvoke[*PostgresPool](i).Pool), nil
+	})
+
+	do.Provide(i, func(i *do.Injector) (auth.Authenticator, error) {
+		opts := do.MustInvoke[*Options](i)
+		keys := do.MustInvoke[auth.KeyStore](i)
+
+		authenticators := []auth.Authenticator{auth.NewAPIKeyAuthenticator(keys)}
+
+		// SSO-issued JWTs are accepted alongside API keys when a JWKS is configured
+		if opts.JWKSURL != "" {
+			if opts.JWTAudience == "" {
+				return nil, errors.New("JWT_AUDIENCE is required with JWKS_URL")
+			}
+
+			var defaultRoles []auth.Role
+
+			if opts.JWTDefaultRole != "" {
+				role, ok := auth.ParseRole(opts.JWTDefaultRole)
+				if !ok {
+					return nil, fmt.Errorf("unknown JWT default role %q", opts.JWTDefaultRole)
+				}
+
+				defaultRoles = []auth.Role{role}
+			}
+
+			authenticators = append(authenticators, auth.NewJWTAuthenticator(
+				auth.NewJWKSCache(opts.JWKSURL, opts.JWKSRefresh),
+				auth.JWTConfig{
+					Issuer:       opts.JWTIssuer,
+					Audience:     opts.JWTAudience,
+					OwnerClaim:   opts.JWTOwnerClaim,
+					RolesClaim:   opts.JWTRolesClaim,
+					DefaultRoles: defaultRoles,
+					Leeway:       time.Minute,
+				},
+			))
+		}
+
+		return auth.NewChainAuthenticator(authenticators...), nil
+	})
+}
+
+// BlocklistPackage provides the destination domain blocklist.
+func BlocklistPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*blocklist.Blocklist, error) {
+		auditLog := do.MustInvoke[*audit.Log](i)
+
+		var entries blocklist.Store = blockliststore.NewMemory()
+		if persistent(i) {
+			entries = blockliststore.NewPostgres(do.MustInvoke[*PostgresPool](i).Pool)
+		}
+
+		return blocklist.New(audit.NewBlocklistStore(entries, auditLog)), nil
+	})
+}
+
+// AuditPackage provides the audit log of link changes and admin actions, kept
+// in PostgreSQL, next to the links with file storage, or in memory otherwise.
+func AuditPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*AuditFile, error) {
+		opts := do.MustInvoke[*Options](i)
+
+		// The link store locks the directory, for the audit file too
+		_ = do.MustInvoke[LinkStore](i)
+
+		file, err := auditstore.NewFile(filepath.Join(opts.StorageDir, auditFileName))
+		if err != nil {
+			return nil, err
+		}
+
+		return &AuditFile{File: file}, nil
+	})
+
+	do.Provide(i, func(i *do.Injector) (*audit.Log, error) {
+		logger := do.MustInvoke[*zap.Logger](i)
+
+		if do.MustInvoke[*Options](i).Storage == StorageFile {
+			return audit.NewLog(do.MustInvoke[*AuditFile](i).File, logger), nil
+		}
+
+		if !persistent(i) {
+			return audit.NewLog(auditstore.NewMemory(), logger), nil
+		}
+
+		return audit.NewLog(auditstore.NewPostgres(do.MustInvoke[*PostgresPool](i).Pool), logger), nil
+	})
+}
+
+// QuotaPackage provides the enforcer of link creation quotas. Usage counters
+// live in PostgreSQL, with Redis as a fast path, or in memory with other storages.
+func QuotaPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*quota.Enforcer, error) {
+		opts := do.MustInvoke[*Options](i)
+
+		var usage quota.Store = quotastore.NewMemory()
+		if persistent(i) {
+			pool := do.MustInvoke[*PostgresPool](i)
+			redisClient := do.MustInvoke[*RedisClient](i)
+			usage = quotastore.NewRedisCache(quotastore.NewPostgres(pool.Pool), redisClient.Client, opts.QuotaCacheTTL)
+		}
+
+		return quota.NewEnforcer(usage, quota.Limits{
+			ActiveLinks:         opts.QuotaActiveLinks,
+			MonthlyCreations:    opts.QuotaMonthlyLinks,
+			KeyMonthlyCreations: opts.QuotaKeyMonthlyLinks,
+		}), nil
+	})
+}
+
+// auditedRepository returns the links repository that enforces quotas on
+// creations and records changes in the audit log, for the components changing
+// links on behalf of callers.
+func auditedRepository(i *do.Injector) shortener.Repository {
+	logger := do.MustInvoke[*zap.Logger](i)
+	enforcer := do.MustInvoke[*quota.Enforcer](i)
+	auditLog := do.MustInvoke[*audit.Log](i)
+
+	return audit.NewRepository(quota.NewRepository(do.MustInvoke[shortener.Repository](i), enforcer, logger), auditLog)
+}
+
+// RetentionPackage provides the purger of deleted links whose restore window has passed.
+func RetentionPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*retention.Purger, error) {
+		opts := do.MustInvoke[*Options](i)
+		logger := do.MustInvoke[*zap.Logger](i)
+		repo := auditedRepository(i)
+
+		var events analytics.EventDeleter
+		if opts.PurgeAnalytics {
+			events = analyticsStore(i)
+		}
+
+		return retention.NewPurger(do.MustInvoke[LinkStore](i), repo, events, opts.LinkRestoreWindow, logger), nil
+	})
+}
+
+// TransferPackage provides the importer of links. Imported links go through the
+// same quota and audit decorators as links created through the API.
+func TransferPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*transfer.Importer, error) {
+		opts := do.MustInvoke[*Options](i)
+		logger := do.MustInvoke[*zap.Logger](i)
+		repo := auditedRepository(i)
+		blocked := do.MustInvoke[*blocklist.Blocklist](i)
+		reserved := do.MustInvoke[*shortener.ReservedWords](i)
+		pub := do.MustInvoke[*messaging.PublisherGroup](i).Publisher()
+
+		return transfer.NewImporter(
+			repo,
+			blocked,
+			reserved,
+			checkedCodes(opts),
+			messaging.NewPublishFunc[analytics.URLCreatedEvent](pub, opts.TopicURLCreated),
+			logger,
+		), nil
+	})
+}
+
+// LinkMetadataPackage provides the enricher that fills in link metadata from destination pages.
+func LinkMetadataPackage(i *do.Injector) {
+	do.Provide(i, func(i *do.Injector) (*linkmeta.Enricher, error) {
+		opts := do.MustInvoke[*Options](i)
+		auditLog := do.MustInvoke[*audit.Log](i)
+		repo := audit.NewRepository(do.MustInvoke[shortener.Repository](i), auditLog)
+		logger := do.MustInvoke[*zap.Logger](i)
+
+		fetcher := linkmeta.NewFetcher(linkmeta.Config{
+			Timeout:   opts.MetadataTimeout,
+			MaxBytes:  opts.MetadataMaxBytes,
+			UserAgent: "url-shortener-metadata/1.0",
+		})
+
+		return linkmeta.NewEnricher(repo, fetcher, logger), nil
 	})
 }
 
@@ -229,6 +871,30 @@ func ConsumerGroupPackage(i *do.Injector) {
 			logger,
 		))
 
+		// Metadata enrichment reads url.created with its own consumer group,
+		// so every event is delivered to both analytics and enrichment.
+		metadataSubscriber, err := redisstream.NewSubscriber(
+			redisstream.SubscriberConfig{
+				Client:        redisClient.Client,
+				ConsumerGroup: opts.MetadataGroup,
+				Consumer:      "consumer-1",
+			},
+			watermill.NewStdLogger(true, true),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		group.AddSubscriber(metadataSubscriber)
+
+		enricher := do.MustInvoke[*linkmeta.Enricher](i)
+		group.Add(messaging.NewConsumer(
+			metadataSubscriber,
+			opts.TopicURLCreated,
+			enricher.HandleURLCreated,
+			logger,
+		))
+
 		return group, nil
 	})
 }
@@ -243,15 +909,27 @@ func HTTPPackage(i *do.Injector) {
 		router := do.MustInvoke[*chi.Mux](i)
 		opts := do.MustInvoke[*Options](i)
 		logger := do.MustInvoke[*zap.Logger](i)
-		redisClient := do.MustInvoke[*RedisClient](i)
-		urlStore := do.MustInvoke[shortener.Repository](i)
+		enforcer := do.MustInvoke[*quota.Enforcer](i)
+		auditLog := do.MustInvoke[*audit.Log](i)
+		urlStore := auditedRepository(i)
+		lister := do.MustInvoke[shortener.Lister](i)
+		statsReader := do.MustInvoke[analytics.StatsReader](i)
+		authenticator := do.MustInvoke[auth.Authenticator](i)
+		workspaces := do.MustInvoke[auth.WorkspaceStore](i)
+		blocked := do.MustInvoke[*blocklist.Blocklist](i)
 		rateLimitStore := do.MustInvoke[ratelimit.Store](i)
 		publisherGroup := do.MustInvoke[*messaging.PublisherGroup](i)
+		reserved := do.MustInvoke[*shortener.ReservedWords](i)
 
-		api := humachi.New(router, huma.DefaultConfig("URL Shortener", "1.0.0"))
+		config := huma.DefaultConfig("URL Shortener", "1.0.0")
+		api := humachi.New(router, config)
 
 		// Set up middleware
+		api.UseMiddleware(middleware.RequestID(api))
 		api.UseMiddleware(middleware.RequestMeta(api))
+		api.UseMiddleware(middleware.Authenticate(api, authenticator, logger))
+		api.UseMiddleware(middleware.SelectWorkspace(api, workspaces, logger))
+		api.UseMiddleware(middleware.Authorize(api))
 
 		// Build rate limit policy from configuration
 		policy := ratelimit.NewPolicyBuilder().
@@ -268,11 +946,17 @@ func HTTPPackage(i *do.Injector) {
 
 		// Set up handlers
 		baseURL := fmt.Sprintf("http://localhost:%d", opts.Port)
-		codeGenerator, _ := nanoid.Standard(opts.CodeLength)
+		codeGenerator := reserved.Generator(do.MustInvoke[*codegrowth.Generator](i).Generate)
+
+		wordGenerator, err := wordcode.New(opts.WordCodeWords, opts.WordCodeDigits)
+		if err != nil {
+			return nil, err
+		}
 
 		strategies := map[handlers.Strategy]shortener.Strategy{
 			handlers.StrategyToken: shortener.NewTokenStrategy(urlStore, codeGenerator),
 			handlers.StrategyHash:  shortener.NewHashStrategy(urlStore, codeGenerator),
+			handlers.StrategyWords: shortener.NewWordStrategy(urlStore, reserved.Generator(wordGenerator)),
 		}
 
 		pub := publisherGroup.Publisher()
@@ -280,16 +964,107 @@ func HTTPPackage(i *do.Injector) {
 			urlStore,
 			baseURL,
 			strategies,
+			opts.AllowAnonymousCreate,
+			checkedCodes(opts),
+			blocked,
 			messaging.NewPublishFunc[analytics.URLCreatedEvent](pub, opts.TopicURLCreated),
 			messaging.NewPublishFunc[analytics.URLAccessedEvent](pub, opts.TopicURLAccessed),
 			logger,
 		)
-		healthHandler := health.NewHandler(health.NewRedisChecker(redisClient.Client))
+		linkHandler := handlers.NewLinkHandler(urlStore, lister, statsReader, blocked, baseURL, opts.LinkRestoreWindow)
+		adminHandler := handlers.NewAdminHandler(urlStore, blocked, linkHandler)
+		usageHandler := handlers.NewUsageHandler(enforcer)
+		historyHandler := handlers.NewHistoryHandler(auditLog)
+		transferHandler := handlers.NewTransferHandler(lister, do.MustInvoke[*transfer.Importer](i), logger)
+		healthHandler := health.NewHandler(redisChecker(i), cacheReporter(i))
 
 		// Register routes
 		handlers.RegisterRoutes(api, urlHandler)
+		handlers.RegisterLinkRoutes(api, linkHandler)
+		handlers.RegisterLinkOwnerRoutes(api, linkHandler)
+		handlers.RegisterAdminRoutes(api, adminHandler)
+		handlers.RegisterUsageRoutes(api, usageHandler)
+		handlers.RegisterHistoryRoutes(api, historyHandler)
+		handlers.RegisterTransferRoutes(api, transferHandler)
 		health.RegisterRoutes(api, healthHandler)
 
+		// Codes must never shadow the routes registered above
+		handlers.ReserveRoutes(api, config, reserved)
+
 		return api, nil
 	})
 }
+
+// inProcess reports whether the server holds its links itself, so that it
+// needs neither PostgreSQL nor Redis.
+func inProcess(opts *Options) bool {
+	return opts.Storage == StorageMemory || opts.Storage == StorageFile
+}
+
+// persistent reports whether the server stores its data in PostgreSQL, rather
+// than in memory.
+func persistent(i *do.Injector) bool {
+	return do.MustInvoke[*Options](i).Storage == StoragePostgres
+}
+
+// analyticsBackend is a store of analytics events, readable and purgeable.
+type analyticsBackend interface {
+	analytics.Store
+	analytics.StatsReader
+	analytics.EventDeleter
+}
+
+// analyticsStore returns the PostgreSQL analytics store, or a no-op store
+// discarding events when data is not persistent.
+func analyticsStore(i *do.Injector) analyticsBackend {
+	if !persistent(i) {
+		return analyticsstore.NewNoop(do.MustInvoke[*zap.Logger](i))
+	}
+
+	return analyticsstore.NewPostgres(do.MustInvoke[*PostgresPool](i).Pool)
+}
+
+// redisChecker returns the health checker of Redis, or nil if the server does
+// not use Redis.
+func redisChecker(i *do.Injector) health.Checker {
+	opts := do.MustInvoke[*Options](i)
+	if inProcess(opts) && opts.RateLimitStore != "redis" {
+		return nil
+	}
+
+	return health.NewRedisChecker(do.MustInvoke[*RedisClient](i).Client)
+}
+
+// cacheReporter returns the reporter of the Redis cache of links, or nil if the
+// server does not use one.
+func cacheReporter(i *do.Injector) health.CacheReporter {
+	linkCache := do.MustInvoke[*LinkCache](i)
+	if linkCache.redis == nil {
+		return nil
+	}
+
+	return linkCache
+}
+
+// checkedCodes returns the codes checked as typos: none unless enabled, and
+// otherwise those longer than the codes made before.
+func checkedCodes(opts *Options) checkcode.Scope {
+	return checkcode.Scope{Enabled: opts.CheckedCodes, LegacyLength: opts.LegacyCodeLength}
+}
+
+// codeFormat returns the generators of token and hash codes of each length, and
+// their keyspace: checked codes if enabled, nanoid otherwise.
+func codeFormat(opts *Options) (codegrowth.Factory, codegrowth.Keyspace) {
+	if opts.CheckedCodes {
+		return checkcode.New, checkcode.Keyspace
+	}
+
+	factory := func(length int) (shortener.CodeGenerator, error) {
+		return nanoid.Standard(length)
+	}
+	keyspace := func(length int) float64 {
+		return math.Pow(nanoidAlphabetSize, float64(length))
+	}
+
+	return factory, keyspace
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// AdminHandler handles administrative operations: link takedowns and the domain blocklist.
+type AdminHandler struct {
+	store     shortener.Repository
+	blocklist *blocklist.Blocklist
+	links     *LinkHandler
+}
+
+// NewAdminHandler creates a new admin handler. Link responses are rendered like the links API.
+func NewAdminHandler(store shortener.Repository, blocklist *blocklist.Blocklist, links *LinkHandler) *AdminHandler {
+	return &AdminHandler{
+		store:     store,
+		blocklist: blocklist,
+		links:     links,
+	}
+}
+
+// DisableLink takes a link down; redirects to it are refused until it is enabled again.
+func (h *AdminHandler) DisableLink(ctx context.Context, req *LinkRequest) (*LinkResponse, error) {
+	return h.setDisabled(ctx, req.Code, true)
+}
+
+// EnableLink restores a link that was taken down.
+func (h *AdminHandler) EnableLink(ctx context.Context, req *LinkRequest) (*LinkResponse, error) {
+	return h.setDisabled(ctx, req.Code, false)
+}
+
+// setDisabled takes a link down or back up. The link is written back whole, so
+// it is read as stored, not as cached.
+func (h *AdminHandler) setDisabled(ctx context.Context, code string, disabled bool) (*LinkResponse, error) {
+	ctx = shortener.ContextWithLatestReads(ctx)
+
+	shortURL, err := h.store.GetByCode(ctx, shortener.Code(code))
+	if err != nil {
+		return nil, storeError(err, "failed to get link")
+	}
+
+	if shortURL.Deleted() {
+		return nil, huma.Error410Gone("short url has been deleted")
+	}
+
+	updated := *shortURL
+	updated.DisabledAt = nil
+
+	if disabled {
+		now := time.Now()
+		updated.DisabledAt = &now
+	}
+
+	if err := h.store.Update(ctx, &updated); err != nil {
+		return nil, storeError(err, "failed to update link")
+	}
+
+	return &LinkResponse{Body: h.links.toLinkItem(&updated)}, nil
+}
+
+func (h *AdminHandler) ListBlockedDomains(ctx context.Context, _ *struct{}) (*ListBlockedDomainsResponse, error) {
+	entries, err := h.blocklist.List(ctx)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to list blocked domains")
+	}
+
+	resp := &ListBlockedDomainsResponse{}
+
+	resp.Body.Items = make([]BlockedDomainItem, 0, len(entries))
+	for _, e := range entries {
+		resp.Body.Items = append(resp.Body.Items, toBlockedDomainItem(e))
+	}
+
+	return resp, nil
+}
+
+func (h *AdminHandler) BlockDomain(ctx context.Context, req *BlockDomainRequest) (*BlockDomainResponse, error) {
+	entry := &blocklist.Entry{
+		Domain:    req.Body.Domain,
+		Reason:    req.Body.Reason,
+		CreatedBy: auth.OwnerIDFromContext(ctx),
+		CreatedAt: time.Now(),
+	}
+
+	stored, err := h.blocklist.Add(ctx, entry)
+	if err != nil {
+		if errors.Is(err, blocklist.ErrInvalidDomain) {
+			return nil, huma.Error422UnprocessableEntity("domain must be a bare host name")
+		}
+
+		return nil, huma.Error500InternalServerError("failed to block domain")
+	}
+
+	return &BlockDomainResponse{Body: toBlockedDomainItem(stored)}, nil
+}
+
+func (h *AdminHandler) UnblockDomain(ctx context.Context, req *UnblockDomainRequest) (*UnblockDomainResponse, error) {
+	if err := h.blocklist.Remove(ctx, req.Domain); err != nil {
+		switch {
+		case errors.Is(err, blocklist.ErrNotFound), errors.Is(err, blocklist.ErrInvalidDomain):
+			return nil, huma.Error404NotFound("domain is not blocked")
+		default:
+			return nil, huma.Error500InternalServerError("failed to unblock domain")
+		}
+	}
+
+	return &UnblockDomainResponse{}, nil
+}
+
+func toBlockedDomainItem(e *blocklist.Entry) BlockedDomainItem {
+	return BlockedDomainItem{
+		Domain:    e.Domain,
+		Reason:    e.Reason,
+		CreatedBy: e.CreatedBy,
+		CreatedAt: e.CreatedAt,
+	}
+}
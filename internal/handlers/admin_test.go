@@ -0,0 +1,181 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingBlocklistStore struct{}
+
+func (failingBlocklistStore) Add(_ context.Context, _ *blocklist.Entry) error {
+	return errMock
+}
+
+func (failingBlocklistStore) Remove(_ context.Context, _ string) error {
+	return errMock
+}
+
+func (failingBlocklistStore) List(_ context.Context) ([]*blocklist.Entry, error) {
+	return nil, errMock
+}
+
+func (failingBlocklistStore) AnyBlocked(_ context.Context, _ []string) (bool, error) {
+	return false, errMock
+}
+
+func newAdminHandler(s shortener.Repository, blocked *blocklist.Blocklist) *handlers.AdminHandler {
+	links := handlers.NewLinkHandler(s, failingLister{}, &fakeStats{}, blocked, "http://localhost:8888", restoreWindow)
+
+	return handlers.NewAdminHandler(s, blocked, links)
+}
+
+func TestAdminHandler_Takedown(t *testing.T) {
+	t.Run("disables and enables a link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newAdminHandler(memStore, newBlocklist())
+
+		disabled, err := handler.DisableLink(adminContext(), &handlers.LinkRequest{Code: "mine"})
+
+		require.NoError(t, err)
+		assert.NotNil(t, disabled.Body.DisabledAt)
+
+		stored, _ := memStore.GetByCode(context.Background(), "mine")
+		assert.True(t, stored.Disabled())
+
+		enabled, err := handler.EnableLink(adminContext(), &handlers.LinkRequest{Code: "mine"})
+
+		require.NoError(t, err)
+		assert.Nil(t, enabled.Body.DisabledAt)
+
+		stored, _ = memStore.GetByCode(context.Background(), "mine")
+		assert.False(t, stored.Disabled())
+	})
+
+	t.Run("keeps changes missing from the cache", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newAdminHandler(cachedOver(t, memStore, "mine"), newBlocklist())
+
+		stored, err := memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+
+		edited := *stored
+		edited.OriginalURL = "https://example.com/new"
+		require.NoError(t, memStore.Update(context.Background(), &edited))
+
+		_, err = handler.DisableLink(adminContext(), &handlers.LinkRequest{Code: "mine"})
+		require.NoError(t, err)
+
+		stored, err = memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+		assert.True(t, stored.Disabled())
+		assert.Equal(t, "https://example.com/new", stored.OriginalURL)
+	})
+
+	t.Run("returns 404 for unknown code", func(t *testing.T) {
+		handler := newAdminHandler(store.NewMemoryStore(), newBlocklist())
+
+		_, err := handler.DisableLink(adminContext(), &handlers.LinkRequest{Code: "missing"})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("returns 410 for deleted links", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedDeletedLink(t, memStore, "gone", "alice", time.Hour)
+		handler := newAdminHandler(memStore, newBlocklist())
+
+		_, err := handler.DisableLink(adminContext(), &handlers.LinkRequest{Code: "gone"})
+
+		assertStatus(t, err, http.StatusGone)
+	})
+
+	t.Run("returns 500 on update error", func(t *testing.T) {
+		handler := newAdminHandler(&mockStore{updateErr: errMock}, newBlocklist())
+
+		_, err := handler.DisableLink(adminContext(), &handlers.LinkRequest{Code: "abc123"})
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
+
+func TestAdminHandler_Blocklist(t *testing.T) {
+	t.Run("blocks, lists and unblocks domains", func(t *testing.T) {
+		handler := newAdminHandler(store.NewMemoryStore(), newBlocklist())
+
+		req := &handlers.BlockDomainRequest{}
+		req.Body.Domain = "Evil.Example."
+		req.Body.Reason = "phishing"
+
+		created, err := handler.BlockDomain(adminContext(), req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "evil.example", created.Body.Domain)
+		assert.Equal(t, "root", created.Body.CreatedBy)
+
+		list, err := handler.ListBlockedDomains(adminContext(), &struct{}{})
+
+		require.NoError(t, err)
+		require.Len(t, list.Body.Items, 1)
+		assert.Equal(t, "phishing", list.Body.Items[0].Reason)
+
+		_, err = handler.UnblockDomain(adminContext(), &handlers.UnblockDomainRequest{Domain: "evil.example"})
+		require.NoError(t, err)
+
+		_, err = handler.UnblockDomain(adminContext(), &handlers.UnblockDomainRequest{Domain: "evil.example"})
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("rejects invalid domains", func(t *testing.T) {
+		handler := newAdminHandler(store.NewMemoryStore(), newBlocklist())
+
+		req := &handlers.BlockDomainRequest{}
+		req.Body.Domain = "https://evil.example/path"
+
+		_, err := handler.BlockDomain(adminContext(), req)
+
+		assertStatus(t, err, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("returns 500 on store errors", func(t *testing.T) {
+		handler := newAdminHandler(store.NewMemoryStore(), blocklist.New(failingBlocklistStore{}))
+
+		req := &handlers.BlockDomainRequest{}
+		req.Body.Domain = "evil.example"
+
+		_, err := handler.BlockDomain(adminContext(), req)
+		assertStatus(t, err, http.StatusInternalServerError)
+
+		_, err = handler.ListBlockedDomains(adminContext(), &struct{}{})
+		assertStatus(t, err, http.StatusInternalServerError)
+
+		_, err = handler.UnblockDomain(adminContext(), &handlers.UnblockDomainRequest{Domain: "evil.example"})
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+
+	t.Run("blocklist errors fail destination changes closed", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+
+		blocked := blocklist.New(failingBlocklistStore{})
+		links := handlers.NewLinkHandler(memStore, memStore, &fakeStats{}, blocked, "http://localhost:8888", restoreWindow)
+
+		newURL := "https://example.org"
+		req := &handlers.UpdateLinkRequest{Code: "mine"}
+		req.Body.URL = &newURL
+
+		_, err := links.UpdateLink(ownerContext("alice"), req)
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
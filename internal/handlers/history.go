@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/audit"
+	"github.com/serroba/web-demo-go/internal/auth"
+)
+
+// HistoryHandler serves the audit trail of links.
+type HistoryHandler struct {
+	log *audit.Log
+}
+
+// NewHistoryHandler creates a new history handler.
+func NewHistoryHandler(log *audit.Log) *HistoryHandler {
+	return &HistoryHandler{log: log}
+}
+
+// GetLinkHistory lists the changes of a link, newest first. History outlives the link,
+// so deleted links can still be inspected; only changes made within the caller's
+// workspace are returned, and links without any are reported as not found.
+func (h *HistoryHandler) GetLinkHistory(ctx context.Context, req *LinkHistoryRequest) (*LinkHistoryResponse, error) {
+	entries, err := h.log.LinkHistory(ctx, auth.WorkspaceIDFromContext(ctx), req.Code, req.Limit)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to get history")
+	}
+
+	resp := &LinkHistoryResponse{}
+	resp.Body.Code = req.Code
+	resp.Body.Items = make([]AuditEntryItem, 0, len(entries))
+
+	for _, e := range entries {
+		resp.Body.Items = append(resp.Body.Items, toAuditEntryItem(e))
+	}
+
+	if len(resp.Body.Items) == 0 {
+		return nil, huma.Error404NotFound("short url not found")
+	}
+
+	return resp, nil
+}
+
+func toAuditEntryItem(e *audit.Entry) AuditEntryItem {
+	return AuditEntryItem{
+		Action:    string(e.Action),
+		Actor:     e.Actor,
+		KeyID:     e.KeyID,
+		RequestID: e.RequestID,
+		Before:    e.Before,
+		After:     e.After,
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/audit"
+	auditstore "github.com/serroba/web-demo-go/internal/audit/store"
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type failingAuditStore struct {
+	audit.Store
+}
+
+func (failingAuditStore) LinkHistory(_ context.Context, _, _ string, _ int) ([]*audit.Entry, error) {
+	return nil, assert.AnError
+}
+
+func TestGetLinkHistory(t *testing.T) {
+	auditLog := audit.NewLog(auditstore.NewMemory(), zap.NewNop())
+	repo := audit.NewRepository(store.NewMemoryStore(), auditLog)
+	ctx := workspaceContext("alice", "team-a")
+
+	link := &shortener.ShortURL{Code: "abc", OriginalURL: "https://example.com", WorkspaceID: "team-a"}
+	require.NoError(t, repo.Save(ctx, link))
+
+	updated := *link
+	updated.OriginalURL = "https://example.org"
+	require.NoError(t, repo.Update(ctx, &updated))
+
+	deleted := updated
+	deletedAt := time.Now()
+	deleted.DeletedAt = &deletedAt
+	require.NoError(t, repo.Update(ctx, &deleted))
+
+	handler := handlers.NewHistoryHandler(auditLog)
+
+	t.Run("lists changes newest first, including deletion", func(t *testing.T) {
+		resp, err := handler.GetLinkHistory(ctx, &handlers.LinkHistoryRequest{Code: "abc", Limit: 50})
+
+		require.NoError(t, err)
+		require.Len(t, resp.Body.Items, 3)
+		assert.Equal(t, "link.deleted", resp.Body.Items[0].Action)
+
+		change := resp.Body.Items[1]
+		assert.Equal(t, "link.updated", change.Action)
+		assert.Equal(t, "alice", change.Actor)
+		assert.Equal(t, "https://example.com", change.Before["originalUrl"])
+		assert.Equal(t, "https://example.org", change.After["originalUrl"])
+	})
+
+	t.Run("limits entries", func(t *testing.T) {
+		resp, err := handler.GetLinkHistory(ctx, &handlers.LinkHistoryRequest{Code: "abc", Limit: 1})
+
+		require.NoError(t, err)
+		assert.Len(t, resp.Body.Items, 1)
+	})
+
+	t.Run("returns 404 for links of other workspaces", func(t *testing.T) {
+		_, err := handler.GetLinkHistory(ownerContext("bob"), &handlers.LinkHistoryRequest{Code: "abc", Limit: 50})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("returns 404 for unknown links", func(t *testing.T) {
+		_, err := handler.GetLinkHistory(ctx, &handlers.LinkHistoryRequest{Code: "missing", Limit: 50})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("returns 500 on store errors", func(t *testing.T) {
+		failing := handlers.NewHistoryHandler(audit.NewLog(failingAuditStore{}, zap.NewNop()))
+
+		_, err := failing.GetLinkHistory(ctx, &handlers.LinkHistoryRequest{Code: "abc", Limit: 50})
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// LinkHandler handles link management operations.
+type LinkHandler struct {
+	store         shortener.Repository
+	lister        shortener.Lister
+	stats         analytics.StatsReader
+	blocklist     *blocklist.Blocklist
+	baseURL       string
+	restoreWindow time.Duration
+}
+
+// NewLinkHandler creates a new link handler. Deleted links can be restored
+// for restoreWindow after their deletion.
+func NewLinkHandler(
+	store shortener.Repository,
+	lister shortener.Lister,
+	stats analytics.StatsReader,
+	blocklist *blocklist.Blocklist,
+	baseURL string,
+	restoreWindow time.Duration,
+) *LinkHandler {
+	return &LinkHandler{
+		store:         store,
+		lister:        lister,
+		stats:         stats,
+		blocklist:     blocklist,
+		baseURL:       baseURL,
+		restoreWindow: restoreWindow,
+	}
+}
+
+func (h *LinkHandler) ListLinks(ctx context.Context, req *ListLinksRequest) (*ListLinksResponse, error) {
+	if auth.PrincipalFromContext(ctx) == nil {
+		return nil, huma.Error401Unauthorized("authentication required")
+	}
+
+	query := shortener.ListQuery{
+		CreatedFrom: req.CreatedAfter,
+		CreatedTo:   req.CreatedBefore,
+		Tag:         req.Tag,
+		Domain:      req.Domain,
+		Strategy:    string(req.Strategy),
+		Search:      req.Query,
+		OwnerID:     req.Owner,
+		WorkspaceID: auth.WorkspaceIDFromContext(ctx),
+	}
+
+	return h.listPage(ctx, query, req.Cursor, req.Limit)
+}
+
+// LookupLinks finds the links of the caller's workspace whose destination is
+// equivalent to the given URL, whatever strategy created them.
+func (h *LinkHandler) LookupLinks(ctx context.Context, req *LookupLinksRequest) (*ListLinksResponse, error) {
+	if auth.PrincipalFromContext(ctx) == nil {
+		return nil, huma.Error401Unauthorized("authentication required")
+	}
+
+	hash, err := shortener.HashOf(req.URL)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity("invalid url")
+	}
+
+	query := shortener.ListQuery{
+		URLHash:     hash,
+		WorkspaceID: auth.WorkspaceIDFromContext(ctx),
+	}
+
+	return h.listPage(ctx, query, req.Cursor, req.Limit)
+}
+
+// listPage returns a page of up to limit links matching query, after cursor if set.
+func (h *LinkHandler) listPage(
+	ctx context.Context, query shortener.ListQuery, cursor string, limit int,
+) (*ListLinksResponse, error) {
+	query.Limit = limit + 1 // one extra row tells us whether there is a next page
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, huma.Error400BadRequest("invalid cursor")
+		}
+
+		query.After = after
+	}
+
+	urls, err := h.lister.List(ctx, query)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to list links")
+	}
+
+	resp := &ListLinksResponse{}
+
+	if len(urls) > limit {
+		urls = urls[:limit]
+		last := urls[len(urls)-1]
+		resp.Body.NextCursor = encodeCursor(shortener.Cursor{CreatedAt: last.CreatedAt, Code: last.Code})
+	}
+
+	resp.Body.Items = make([]LinkItem, 0, len(urls))
+	for _, u := range urls {
+		resp.Body.Items = append(resp.Body.Items, h.toLinkItem(u))
+	}
+
+	return resp, nil
+}
+
+func (h *LinkHandler) GetLink(ctx context.Context, req *LinkRequest) (*LinkResponse, error) {
+	shortURL, err := h.readableLink(ctx, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LinkResponse{Body: h.toLinkItem(shortURL)}, nil
+}
+
+func (h *LinkHandler) UpdateLink(ctx context.Context, req *UpdateLinkRequest) (*LinkResponse, error) {
+	// The link is written back whole, so it is read as stored, not as cached
+	ctx = shortener.ContextWithLatestReads(ctx)
+
+	shortURL, err := h.ownedLink(ctx, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *shortURL
+
+	if req.Body.URL != nil && *req.Body.URL != shortURL.OriginalURL {
+		if shortURL.Strategy == shortener.StrategyHash {
+			return nil, huma.Error409Conflict("the destination of a hash-strategy link cannot be changed")
+		}
+
+		if err := checkDestination(ctx, h.blocklist, *req.Body.URL); err != nil {
+			return nil, err
+		}
+
+		updated.OriginalURL = *req.Body.URL
+		updated.URLHash, _ = shortener.HashOf(updated.OriginalURL)
+	}
+
+	if req.Body.Title != nil {
+		updated.Title = *req.Body.Title
+	}
+
+	if req.Body.Tags != nil {
+		updated.Tags = req.Body.Tags
+	}
+
+	if req.Body.Notes != nil {
+		updated.Notes = *req.Body.Notes
+	}
+
+	if err := h.store.Update(ctx, &updated); err != nil {
+		return nil, storeError(err, "failed to update link")
+	}
+
+	return &LinkResponse{Body: h.toLinkItem(&updated)}, nil
+}
+
+// DeleteLink moves a link to the trash. It answers 410 Gone until it is
+// restored, or purged once the restore window has passed.
+func (h *LinkHandler) DeleteLink(ctx context.Context, req *LinkRequest) (*DeleteLinkResponse, error) {
+	ctx = shortener.ContextWithLatestReads(ctx)
+
+	shortURL, err := h.ownedLink(ctx, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := *shortURL
+	now := time.Now()
+	deleted.DeletedAt = &now
+
+	if err := h.store.Update(ctx, &deleted); err != nil {
+		return nil, storeError(err, "failed to delete link")
+	}
+
+	return &DeleteLinkResponse{}, nil
+}
+
+// RestoreLink brings back a deleted link within the restore window.
+func (h *LinkHandler) RestoreLink(ctx context.Context, req *LinkRequest) (*LinkResponse, error) {
+	ctx = shortener.ContextWithLatestReads(ctx)
+
+	shortURL, err := h.workspaceLink(ctx, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOwner(ctx, shortURL); err != nil {
+		return nil, err
+	}
+
+	if !shortURL.Deleted() {
+		return nil, huma.Error409Conflict("link is not deleted")
+	}
+
+	if time.Since(*shortURL.DeletedAt) > h.restoreWindow {
+		return nil, huma.Error410Gone("restore window has expired")
+	}
+
+	restored := *shortURL
+	restored.DeletedAt = nil
+
+	if err := h.store.Update(ctx, &restored); err != nil {
+		if qErr := quotaError(err); qErr != nil {
+			return nil, qErr
+		}
+
+		return nil, storeError(err, "failed to restore link")
+	}
+
+	return &LinkResponse{Body: h.toLinkItem(&restored)}, nil
+}
+
+func (h *LinkHandler) GetLinkStats(ctx context.Context, req *LinkStatsRequest) (*LinkStatsResponse, error) {
+	if _, err := h.readableLink(ctx, req.Code); err != nil {
+		return nil, err
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-req.Days)
+
+	stats, err := h.stats.LinkStats(ctx, req.Code, since)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to get link stats")
+	}
+
+	resp := &LinkStatsResponse{}
+	resp.Body.Code = stats.Code
+	resp.Body.Since = stats.Since
+	resp.Body.TotalClicks = stats.TotalClicks
+	resp.Body.UniqueVisitors = stats.UniqueVisitors
+	resp.Body.LastAccessedAt = stats.LastAccessedAt
+
+	resp.Body.Daily = make([]DailyClicksItem, 0, len(stats.Daily))
+	for _, d := range stats.Daily {
+		resp.Body.Daily = append(resp.Body.Daily, DailyClicksItem{Day: d.Day, Clicks: d.Clicks})
+	}
+
+	return resp, nil
+}
+
+// ownedLink loads a link the caller may change: one they own, or any link in the
+// workspace for workspace admins. Links created anonymously have no owner.
+func (h *LinkHandler) ownedLink(ctx context.Context, code string) (*shortener.ShortURL, error) {
+	shortURL, err := h.readableLink(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOwner(ctx, shortURL); err != nil {
+		return nil, err
+	}
+
+	return shortURL, nil
+}
+
+// checkOwner rejects callers that neither own shortURL nor administer its workspace.
+func checkOwner(ctx context.Context, shortURL *shortener.ShortURL) error {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal.Can(auth.PermissionAdmin) {
+		return nil
+	}
+
+	if shortURL.OwnerID == "" || shortURL.OwnerID != principal.OwnerID {
+		return huma.Error403Forbidden("you do not own this link")
+	}
+
+	return nil
+}
+
+// readableLink loads a link the caller may read: any link shared in their workspace
+// that has not been deleted.
+func (h *LinkHandler) readableLink(ctx context.Context, code string) (*shortener.ShortURL, error) {
+	shortURL, err := h.workspaceLink(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if shortURL.Deleted() {
+		return nil, huma.Error410Gone("short url has been deleted")
+	}
+
+	return shortURL, nil
+}
+
+// workspaceLink loads a link of the caller's workspace, deleted or not, or of
+// any workspace for global admins. Links of other workspaces are reported as
+// not found.
+func (h *LinkHandler) workspaceLink(ctx context.Context, code string) (*shortener.ShortURL, error) {
+	if auth.PrincipalFromContext(ctx) == nil {
+		return nil, huma.Error401Unauthorized("authentication required")
+	}
+
+	shortURL, err := h.store.GetByCode(ctx, shortener.Code(code))
+	if err != nil {
+		return nil, storeError(err, "failed to get link")
+	}
+
+	if shortURL.WorkspaceID != auth.WorkspaceIDFromContext(ctx) && !globalAdmin(ctx) {
+		return nil, huma.Error404NotFound("short url not found")
+	}
+
+	return shortURL, nil
+}
+
+// globalAdmin reports whether the caller administers DefaultWorkspace, and so may
+// read and take down the links of every workspace.
+func globalAdmin(ctx context.Context) bool {
+	return auth.PrincipalFromContext(ctx).Can(auth.PermissionAdmin) &&
+		auth.WorkspaceIDFromContext(ctx) == auth.DefaultWorkspace
+}
+
+// storeError maps repository errors to problem responses.
+func storeError(err error, msg string) error {
+	if errors.Is(err, shortener.ErrNotFound) {
+		return huma.Error404NotFound("short url not found")
+	}
+
+	if errors.Is(err, shortener.ErrDuplicate) {
+		return huma.Error409Conflict("an equivalent hash link already exists")
+	}
+
+	return huma.Error500InternalServerError(msg)
+}
+
+func (h *LinkHandler) toLinkItem(u *shortener.ShortURL) LinkItem {
+	return LinkItem{
+		Code:        string(u.Code),
+		ShortURL:    fmt.Sprintf("%s/%s", h.baseURL, u.Code),
+		OriginalURL: u.OriginalURL,
+		Strategy:    u.Strategy,
+		Title:       u.Title,
+		Description: u.Description,
+		ImageURL:    u.ImageURL,
+		Tags:        u.Tags,
+		Notes:       u.Notes,
+		CreatedAt:   u.CreatedAt,
+		DisabledAt:  u.DisabledAt,
+	}
+}
+
+// encodeCursor serializes a cursor into an opaque, URL-safe token.
+func encodeCursor(c shortener.Cursor) string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + string(c.Code)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token produced by encodeCursor.
+func decodeCursor(token string) (*shortener.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	nanos, code, ok := strings.Cut(string(raw), ":")
+	if !ok || code == "" {
+		return nil, errInvalidCursor
+	}
+
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &shortener.Cursor{CreatedAt: time.Unix(0, ts), Code: shortener.Code(code)}, nil
+}
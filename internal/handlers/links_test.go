@@ -0,0 +1,849 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	blockliststore "github.com/serroba/web-demo-go/internal/blocklist/store"
+	"github.com/serroba/web-demo-go/internal/cache"
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/quota"
+	quotastore "github.com/serroba/web-demo-go/internal/quota/store"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// restoreWindow is how long deleted links can be restored in tests.
+const restoreWindow = 24 * time.Hour
+
+type failingUpdateRepository struct {
+	shortener.Repository
+}
+
+func (failingUpdateRepository) Update(_ context.Context, _ *shortener.ShortURL) error {
+	return errMock
+}
+
+type failingLister struct{}
+
+func (failingLister) List(_ context.Context, _ shortener.ListQuery) ([]*shortener.ShortURL, error) {
+	return nil, errMock
+}
+
+type fakeStats struct {
+	stats *analytics.LinkStats
+	err   error
+	since time.Time
+}
+
+func (f *fakeStats) LinkStats(_ context.Context, code string, since time.Time) (*analytics.LinkStats, error) {
+	f.since = since
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if f.stats == nil {
+		return &analytics.LinkStats{Code: code, Since: since}, nil
+	}
+
+	return f.stats, nil
+}
+
+func newLinkHandler(s *store.MemoryStore) *handlers.LinkHandler {
+	return handlers.NewLinkHandler(s, s, &fakeStats{}, newBlocklist(), "http://localhost:8888", restoreWindow)
+}
+
+// newBlocklist returns an empty in-memory blocklist.
+func newBlocklist() *blocklist.Blocklist {
+	return blocklist.New(blockliststore.NewMemory())
+}
+
+// ownerContext returns a context authenticated as ownerID with the creator role.
+func ownerContext(ownerID string) context.Context {
+	return auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+		OwnerID: ownerID,
+		KeyID:   "key-" + ownerID,
+		Roles:   []auth.Role{auth.RoleCreator},
+	})
+}
+
+// adminContext returns a context authenticated as an admin.
+func adminContext() context.Context {
+	return auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+		OwnerID: "root",
+		Roles:   []auth.Role{auth.RoleAdmin},
+	})
+}
+
+// workspaceContext returns a context authenticated as ownerID with the creator role in workspaceID.
+func workspaceContext(ownerID, workspaceID string) context.Context {
+	return auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+		OwnerID:     ownerID,
+		WorkspaceID: workspaceID,
+		Roles:       []auth.Role{auth.RoleCreator},
+	})
+}
+
+// seedOwnedLink stores a token link owned by ownerID in the default workspace.
+func seedOwnedLink(t *testing.T, s shortener.Repository, code, ownerID string) {
+	t.Helper()
+
+	seedWorkspaceLink(t, s, code, ownerID, auth.DefaultWorkspace)
+}
+
+// cachedOver returns a cache of s holding the current version of a link, which
+// changes made on s directly then leave stale.
+func cachedOver(t *testing.T, s shortener.Repository, code string) shortener.Repository {
+	t.Helper()
+
+	cached := store.NewCachedRepository(s, cache.New(10), store.CachedConfig{})
+
+	_, err := cached.GetByCode(context.Background(), shortener.Code(code))
+	require.NoError(t, err)
+
+	return cached
+}
+
+// seedWorkspaceLink stores a token link owned by ownerID in workspaceID.
+func seedWorkspaceLink(t *testing.T, s shortener.Repository, code, ownerID, workspaceID string) {
+	t.Helper()
+
+	err := s.Save(context.Background(), &shortener.ShortURL{
+		Code:        shortener.Code(code),
+		OriginalURL: testURL,
+		Strategy:    shortener.StrategyToken,
+		OwnerID:     ownerID,
+		WorkspaceID: workspaceID,
+		CreatedAt:   time.Now(),
+	})
+	require.NoError(t, err)
+}
+
+// seedDeletedLink stores a token link owned by ownerID that was deleted deletedAgo.
+func seedDeletedLink(t *testing.T, s shortener.Repository, code, ownerID string, deletedAgo time.Duration) {
+	t.Helper()
+
+	deletedAt := time.Now().Add(-deletedAgo)
+	err := s.Save(context.Background(), &shortener.ShortURL{
+		Code:        shortener.Code(code),
+		OriginalURL: testURL,
+		Strategy:    shortener.StrategyToken,
+		OwnerID:     ownerID,
+		WorkspaceID: auth.DefaultWorkspace,
+		CreatedAt:   time.Now(),
+		DeletedAt:   &deletedAt,
+	})
+	require.NoError(t, err)
+}
+
+func seedLinks(t *testing.T, s *store.MemoryStore, n int) {
+	t.Helper()
+
+	base := time.Now()
+
+	for i := range n {
+		err := s.Save(context.Background(), &shortener.ShortURL{
+			Code:        shortener.Code(string(rune('a' + i))),
+			OriginalURL: testURL,
+			Strategy:    shortener.StrategyToken,
+			WorkspaceID: auth.DefaultWorkspace,
+			CreatedAt:   base.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestListLinks(t *testing.T) {
+	t.Run("paginates with cursor", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedLinks(t, memStore, 5)
+		handler := newLinkHandler(memStore)
+
+		first, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{Limit: 2})
+
+		require.NoError(t, err)
+		require.Len(t, first.Body.Items, 2)
+		assert.Equal(t, "e", first.Body.Items[0].Code)
+		assert.Equal(t, "http://localhost:8888/e", first.Body.Items[0].ShortURL)
+		assert.NotEmpty(t, first.Body.NextCursor)
+
+		second, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{
+			Limit:  2,
+			Cursor: first.Body.NextCursor,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, second.Body.Items, 2)
+		assert.Equal(t, "c", second.Body.Items[0].Code)
+
+		last, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{
+			Limit:  2,
+			Cursor: second.Body.NextCursor,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, last.Body.Items, 1)
+		assert.Empty(t, last.Body.NextCursor)
+	})
+
+	t.Run("passes filters to lister", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedLinks(t, memStore, 2)
+		_ = memStore.Save(context.Background(), &shortener.ShortURL{
+			Code:        "tagged",
+			OriginalURL: "https://other.org/page",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: auth.DefaultWorkspace,
+			Metadata:    shortener.Metadata{Tags: []string{"docs"}},
+		})
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{
+			Limit:    10,
+			Tag:      "docs",
+			Domain:   "other.org",
+			Strategy: handlers.StrategyHash,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, resp.Body.Items, 1)
+		assert.Equal(t, "tagged", resp.Body.Items[0].Code)
+	})
+
+	t.Run("lists links shared in the caller's workspace", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedWorkspaceLink(t, memStore, "mine", "alice", "team-a")
+		seedWorkspaceLink(t, memStore, "teammate", "bob", "team-a")
+		seedWorkspaceLink(t, memStore, "other", "carol", "team-b")
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.ListLinks(workspaceContext("alice", "team-a"), &handlers.ListLinksRequest{Limit: 10})
+
+		require.NoError(t, err)
+		require.Len(t, resp.Body.Items, 2)
+
+		resp, err = handler.ListLinks(workspaceContext("alice", "team-a"), &handlers.ListLinksRequest{
+			Limit: 10,
+			Owner: "alice",
+		})
+
+		require.NoError(t, err)
+		require.Len(t, resp.Body.Items, 1)
+		assert.Equal(t, "mine", resp.Body.Items[0].Code)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		handler := newLinkHandler(store.NewMemoryStore())
+
+		_, err := handler.ListLinks(context.Background(), &handlers.ListLinksRequest{Limit: 10})
+
+		assertStatus(t, err, http.StatusUnauthorized)
+	})
+
+	t.Run("returns empty list", func(t *testing.T) {
+		handler := newLinkHandler(store.NewMemoryStore())
+
+		resp, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{Limit: 10})
+
+		require.NoError(t, err)
+		assert.NotNil(t, resp.Body.Items)
+		assert.Empty(t, resp.Body.Items)
+	})
+
+	t.Run("rejects invalid cursor", func(t *testing.T) {
+		handler := newLinkHandler(store.NewMemoryStore())
+
+		for _, cursor := range []string{"!!!", "bm9jb2xvbg", "YWJjOmNvZGU"} {
+			resp, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{Limit: 10, Cursor: cursor})
+
+			assert.Nil(t, resp)
+			assert.Error(t, err, cursor)
+		}
+	})
+
+	t.Run("returns 500 on lister error", func(t *testing.T) {
+		handler := handlers.NewLinkHandler(
+			store.NewMemoryStore(), failingLister{}, &fakeStats{}, newBlocklist(), "http://localhost:8888", restoreWindow,
+		)
+
+		resp, err := handler.ListLinks(adminContext(), &handlers.ListLinksRequest{Limit: 10})
+
+		assert.Nil(t, resp)
+		assert.Error(t, err)
+	})
+}
+
+func TestLookupLinks(t *testing.T) {
+	shorten := func(t *testing.T, strategy shortener.Strategy, rawURL, workspaceID string) string {
+		t.Helper()
+
+		shortURL, err := strategy.Shorten(context.Background(), rawURL, shortener.WithWorkspace(workspaceID))
+		require.NoError(t, err)
+
+		return string(shortURL.Code)
+	}
+
+	t.Run("finds links of every strategy to an equivalent destination", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		codes := []string{"t1", "h1", "t2", "other", "elsewhere"}
+		generate := func() string {
+			code := codes[0]
+			codes = codes[1:]
+
+			return code
+		}
+		token := shortener.NewTokenStrategy(memStore, generate)
+		hash := shortener.NewHashStrategy(memStore, generate)
+
+		shorten(t, token, "https://example.com/page", auth.DefaultWorkspace)
+		shorten(t, hash, "https://EXAMPLE.com/page/", auth.DefaultWorkspace)
+		shorten(t, token, "https://example.com:443/page", auth.DefaultWorkspace)
+		shorten(t, token, "https://example.com/other", auth.DefaultWorkspace)
+		shorten(t, token, "https://example.com/page", "team-b")
+
+		resp, err := newLinkHandler(memStore).LookupLinks(adminContext(), &handlers.LookupLinksRequest{
+			URL:   "https://example.com/page",
+			Limit: 10,
+		})
+
+		require.NoError(t, err)
+
+		found := make([]string, 0, len(resp.Body.Items))
+		for _, item := range resp.Body.Items {
+			found = append(found, item.Code)
+		}
+
+		assert.ElementsMatch(t, []string{"t1", "h1", "t2"}, found)
+	})
+
+	t.Run("paginates with cursor", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		codes := []string{"a", "b", "c"}
+		token := shortener.NewTokenStrategy(memStore, func() string {
+			code := codes[0]
+			codes = codes[1:]
+
+			return code
+		})
+
+		for range 3 {
+			shorten(t, token, testURL, auth.DefaultWorkspace)
+		}
+
+		handler := newLinkHandler(memStore)
+
+		first, err := handler.LookupLinks(adminContext(), &handlers.LookupLinksRequest{URL: testURL, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, first.Body.Items, 2)
+		require.NotEmpty(t, first.Body.NextCursor)
+
+		second, err := handler.LookupLinks(adminContext(), &handlers.LookupLinksRequest{
+			URL:    testURL,
+			Limit:  2,
+			Cursor: first.Body.NextCursor,
+		})
+		require.NoError(t, err)
+		require.Len(t, second.Body.Items, 1)
+		assert.Empty(t, second.Body.NextCursor)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		_, err := newLinkHandler(store.NewMemoryStore()).LookupLinks(
+			context.Background(), &handlers.LookupLinksRequest{URL: testURL, Limit: 10},
+		)
+
+		assertStatus(t, err, http.StatusUnauthorized)
+	})
+
+	t.Run("rejects urls that cannot be normalized", func(t *testing.T) {
+		_, err := newLinkHandler(store.NewMemoryStore()).LookupLinks(
+			adminContext(), &handlers.LookupLinksRequest{URL: "http://[::1", Limit: 10},
+		)
+
+		assertStatus(t, err, http.StatusUnprocessableEntity)
+	})
+}
+
+func TestGetLink(t *testing.T) {
+	t.Run("returns owned link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.GetLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "mine", resp.Body.Code)
+		assert.Equal(t, "http://localhost:8888/mine", resp.Body.ShortURL)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.GetLink(context.Background(), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusUnauthorized)
+	})
+
+	t.Run("shares links within the workspace", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.GetLink(ownerContext("bob"), &handlers.LinkRequest{Code: "mine"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "mine", resp.Body.Code)
+	})
+
+	t.Run("hides links of other workspaces", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedWorkspaceLink(t, memStore, "theirs", "alice", "team-b")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.GetLink(workspaceContext("alice", "team-a"), &handlers.LinkRequest{Code: "theirs"})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("admins read any link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "anon", "")
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.GetLink(adminContext(), &handlers.LinkRequest{Code: "anon"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "anon", resp.Body.Code)
+	})
+
+	t.Run("returns 404 for unknown code to admins", func(t *testing.T) {
+		handler := newLinkHandler(store.NewMemoryStore())
+
+		_, err := handler.GetLink(adminContext(), &handlers.LinkRequest{Code: "missing"})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("returns 404 for unknown code", func(t *testing.T) {
+		handler := newLinkHandler(store.NewMemoryStore())
+
+		_, err := handler.GetLink(ownerContext("alice"), &handlers.LinkRequest{Code: "missing"})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("returns 500 on store error", func(t *testing.T) {
+		handler := handlers.NewLinkHandler(
+			&mockStore{getByCodeErr: errMock}, failingLister{}, &fakeStats{}, newBlocklist(),
+			"http://localhost:8888", restoreWindow,
+		)
+
+		_, err := handler.GetLink(ownerContext("alice"), &handlers.LinkRequest{Code: "abc123"})
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
+
+func TestUpdateLink(t *testing.T) {
+	t.Run("updates provided fields only", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		title := "New title"
+		newURL := "https://example.com/new"
+		req := &handlers.UpdateLinkRequest{Code: "mine"}
+		req.Body.URL = &newURL
+		req.Body.Title = &title
+		req.Body.Tags = []string{"docs"}
+
+		resp, err := handler.UpdateLink(ownerContext("alice"), req)
+
+		require.NoError(t, err)
+		assert.Equal(t, newURL, resp.Body.OriginalURL)
+		assert.Equal(t, title, resp.Body.Title)
+		assert.Equal(t, []string{"docs"}, resp.Body.Tags)
+
+		stored, err := memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+		assert.Equal(t, newURL, stored.OriginalURL)
+		assert.Equal(t, shortener.URLHash(shortener.HashURL(newURL)), stored.URLHash)
+		assert.Equal(t, "alice", stored.OwnerID)
+	})
+
+	t.Run("does not undo changes missing from the cache", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := handlers.NewLinkHandler(
+			cachedOver(t, memStore, "mine"), memStore, &fakeStats{}, newBlocklist(), "http://localhost:8888", restoreWindow,
+		)
+
+		stored, err := memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+
+		deleted := *stored
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+		require.NoError(t, memStore.Update(context.Background(), &deleted))
+
+		title := "New title"
+		req := &handlers.UpdateLinkRequest{Code: "mine"}
+		req.Body.Title = &title
+
+		_, err = handler.UpdateLink(ownerContext("alice"), req)
+		assertStatus(t, err, http.StatusGone)
+
+		stored, err = memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+		assert.True(t, stored.Deleted())
+		assert.Empty(t, stored.Title)
+	})
+
+	t.Run("rejects changing hash link destination", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		_ = memStore.Save(context.Background(), &shortener.ShortURL{
+			Code:        "hashed",
+			OriginalURL: testURL,
+			URLHash:     "h",
+			Strategy:    shortener.StrategyHash,
+			OwnerID:     "alice",
+			WorkspaceID: auth.DefaultWorkspace,
+		})
+		handler := newLinkHandler(memStore)
+
+		newURL := "https://example.com/other"
+		req := &handlers.UpdateLinkRequest{Code: "hashed"}
+		req.Body.URL = &newURL
+
+		_, err := handler.UpdateLink(ownerContext("alice"), req)
+
+		assertStatus(t, err, http.StatusConflict)
+	})
+
+	t.Run("forbids other owners", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.UpdateLink(ownerContext("bob"), &handlers.UpdateLinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("workspace admins change any link of the workspace", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		title := "Moderated"
+		req := &handlers.UpdateLinkRequest{Code: "mine"}
+		req.Body.Title = &title
+
+		resp, err := handler.UpdateLink(adminContext(), req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Moderated", resp.Body.Title)
+	})
+
+	t.Run("rejects blocked destination", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+
+		blocked := newBlocklist()
+		_, err := blocked.Add(context.Background(), &blocklist.Entry{Domain: "evil.example"})
+		require.NoError(t, err)
+
+		handler := handlers.NewLinkHandler(memStore, memStore, &fakeStats{}, blocked, "http://localhost:8888", restoreWindow)
+
+		newURL := "https://cdn.evil.example/payload"
+		req := &handlers.UpdateLinkRequest{Code: "mine"}
+		req.Body.URL = &newURL
+
+		_, err = handler.UpdateLink(ownerContext("alice"), req)
+
+		assertStatus(t, err, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("returns 500 on update error", func(t *testing.T) {
+		mock := &mockStore{updateErr: errMock}
+		handler := handlers.NewLinkHandler(
+			mock, failingLister{}, &fakeStats{}, newBlocklist(), "http://localhost:8888", restoreWindow,
+		)
+
+		notes := "n"
+		req := &handlers.UpdateLinkRequest{Code: "abc123"}
+		req.Body.Notes = &notes
+
+		_, err := handler.UpdateLink(ownerContext(testOwner), req)
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
+
+func TestDeleteLink(t *testing.T) {
+	t.Run("deletes owned link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.DeleteLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		require.NoError(t, err)
+
+		tombstone, err := memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+		assert.True(t, tombstone.Deleted())
+
+		_, err = handler.GetLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+		assertStatus(t, err, http.StatusGone)
+
+		_, err = handler.DeleteLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+		assertStatus(t, err, http.StatusGone)
+	})
+
+	t.Run("forbids other owners", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.DeleteLink(ownerContext("bob"), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusForbidden)
+
+		_, err = memStore.GetByCode(context.Background(), "mine")
+		require.NoError(t, err)
+	})
+
+	t.Run("returns 500 on delete error", func(t *testing.T) {
+		mock := &mockStore{updateErr: errMock}
+		handler := handlers.NewLinkHandler(
+			mock, failingLister{}, &fakeStats{}, newBlocklist(), "http://localhost:8888", restoreWindow,
+		)
+
+		_, err := handler.DeleteLink(ownerContext(testOwner), &handlers.LinkRequest{Code: "abc123"})
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
+
+func TestRestoreLink(t *testing.T) {
+	t.Run("restores a deleted link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.DeleteLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+		require.NoError(t, err)
+
+		resp, err := handler.RestoreLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "mine", resp.Body.Code)
+
+		_, err = handler.GetLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+		require.NoError(t, err)
+	})
+
+	t.Run("returns 410 once the restore window has passed", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedDeletedLink(t, memStore, "old", "alice", restoreWindow+time.Hour)
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.RestoreLink(ownerContext("alice"), &handlers.LinkRequest{Code: "old"})
+
+		assertStatus(t, err, http.StatusGone)
+	})
+
+	t.Run("returns 409 for links that are not deleted", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.RestoreLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusConflict)
+	})
+
+	t.Run("forbids other owners", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedDeletedLink(t, memStore, "mine", "alice", time.Hour)
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.RestoreLink(ownerContext("bob"), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusForbidden)
+
+		_, err = handler.RestoreLink(adminContext(), &handlers.LinkRequest{Code: "mine"})
+		require.NoError(t, err)
+	})
+
+	t.Run("returns 409 when the destination was shortened again meanwhile", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newLinkHandler(memStore)
+		hash, err := shortener.HashOf(testURL)
+		require.NoError(t, err)
+
+		for _, code := range []string{"mine", "again"} {
+			err = memStore.Save(context.Background(), &shortener.ShortURL{
+				Code:        shortener.Code(code),
+				OriginalURL: testURL,
+				URLHash:     hash,
+				Strategy:    shortener.StrategyHash,
+				OwnerID:     "alice",
+				WorkspaceID: auth.DefaultWorkspace,
+				CreatedAt:   time.Now(),
+			})
+			require.NoError(t, err)
+
+			if code == "mine" {
+				_, err = handler.DeleteLink(ownerContext("alice"), &handlers.LinkRequest{Code: code})
+				require.NoError(t, err)
+			}
+		}
+
+		_, err = handler.RestoreLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusConflict)
+	})
+
+	t.Run("returns 402 when the workspace is out of active links", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{ActiveLinks: 1})
+		repo := quota.NewRepository(memStore, enforcer, zap.NewNop())
+		handler := handlers.NewLinkHandler(
+			repo, memStore, &fakeStats{}, newBlocklist(), "http://localhost:8888", restoreWindow,
+		)
+
+		seedOwnedLink(t, repo, "mine", "alice")
+
+		_, err := handler.DeleteLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+		require.NoError(t, err)
+
+		// The deleted link's active link was freed, and is now taken by another one
+		seedOwnedLink(t, repo, "other", "alice")
+
+		_, err = handler.RestoreLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusPaymentRequired)
+	})
+
+	t.Run("returns 500 on update error", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedDeletedLink(t, memStore, "mine", "alice", time.Hour)
+		handler := handlers.NewLinkHandler(
+			failingUpdateRepository{memStore}, memStore, &fakeStats{}, newBlocklist(),
+			"http://localhost:8888", restoreWindow,
+		)
+
+		_, err := handler.RestoreLink(ownerContext("alice"), &handlers.LinkRequest{Code: "mine"})
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
+
+func TestGetLinkStats(t *testing.T) {
+	t.Run("returns stats for owned link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+
+		day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+		stats := &fakeStats{stats: &analytics.LinkStats{
+			Code:           "mine",
+			TotalClicks:    3,
+			UniqueVisitors: 2,
+			LastAccessedAt: &day,
+			Daily:          []analytics.DailyClicks{{Day: day, Clicks: 3}},
+		}}
+		handler := handlers.NewLinkHandler(memStore, memStore, stats, newBlocklist(), "http://localhost:8888", restoreWindow)
+
+		resp, err := handler.GetLinkStats(ownerContext("alice"), &handlers.LinkStatsRequest{Code: "mine", Days: 7})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), resp.Body.TotalClicks)
+		assert.Equal(t, int64(2), resp.Body.UniqueVisitors)
+		require.Len(t, resp.Body.Daily, 1)
+		assert.Equal(t, int64(3), resp.Body.Daily[0].Clicks)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, -7), stats.since, 48*time.Hour)
+	})
+
+	t.Run("hides stats of other workspaces", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedWorkspaceLink(t, memStore, "theirs", "alice", "team-b")
+		handler := newLinkHandler(memStore)
+
+		_, err := handler.GetLinkStats(workspaceContext("alice", "team-a"),
+			&handlers.LinkStatsRequest{Code: "theirs", Days: 7})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("admins read stats of any link", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.GetLinkStats(adminContext(), &handlers.LinkStatsRequest{Code: "mine", Days: 7})
+
+		require.NoError(t, err)
+		assert.Equal(t, "mine", resp.Body.Code)
+	})
+
+	t.Run("global admins read stats of other workspaces", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedWorkspaceLink(t, memStore, "theirs", "alice", "team-b")
+		handler := newLinkHandler(memStore)
+
+		resp, err := handler.GetLinkStats(adminContext(), &handlers.LinkStatsRequest{Code: "theirs", Days: 7})
+
+		require.NoError(t, err)
+		assert.Equal(t, "theirs", resp.Body.Code)
+	})
+
+	t.Run("workspace admins do not read stats of other workspaces", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedWorkspaceLink(t, memStore, "theirs", "alice", "team-b")
+		handler := newLinkHandler(memStore)
+		ctx := auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+			OwnerID:     "bob",
+			WorkspaceID: "team-a",
+			Roles:       []auth.Role{auth.RoleAdmin},
+		})
+
+		_, err := handler.GetLinkStats(ctx, &handlers.LinkStatsRequest{Code: "theirs", Days: 7})
+
+		assertStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("returns 500 on stats error", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "mine", "alice")
+		stats := &fakeStats{err: errMock}
+		handler := handlers.NewLinkHandler(memStore, memStore, stats, newBlocklist(), "http://localhost:8888", restoreWindow)
+
+		_, err := handler.GetLinkStats(ownerContext("alice"), &handlers.LinkStatsRequest{Code: "mine", Days: 7})
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
+
+func assertStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	var statusErr huma.StatusError
+
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, status, statusErr.GetStatus())
+}
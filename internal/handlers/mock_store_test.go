@@ -4,18 +4,25 @@ import (
 	"context"
 	"errors"
 
+	"github.com/serroba/web-demo-go/internal/auth"
 	"github.com/serroba/web-demo-go/internal/shortener"
 )
 
 var errMock = errors.New("mock error")
 
-const testURL = "https://example.com"
+const (
+	testURL   = "https://example.com"
+	testOwner = "owner"
+)
 
 // mockStore is a test double for shortener.Repository that can be configured to return errors.
 type mockStore struct {
 	saveErr         error
 	getByCodeErr    error
 	getByHashErr    error
+	updateErr       error
+	deleteErr       error
+	deleted         shortener.Code
 	saved           *shortener.ShortURL
 	getByHashResult *shortener.ShortURL
 }
@@ -26,6 +33,18 @@ func (m *mockStore) Save(_ context.Context, shortURL *shortener.ShortURL) error
 	return m.saveErr
 }
 
+func (m *mockStore) SaveOrGet(
+	_ context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	m.saved = shortURL
+
+	if m.saveErr != nil {
+		return nil, false, m.saveErr
+	}
+
+	return shortURL, true, nil
+}
+
 func (m *mockStore) GetByCode(_ context.Context, _ shortener.Code) (*shortener.ShortURL, error) {
 	if m.getByCodeErr != nil {
 		return nil, m.getByCodeErr
@@ -34,13 +53,27 @@ func (m *mockStore) GetByCode(_ context.Context, _ shortener.Code) (*shortener.S
 	return &shortener.ShortURL{
 		Code:        "abc123",
 		OriginalURL: testURL,
+		OwnerID:     testOwner,
+		WorkspaceID: auth.DefaultWorkspace,
 	}, nil
 }
 
-func (m *mockStore) GetByHash(_ context.Context, _ shortener.URLHash) (*shortener.ShortURL, error) {
+func (m *mockStore) GetByHash(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
 	if m.getByHashErr != nil {
 		return nil, m.getByHashErr
 	}
 
 	return m.getByHashResult, nil
 }
+
+func (m *mockStore) Update(_ context.Context, shortURL *shortener.ShortURL) error {
+	m.saved = shortURL
+
+	return m.updateErr
+}
+
+func (m *mockStore) Delete(_ context.Context, code shortener.Code) error {
+	m.deleted = code
+
+	return m.deleteErr
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// ReserveRoutes reserves the first path segment of every operation registered on
+// api, so codes served at the root can never shadow an endpoint. The OpenAPI,
+// docs and schema routes huma serves from config are not operations, so they are
+// reserved from config. Call it after all routes are registered.
+func ReserveRoutes(api huma.API, config huma.Config, reserved *shortener.ReservedWords) {
+	for path := range api.OpenAPI().Paths {
+		reserved.Add(firstSegment(path))
+	}
+
+	if config.OpenAPIPath != "" {
+		for _, suffix := range []string{".json", ".yaml", "-3.0.json", "-3.0.yaml"} {
+			reserved.Add(firstSegment(config.OpenAPIPath + suffix))
+		}
+	}
+
+	reserved.Add(firstSegment(config.DocsPath), firstSegment(config.SchemasPath))
+}
+
+// firstSegment returns the first segment of path, or "" if it is a path parameter.
+func firstSegment(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if strings.HasPrefix(segment, "{") {
+		return ""
+	}
+
+	return segment
+}
@@ -0,0 +1,35 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveRoutes(t *testing.T) {
+	config := huma.DefaultConfig("Test", "1.0.0")
+	api := humachi.New(chi.NewMux(), config)
+	memStore := store.NewMemoryStore()
+
+	handlers.RegisterRoutes(api, newTestHandler(memStore))
+	handlers.RegisterLinkRoutes(api, newLinkHandler(memStore))
+
+	reserved := shortener.NewReservedWords("admin")
+	handlers.ReserveRoutes(api, config, reserved)
+
+	for _, word := range []string{
+		"shorten", "links", "docs", "schemas", "openapi.json", "openapi.yaml", "openapi-3.0.json", "admin",
+	} {
+		assert.True(t, reserved.Reserved(word), word)
+	}
+
+	assert.False(t, reserved.Reserved("{code}"))
+	assert.False(t, reserved.Reserved(""))
+	assert.False(t, reserved.Reserved("abc123"))
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/auth"
 	"github.com/serroba/web-demo-go/internal/ratelimit"
 )
 
@@ -13,12 +14,15 @@ func RegisterRoutes(api huma.API, urlHandler *URLHandler) {
 	// POST /shorten - Create short URL
 	// Uses stricter rate limits for write operations
 	huma.Register(api, huma.Operation{
-		Method:      http.MethodPost,
-		Path:        "/shorten",
-		Summary:     "Create short URL",
-		Description: "Creates a shortened URL using the specified strategy (token or hash).",
-		Tags:        []string{"URLs"},
+		Method:  http.MethodPost,
+		Path:    "/shorten",
+		Summary: "Create short URL",
+		Description: "Creates a shortened URL using the specified strategy (token, hash or words). " +
+			"Fails with 402 when a quota of the workspace or API key is exhausted.",
+		Tags: []string{"URLs"},
 		Metadata: map[string]any{
+			// Anonymous creation is allowed here; the handler enforces the configured policy
+			auth.MetadataKey: auth.OperationAccess{Permission: auth.PermissionWriteLinks, AllowAnonymous: true},
 			ratelimit.MetadataKey: ratelimit.EndpointConfig{
 				Limits: []ratelimit.LimitConfig{
 					{Window: time.Minute, Max: 10},     // 10 per minute
@@ -32,11 +36,12 @@ func RegisterRoutes(api huma.API, urlHandler *URLHandler) {
 	// GET /{code} - Redirect to original URL
 	// Uses relaxed rate limits for high-traffic read operations
 	huma.Register(api, huma.Operation{
-		Method:      http.MethodGet,
-		Path:        "/{code}",
-		Summary:     "Redirect to original URL",
-		Description: "Redirects to the original URL associated with the short code.",
-		Tags:        []string{"URLs"},
+		Method:  http.MethodGet,
+		Path:    "/{code}",
+		Summary: "Redirect to original URL",
+		Description: "Redirects to the original URL associated with the short code. " +
+			"With checked codes enabled, a mistyped code fails with 404 and may carry a suggestion.",
+		Tags: []string{"URLs"},
 		Metadata: map[string]any{
 			ratelimit.MetadataKey: ratelimit.EndpointConfig{
 				Limits: []ratelimit.LimitConfig{
@@ -46,3 +51,195 @@ func RegisterRoutes(api huma.API, urlHandler *URLHandler) {
 		},
 	}, urlHandler.RedirectToURL)
 }
+
+// RegisterLinkRoutes registers link management routes.
+func RegisterLinkRoutes(api huma.API, linkHandler *LinkHandler) {
+	// GET /links - List and search links
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/links",
+		Summary: "List links",
+		Description: "Lists the links of the caller's workspace newest first, filtered by creation date, tag, " +
+			"domain, strategy, owner or free text.",
+		Tags:     []string{"Links"},
+		Metadata: access(auth.PermissionReadLinks),
+	}, linkHandler.ListLinks)
+
+	// GET /lookup - Find the links to a destination
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/lookup",
+		Summary: "Look up links by destination",
+		Description: "Lists the links of the caller's workspace newest first whose destination is equivalent " +
+			"to the given URL, created with any strategy.",
+		Tags:     []string{"Links"},
+		Metadata: access(auth.PermissionReadLinks),
+	}, linkHandler.LookupLinks)
+}
+
+// RegisterLinkOwnerRoutes registers routes for managing a single link.
+// Links are shared within their workspace: members may read any of them, while changes
+// are restricted to the link's owner and workspace admins.
+func RegisterLinkOwnerRoutes(api huma.API, linkHandler *LinkHandler) {
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/links/{code}",
+		Summary:     "Get link",
+		Description: "Returns a link and its metadata.",
+		Tags:        []string{"Links"},
+		Metadata:    access(auth.PermissionReadLinks),
+	}, linkHandler.GetLink)
+
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPatch,
+		Path:        "/links/{code}",
+		Summary:     "Update link",
+		Description: "Changes the destination, title, tags or notes of a link. Restricted to the link's owner and admins.",
+		Tags:        []string{"Links"},
+		Metadata:    access(auth.PermissionWriteLinks),
+	}, linkHandler.UpdateLink)
+
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodDelete,
+		Path:    "/links/{code}",
+		Summary: "Delete link",
+		Description: "Deletes a link. It answers 410 Gone and can be restored until the restore window passes, " +
+			"after which it is purged. Restricted to the link's owner and admins.",
+		Tags:          []string{"Links"},
+		DefaultStatus: http.StatusNoContent,
+		Metadata:      access(auth.PermissionWriteLinks),
+	}, linkHandler.DeleteLink)
+
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/links/{code}/restore",
+		Summary:     "Restore link",
+		Description: "Restores a deleted link within the restore window. Restricted to the link's owner and admins.",
+		Tags:        []string{"Links"},
+		Metadata:    access(auth.PermissionWriteLinks),
+	}, linkHandler.RestoreLink)
+
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/links/{code}/stats",
+		Summary:     "Get link stats",
+		Description: "Returns access statistics for a link.",
+		Tags:        []string{"Links"},
+		Metadata:    access(auth.PermissionReadLinks),
+	}, linkHandler.GetLinkStats)
+}
+
+// RegisterAdminRoutes registers admin-only routes for takedowns and the domain blocklist.
+// They affect every workspace, so they require the admin role in the default workspace.
+func RegisterAdminRoutes(api huma.API, adminHandler *AdminHandler) {
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/admin/links/{code}/disable",
+		Summary:     "Disable link",
+		Description: "Takes a link down. Redirects to it are refused until it is enabled again.",
+		Tags:        []string{"Admin"},
+		Metadata:    globalAccess(auth.PermissionAdmin),
+	}, adminHandler.DisableLink)
+
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/admin/links/{code}/enable",
+		Summary:     "Enable link",
+		Description: "Restores a link that was taken down.",
+		Tags:        []string{"Admin"},
+		Metadata:    globalAccess(auth.PermissionAdmin),
+	}, adminHandler.EnableLink)
+
+	huma.Register(api, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/admin/blocklist",
+		Summary:     "List blocked domains",
+		Description: "Lists destination domains that links may not point at.",
+		Tags:        []string{"Admin"},
+		Metadata:    globalAccess(auth.PermissionAdmin),
+	}, adminHandler.ListBlockedDomains)
+
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodPost,
+		Path:          "/admin/blocklist",
+		Summary:       "Block domain",
+		Description:   "Blocks a destination domain and its subdomains for new and updated links.",
+		Tags:          []string{"Admin"},
+		DefaultStatus: http.StatusCreated,
+		Metadata:      globalAccess(auth.PermissionAdmin),
+	}, adminHandler.BlockDomain)
+
+	huma.Register(api, huma.Operation{
+		Method:        http.MethodDelete,
+		Path:          "/admin/blocklist/{domain}",
+		Summary:       "Unblock domain",
+		Description:   "Removes a domain from the blocklist.",
+		Tags:          []string{"Admin"},
+		DefaultStatus: http.StatusNoContent,
+		Metadata:      globalAccess(auth.PermissionAdmin),
+	}, adminHandler.UnblockDomain)
+}
+
+// importMaxBodyBytes bounds the size of an import; larger ones can go through the CLI.
+const importMaxBodyBytes = 64 << 20
+
+// RegisterTransferRoutes registers admin-only routes for exporting and importing links.
+func RegisterTransferRoutes(api huma.API, transferHandler *TransferHandler) {
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/admin/links/export",
+		Summary: "Export links",
+		Description: "Streams every live link with its metadata as CSV or JSONL, newest first, " +
+			"optionally restricted to one workspace.",
+		Tags:     []string{"Admin"},
+		Metadata: globalAccess(auth.PermissionAdmin),
+	}, transferHandler.ExportLinks)
+
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodPost,
+		Path:    "/admin/links/import",
+		Summary: "Import links",
+		Description: "Stores the links of a CSV or JSONL body, validated like links created through the API. " +
+			"Taken codes are skipped, overwritten or stop the import (409) depending on the conflict policy. " +
+			"Invalid rows are reported without stopping the import.",
+		Tags:         []string{"Admin"},
+		MaxBodyBytes: importMaxBodyBytes,
+		Metadata:     globalAccess(auth.PermissionAdmin),
+	}, transferHandler.ImportLinks)
+}
+
+// RegisterHistoryRoutes registers the link audit trail route.
+func RegisterHistoryRoutes(api huma.API, historyHandler *HistoryHandler) {
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/{code}/history",
+		Summary: "Get link history",
+		Description: "Lists who changed a link and when, newest first, with the link before and after each change. " +
+			"History remains available after the link is deleted.",
+		Tags:     []string{"Links"},
+		Metadata: access(auth.PermissionReadLinks),
+	}, historyHandler.GetLinkHistory)
+}
+
+// RegisterUsageRoutes registers the quota usage route.
+func RegisterUsageRoutes(api huma.API, usageHandler *UsageHandler) {
+	huma.Register(api, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/usage",
+		Summary: "Get usage",
+		Description: "Reports link creation quota usage of the caller's workspace and, for API keys, " +
+			"of the calling key.",
+		Tags:     []string{"Usage"},
+		Metadata: access(auth.PermissionReadLinks),
+	}, usageHandler.GetUsage)
+}
+
+// globalAccess builds metadata for operations that affect every workspace.
+func globalAccess(perm auth.Permission) map[string]any {
+	return map[string]any{auth.MetadataKey: auth.OperationAccess{Permission: perm, Global: true}}
+}
+
+// access builds operation metadata requiring perm from authenticated callers.
+func access(perm auth.Permission) map[string]any {
+	return map[string]any{auth.MetadataKey: auth.OperationAccess{Permission: perm}}
+}
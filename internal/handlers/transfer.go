@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/transfer"
+	"go.uber.org/zap"
+)
+
+// TransferHandler handles bulk export and import of links.
+type TransferHandler struct {
+	lister   shortener.Lister
+	importer *transfer.Importer
+	logger   *zap.Logger
+}
+
+// NewTransferHandler creates a new transfer handler.
+func NewTransferHandler(lister shortener.Lister, importer *transfer.Importer, logger *zap.Logger) *TransferHandler {
+	return &TransferHandler{
+		lister:   lister,
+		importer: importer,
+		logger:   logger,
+	}
+}
+
+// ExportLinks streams every live link, or those of one workspace, as CSV or JSONL.
+func (h *TransferHandler) ExportLinks(_ context.Context, req *ExportLinksRequest) (*huma.StreamResponse, error) {
+	format := transfer.Format(req.Format)
+	query := shortener.ListQuery{WorkspaceID: req.Workspace}
+
+	return &huma.StreamResponse{
+		Body: func(ctx huma.Context) {
+			ctx.SetHeader("Content-Type", format.ContentType())
+			ctx.SetHeader("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+
+			enc, err := transfer.NewEncoder(ctx.BodyWriter(), format)
+			if err != nil {
+				h.logger.Error("failed to export links", zap.Error(err))
+
+				return
+			}
+
+			// The status is sent with the first page, so a failure can only cut the export short
+			if _, err := transfer.Export(ctx.Context(), h.lister, query, enc); err != nil {
+				h.logger.Error("failed to export links", zap.Error(err))
+			}
+		},
+	}, nil
+}
+
+// ImportLinks stores the links of a CSV or JSONL body and reports how each row went.
+func (h *TransferHandler) ImportLinks(ctx context.Context, req *ImportLinksRequest) (*ImportLinksResponse, error) {
+	conflict, err := transfer.ParseConflictPolicy(req.Conflict)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	dec, err := transfer.NewDecoder(bytes.NewReader(req.RawBody), transfer.Format(req.Format))
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	report, err := h.importer.Import(ctx, dec, transfer.Options{
+		Conflict:       conflict,
+		DryRun:         req.DryRun,
+		SuppressEvents: req.SuppressEvents,
+		Progress: func(r transfer.Report) {
+			h.logger.Info("importing links",
+				zap.Int("rows", r.Rows),
+				zap.Int("created", r.Created),
+				zap.Int("failed", r.Failed),
+			)
+		},
+	})
+
+	var conflictErr *transfer.ConflictError
+
+	switch {
+	case errors.As(err, &conflictErr):
+		return nil, huma.Error409Conflict(
+			fmt.Sprintf("%s, import stopped after creating %d links", conflictErr, report.Created))
+	case errors.Is(err, transfer.ErrInvalidHeader):
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	case err != nil:
+		h.logger.Error("failed to import links", zap.Int("rows", report.Rows), zap.Error(err))
+
+		return nil, huma.Error500InternalServerError(
+			fmt.Sprintf("failed to import links, stopped after creating %d links", report.Created))
+	}
+
+	return toImportLinksResponse(report, req.DryRun), nil
+}
+
+func toImportLinksResponse(report *transfer.Report, dryRun bool) *ImportLinksResponse {
+	resp := &ImportLinksResponse{}
+	resp.Body.DryRun = dryRun
+	resp.Body.Rows = report.Rows
+	resp.Body.Created = report.Created
+	resp.Body.Overwritten = report.Overwritten
+	resp.Body.Skipped = report.Skipped
+	resp.Body.Demoted = report.Demoted
+	resp.Body.Failed = report.Failed
+
+	for _, e := range report.Errors {
+		resp.Body.Errors = append(resp.Body.Errors, ImportErrorItem{Line: e.Line, Code: e.Code, Message: e.Message})
+	}
+
+	return resp
+}
@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/checkcode"
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/serroba/web-demo-go/internal/transfer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTransferHandler(memStore *store.MemoryStore) *handlers.TransferHandler {
+	publish := func(_ *analytics.URLCreatedEvent) error { return nil }
+	importer := transfer.NewImporter(memStore, newBlocklist(), shortener.NewReservedWords(), checkcode.Scope{}, publish,
+		zap.NewNop())
+
+	return handlers.NewTransferHandler(memStore, importer, zap.NewNop())
+}
+
+func TestTransferHandler_ExportLinks(t *testing.T) {
+	memStore := store.NewMemoryStore()
+	seedOwnedLink(t, memStore, "mine", "alice")
+	handler := newTransferHandler(memStore)
+
+	resp, err := handler.ExportLinks(adminContext(), &handlers.ExportLinksRequest{Format: "csv"})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	resp.Body(humatest.NewContext(nil, httptest.NewRequest(http.MethodGet, "/admin/links/export", nil), rec))
+
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], "mine,"+testURL+",token,alice,"))
+}
+
+func TestTransferHandler_ImportLinks(t *testing.T) {
+	importRequest := func(body, conflict string, dryRun bool) *handlers.ImportLinksRequest {
+		return &handlers.ImportLinksRequest{
+			Format:   "csv",
+			Conflict: conflict,
+			DryRun:   dryRun,
+			RawBody:  []byte(body),
+		}
+	}
+
+	t.Run("imports links and reports failed rows", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newTransferHandler(memStore)
+
+		resp, err := handler.ImportLinks(adminContext(), importRequest(
+			"code,originalUrl\nabc,https://example.com\nbad code,https://example.com\n", "fail", false))
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, resp.Body.Rows)
+		assert.Equal(t, 1, resp.Body.Created)
+		assert.Equal(t, 1, resp.Body.Failed)
+		require.Len(t, resp.Body.Errors, 1)
+		assert.Equal(t, 3, resp.Body.Errors[0].Line)
+
+		_, err = memStore.GetByCode(context.Background(), "abc")
+		require.NoError(t, err)
+	})
+
+	t.Run("returns 409 when a taken code stops the import", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		seedOwnedLink(t, memStore, "abc", "alice")
+		handler := newTransferHandler(memStore)
+
+		_, err := handler.ImportLinks(adminContext(), importRequest(
+			"code,originalUrl\nabc,https://example.com\n", "fail", false))
+
+		assertStatus(t, err, http.StatusConflict)
+	})
+
+	t.Run("returns 422 for a header without required columns", func(t *testing.T) {
+		handler := newTransferHandler(store.NewMemoryStore())
+
+		_, err := handler.ImportLinks(adminContext(), importRequest("url\nhttps://example.com\n", "skip", false))
+
+		assertStatus(t, err, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("stores nothing in a dry run", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newTransferHandler(memStore)
+
+		resp, err := handler.ImportLinks(adminContext(), importRequest(
+			"code,originalUrl,createdAt\nabc,https://example.com,"+time.Now().Format(time.RFC3339)+"\n", "fail", true))
+
+		require.NoError(t, err)
+		assert.True(t, resp.Body.DryRun)
+		assert.Equal(t, 1, resp.Body.Created)
+
+		_, err = memStore.GetByCode(context.Background(), "abc")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+}
@@ -1,5 +1,7 @@
 package handlers
 
+import "time"
+
 // Strategy defines the URL shortening strategy.
 type Strategy string
 
@@ -8,13 +10,18 @@ const (
 	StrategyToken Strategy = "token"
 	// StrategyHash deduplicates by URL content - same URL returns same code.
 	StrategyHash Strategy = "hash"
+	// StrategyWords always generates a new human-readable code, such as brave-otter-42.
+	StrategyWords Strategy = "words"
 )
 
 // CreateShortURLRequest is the request body for creating a short URL.
 type CreateShortURLRequest struct {
 	Body struct {
-		URL      string   `doc:"The URL to shorten" format:"uri"   json:"url"`
-		Strategy Strategy `default:"token"          doc:"Strategy" enum:"token,hash" json:"strategy"`
+		URL      string   `doc:"The URL to shorten"         format:"uri"           json:"url"`
+		Strategy Strategy `default:"token"                  doc:"Strategy"         enum:"token,hash,words" json:"strategy"`
+		Title    string   `doc:"Title (fetched if omitted)" json:"title,omitempty" maxLength:"512"`
+		Tags     []string `doc:"Free-form tags"             json:"tags,omitempty"  maxItems:"20"`
+		Notes    string   `doc:"Free-form notes"            json:"notes,omitempty" maxLength:"2000"`
 	}
 }
 
@@ -24,9 +31,12 @@ type CreateShortURLResponse struct {
 		Location string `doc:"The short URL location" header:"Location"`
 	}
 	Body struct {
-		Code        string `doc:"The short code"     example:"abc123"                             json:"code"`
-		ShortURL    string `doc:"The full short URL" example:"http://localhost:8888/abc123"       json:"shortUrl"`
-		OriginalURL string `doc:"The original URL"   example:"https://example.com/very/long/path" json:"originalUrl"`
+		Code        string   `doc:"The short code"     example:"abc123"                             json:"code"`
+		ShortURL    string   `doc:"The full short URL" example:"http://localhost:8888/abc123"       json:"shortUrl"`
+		OriginalURL string   `doc:"The original URL"   example:"https://example.com/very/long/path" json:"originalUrl"`
+		Title       string   `doc:"The link title"     json:"title,omitempty"`
+		Tags        []string `doc:"The link tags"      json:"tags,omitempty"`
+		Notes       string   `doc:"The link notes"     json:"notes,omitempty"`
 	}
 }
 
@@ -42,3 +52,221 @@ type RedirectResponse struct {
 		Location string `doc:"The original URL to redirect to" header:"Location"`
 	}
 }
+
+// ListLinksRequest is the request for listing links.
+type ListLinksRequest struct {
+	CreatedAfter  time.Time `doc:"Created at or after"   query:"createdAfter"`
+	CreatedBefore time.Time `doc:"Created before"        query:"createdBefore"`
+	Tag           string    `doc:"Has this tag"          query:"tag"`
+	Domain        string    `doc:"Destination host"      example:"example.com"   query:"domain"`
+	Strategy      Strategy  `doc:"Creation strategy"     enum:"token,hash,words" query:"strategy"`
+	Query         string    `doc:"Search URL and title"  query:"q"`
+	Owner         string    `doc:"Created by this owner" query:"owner"`
+	Cursor        string    `doc:"Pagination cursor"     query:"cursor"`
+	Limit         int       `default:"20"                doc:"Page size"         maximum:"100" minimum:"1" query:"limit"`
+}
+
+// LookupLinksRequest is the request for finding the links to a destination.
+type LookupLinksRequest struct {
+	URL    string `doc:"Destination to look up" format:"uri"    query:"url"   required:"true"`
+	Cursor string `doc:"Pagination cursor"      query:"cursor"`
+	Limit  int    `default:"20"                 doc:"Page size" maximum:"100" minimum:"1" query:"limit"`
+}
+
+// LinkItem describes a single link in a listing.
+type LinkItem struct {
+	Code        string     `doc:"The short code"               json:"code"`
+	ShortURL    string     `doc:"The full short URL"           json:"shortUrl"`
+	OriginalURL string     `doc:"The original URL"             json:"originalUrl"`
+	Strategy    string     `doc:"The strategy that created it" json:"strategy"`
+	Title       string     `doc:"The link title"               json:"title,omitempty"`
+	Description string     `doc:"The page description"         json:"description,omitempty"`
+	ImageURL    string     `doc:"The page preview image"       json:"imageUrl,omitempty"`
+	Tags        []string   `doc:"The link tags"                json:"tags,omitempty"`
+	Notes       string     `doc:"The link notes"               json:"notes,omitempty"`
+	CreatedAt   time.Time  `doc:"When the link was created"    json:"createdAt"`
+	DisabledAt  *time.Time `doc:"When the link was taken down" json:"disabledAt,omitempty"`
+}
+
+// ListLinksResponse is a page of links, newest first.
+type ListLinksResponse struct {
+	Body struct {
+		Items      []LinkItem `doc:"Links on this page"                           json:"items"`
+		NextCursor string     `doc:"Cursor for the next page, absent on the last" json:"nextCursor,omitempty"`
+	}
+}
+
+// LinkRequest identifies a link by its code.
+type LinkRequest struct {
+	Code string `doc:"The short code" example:"abc123" path:"code"`
+}
+
+// LinkResponse describes a single link.
+type LinkResponse struct {
+	Body LinkItem
+}
+
+// UpdateLinkRequest is the request for changing a link. Omitted fields are left unchanged.
+type UpdateLinkRequest struct {
+	Code string `doc:"The short code" example:"abc123" path:"code"`
+	Body struct {
+		URL   *string  `doc:"New destination URL" format:"uri"           json:"url,omitempty"`
+		Title *string  `doc:"New title"           json:"title,omitempty" maxLength:"512"`
+		Tags  []string `doc:"New tags"            json:"tags,omitempty"  maxItems:"20"`
+		Notes *string  `doc:"New notes"           json:"notes,omitempty" maxLength:"2000"`
+	}
+}
+
+// DeleteLinkResponse is the empty response for a link moved to the trash.
+type DeleteLinkResponse struct{}
+
+// LinkStatsRequest is the request for a link's access statistics.
+type LinkStatsRequest struct {
+	Code string `doc:"The short code" example:"abc123"                path:"code"`
+	Days int    `default:"30"         doc:"Number of days to include" maximum:"365" minimum:"1" query:"days"`
+}
+
+// DailyClicksItem is the number of accesses on one day.
+type DailyClicksItem struct {
+	Day    time.Time `doc:"Start of the day (UTC)" json:"day"`
+	Clicks int64     `doc:"Accesses on the day"    json:"clicks"`
+}
+
+// LinkStatsResponse summarizes a link's accesses.
+type LinkStatsResponse struct {
+	Body struct {
+		Code           string            `doc:"The short code"                 json:"code"`
+		Since          time.Time         `doc:"Start of the reporting period"  json:"since"`
+		TotalClicks    int64             `doc:"Accesses in the period"         json:"totalClicks"`
+		UniqueVisitors int64             `doc:"Distinct client IPs"            json:"uniqueVisitors"`
+		LastAccessedAt *time.Time        `doc:"Most recent access"             json:"lastAccessedAt,omitempty"`
+		Daily          []DailyClicksItem `doc:"Accesses per day, oldest first" json:"daily"`
+	}
+}
+
+// BlockedDomainItem describes a blocked destination domain.
+type BlockedDomainItem struct {
+	Domain    string    `doc:"The blocked domain, including its subdomains" example:"malware.example" json:"domain"`
+	Reason    string    `doc:"Why the domain is blocked"                    json:"reason,omitempty"`
+	CreatedBy string    `doc:"Owner ID of the admin who blocked it"         json:"createdBy,omitempty"`
+	CreatedAt time.Time `doc:"When the domain was blocked"                  json:"createdAt"`
+}
+
+// ListBlockedDomainsResponse lists all blocked domains.
+type ListBlockedDomainsResponse struct {
+	Body struct {
+		Items []BlockedDomainItem `json:"items"`
+	}
+}
+
+// BlockDomainRequest adds a domain to the blocklist.
+type BlockDomainRequest struct {
+	Body struct {
+		Domain string `doc:"Domain to block"           example:"malware.example" json:"domain" maxLength:"253" minLength:"1"`
+		Reason string `doc:"Why the domain is blocked" json:"reason,omitempty"   maxLength:"512"`
+	}
+}
+
+// BlockDomainResponse is the stored blocklist entry.
+type BlockDomainResponse struct {
+	Body BlockedDomainItem
+}
+
+// UnblockDomainRequest removes a domain from the blocklist.
+type UnblockDomainRequest struct {
+	Domain string `doc:"The blocked domain" path:"domain"`
+}
+
+// UnblockDomainResponse is the empty 204 response for removing a blocked domain.
+type UnblockDomainResponse struct{}
+
+// MeterItem reports usage of one quota against its limit.
+type MeterItem struct {
+	Used     int64      `doc:"Usage so far"                                    json:"used"`
+	Limit    int64      `doc:"Maximum allowed, 0 when unlimited"               json:"limit"`
+	ResetsAt *time.Time `doc:"When usage starts over, absent if it never does" json:"resetsAt,omitempty"`
+}
+
+// UsageItem reports the quota usage of a workspace or API key.
+type UsageItem struct {
+	Subject          string     `doc:"The workspace or API key"                        json:"subject"`
+	ActiveLinks      *MeterItem `doc:"Links that currently exist, absent for API keys" json:"activeLinks,omitempty"`
+	MonthlyCreations MeterItem  `doc:"Links created this calendar month (UTC)"         json:"monthlyCreations"`
+}
+
+// UsageResponse reports the caller's quota usage.
+type UsageResponse struct {
+	Body struct {
+		Workspace UsageItem  `doc:"Usage of the caller's workspace"                        json:"workspace"`
+		APIKey    *UsageItem `doc:"Usage of the calling API key, absent for other callers" json:"apiKey,omitempty"`
+	}
+}
+
+// QuotaItem describes the quota a request exceeded.
+type QuotaItem struct {
+	Subject  string     `doc:"The workspace or API key"   json:"subject"`
+	Kind     string     `doc:"The exhausted quota"        enum:"activeLinks,monthlyCreations" json:"kind"`
+	Limit    int64      `doc:"Maximum allowed"            json:"limit"`
+	ResetsAt *time.Time `doc:"When the quota starts over" json:"resetsAt,omitempty"`
+}
+
+// LinkHistoryRequest is the request for a link's change history.
+type LinkHistoryRequest struct {
+	Code  string `doc:"The short code" example:"abc123"                path:"code"`
+	Limit int    `default:"50"         doc:"Maximum number of entries" maximum:"200" minimum:"1" query:"limit"`
+}
+
+// AuditEntryItem is one recorded change of a link.
+type AuditEntryItem struct {
+	Action    string         `doc:"What changed"                              example:"link.updated" json:"action"`
+	Actor     string         `doc:"Owner ID of who made the change, if known" json:"actor,omitempty"`
+	KeyID     string         `doc:"API key used for the change"               json:"keyId,omitempty"`
+	RequestID string         `doc:"ID of the request"                         json:"requestId,omitempty"`
+	Before    map[string]any `doc:"The link before the change"                json:"before,omitempty"`
+	After     map[string]any `doc:"The link after the change"                 json:"after,omitempty"`
+	CreatedAt time.Time      `doc:"When the change was made"                  json:"createdAt"`
+}
+
+// LinkHistoryResponse lists the changes of a link, newest first.
+type LinkHistoryResponse struct {
+	Body struct {
+		Code  string           `doc:"The short code"        json:"code"`
+		Items []AuditEntryItem `doc:"Changes, newest first" json:"items"`
+	}
+}
+
+// ExportLinksRequest is the request for exporting links.
+type ExportLinksRequest struct {
+	Format    string `default:"jsonl"           doc:"Export format" enum:"csv,jsonl" query:"format"`
+	Workspace string `doc:"Only this workspace" query:"workspace"`
+}
+
+// ImportLinksRequest is the request for importing links from an export or another shortener.
+type ImportLinksRequest struct {
+	Format         string `default:"jsonl" doc:"Import format"            enum:"csv,jsonl"           query:"format"`
+	Conflict       string `default:"fail"  doc:"Policy for taken codes"   enum:"skip,overwrite,fail" query:"conflict"`
+	DryRun         bool   `default:"false" doc:"Validate without storing" query:"dryRun"`
+	SuppressEvents bool   `default:"false" doc:"Skip url.created events"  query:"suppressEvents"`
+	RawBody        []byte
+}
+
+// ImportErrorItem describes a row that could not be imported.
+type ImportErrorItem struct {
+	Line    int    `doc:"Line the row starts on"    json:"line"`
+	Code    string `doc:"The short code of the row" json:"code,omitempty"`
+	Message string `doc:"Why the row failed"        json:"message"`
+}
+
+// ImportLinksResponse tallies the rows of an import, or of what it would do in a dry run.
+type ImportLinksResponse struct {
+	Body struct {
+		DryRun      bool              `doc:"Whether nothing was stored"       json:"dryRun"`
+		Rows        int               `doc:"Rows read"                        json:"rows"`
+		Created     int               `doc:"Links created"                    json:"created"`
+		Overwritten int               `doc:"Links replaced"                   json:"overwritten"`
+		Skipped     int               `doc:"Rows skipped"                     json:"skipped"`
+		Demoted     int               `doc:"Hash links stored as token links" json:"demoted"`
+		Failed      int               `doc:"Rows that could not be imported"  json:"failed"`
+		Errors      []ImportErrorItem `doc:"The first rows that failed"       json:"errors,omitempty"`
+	}
+}
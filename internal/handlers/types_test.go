@@ -0,0 +1,35 @@
+package handlers_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMetadataLimits checks that the validation tags of requests setting the
+// metadata of links repeat the limits of imports.
+func TestMetadataLimits(t *testing.T) {
+	limits := map[string]struct {
+		tag   string
+		limit int
+	}{
+		"Title": {tag: "maxLength", limit: shortener.MaxTitleLength},
+		"Notes": {tag: "maxLength", limit: shortener.MaxNotesLength},
+		"Tags":  {tag: "maxItems", limit: shortener.MaxTags},
+	}
+
+	for _, request := range []any{handlers.CreateShortURLRequest{}, handlers.UpdateLinkRequest{}} {
+		body, _ := reflect.TypeOf(request).FieldByName("Body")
+
+		for name, want := range limits {
+			field, ok := body.Type.FieldByName(name)
+			if assert.True(t, ok, "%T has no %s", request, name) {
+				assert.Equal(t, strconv.Itoa(want.limit), field.Tag.Get(want.tag), "%T.%s", request, name)
+			}
+		}
+	}
+}
@@ -9,6 +9,9 @@ import (
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/checkcode"
 	"github.com/serroba/web-demo-go/internal/messaging"
 	"github.com/serroba/web-demo-go/internal/shortener"
 	"go.uber.org/zap"
@@ -20,16 +23,24 @@ type URLHandler struct {
 	store              shortener.Repository
 	baseURL            string
 	defaultStrategy    Strategy
+	allowAnonymous     bool
+	checkedCodes       checkcode.Scope
+	blocklist          *blocklist.Blocklist
 	publishURLCreated  messaging.Publish[analytics.URLCreatedEvent]
 	publishURLAccessed messaging.Publish[analytics.URLAccessedEvent]
 	logger             *zap.Logger
 }
 
-// NewURLHandler creates a new URL handler with injected strategies.
+// NewURLHandler creates a new URL handler with injected strategies. Codes of
+// checkedCodes that fail their check are rejected as typos before they reach the
+// store.
 func NewURLHandler(
 	store shortener.Repository,
 	baseURL string,
 	strategies map[Strategy]shortener.Strategy,
+	allowAnonymous bool,
+	checkedCodes checkcode.Scope,
+	blocklist *blocklist.Blocklist,
 	publishURLCreated messaging.Publish[analytics.URLCreatedEvent],
 	publishURLAccessed messaging.Publish[analytics.URLAccessedEvent],
 	logger *zap.Logger,
@@ -39,6 +50,9 @@ func NewURLHandler(
 		store:              store,
 		baseURL:            baseURL,
 		defaultStrategy:    StrategyToken,
+		allowAnonymous:     allowAnonymous,
+		checkedCodes:       checkedCodes,
+		blocklist:          blocklist,
 		publishURLCreated:  publishURLCreated,
 		publishURLAccessed: publishURLAccessed,
 		logger:             logger,
@@ -69,6 +83,11 @@ func RequestMetaFromContext(ctx context.Context) RequestMeta {
 }
 
 func (h *URLHandler) CreateShortURL(ctx context.Context, req *CreateShortURLRequest) (*CreateShortURLResponse, error) {
+	ownerID := auth.OwnerIDFromContext(ctx)
+	if ownerID == "" && !h.allowAnonymous {
+		return nil, huma.Error401Unauthorized("authentication required")
+	}
+
 	strategyName := req.Body.Strategy
 	if strategyName == "" {
 		strategyName = h.defaultStrategy
@@ -76,11 +95,23 @@ func (h *URLHandler) CreateShortURL(ctx context.Context, req *CreateShortURLRequ
 
 	strategy, ok := h.strategies[strategyName]
 	if !ok {
-		return nil, huma.Error400BadRequest("invalid strategy: must be 'token' or 'hash'")
+		return nil, huma.Error400BadRequest("invalid strategy: must be 'token', 'hash' or 'words'")
+	}
+
+	if err := checkDestination(ctx, h.blocklist, req.Body.URL); err != nil {
+		return nil, err
 	}
 
-	shortURL, err := strategy.Shorten(ctx, req.Body.URL)
+	shortURL, err := strategy.Shorten(ctx, req.Body.URL, shortener.WithMetadata(shortener.Metadata{
+		Title: req.Body.Title,
+		Tags:  req.Body.Tags,
+		Notes: req.Body.Notes,
+	}), shortener.WithOwner(ownerID), shortener.WithWorkspace(auth.WorkspaceIDFromContext(ctx)))
 	if err != nil {
+		if qErr := quotaError(err); qErr != nil {
+			return nil, qErr
+		}
+
 		return nil, huma.Error500InternalServerError("failed to save url")
 	}
 
@@ -91,6 +122,7 @@ func (h *URLHandler) CreateShortURL(ctx context.Context, req *CreateShortURLRequ
 		OriginalURL: shortURL.OriginalURL,
 		URLHash:     string(shortURL.URLHash),
 		Strategy:    string(strategyName),
+		WorkspaceID: shortURL.WorkspaceID,
 		CreatedAt:   shortURL.CreatedAt,
 		ClientIP:    meta.ClientIP,
 		UserAgent:   meta.UserAgent,
@@ -110,11 +142,18 @@ func (h *URLHandler) CreateShortURL(ctx context.Context, req *CreateShortURLRequ
 	resp.Body.Code = string(shortURL.Code)
 	resp.Body.ShortURL = fullShortURL
 	resp.Body.OriginalURL = shortURL.OriginalURL
+	resp.Body.Title = shortURL.Title
+	resp.Body.Tags = shortURL.Tags
+	resp.Body.Notes = shortURL.Notes
 
 	return resp, nil
 }
 
 func (h *URLHandler) RedirectToURL(ctx context.Context, req *RedirectRequest) (*RedirectResponse, error) {
+	if h.checkedCodes.Mistyped(req.Code) {
+		return nil, mistypedCodeError(req.Code)
+	}
+
 	shortURL, err := h.store.GetByCode(ctx, shortener.Code(req.Code))
 	if err != nil {
 		if errors.Is(err, shortener.ErrNotFound) {
@@ -124,13 +163,22 @@ func (h *URLHandler) RedirectToURL(ctx context.Context, req *RedirectRequest) (*
 		return nil, huma.Error500InternalServerError("failed to get url")
 	}
 
+	if shortURL.Deleted() {
+		return nil, huma.Error410Gone("short url has been deleted")
+	}
+
+	if shortURL.Disabled() {
+		return nil, huma.Error403Forbidden("short url has been disabled")
+	}
+
 	meta := RequestMetaFromContext(ctx)
 	event := &analytics.URLAccessedEvent{
-		Code:       req.Code,
-		AccessedAt: time.Now(),
-		ClientIP:   meta.ClientIP,
-		UserAgent:  meta.UserAgent,
-		Referrer:   meta.Referrer,
+		Code:        req.Code,
+		WorkspaceID: shortURL.WorkspaceID,
+		AccessedAt:  time.Now(),
+		ClientIP:    meta.ClientIP,
+		UserAgent:   meta.UserAgent,
+		Referrer:    meta.Referrer,
 	}
 
 	if err = h.publishURLAccessed(event); err != nil {
@@ -147,3 +195,43 @@ func (h *URLHandler) RedirectToURL(ctx context.Context, req *RedirectRequest) (*
 
 	return resp, nil
 }
+
+// MistypedCodeError is the problem response for a code that fails its check character.
+type MistypedCodeError struct {
+	huma.ErrorModel
+
+	Suggestion string `doc:"The code likely meant, if there is one" json:"suggestion,omitempty"`
+}
+
+// mistypedCodeError returns a 404 problem response for code, suggesting the
+// code it likely is a typo of. The suggested code is not known to exist.
+func mistypedCodeError(code string) error {
+	problem := &MistypedCodeError{
+		ErrorModel: huma.ErrorModel{
+			Title:  http.StatusText(http.StatusNotFound),
+			Status: http.StatusNotFound,
+			Detail: "short url not found",
+		},
+	}
+
+	if suggestion, ok := checkcode.Suggest(code); ok {
+		problem.Detail = fmt.Sprintf("short url not found, did you mean %s?", suggestion)
+		problem.Suggestion = suggestion
+	}
+
+	return problem
+}
+
+// checkDestination rejects destinations on the blocklist.
+func checkDestination(ctx context.Context, blocked *blocklist.Blocklist, rawURL string) error {
+	isBlocked, err := blocked.IsBlocked(ctx, rawURL)
+	if err != nil {
+		return huma.Error500InternalServerError("failed to check blocklist")
+	}
+
+	if isBlocked {
+		return huma.Error422UnprocessableEntity("destination domain is blocked")
+	}
+
+	return nil
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jaevor/go-nanoid"
 	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/checkcode"
 	"github.com/serroba/web-demo-go/internal/handlers"
 	"github.com/serroba/web-demo-go/internal/messaging"
 	"github.com/serroba/web-demo-go/internal/shortener"
@@ -33,12 +38,16 @@ func newTestHandler(s shortener.Repository) *handlers.URLHandler {
 	strategies := map[handlers.Strategy]shortener.Strategy{
 		handlers.StrategyToken: shortener.NewTokenStrategy(s, gen),
 		handlers.StrategyHash:  shortener.NewHashStrategy(s, gen),
+		handlers.StrategyWords: shortener.NewWordStrategy(s, func() string { return "brave-otter-42" }),
 	}
 
 	return handlers.NewURLHandler(
 		s,
 		"http://localhost:8888",
 		strategies,
+		true,
+		checkcode.Scope{},
+		newBlocklist(),
 		noopPublish[analytics.URLCreatedEvent](),
 		noopPublish[analytics.URLAccessedEvent](),
 		zap.NewNop(),
@@ -57,6 +66,9 @@ func newTestHandlerWithPublishError(s shortener.Repository) *handlers.URLHandler
 		s,
 		"http://localhost:8888",
 		strategies,
+		true,
+		checkcode.Scope{},
+		newBlocklist(),
 		errorPublish[analytics.URLCreatedEvent](errors.New("publish error")),
 		errorPublish[analytics.URLAccessedEvent](errors.New("publish error")),
 		zap.NewNop(),
@@ -80,6 +92,46 @@ func TestCreateShortURL(t *testing.T) {
 		assert.Equal(t, resp.Body.ShortURL, resp.Headers.Location)
 	})
 
+	t.Run("stores and returns link metadata", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newTestHandler(memStore)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+		req.Body.Title = "Example"
+		req.Body.Tags = []string{"docs", "team"}
+		req.Body.Notes = "shared in onboarding"
+
+		resp, err := handler.CreateShortURL(context.Background(), req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Example", resp.Body.Title)
+		assert.Equal(t, []string{"docs", "team"}, resp.Body.Tags)
+		assert.Equal(t, "shared in onboarding", resp.Body.Notes)
+
+		saved, err := memStore.GetByCode(context.Background(), shortener.Code(resp.Body.Code))
+		require.NoError(t, err)
+		assert.Equal(t, "Example", saved.Title)
+	})
+
+	t.Run("creates a human-readable code with the words strategy", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newTestHandler(memStore)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+		req.Body.Strategy = handlers.StrategyWords
+
+		resp, err := handler.CreateShortURL(context.Background(), req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "brave-otter-42", resp.Body.Code)
+
+		saved, err := memStore.GetByCode(context.Background(), "brave-otter-42")
+		require.NoError(t, err)
+		assert.Equal(t, shortener.StrategyWords, saved.Strategy)
+	})
+
 	t.Run("returns error for invalid strategy", func(t *testing.T) {
 		memStore := store.NewMemoryStore()
 		handler := newTestHandler(memStore)
@@ -166,6 +218,46 @@ func TestCreateShortURL(t *testing.T) {
 		assert.NotEqual(t, resp1.Body.Code, resp2.Body.Code)
 	})
 
+	t.Run("hash strategy deduplicates per workspace", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newTestHandler(memStore)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+		req.Body.Strategy = handlers.StrategyHash
+
+		teamA, err := handler.CreateShortURL(workspaceContext("alice", "team-a"), req)
+		require.NoError(t, err)
+
+		teamB, err := handler.CreateShortURL(workspaceContext("bob", "team-b"), req)
+		require.NoError(t, err)
+
+		again, err := handler.CreateShortURL(workspaceContext("carol", "team-a"), req)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, teamA.Body.Code, teamB.Body.Code)
+		assert.Equal(t, teamA.Body.Code, again.Body.Code)
+
+		stored, err := memStore.GetByCode(context.Background(), shortener.Code(teamB.Body.Code))
+		require.NoError(t, err)
+		assert.Equal(t, "team-b", stored.WorkspaceID)
+	})
+
+	t.Run("anonymous links belong to the default workspace", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newTestHandler(memStore)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+
+		resp, err := handler.CreateShortURL(context.Background(), req)
+		require.NoError(t, err)
+
+		stored, err := memStore.GetByCode(context.Background(), shortener.Code(resp.Body.Code))
+		require.NoError(t, err)
+		assert.Equal(t, auth.DefaultWorkspace, stored.WorkspaceID)
+	})
+
 	t.Run("defaults to token strategy when not specified", func(t *testing.T) {
 		memStore := store.NewMemoryStore()
 		handler := newTestHandler(memStore)
@@ -372,3 +464,226 @@ func TestRedirectToURL_PublishError(t *testing.T) {
 		assert.Equal(t, http.StatusMovedPermanently, resp.Status)
 	})
 }
+
+func TestCreateShortURL_Ownership(t *testing.T) {
+	newHandler := func(s shortener.Repository, allowAnonymous bool) *handlers.URLHandler {
+		gen, _ := nanoid.Standard(8)
+
+		return handlers.NewURLHandler(
+			s,
+			"http://localhost:8888",
+			map[handlers.Strategy]shortener.Strategy{
+				handlers.StrategyToken: shortener.NewTokenStrategy(s, gen),
+			},
+			allowAnonymous,
+			checkcode.Scope{},
+			newBlocklist(),
+			noopPublish[analytics.URLCreatedEvent](),
+			noopPublish[analytics.URLAccessedEvent](),
+			zap.NewNop(),
+		)
+	}
+
+	t.Run("records authenticated owner", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newHandler(memStore, false)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+
+		resp, err := handler.CreateShortURL(ownerContext("alice"), req)
+
+		require.NoError(t, err)
+
+		stored, err := memStore.GetByCode(context.Background(), shortener.Code(resp.Body.Code))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", stored.OwnerID)
+	})
+
+	t.Run("rejects anonymous creation when disabled", func(t *testing.T) {
+		handler := newHandler(store.NewMemoryStore(), false)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+
+		_, err := handler.CreateShortURL(context.Background(), req)
+
+		assertStatus(t, err, http.StatusUnauthorized)
+	})
+
+	t.Run("allows anonymous creation when enabled", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		handler := newHandler(memStore, true)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = testURL
+
+		resp, err := handler.CreateShortURL(context.Background(), req)
+
+		require.NoError(t, err)
+
+		stored, err := memStore.GetByCode(context.Background(), shortener.Code(resp.Body.Code))
+		require.NoError(t, err)
+		assert.Empty(t, stored.OwnerID)
+	})
+}
+
+func TestCreateShortURL_Blocklist(t *testing.T) {
+	t.Run("rejects blocked destination domain", func(t *testing.T) {
+		blocked := newBlocklist()
+		_, err := blocked.Add(context.Background(), &blocklist.Entry{Domain: "evil.example"})
+		require.NoError(t, err)
+
+		memStore := store.NewMemoryStore()
+		gen, _ := nanoid.Standard(8)
+		handler := handlers.NewURLHandler(
+			memStore,
+			"http://localhost:8888",
+			map[handlers.Strategy]shortener.Strategy{
+				handlers.StrategyToken: shortener.NewTokenStrategy(memStore, gen),
+			},
+			true,
+			checkcode.Scope{},
+			blocked,
+			noopPublish[analytics.URLCreatedEvent](),
+			noopPublish[analytics.URLAccessedEvent](),
+			zap.NewNop(),
+		)
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = "https://login.evil.example/account"
+
+		_, err = handler.CreateShortURL(context.Background(), req)
+
+		assertStatus(t, err, http.StatusUnprocessableEntity)
+	})
+}
+
+func TestRedirectToURL_Disabled(t *testing.T) {
+	t.Run("refuses disabled links", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		disabledAt := time.Now()
+		_ = memStore.Save(context.Background(), &shortener.ShortURL{
+			Code:        "down",
+			OriginalURL: testURL,
+			DisabledAt:  &disabledAt,
+		})
+		handler := newTestHandler(memStore)
+
+		_, err := handler.RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: "down"})
+
+		assertStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("answers 410 for deleted links", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		deletedAt := time.Now()
+		_ = memStore.Save(context.Background(), &shortener.ShortURL{
+			Code:        "gone",
+			OriginalURL: testURL,
+			DeletedAt:   &deletedAt,
+		})
+		handler := newTestHandler(memStore)
+
+		_, err := handler.RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: "gone"})
+
+		assertStatus(t, err, http.StatusGone)
+	})
+}
+
+func TestRedirectToURL_CheckedCodes(t *testing.T) {
+	// Codes of up to 8 characters were made before checked codes were enabled
+	checked := checkcode.Scope{Enabled: true, LegacyLength: 8}
+
+	newHandler := func(s shortener.Repository) *handlers.URLHandler {
+		return handlers.NewURLHandler(
+			s,
+			"http://localhost:8888",
+			map[handlers.Strategy]shortener.Strategy{},
+			true,
+			checked,
+			newBlocklist(),
+			noopPublish[analytics.URLCreatedEvent](),
+			noopPublish[analytics.URLAccessedEvent](),
+			zap.NewNop(),
+		)
+	}
+
+	generate, err := checkcode.New(9)
+	require.NoError(t, err)
+
+	t.Run("rejects mistyped codes without looking them up", func(t *testing.T) {
+		handler := newHandler(&mockStore{getByCodeErr: errMock})
+		code := generate()
+		typo := code[:8] + string(checkcode.Alphabet[(strings.IndexByte(checkcode.Alphabet, code[8])+1)%31])
+
+		_, err := handler.RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: typo})
+
+		var problem *handlers.MistypedCodeError
+
+		require.ErrorAs(t, err, &problem)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+	})
+
+	t.Run("suggests the code of a swap of adjacent characters", func(t *testing.T) {
+		handler := newHandler(store.NewMemoryStore())
+
+		for {
+			// Skip codes whose swap is a no-op or ambiguous
+			code := generate()
+			typo := code[1:2] + code[:1] + code[2:]
+
+			if _, ok := checkcode.Suggest(typo); typo == code || !ok {
+				continue
+			}
+
+			_, err := handler.RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: typo})
+
+			var problem *handlers.MistypedCodeError
+
+			require.ErrorAs(t, err, &problem)
+			assert.Equal(t, http.StatusNotFound, problem.Status)
+			assert.Equal(t, code, problem.Suggestion)
+			assert.Contains(t, problem.Detail, "did you mean "+code)
+
+			return
+		}
+	})
+
+	t.Run("redirects valid codes", func(t *testing.T) {
+		memStore := store.NewMemoryStore()
+		code := generate()
+		require.NoError(t, memStore.Save(context.Background(), &shortener.ShortURL{
+			Code:        shortener.Code(code),
+			OriginalURL: testURL,
+		}))
+
+		resp, err := newHandler(memStore).RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: code})
+
+		require.NoError(t, err)
+		assert.Equal(t, testURL, resp.Headers.Location)
+	})
+
+	t.Run("looks up legacy codes and codes of other formats", func(t *testing.T) {
+		// fx3kp7qa, like nanoid codes made before checked codes were enabled,
+		// only uses the alphabet of checked codes but fails its check
+		memStore := store.NewMemoryStore()
+		for _, code := range []string{"brave-otter-42", "Legacy01", "fx3kp7qa"} {
+			require.NoError(t, memStore.Save(context.Background(), &shortener.ShortURL{
+				Code:        shortener.Code(code),
+				OriginalURL: testURL,
+			}))
+
+			resp, err := newHandler(memStore).RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: code})
+
+			require.NoError(t, err)
+			assert.Equal(t, testURL, resp.Headers.Location)
+		}
+
+		_, err := newHandler(memStore).RedirectToURL(context.Background(), &handlers.RedirectRequest{Code: "fx3kp7qb"})
+		assertStatus(t, err, http.StatusNotFound)
+
+		var problem *handlers.MistypedCodeError
+		require.NotErrorAs(t, err, &problem)
+	})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/quota"
+)
+
+// UsageHandler reports quota usage.
+type UsageHandler struct {
+	enforcer *quota.Enforcer
+}
+
+// NewUsageHandler creates a new usage handler.
+func NewUsageHandler(enforcer *quota.Enforcer) *UsageHandler {
+	return &UsageHandler{enforcer: enforcer}
+}
+
+// GetUsage reports the usage of the caller's workspace and, for API keys, of the key itself.
+func (h *UsageHandler) GetUsage(ctx context.Context, _ *struct{}) (*UsageResponse, error) {
+	workspace, err := h.enforcer.WorkspaceUsage(ctx, auth.WorkspaceIDFromContext(ctx))
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to get usage")
+	}
+
+	resp := &UsageResponse{}
+	resp.Body.Workspace = toUsageItem(workspace)
+
+	if p := auth.PrincipalFromContext(ctx); p != nil && p.KeyID != "" {
+		key, err := h.enforcer.KeyUsage(ctx, p.KeyID)
+		if err != nil {
+			return nil, huma.Error500InternalServerError("failed to get usage")
+		}
+
+		item := toUsageItem(key)
+		resp.Body.APIKey = &item
+	}
+
+	return resp, nil
+}
+
+func toUsageItem(u *quota.Usage) UsageItem {
+	item := UsageItem{
+		Subject:          string(u.Subject),
+		MonthlyCreations: MeterItem(u.MonthlyCreations),
+	}
+
+	if u.ActiveLinks != nil {
+		active := MeterItem(*u.ActiveLinks)
+		item.ActiveLinks = &active
+	}
+
+	return item
+}
+
+// QuotaExceededError is the problem response for requests over quota.
+type QuotaExceededError struct {
+	huma.ErrorModel
+
+	Quota QuotaItem `doc:"The exceeded quota" json:"quota"`
+}
+
+// quotaError maps an *quota.ExceededError to a 402 problem response carrying its details.
+// It returns nil for other errors.
+func quotaError(err error) error {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		return nil
+	}
+
+	return &QuotaExceededError{
+		ErrorModel: huma.ErrorModel{
+			Title:  http.StatusText(http.StatusPaymentRequired),
+			Status: http.StatusPaymentRequired,
+			Detail: exceeded.Error(),
+		},
+		Quota: QuotaItem{
+			Subject:  string(exceeded.Subject),
+			Kind:     string(exceeded.Kind),
+			Limit:    exceeded.Limit,
+			ResetsAt: exceeded.ResetsAt,
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/handlers"
+	"github.com/serroba/web-demo-go/internal/quota"
+	quotastore "github.com/serroba/web-demo-go/internal/quota/store"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type failingUsageStore struct {
+	quota.Store
+}
+
+func (failingUsageStore) Get(_ context.Context, _ quota.Counter) (int64, error) {
+	return 0, assert.AnError
+}
+
+func TestCreateShortURL_Quota(t *testing.T) {
+	t.Run("rejects links over quota with 402", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{KeyMonthlyCreations: 1})
+		handler := newTestHandler(quota.NewRepository(store.NewMemoryStore(), enforcer, zap.NewNop()))
+		ctx := ownerContext("alice")
+
+		req := &handlers.CreateShortURLRequest{}
+		req.Body.URL = "https://example.com"
+		req.Body.Strategy = handlers.StrategyToken
+
+		_, err := handler.CreateShortURL(ctx, req)
+		require.NoError(t, err)
+
+		_, err = handler.CreateShortURL(ctx, req)
+
+		assertStatus(t, err, http.StatusPaymentRequired)
+
+		var problem *handlers.QuotaExceededError
+
+		require.ErrorAs(t, err, &problem)
+		assert.Equal(t, "key:key-alice", problem.Quota.Subject)
+		assert.Equal(t, "monthlyCreations", problem.Quota.Kind)
+		assert.Equal(t, int64(1), problem.Quota.Limit)
+		assert.NotNil(t, problem.Quota.ResetsAt)
+
+		body, err := json.Marshal(problem)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"status":402`)
+		assert.Contains(t, string(body), `"quota":{"subject":"key:key-alice"`)
+	})
+}
+
+func TestGetUsage(t *testing.T) {
+	limits := quota.Limits{ActiveLinks: 10, MonthlyCreations: 100, KeyMonthlyCreations: 5}
+
+	t.Run("reports workspace and API key usage", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), limits)
+		ctx := ownerContext("alice")
+		require.NoError(t, enforcer.ReserveLink(ctx, auth.DefaultWorkspace, "key-alice"))
+
+		resp, err := handlers.NewUsageHandler(enforcer).GetUsage(ctx, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "workspace:default", resp.Body.Workspace.Subject)
+		assert.Equal(t, &handlers.MeterItem{Used: 1, Limit: 10}, resp.Body.Workspace.ActiveLinks)
+		assert.Equal(t, int64(1), resp.Body.Workspace.MonthlyCreations.Used)
+		assert.Equal(t, int64(100), resp.Body.Workspace.MonthlyCreations.Limit)
+		require.NotNil(t, resp.Body.APIKey)
+		assert.Equal(t, "key:key-alice", resp.Body.APIKey.Subject)
+		assert.Nil(t, resp.Body.APIKey.ActiveLinks)
+		assert.Equal(t, int64(5), resp.Body.APIKey.MonthlyCreations.Limit)
+	})
+
+	t.Run("omits API key usage for other callers", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), limits)
+
+		resp, err := handlers.NewUsageHandler(enforcer).GetUsage(workspaceContext("bob", "team-a"), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "workspace:team-a", resp.Body.Workspace.Subject)
+		assert.Nil(t, resp.Body.APIKey)
+	})
+
+	t.Run("returns 500 on store errors", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(failingUsageStore{}, limits)
+
+		_, err := handlers.NewUsageHandler(enforcer).GetUsage(ownerContext("alice"), nil)
+
+		assertStatus(t, err, http.StatusInternalServerError)
+	})
+}
@@ -27,21 +27,37 @@ func (r *RedisChecker) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
+// CacheStats counts the early refreshes of the links of the Redis cache.
+type CacheStats struct {
+	Refreshed uint64 `json:"refreshed"`
+	Failed    uint64 `json:"failed"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// CacheReporter reports the statistics of the Redis cache of links.
+type CacheReporter interface {
+	CacheStats() CacheStats
+}
+
 // Handler handles health check operations.
 type Handler struct {
 	redis Checker
+	cache CacheReporter
 }
 
-// NewHandler creates a new health handler.
-func NewHandler(redis Checker) *Handler {
-	return &Handler{redis: redis}
+// NewHandler creates a new health handler. A nil checker leaves Redis out of
+// the checks, for servers that do not use it, and a nil reporter leaves out
+// the statistics of the cache, for servers without one.
+func NewHandler(redis Checker, cache CacheReporter) *Handler {
+	return &Handler{redis: redis, cache: cache}
 }
 
 // Response is the response for health check endpoint.
 type Response struct {
 	Body struct {
-		Status string `json:"status"`
-		Redis  string `json:"redis"`
+		Status string      `json:"status"`
+		Redis  string      `json:"redis,omitempty"`
+		Cache  *CacheStats `json:"cache,omitempty"`
 	}
 }
 
@@ -50,6 +66,15 @@ func (h *Handler) Check(ctx context.Context, _ *struct{}) (*Response, error) {
 	resp := &Response{}
 	resp.Body.Status = "ok"
 
+	if h.cache != nil {
+		stats := h.cache.CacheStats()
+		resp.Body.Cache = &stats
+	}
+
+	if h.redis == nil {
+		return resp, nil
+	}
+
 	if err := h.redis.Ping(ctx); err != nil {
 		resp.Body.Redis = "unhealthy"
 		resp.Body.Status = "degraded"
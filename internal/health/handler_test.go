@@ -21,9 +21,17 @@ func (m *mockChecker) Ping(_ context.Context) error {
 	return m.err
 }
 
+type mockReporter struct {
+	stats health.CacheStats
+}
+
+func (m *mockReporter) CacheStats() health.CacheStats {
+	return m.stats
+}
+
 func TestNewHandler(t *testing.T) {
 	checker := &mockChecker{}
-	handler := health.NewHandler(checker)
+	handler := health.NewHandler(checker, nil)
 
 	assert.NotNil(t, handler)
 }
@@ -31,7 +39,7 @@ func TestNewHandler(t *testing.T) {
 func TestHandler_Check(t *testing.T) {
 	t.Run("returns ok when redis is healthy", func(t *testing.T) {
 		checker := &mockChecker{err: nil}
-		handler := health.NewHandler(checker)
+		handler := health.NewHandler(checker, nil)
 
 		resp, err := handler.Check(context.Background(), nil)
 
@@ -42,7 +50,7 @@ func TestHandler_Check(t *testing.T) {
 
 	t.Run("returns degraded when redis is unhealthy", func(t *testing.T) {
 		checker := &mockChecker{err: errors.New("connection refused")}
-		handler := health.NewHandler(checker)
+		handler := health.NewHandler(checker, nil)
 
 		resp, err := handler.Check(context.Background(), nil)
 
@@ -50,6 +58,27 @@ func TestHandler_Check(t *testing.T) {
 		assert.Equal(t, "degraded", resp.Body.Status)
 		assert.Equal(t, "unhealthy", resp.Body.Redis)
 	})
+
+	t.Run("leaves redis out without a checker", func(t *testing.T) {
+		handler := health.NewHandler(nil, nil)
+
+		resp, err := handler.Check(context.Background(), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp.Body.Status)
+		assert.Empty(t, resp.Body.Redis)
+		assert.Nil(t, resp.Body.Cache)
+	})
+
+	t.Run("reports the statistics of the cache", func(t *testing.T) {
+		stats := health.CacheStats{Refreshed: 3, Failed: 1, Dropped: 2}
+		handler := health.NewHandler(nil, &mockReporter{stats: stats})
+
+		resp, err := handler.Check(context.Background(), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, &stats, resp.Body.Cache)
+	})
 }
 
 func TestRedisChecker(t *testing.T) {
@@ -0,0 +1,108 @@
+package linkmeta
+
+import (
+	"context"
+	"errors"
+
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// PageFetcher retrieves metadata for a destination URL.
+type PageFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*Page, error)
+}
+
+// Enricher fills in missing link metadata from the destination page.
+type Enricher struct {
+	repo    shortener.Repository
+	fetcher PageFetcher
+	logger  *zap.Logger
+}
+
+// NewEnricher creates a new metadata enricher.
+func NewEnricher(repo shortener.Repository, fetcher PageFetcher, logger *zap.Logger) *Enricher {
+	return &Enricher{
+		repo:    repo,
+		fetcher: fetcher,
+		logger:  logger,
+	}
+}
+
+// HandleURLCreated enriches the link referenced by a url.created event.
+// Fetch failures are logged and not returned, so unreachable pages are not redelivered.
+// The link may change while its page is fetched, so it is read again, as stored,
+// and left alone if it was meanwhile deleted, taken down or given another destination.
+func (e *Enricher) HandleURLCreated(ctx context.Context, event *analytics.URLCreatedEvent) error {
+	shortURL, err := e.repo.GetByCode(ctx, shortener.Code(event.Code))
+	if err != nil {
+		if errors.Is(err, shortener.ErrNotFound) {
+			e.logger.Warn("skipping metadata for unknown code", zap.String("code", event.Code))
+
+			return nil
+		}
+
+		return err
+	}
+
+	if !enrichable(shortURL) {
+		return nil
+	}
+
+	page, err := e.fetcher.Fetch(ctx, shortURL.OriginalURL)
+	if err != nil {
+		e.logger.Info("failed to fetch page metadata",
+			zap.String("code", event.Code),
+			zap.Error(err),
+		)
+
+		return nil
+	}
+
+	current, err := e.repo.GetByCode(shortener.ContextWithLatestReads(ctx), shortURL.Code)
+	if errors.Is(err, shortener.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !enrichable(current) || current.OriginalURL != shortURL.OriginalURL {
+		return nil
+	}
+
+	updated := *current
+	if !fill(&updated.Metadata, page) {
+		return nil
+	}
+
+	return e.repo.Update(ctx, &updated)
+}
+
+// enrichable reports whether a link is live and misses metadata.
+func enrichable(shortURL *shortener.ShortURL) bool {
+	return !shortURL.Deleted() && !shortURL.Disabled() && shortURL.NeedsEnrichment()
+}
+
+// fill copies page fields into empty metadata fields and reports whether anything changed.
+func fill(meta *shortener.Metadata, page *Page) bool {
+	changed := false
+
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&meta.Title, page.Title},
+		{&meta.Description, page.Description},
+		{&meta.ImageURL, page.ImageURL},
+	} {
+		if *f.dst == "" && f.src != "" {
+			*f.dst = f.src
+			changed = true
+		}
+	}
+
+	return changed
+}
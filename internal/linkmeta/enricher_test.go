@@ -0,0 +1,188 @@
+package linkmeta_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/linkmeta"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type stubFetcher struct {
+	page  *linkmeta.Page
+	err   error
+	calls int
+	// meanwhile runs during the fetch, if set
+	meanwhile func()
+}
+
+func (f *stubFetcher) Fetch(_ context.Context, _ string) (*linkmeta.Page, error) {
+	f.calls++
+
+	if f.meanwhile != nil {
+		f.meanwhile()
+	}
+
+	return f.page, f.err
+}
+
+func TestEnricher_HandleURLCreated(t *testing.T) {
+	ctx := context.Background()
+	event := &analytics.URLCreatedEvent{Code: "abc123"}
+
+	t.Run("fills in missing fields and keeps user supplied ones", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		_ = repo.Save(ctx, &shortener.ShortURL{
+			Code:        "abc123",
+			OriginalURL: "https://example.com",
+			Metadata:    shortener.Metadata{Title: "My title", Tags: []string{"docs"}},
+		})
+		fetcher := &stubFetcher{page: &linkmeta.Page{
+			Title:       "Page title",
+			Description: "Page description",
+			ImageURL:    "https://example.com/cover.png",
+		}}
+
+		err := linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event)
+
+		require.NoError(t, err)
+
+		got, _ := repo.GetByCode(ctx, "abc123")
+		assert.Equal(t, "My title", got.Title)
+		assert.Equal(t, "Page description", got.Description)
+		assert.Equal(t, "https://example.com/cover.png", got.ImageURL)
+		assert.Equal(t, []string{"docs"}, got.Tags)
+	})
+
+	t.Run("skips fetch when metadata is complete", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		_ = repo.Save(ctx, &shortener.ShortURL{
+			Code: "abc123",
+			Metadata: shortener.Metadata{
+				Title: "Title", Description: "Description", ImageURL: "https://example.com/a.png",
+			},
+		})
+		fetcher := &stubFetcher{}
+
+		err := linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, fetcher.calls)
+	})
+
+	t.Run("ignores unknown codes", func(t *testing.T) {
+		fetcher := &stubFetcher{}
+
+		err := linkmeta.NewEnricher(store.NewMemoryStore(), fetcher, zap.NewNop()).HandleURLCreated(ctx, event)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, fetcher.calls)
+	})
+
+	t.Run("swallows fetch errors", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		_ = repo.Save(ctx, &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"})
+		fetcher := &stubFetcher{err: errors.New("timeout")}
+
+		err := linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("does not update when page has nothing new", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		original := &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"}
+		_ = repo.Save(ctx, original)
+		fetcher := &stubFetcher{page: &linkmeta.Page{}}
+
+		err := linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event)
+
+		require.NoError(t, err)
+
+		got, _ := repo.GetByCode(ctx, "abc123")
+		assert.Same(t, original, got)
+	})
+
+	// enrichChanged enriches a link changed by change while its page is fetched,
+	// and returns the link as stored then.
+	enrichChanged := func(t *testing.T, change func(*shortener.ShortURL)) *shortener.ShortURL {
+		t.Helper()
+
+		repo := store.NewMemoryStore()
+		require.NoError(t, repo.Save(ctx, &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"}))
+
+		fetcher := &stubFetcher{
+			page: &linkmeta.Page{Title: "Page title", Description: "Page description"},
+			meanwhile: func() {
+				changed := shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"}
+				change(&changed)
+				require.NoError(t, repo.Update(ctx, &changed))
+			},
+		}
+
+		require.NoError(t, linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event))
+
+		got, err := repo.GetByCode(ctx, "abc123")
+		require.NoError(t, err)
+
+		return got
+	}
+
+	t.Run("leaves links deleted, taken down or redirected during the fetch", func(t *testing.T) {
+		now := time.Now()
+
+		deleted := enrichChanged(t, func(u *shortener.ShortURL) { u.DeletedAt = &now })
+		assert.True(t, deleted.Deleted())
+		assert.Empty(t, deleted.Title)
+
+		disabled := enrichChanged(t, func(u *shortener.ShortURL) { u.DisabledAt = &now })
+		assert.True(t, disabled.Disabled())
+		assert.Empty(t, disabled.Title)
+
+		redirected := enrichChanged(t, func(u *shortener.ShortURL) { u.OriginalURL = "https://example.org" })
+		assert.Equal(t, "https://example.org", redirected.OriginalURL)
+		assert.Empty(t, redirected.Title)
+	})
+
+	t.Run("keeps edits made during the fetch", func(t *testing.T) {
+		got := enrichChanged(t, func(u *shortener.ShortURL) { u.Title = "My title" })
+
+		assert.Equal(t, "My title", got.Title)
+		assert.Equal(t, "Page description", got.Description)
+	})
+
+	t.Run("leaves links purged during the fetch", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		require.NoError(t, repo.Save(ctx, &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"}))
+
+		fetcher := &stubFetcher{page: &linkmeta.Page{Title: "Page title"}, meanwhile: func() {
+			require.NoError(t, repo.Delete(ctx, "abc123"))
+		}}
+
+		require.NoError(t, linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event))
+
+		_, err := repo.GetByCode(ctx, "abc123")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("skips links that are not live", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		disabledAt := time.Now()
+		_ = repo.Save(ctx, &shortener.ShortURL{
+			Code: "abc123", OriginalURL: "https://example.com", DisabledAt: &disabledAt,
+		})
+		fetcher := &stubFetcher{page: &linkmeta.Page{Title: "Page title"}}
+
+		err := linkmeta.NewEnricher(repo, fetcher, zap.NewNop()).HandleURLCreated(ctx, event)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, fetcher.calls)
+	})
+}
@@ -0,0 +1,230 @@
+package linkmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// maxFieldLength caps the length of each extracted field, in runes.
+const maxFieldLength = 512
+
+var (
+	// ErrNotHTML is returned when the destination does not serve an HTML document.
+	ErrNotHTML = errors.New("destination is not an html document")
+	// ErrUnsupportedScheme is returned for destinations that are not http or https.
+	ErrUnsupportedScheme = errors.New("unsupported url scheme")
+	// ErrForbiddenAddress is returned when the destination resolves to a private or local address.
+	ErrForbiddenAddress = errors.New("destination resolves to a forbidden address")
+)
+
+var (
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern  = regexp.MustCompile(`(?is)([a-z][a-z0-9:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// Page holds the metadata extracted from an HTML document.
+type Page struct {
+	Title       string
+	Description string
+	ImageURL    string
+}
+
+// Config controls how destination pages are fetched.
+type Config struct {
+	// Timeout bounds the whole request, including redirects and reading the body.
+	Timeout time.Duration
+	// MaxBytes is the maximum number of body bytes read from the destination.
+	MaxBytes int64
+	// UserAgent is sent with every request.
+	UserAgent string
+	// AllowPrivateNetworks permits loopback, private and link-local destinations.
+	// It should only be enabled in tests.
+	AllowPrivateNetworks bool
+}
+
+// Fetcher downloads destination pages and extracts their title and OpenGraph fields.
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+// NewFetcher creates a new page fetcher.
+func NewFetcher(cfg Config) *Fetcher {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = rejectPrivateAddress
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+		},
+		maxBytes:  cfg.MaxBytes,
+		userAgent: cfg.UserAgent,
+	}
+}
+
+// Fetch retrieves the page at rawURL and extracts its metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	if f.userAgent != "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("unexpected status fetching page: %d", resp.StatusCode)
+	}
+
+	if !isHTML(resp.Header.Get("Content-Type")) {
+		return nil, ErrNotHTML
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(string(body), resp.Request.URL), nil
+}
+
+// Parse extracts the title and OpenGraph fields from an HTML document.
+// Relative image URLs are resolved against base.
+func Parse(document string, base *url.URL) *Page {
+	meta := make(map[string]string)
+
+	for _, tag := range metaPattern.FindAllString(document, -1) {
+		attrs := parseAttributes(tag)
+
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+
+		key = strings.ToLower(key)
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = attrs["content"]
+		}
+	}
+
+	page := &Page{
+		Title:       clean(meta["og:title"]),
+		Description: clean(meta["og:description"]),
+		ImageURL:    resolve(base, clean(meta["og:image"])),
+	}
+
+	if page.Title == "" {
+		if m := titlePattern.FindStringSubmatch(document); m != nil {
+			page.Title = clean(m[1])
+		}
+	}
+
+	if page.Description == "" {
+		page.Description = clean(meta["description"])
+	}
+
+	return page
+}
+
+func parseAttributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+
+	for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+	}
+
+	return attrs
+}
+
+// clean unescapes entities, collapses whitespace and truncates to maxFieldLength runes.
+func clean(s string) string {
+	s = strings.TrimSpace(spacePattern.ReplaceAllString(html.UnescapeString(s), " "))
+
+	if utf8.RuneCountInString(s) > maxFieldLength {
+		s = string([]rune(s)[:maxFieldLength])
+	}
+
+	return s
+}
+
+func resolve(base *url.URL, ref string) string {
+	if ref == "" || base == nil {
+		return ref
+	}
+
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	return u.String()
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// rejectPrivateAddress refuses connections to loopback, private, link-local and unspecified addresses.
+func rejectPrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package linkmeta_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/linkmeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPage = `<!doctype html>
+<html><head>
+<title>  Example &amp; Co
+ Home </title>
+<meta name="description" content="Plain description">
+<meta property="og:description" content='OpenGraph description'>
+<meta content="/images/cover.png" property="og:image">
+</head><body>hello</body></html>`
+
+func newTestFetcher() *linkmeta.Fetcher {
+	return linkmeta.NewFetcher(linkmeta.Config{
+		Timeout:              time.Second,
+		MaxBytes:             1 << 16,
+		AllowPrivateNetworks: true,
+	})
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/1")
+
+	t.Run("extracts title and OpenGraph fields", func(t *testing.T) {
+		page := linkmeta.Parse(testPage, base)
+
+		assert.Equal(t, "Example & Co Home", page.Title)
+		assert.Equal(t, "OpenGraph description", page.Description)
+		assert.Equal(t, "https://example.com/images/cover.png", page.ImageURL)
+	})
+
+	t.Run("prefers og:title over title element", func(t *testing.T) {
+		page := linkmeta.Parse(`<title>Plain</title><meta property="og:title" content="Rich">`, base)
+
+		assert.Equal(t, "Rich", page.Title)
+	})
+
+	t.Run("falls back to meta description", func(t *testing.T) {
+		page := linkmeta.Parse(`<meta name="Description" content="Plain">`, base)
+
+		assert.Equal(t, "Plain", page.Description)
+	})
+
+	t.Run("truncates long fields", func(t *testing.T) {
+		page := linkmeta.Parse("<title>"+strings.Repeat("a", 1000)+"</title>", base)
+
+		assert.Len(t, page.Title, 512)
+	})
+
+	t.Run("returns empty page for document without metadata", func(t *testing.T) {
+		page := linkmeta.Parse("<p>nothing here</p>", base)
+
+		assert.Equal(t, &linkmeta.Page{}, page)
+	})
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	t.Run("fetches and parses html page", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(testPage))
+		}))
+		defer srv.Close()
+
+		page, err := newTestFetcher().Fetch(context.Background(), srv.URL+"/articles/1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "Example & Co Home", page.Title)
+		assert.Equal(t, srv.URL+"/images/cover.png", page.ImageURL)
+	})
+
+	t.Run("stops reading at MaxBytes", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(strings.Repeat(" ", 100) + "<title>Too late</title>"))
+		}))
+		defer srv.Close()
+
+		fetcher := linkmeta.NewFetcher(linkmeta.Config{Timeout: time.Second, MaxBytes: 64, AllowPrivateNetworks: true})
+		page, err := fetcher.Fetch(context.Background(), srv.URL)
+
+		require.NoError(t, err)
+		assert.Empty(t, page.Title)
+	})
+
+	t.Run("rejects non-html content", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/pdf")
+		}))
+		defer srv.Close()
+
+		_, err := newTestFetcher().Fetch(context.Background(), srv.URL)
+
+		assert.ErrorIs(t, err, linkmeta.ErrNotHTML)
+	})
+
+	t.Run("returns error for non-2xx status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer srv.Close()
+
+		_, err := newTestFetcher().Fetch(context.Background(), srv.URL)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects unsupported scheme", func(t *testing.T) {
+		_, err := newTestFetcher().Fetch(context.Background(), "ftp://example.com/file")
+
+		assert.ErrorIs(t, err, linkmeta.ErrUnsupportedScheme)
+	})
+
+	t.Run("rejects private addresses by default", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+		}))
+		defer srv.Close()
+
+		fetcher := linkmeta.NewFetcher(linkmeta.Config{Timeout: time.Second, MaxBytes: 1024})
+		_, err := fetcher.Fetch(context.Background(), srv.URL)
+
+		assert.ErrorIs(t, err, linkmeta.ErrForbiddenAddress)
+	})
+}
@@ -16,16 +16,16 @@ type Runnable interface {
 
 // ConsumerGroup manages multiple consumers with unified lifecycle.
 type ConsumerGroup struct {
-	consumers  []Runnable
-	subscriber message.Subscriber
-	logger     *zap.Logger
+	consumers   []Runnable
+	subscribers []message.Subscriber
+	logger      *zap.Logger
 }
 
 // NewConsumerGroup creates a new consumer group.
 func NewConsumerGroup(subscriber message.Subscriber, logger *zap.Logger) *ConsumerGroup {
 	return &ConsumerGroup{
-		subscriber: subscriber,
-		logger:     logger,
+		subscribers: []message.Subscriber{subscriber},
+		logger:      logger,
 	}
 }
 
@@ -34,6 +34,12 @@ func (g *ConsumerGroup) Add(consumer Runnable) {
 	g.consumers = append(g.consumers, consumer)
 }
 
+// AddSubscriber registers an additional subscriber that is closed with the group.
+// Consumers that need their own consumer group on a shared topic use a separate subscriber.
+func (g *ConsumerGroup) AddSubscriber(subscriber message.Subscriber) {
+	g.subscribers = append(g.subscribers, subscriber)
+}
+
 // Start starts all consumers in the group.
 func (g *ConsumerGroup) Start(ctx context.Context) error {
 	for i, consumer := range g.consumers {
@@ -64,8 +70,10 @@ func (g *ConsumerGroup) Shutdown() error {
 		}
 	}
 
-	if err := g.subscriber.Close(); err != nil && firstErr == nil {
-		firstErr = err
+	for _, subscriber := range g.subscribers {
+		if err := subscriber.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
 
 	return firstErr
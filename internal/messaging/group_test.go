@@ -105,3 +105,18 @@ func TestConsumerGroup_Shutdown(t *testing.T) {
 		assert.True(t, consumer2.shutdown) // Still attempted
 	})
 }
+
+func TestConsumerGroup_AddSubscriber(t *testing.T) {
+	t.Run("closes additional subscribers on shutdown", func(t *testing.T) {
+		sub := newMockSubscriber()
+		extra := newMockSubscriber()
+		group := messaging.NewConsumerGroup(sub, zap.NewNop())
+		group.AddSubscriber(extra)
+
+		err := group.Shutdown()
+
+		require.NoError(t, err)
+		assert.True(t, sub.closed)
+		assert.True(t, extra.closed)
+	})
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"go.uber.org/zap"
+)
+
+// Authenticate is a middleware that resolves the request credential to an auth.Principal
+// and adds it to the request context.
+//
+// Credentials are read from "Authorization: Bearer <credential>" or the X-API-Key header.
+// Requests without a credential continue anonymously; handlers decide whether that is allowed.
+// Requests with an invalid credential are rejected with 401.
+func Authenticate(
+	api huma.API,
+	authenticator auth.Authenticator,
+	logger *zap.Logger,
+) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		credential := extractCredential(ctx)
+		if credential == "" {
+			next(ctx)
+
+			return
+		}
+
+		principal, err := authenticator.Authenticate(ctx.Context(), credential)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				writeUnauthorized(api, ctx, "invalid credentials")
+
+				return
+			}
+
+			logger.Error("authentication failed", zap.String("path", getOperationPath(ctx)), zap.Error(err))
+			_ = huma.WriteErr(api, ctx, http.StatusInternalServerError, "internal server error", err)
+
+			return
+		}
+
+		ctx = huma.WithContext(ctx, auth.ContextWithPrincipal(ctx.Context(), principal))
+
+		next(ctx)
+	}
+}
+
+// WorkspaceHeader selects the workspace a request acts within.
+const WorkspaceHeader = "X-Workspace-ID"
+
+// SelectWorkspace is a middleware that switches the authenticated principal to the
+// workspace named in the X-Workspace-ID header; see auth.SelectWorkspace.
+// It must run after Authenticate. Anonymous requests always act within auth.DefaultWorkspace.
+// Principals that cannot act within the requested workspace are rejected with 403.
+func SelectWorkspace(
+	api huma.API,
+	workspaces auth.WorkspaceStore,
+	logger *zap.Logger,
+) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		principal := auth.PrincipalFromContext(ctx.Context())
+		workspaceID := strings.TrimSpace(ctx.Header(WorkspaceHeader))
+
+		if principal == nil || workspaceID == "" {
+			next(ctx)
+
+			return
+		}
+
+		selected, err := auth.SelectWorkspace(ctx.Context(), workspaces, principal, workspaceID)
+		if err != nil {
+			if errors.Is(err, auth.ErrNotMember) {
+				_ = huma.WriteErr(api, ctx, http.StatusForbidden, "not a member of workspace "+workspaceID)
+
+				return
+			}
+
+			logger.Error("workspace lookup failed", zap.String("path", getOperationPath(ctx)), zap.Error(err))
+			_ = huma.WriteErr(api, ctx, http.StatusInternalServerError, "internal server error", err)
+
+			return
+		}
+
+		ctx = huma.WithContext(ctx, auth.ContextWithPrincipal(ctx.Context(), selected))
+
+		next(ctx)
+	}
+}
+
+// Authorize is a middleware that enforces the auth.OperationAccess declared in
+// operation metadata under auth.MetadataKey. Operations without it are public.
+//
+// Anonymous callers get 401 unless the operation allows anonymous access;
+// authenticated callers without the required permission, or outside the
+// default workspace for global operations, get 403.
+func Authorize(api huma.API) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		access := auth.GetOperationAccess(ctx)
+		if access == nil {
+			next(ctx)
+
+			return
+		}
+
+		principal := auth.PrincipalFromContext(ctx.Context())
+
+		switch {
+		case principal == nil && access.AllowAnonymous:
+			next(ctx)
+		case principal == nil:
+			writeUnauthorized(api, ctx, "authentication required")
+		case !principal.Can(access.Permission):
+			_ = huma.WriteErr(api, ctx, http.StatusForbidden, "missing permission "+string(access.Permission))
+		case access.Global && auth.WorkspaceIDFromContext(ctx.Context()) != auth.DefaultWorkspace:
+			_ = huma.WriteErr(api, ctx, http.StatusForbidden, "operation requires the default workspace")
+		default:
+			next(ctx)
+		}
+	}
+}
+
+// writeUnauthorized writes a 401 problem response with a bearer challenge.
+func writeUnauthorized(api huma.API, ctx huma.Context, msg string) {
+	ctx.SetHeader("WWW-Authenticate", `Bearer realm="url-shortener"`)
+	_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, msg)
+}
+
+// extractCredential reads a bearer token or API key from the request headers.
+func extractCredential(ctx huma.Context) string {
+	if header := ctx.Header("Authorization"); header != "" {
+		scheme, credential, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(credential)
+		}
+	}
+
+	return strings.TrimSpace(ctx.Header("X-API-Key"))
+}
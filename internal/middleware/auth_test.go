@@ -0,0 +1,293 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/serroba/web-demo-go/internal/auth"
+	authstore "github.com/serroba/web-demo-go/internal/auth/store"
+	"github.com/serroba/web-demo-go/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type stubAuthenticator struct {
+	credentials map[string]*auth.Principal
+	err         error
+}
+
+func (s stubAuthenticator) Authenticate(_ context.Context, credential string) (*auth.Principal, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if p, ok := s.credentials[credential]; ok {
+		return p, nil
+	}
+
+	return nil, auth.ErrInvalidCredentials
+}
+
+func setupAuthAPI(t *testing.T, authenticator auth.Authenticator) (*chi.Mux, chan *auth.Principal) {
+	t.Helper()
+
+	router := chi.NewMux()
+	api := humachi.New(router, huma.DefaultConfig("Test", "1.0.0"))
+	api.UseMiddleware(middleware.Authenticate(api, authenticator, zap.NewNop()))
+
+	principals := make(chan *auth.Principal, 1)
+
+	huma.Get(api, "/test", func(ctx context.Context, _ *struct{}) (*testOutput, error) {
+		principals <- auth.PrincipalFromContext(ctx)
+
+		return &testOutput{Body: "ok"}, nil
+	})
+
+	return router, principals
+}
+
+func TestAuthenticate(t *testing.T) {
+	alice := &auth.Principal{OwnerID: "alice", KeyID: "key-1"}
+	authenticator := stubAuthenticator{credentials: map[string]*auth.Principal{"sk_alice": alice}}
+
+	t.Run("accepts bearer token", func(t *testing.T) {
+		router, principals := setupAuthAPI(t, authenticator)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer sk_alice")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, alice, <-principals)
+	})
+
+	t.Run("accepts X-API-Key header", func(t *testing.T) {
+		router, principals := setupAuthAPI(t, authenticator)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("X-API-Key", "sk_alice")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, alice, <-principals)
+	})
+
+	t.Run("continues anonymously without credential", func(t *testing.T) {
+		router, principals := setupAuthAPI(t, authenticator)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, <-principals)
+	})
+
+	t.Run("rejects invalid credential", func(t *testing.T) {
+		router, _ := setupAuthAPI(t, authenticator)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer sk_wrong")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("returns 500 on authenticator error", func(t *testing.T) {
+		router, _ := setupAuthAPI(t, stubAuthenticator{err: assert.AnError})
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("X-API-Key", "sk_alice")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func setupAuthorizeAPI(t *testing.T, access *auth.OperationAccess) *chi.Mux {
+	t.Helper()
+
+	router := chi.NewMux()
+	api := humachi.New(router, huma.DefaultConfig("Test", "1.0.0"))
+	api.UseMiddleware(middleware.Authenticate(api, stubAuthenticator{credentials: map[string]*auth.Principal{
+		"sk_viewer": {OwnerID: "v", Roles: []auth.Role{auth.RoleViewer}},
+		"sk_admin":  {OwnerID: "a", Roles: []auth.Role{auth.RoleAdmin}},
+		"sk_team":   {OwnerID: "t", WorkspaceID: "team-a", Roles: []auth.Role{auth.RoleAdmin}},
+	}}, zap.NewNop()))
+	api.UseMiddleware(middleware.Authorize(api))
+
+	op := huma.Operation{Method: http.MethodGet, Path: "/test"}
+	if access != nil {
+		op.Metadata = map[string]any{auth.MetadataKey: *access}
+	}
+
+	huma.Register(api, op, func(_ context.Context, _ *struct{}) (*testOutput, error) {
+		return &testOutput{Body: "ok"}, nil
+	})
+
+	return router
+}
+
+func TestAuthorize(t *testing.T) {
+	serve := func(router *chi.Mux, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("operations without access metadata are public", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(setupAuthorizeAPI(t, nil), "").Code)
+	})
+
+	t.Run("rejects anonymous callers with 401", func(t *testing.T) {
+		w := serve(setupAuthorizeAPI(t, &auth.OperationAccess{Permission: auth.PermissionReadLinks}), "")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+		assert.Contains(t, w.Header().Get("Content-Type"), "problem+json")
+	})
+
+	t.Run("allows anonymous callers when permitted", func(t *testing.T) {
+		router := setupAuthorizeAPI(t, &auth.OperationAccess{Permission: auth.PermissionWriteLinks, AllowAnonymous: true})
+
+		assert.Equal(t, http.StatusOK, serve(router, "").Code)
+		assert.Equal(t, http.StatusForbidden, serve(router, "sk_viewer").Code)
+	})
+
+	t.Run("rejects callers without permission with 403", func(t *testing.T) {
+		router := setupAuthorizeAPI(t, &auth.OperationAccess{Permission: auth.PermissionAdmin})
+
+		w := serve(router, "sk_viewer")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "problem+json")
+		assert.Equal(t, http.StatusOK, serve(router, "sk_admin").Code)
+	})
+
+	t.Run("global operations require the default workspace", func(t *testing.T) {
+		router := setupAuthorizeAPI(t, &auth.OperationAccess{Permission: auth.PermissionAdmin, Global: true})
+
+		assert.Equal(t, http.StatusForbidden, serve(router, "sk_team").Code)
+		assert.Equal(t, http.StatusOK, serve(router, "sk_admin").Code)
+	})
+}
+
+func TestSelectWorkspace(t *testing.T) {
+	workspaces := authstore.NewMemoryWorkspaces()
+	require.NoError(t, workspaces.CreateWorkspace(context.Background(), &auth.Workspace{ID: "team-a"}))
+	require.NoError(t, workspaces.AddMember(context.Background(), &auth.Member{
+		WorkspaceID: "team-a",
+		UserID:      "alice",
+		Roles:       []auth.Role{auth.RoleViewer},
+	}))
+
+	authenticator := stubAuthenticator{credentials: map[string]*auth.Principal{
+		"jwt-alice": {OwnerID: "alice", WorkspaceID: auth.DefaultWorkspace, Roles: []auth.Role{auth.RoleCreator}},
+		"jwt-bob":   {OwnerID: "bob", WorkspaceID: auth.DefaultWorkspace},
+	}}
+
+	setup := func(t *testing.T, workspaces auth.WorkspaceStore) (*chi.Mux, chan *auth.Principal) {
+		t.Helper()
+
+		router := chi.NewMux()
+		api := humachi.New(router, huma.DefaultConfig("Test", "1.0.0"))
+		api.UseMiddleware(middleware.Authenticate(api, authenticator, zap.NewNop()))
+		api.UseMiddleware(middleware.SelectWorkspace(api, workspaces, zap.NewNop()))
+
+		principals := make(chan *auth.Principal, 1)
+
+		huma.Get(api, "/test", func(ctx context.Context, _ *struct{}) (*testOutput, error) {
+			principals <- auth.PrincipalFromContext(ctx)
+
+			return &testOutput{Body: "ok"}, nil
+		})
+
+		return router, principals
+	}
+
+	serve := func(router *chi.Mux, credential, workspaceID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+
+		if workspaceID != "" {
+			req.Header.Set(middleware.WorkspaceHeader, workspaceID)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("switches members to the requested workspace", func(t *testing.T) {
+		router, principals := setup(t, workspaces)
+
+		w := serve(router, "jwt-alice", "team-a")
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		principal := <-principals
+		assert.Equal(t, "team-a", principal.WorkspaceID)
+		assert.Equal(t, []auth.Role{auth.RoleViewer}, principal.Roles)
+	})
+
+	t.Run("keeps the principal without the header", func(t *testing.T) {
+		router, principals := setup(t, workspaces)
+
+		require.Equal(t, http.StatusOK, serve(router, "jwt-alice", "").Code)
+		assert.Equal(t, auth.DefaultWorkspace, (<-principals).WorkspaceID)
+	})
+
+	t.Run("ignores the header for anonymous requests", func(t *testing.T) {
+		router, principals := setup(t, workspaces)
+
+		require.Equal(t, http.StatusOK, serve(router, "", "team-a").Code)
+		assert.Nil(t, <-principals)
+	})
+
+	t.Run("rejects non-members with 403", func(t *testing.T) {
+		router, _ := setup(t, workspaces)
+
+		w := serve(router, "jwt-bob", "team-a")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "problem+json")
+	})
+
+	t.Run("returns 500 on store errors", func(t *testing.T) {
+		router, _ := setup(t, failingWorkspaceStore{})
+
+		assert.Equal(t, http.StatusInternalServerError, serve(router, "jwt-alice", "team-a").Code)
+	})
+}
+
+type failingWorkspaceStore struct {
+	auth.WorkspaceStore
+}
+
+func (failingWorkspaceStore) GetMember(_ context.Context, _, _ string) (*auth.Member, error) {
+	return nil, errors.New("db down")
+}
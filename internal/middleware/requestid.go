@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/web-demo-go/internal/requestid"
+)
+
+// RequestID is a middleware that tags each request with an ID, echoed in the X-Request-ID
+// response header. A valid ID sent by the client is kept so requests can be traced across services.
+func RequestID(_ huma.API) func(ctx huma.Context, next func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		id := ctx.Header(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		ctx.SetHeader(requestid.Header, id)
+
+		next(huma.WithContext(ctx, requestid.NewContext(ctx.Context(), id)))
+	}
+}
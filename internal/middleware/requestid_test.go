@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/serroba/web-demo-go/internal/middleware"
+	"github.com/serroba/web-demo-go/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	setup := func(t *testing.T) (*chi.Mux, chan string) {
+		t.Helper()
+
+		router := chi.NewMux()
+		api := humachi.New(router, huma.DefaultConfig("Test", "1.0.0"))
+		api.UseMiddleware(middleware.RequestID(api))
+
+		ids := make(chan string, 1)
+
+		huma.Get(api, "/test", func(ctx context.Context, _ *struct{}) (*testOutput, error) {
+			ids <- requestid.FromContext(ctx)
+
+			return &testOutput{Body: "ok"}, nil
+		})
+
+		return router, ids
+	}
+
+	t.Run("generates an ID and echoes it", func(t *testing.T) {
+		router, ids := setup(t)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		id := <-ids
+		require.NotEmpty(t, id)
+		assert.Equal(t, id, w.Header().Get(requestid.Header))
+	})
+
+	t.Run("keeps a valid client ID", func(t *testing.T) {
+		router, ids := setup(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(requestid.Header, "trace-42")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "trace-42", <-ids)
+		assert.Equal(t, "trace-42", w.Header().Get(requestid.Header))
+	})
+
+	t.Run("replaces an invalid client ID", func(t *testing.T) {
+		router, ids := setup(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(requestid.Header, "not valid")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.NotEqual(t, "not valid", <-ids)
+	})
+}
@@ -0,0 +1,226 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Subject is the tenant a usage counter belongs to: a workspace or an API key.
+type Subject string
+
+// WorkspaceSubject returns the subject counting usage of a workspace.
+func WorkspaceSubject(workspaceID string) Subject {
+	return Subject("workspace:" + workspaceID)
+}
+
+// KeySubject returns the subject counting usage of an API key.
+func KeySubject(keyID string) Subject {
+	return Subject("key:" + keyID)
+}
+
+// Kind is the kind of usage a counter tracks.
+type Kind string
+
+const (
+	// KindActiveLinks counts links that currently exist.
+	KindActiveLinks Kind = "activeLinks"
+	// KindMonthlyCreations counts links created in a calendar month (UTC).
+	KindMonthlyCreations Kind = "monthlyCreations"
+)
+
+// Counter identifies a usage counter.
+type Counter struct {
+	Subject Subject
+	Kind    Kind
+	// Period is the month ("2006-01") of a KindMonthlyCreations counter, empty otherwise.
+	Period string
+}
+
+// Reservation asks for a counter to be incremented without passing Limit.
+// A zero Limit means unlimited.
+type Reservation struct {
+	Counter
+	Limit int64
+}
+
+// ExceededError is returned when a reservation would take a counter past its limit.
+type ExceededError struct {
+	Reservation
+	// ResetsAt is when a monthly counter starts over; nil for active links.
+	ResetsAt *time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s of %s limited to %d", e.Kind, e.Subject, e.Limit)
+}
+
+// Store persists usage counters.
+type Store interface {
+	// Get returns the value of a counter; counters never incremented are zero.
+	Get(ctx context.Context, counter Counter) (int64, error)
+	// Reserve increments every counter by one, atomically: if any would pass its limit,
+	// none change and an *ExceededError for that reservation is returned.
+	Reserve(ctx context.Context, reservations []Reservation) error
+	// Release decrements every counter by one, never below zero.
+	Release(ctx context.Context, counters []Counter) error
+}
+
+// Limits are the quotas on link creation. Zero values mean unlimited.
+type Limits struct {
+	// ActiveLinks limits the links a workspace has at any time.
+	ActiveLinks int64
+	// MonthlyCreations limits the links a workspace creates per calendar month.
+	MonthlyCreations int64
+	// KeyMonthlyCreations limits the links an API key creates per calendar month.
+	KeyMonthlyCreations int64
+}
+
+// Meter reports the usage of one counter against its limit.
+type Meter struct {
+	Used     int64
+	Limit    int64 // zero means unlimited
+	ResetsAt *time.Time
+}
+
+// Usage reports the usage of a subject.
+type Usage struct {
+	Subject          Subject
+	ActiveLinks      *Meter // nil for API keys, whose active links are not limited
+	MonthlyCreations Meter
+}
+
+// Enforcer counts link creations and deletions and enforces Limits.
+type Enforcer struct {
+	store  Store
+	limits Limits
+	now    func() time.Time
+}
+
+// NewEnforcer creates a new quota enforcer.
+func NewEnforcer(store Store, limits Limits) *Enforcer {
+	return &Enforcer{store: store, limits: limits, now: time.Now}
+}
+
+// ReserveLink counts a link about to be created in workspaceID, with keyID if the
+// request used an API key. It returns an *ExceededError if a quota would be exceeded.
+func (e *Enforcer) ReserveLink(ctx context.Context, workspaceID, keyID string) error {
+	reservations := e.linkReservations(workspaceID, keyID)
+
+	err := e.store.Reserve(ctx, reservations)
+
+	var exceeded *ExceededError
+	if errors.As(err, &exceeded) && exceeded.Kind == KindMonthlyCreations {
+		resetsAt := e.monthEnd()
+		exceeded.ResetsAt = &resetsAt
+	}
+
+	return err
+}
+
+// CancelLink undoes a ReserveLink whose link was not created after all.
+func (e *Enforcer) CancelLink(ctx context.Context, workspaceID, keyID string) error {
+	reservations := e.linkReservations(workspaceID, keyID)
+
+	counters := make([]Counter, 0, len(reservations))
+	for _, r := range reservations {
+		counters = append(counters, r.Counter)
+	}
+
+	return e.store.Release(ctx, counters)
+}
+
+// RemoveLink frees the active link a deleted link of workspaceID was using.
+// Monthly creations are not given back.
+func (e *Enforcer) RemoveLink(ctx context.Context, workspaceID string) error {
+	return e.store.Release(ctx, []Counter{{Subject: WorkspaceSubject(workspaceID), Kind: KindActiveLinks}})
+}
+
+// RestoreLink reserves the active link a restored link of workspaceID uses again.
+// Restoring is not a creation, so monthly creations are not charged.
+func (e *Enforcer) RestoreLink(ctx context.Context, workspaceID string) error {
+	return e.store.Reserve(ctx, []Reservation{{
+		Counter: Counter{Subject: WorkspaceSubject(workspaceID), Kind: KindActiveLinks},
+		Limit:   e.limits.ActiveLinks,
+	}})
+}
+
+// WorkspaceUsage reports the usage of a workspace.
+func (e *Enforcer) WorkspaceUsage(ctx context.Context, workspaceID string) (*Usage, error) {
+	subject := WorkspaceSubject(workspaceID)
+
+	active, err := e.store.Get(ctx, Counter{Subject: subject, Kind: KindActiveLinks})
+	if err != nil {
+		return nil, err
+	}
+
+	monthly, err := e.monthlyMeter(ctx, subject, e.limits.MonthlyCreations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		Subject:          subject,
+		ActiveLinks:      &Meter{Used: active, Limit: e.limits.ActiveLinks},
+		MonthlyCreations: *monthly,
+	}, nil
+}
+
+// KeyUsage reports the usage of an API key.
+func (e *Enforcer) KeyUsage(ctx context.Context, keyID string) (*Usage, error) {
+	subject := KeySubject(keyID)
+
+	monthly, err := e.monthlyMeter(ctx, subject, e.limits.KeyMonthlyCreations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usage{Subject: subject, MonthlyCreations: *monthly}, nil
+}
+
+func (e *Enforcer) monthlyMeter(ctx context.Context, subject Subject, limit int64) (*Meter, error) {
+	used, err := e.store.Get(ctx, Counter{Subject: subject, Kind: KindMonthlyCreations, Period: e.period()})
+	if err != nil {
+		return nil, err
+	}
+
+	resetsAt := e.monthEnd()
+
+	return &Meter{Used: used, Limit: limit, ResetsAt: &resetsAt}, nil
+}
+
+// linkReservations lists the counters a new link increments.
+func (e *Enforcer) linkReservations(workspaceID, keyID string) []Reservation {
+	workspace := WorkspaceSubject(workspaceID)
+	period := e.period()
+
+	reservations := []Reservation{
+		{Counter: Counter{Subject: workspace, Kind: KindActiveLinks}, Limit: e.limits.ActiveLinks},
+		{
+			Counter: Counter{Subject: workspace, Kind: KindMonthlyCreations, Period: period},
+			Limit:   e.limits.MonthlyCreations,
+		},
+	}
+
+	if keyID != "" {
+		reservations = append(reservations, Reservation{
+			Counter: Counter{Subject: KeySubject(keyID), Kind: KindMonthlyCreations, Period: period},
+			Limit:   e.limits.KeyMonthlyCreations,
+		})
+	}
+
+	return reservations
+}
+
+// period returns the current month, which monthly counters are keyed by.
+func (e *Enforcer) period() string {
+	return e.now().UTC().Format("2006-01")
+}
+
+// monthEnd returns the start of the next month, when monthly counters start over.
+func (e *Enforcer) monthEnd() time.Time {
+	now := e.now().UTC()
+
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,130 @@
+package quota_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/quota"
+	"github.com/serroba/web-demo-go/internal/quota/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct {
+	quota.Store
+}
+
+func (failingStore) Get(_ context.Context, _ quota.Counter) (int64, error) {
+	return 0, errors.New("db down")
+}
+
+func TestEnforcer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("enforces the active links of a workspace", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(store.NewMemory(), quota.Limits{ActiveLinks: 2})
+
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", ""))
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", ""))
+
+		var exceeded *quota.ExceededError
+
+		require.ErrorAs(t, enforcer.ReserveLink(ctx, "team-a", ""), &exceeded)
+		assert.Equal(t, quota.WorkspaceSubject("team-a"), exceeded.Subject)
+		assert.Equal(t, quota.KindActiveLinks, exceeded.Kind)
+		assert.Equal(t, int64(2), exceeded.Limit)
+		assert.Nil(t, exceeded.ResetsAt)
+
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-b", ""), "other workspaces have their own quota")
+
+		require.NoError(t, enforcer.RemoveLink(ctx, "team-a"))
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", ""))
+	})
+
+	t.Run("enforces monthly creations of a workspace", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(store.NewMemory(), quota.Limits{MonthlyCreations: 1})
+
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", ""))
+		require.NoError(t, enforcer.RemoveLink(ctx, "team-a"))
+
+		var exceeded *quota.ExceededError
+
+		require.ErrorAs(t, enforcer.ReserveLink(ctx, "team-a", ""), &exceeded, "deletions do not refund creations")
+		assert.Equal(t, quota.KindMonthlyCreations, exceeded.Kind)
+		require.NotNil(t, exceeded.ResetsAt)
+		assert.True(t, exceeded.ResetsAt.After(time.Now()))
+		assert.Equal(t, 1, exceeded.ResetsAt.Day())
+	})
+
+	t.Run("enforces monthly creations of an API key", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(store.NewMemory(), quota.Limits{KeyMonthlyCreations: 1})
+
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", "key-1"))
+
+		var exceeded *quota.ExceededError
+
+		require.ErrorAs(t, enforcer.ReserveLink(ctx, "team-a", "key-1"), &exceeded)
+		assert.Equal(t, quota.KeySubject("key-1"), exceeded.Subject)
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", "key-2"))
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", ""))
+	})
+
+	t.Run("cancelled links are not counted", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(store.NewMemory(), quota.Limits{})
+
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", "key-1"))
+		require.NoError(t, enforcer.CancelLink(ctx, "team-a", "key-1"))
+
+		usage, err := enforcer.WorkspaceUsage(ctx, "team-a")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), usage.ActiveLinks.Used)
+		assert.Equal(t, int64(0), usage.MonthlyCreations.Used)
+
+		keyUsage, err := enforcer.KeyUsage(ctx, "key-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), keyUsage.MonthlyCreations.Used)
+	})
+
+	t.Run("reports usage against limits", func(t *testing.T) {
+		limits := quota.Limits{ActiveLinks: 10, MonthlyCreations: 20, KeyMonthlyCreations: 5}
+		enforcer := quota.NewEnforcer(store.NewMemory(), limits)
+
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", "key-1"))
+		require.NoError(t, enforcer.ReserveLink(ctx, "team-a", ""))
+
+		usage, err := enforcer.WorkspaceUsage(ctx, "team-a")
+		require.NoError(t, err)
+		assert.Equal(t, quota.WorkspaceSubject("team-a"), usage.Subject)
+		assert.Equal(t, &quota.Meter{Used: 2, Limit: 10}, usage.ActiveLinks)
+		assert.Equal(t, int64(2), usage.MonthlyCreations.Used)
+		assert.Equal(t, int64(20), usage.MonthlyCreations.Limit)
+		assert.NotNil(t, usage.MonthlyCreations.ResetsAt)
+
+		keyUsage, err := enforcer.KeyUsage(ctx, "key-1")
+		require.NoError(t, err)
+		assert.Nil(t, keyUsage.ActiveLinks)
+		assert.Equal(t, int64(1), keyUsage.MonthlyCreations.Used)
+		assert.Equal(t, int64(5), keyUsage.MonthlyCreations.Limit)
+	})
+
+	t.Run("returns store errors from usage", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(failingStore{}, quota.Limits{})
+
+		_, err := enforcer.WorkspaceUsage(ctx, "team-a")
+		require.Error(t, err)
+
+		_, err = enforcer.KeyUsage(ctx, "key-1")
+		require.Error(t, err)
+	})
+}
+
+func TestExceededError(t *testing.T) {
+	err := &quota.ExceededError{Reservation: quota.Reservation{
+		Counter: quota.Counter{Subject: quota.WorkspaceSubject("team-a"), Kind: quota.KindActiveLinks},
+		Limit:   3,
+	}}
+
+	assert.Equal(t, "quota exceeded: activeLinks of workspace:team-a limited to 3", err.Error())
+}
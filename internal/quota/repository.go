@@ -0,0 +1,143 @@
+package quota
+
+import (
+	"context"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// Repository wraps a shortener.Repository, counting saved, deleted and restored
+// links against the quotas of their workspace and of the API key creating them.
+type Repository struct {
+	shortener.Repository
+
+	enforcer *Enforcer
+	logger   *zap.Logger
+}
+
+// NewRepository creates a new quota-enforcing repository decorator.
+func NewRepository(repo shortener.Repository, enforcer *Enforcer, logger *zap.Logger) *Repository {
+	return &Repository{Repository: repo, enforcer: enforcer, logger: logger}
+}
+
+// Save reserves quota for the link before storing it, and releases it if storing fails.
+// It returns an *ExceededError if a quota is exhausted. Links saved deleted, as
+// when a purge is undone, use no quota.
+func (r *Repository) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
+	if shortURL.Deleted() {
+		return r.Repository.Save(ctx, shortURL)
+	}
+
+	keyID := keyIDFromContext(ctx)
+
+	if err := r.enforcer.ReserveLink(ctx, shortURL.WorkspaceID, keyID); err != nil {
+		return err
+	}
+
+	if err := r.Repository.Save(ctx, shortURL); err != nil {
+		if cancelErr := r.enforcer.CancelLink(ctx, shortURL.WorkspaceID, keyID); cancelErr != nil {
+			r.logger.Error("failed to release link quota",
+				zap.String("workspaceId", shortURL.WorkspaceID), zap.Error(cancelErr))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// SaveOrGet reserves quota for the link before storing it, and releases it if
+// storing fails or an equivalent link is returned instead. It returns an
+// *ExceededError if a quota is exhausted, even when such a link exists.
+func (r *Repository) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	keyID := keyIDFromContext(ctx)
+
+	if err := r.enforcer.ReserveLink(ctx, shortURL.WorkspaceID, keyID); err != nil {
+		return nil, false, err
+	}
+
+	stored, created, err := r.Repository.SaveOrGet(ctx, shortURL)
+	if err != nil || !created {
+		if cancelErr := r.enforcer.CancelLink(ctx, shortURL.WorkspaceID, keyID); cancelErr != nil {
+			r.logger.Error("failed to release link quota",
+				zap.String("workspaceId", shortURL.WorkspaceID), zap.Error(cancelErr))
+		}
+	}
+
+	return stored, created, err
+}
+
+// Update replaces a short URL. Deleting a link frees the active link it was
+// using, and restoring one reserves it again, returning an *ExceededError if
+// the workspace has since run out.
+func (r *Repository) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	before, err := r.GetByCode(shortener.ContextWithLatestReads(ctx), shortURL.Code)
+	if err != nil {
+		return err
+	}
+
+	restoring := before.Deleted() && !shortURL.Deleted()
+
+	if restoring {
+		if err := r.enforcer.RestoreLink(ctx, before.WorkspaceID); err != nil {
+			return err
+		}
+	}
+
+	if err := r.Repository.Update(ctx, shortURL); err != nil {
+		if restoring {
+			r.removeLink(ctx, before.WorkspaceID)
+		}
+
+		return err
+	}
+
+	if !before.Deleted() && shortURL.Deleted() {
+		r.removeLink(ctx, before.WorkspaceID)
+	}
+
+	return nil
+}
+
+// Delete removes a short URL and frees the active link it was using,
+// unless it had already been freed when the link was moved to the trash.
+func (r *Repository) Delete(ctx context.Context, code shortener.Code) error {
+	shortURL, err := r.GetByCode(shortener.ContextWithLatestReads(ctx), code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Repository.Delete(ctx, code); err != nil {
+		return err
+	}
+
+	if !shortURL.Deleted() {
+		r.removeLink(ctx, shortURL.WorkspaceID)
+	}
+
+	return nil
+}
+
+// removeLink frees an active link of workspaceID, logging failures: the
+// change it accounts for has already been made.
+func (r *Repository) removeLink(ctx context.Context, workspaceID string) {
+	if err := r.enforcer.RemoveLink(ctx, workspaceID); err != nil {
+		r.logger.Error("failed to release link quota",
+			zap.String("workspaceId", workspaceID), zap.Error(err))
+	}
+}
+
+func keyIDFromContext(ctx context.Context) string {
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		return p.KeyID
+	}
+
+	return ""
+}
+
+// Compile-time check.
+var _ shortener.Repository = (*Repository)(nil)
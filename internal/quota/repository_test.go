@@ -0,0 +1,168 @@
+package quota_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/quota"
+	quotastore "github.com/serroba/web-demo-go/internal/quota/store"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type failingSaveRepository struct {
+	shortener.Repository
+}
+
+func (failingSaveRepository) Save(_ context.Context, _ *shortener.ShortURL) error {
+	return errors.New("db down")
+}
+
+type failingReleaseStore struct {
+	quota.Store
+}
+
+func (failingReleaseStore) Release(_ context.Context, _ []quota.Counter) error {
+	return errors.New("db down")
+}
+
+func TestRepository(t *testing.T) {
+	keyContext := auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+		OwnerID: "alice", KeyID: "key-1", WorkspaceID: "team-a",
+	})
+
+	link := func(code string) *shortener.ShortURL {
+		return &shortener.ShortURL{Code: shortener.Code(code), OriginalURL: "https://example.com", WorkspaceID: "team-a"}
+	}
+
+	t.Run("counts saved and deleted links", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{})
+		repo := quota.NewRepository(store.NewMemoryStore(), enforcer, zap.NewNop())
+
+		require.NoError(t, repo.Save(keyContext, link("abc")))
+		require.NoError(t, repo.Save(keyContext, link("def")))
+		require.NoError(t, repo.Delete(keyContext, "abc"))
+
+		usage, err := enforcer.WorkspaceUsage(keyContext, "team-a")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), usage.ActiveLinks.Used)
+		assert.Equal(t, int64(2), usage.MonthlyCreations.Used)
+
+		keyUsage, err := enforcer.KeyUsage(keyContext, "key-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), keyUsage.MonthlyCreations.Used)
+	})
+
+	t.Run("does not save links over quota", func(t *testing.T) {
+		inner := store.NewMemoryStore()
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{ActiveLinks: 1})
+		repo := quota.NewRepository(inner, enforcer, zap.NewNop())
+
+		require.NoError(t, repo.Save(context.Background(), link("abc")))
+
+		var exceeded *quota.ExceededError
+
+		require.ErrorAs(t, repo.Save(context.Background(), link("def")), &exceeded)
+
+		_, err := inner.GetByCode(context.Background(), "def")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("releases quota when saving fails", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{})
+		repo := quota.NewRepository(failingSaveRepository{}, enforcer, zap.NewNop())
+
+		require.Error(t, repo.Save(keyContext, link("abc")))
+
+		usage, err := enforcer.WorkspaceUsage(keyContext, "team-a")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), usage.ActiveLinks.Used)
+		assert.Equal(t, int64(0), usage.MonthlyCreations.Used)
+	})
+
+	t.Run("frees deleted links and reserves restored ones", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{ActiveLinks: 1})
+		repo := quota.NewRepository(store.NewMemoryStore(), enforcer, zap.NewNop())
+
+		activeLinks := func() int64 {
+			usage, err := enforcer.WorkspaceUsage(keyContext, "team-a")
+			require.NoError(t, err)
+
+			return usage.ActiveLinks.Used
+		}
+
+		require.NoError(t, repo.Save(keyContext, link("abc")))
+
+		deletedAt := time.Now()
+		deleted := link("abc")
+		deleted.DeletedAt = &deletedAt
+		require.NoError(t, repo.Update(keyContext, deleted))
+		assert.Equal(t, int64(0), activeLinks())
+
+		require.NoError(t, repo.Save(keyContext, link("def")))
+
+		var exceeded *quota.ExceededError
+
+		require.ErrorAs(t, repo.Update(keyContext, link("abc")), &exceeded)
+		assert.Equal(t, quota.KindActiveLinks, exceeded.Kind)
+
+		// Purging a deleted link does not free its active link a second time
+		require.NoError(t, repo.Delete(keyContext, "abc"))
+		assert.Equal(t, int64(1), activeLinks())
+
+		require.NoError(t, repo.Delete(keyContext, "def"))
+		assert.Equal(t, int64(0), activeLinks())
+	})
+
+	t.Run("releases quota when an equivalent link is returned", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{})
+		repo := quota.NewRepository(store.NewMemoryStore(), enforcer, zap.NewNop())
+
+		hashLink := func(code string) *shortener.ShortURL {
+			l := link(code)
+			l.URLHash = "somehash"
+			l.Strategy = shortener.StrategyHash
+
+			return l
+		}
+
+		_, created, err := repo.SaveOrGet(keyContext, hashLink("abc"))
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		stored, created, err := repo.SaveOrGet(keyContext, hashLink("def"))
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, shortener.Code("abc"), stored.Code)
+
+		usage, err := enforcer.WorkspaceUsage(keyContext, "team-a")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), usage.ActiveLinks.Used)
+		assert.Equal(t, int64(1), usage.MonthlyCreations.Used)
+	})
+
+	t.Run("returns ErrNotFound when deleting unknown links", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(quotastore.NewMemory(), quota.Limits{})
+		repo := quota.NewRepository(store.NewMemoryStore(), enforcer, zap.NewNop())
+
+		require.ErrorIs(t, repo.Delete(context.Background(), "missing"), shortener.ErrNotFound)
+		require.ErrorIs(t, repo.Update(context.Background(), link("missing")), shortener.ErrNotFound)
+	})
+
+	t.Run("logs counter errors without failing", func(t *testing.T) {
+		enforcer := quota.NewEnforcer(failingReleaseStore{quotastore.NewMemory()}, quota.Limits{})
+
+		failingSave := quota.NewRepository(failingSaveRepository{}, enforcer, zap.NewNop())
+		require.EqualError(t, failingSave.Save(keyContext, link("abc")), "db down")
+
+		repo := quota.NewRepository(store.NewMemoryStore(), enforcer, zap.NewNop())
+		require.NoError(t, repo.Save(keyContext, link("abc")))
+		require.NoError(t, repo.Delete(keyContext, "abc"))
+	})
+}
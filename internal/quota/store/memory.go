@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/serroba/web-demo-go/internal/quota"
+)
+
+// Memory is an in-memory implementation of quota.Store.
+type Memory struct {
+	mu       sync.Mutex
+	counters map[quota.Counter]int64
+}
+
+// NewMemory creates a new in-memory quota store.
+func NewMemory() *Memory {
+	return &Memory{
+		counters: make(map[quota.Counter]int64),
+	}
+}
+
+func (s *Memory) Get(_ context.Context, counter quota.Counter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counters[counter], nil
+}
+
+func (s *Memory) Reserve(_ context.Context, reservations []quota.Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range reservations {
+		if r.Limit > 0 && s.counters[r.Counter] >= r.Limit {
+			return &quota.ExceededError{Reservation: r}
+		}
+	}
+
+	for _, r := range reservations {
+		s.counters[r.Counter]++
+	}
+
+	return nil
+}
+
+func (s *Memory) Release(_ context.Context, counters []quota.Counter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range counters {
+		if s.counters[c] > 0 {
+			s.counters[c]--
+		}
+	}
+
+	return nil
+}
+
+// Compile-time check.
+var _ quota.Store = (*Memory)(nil)
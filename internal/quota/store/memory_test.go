@@ -0,0 +1,63 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/quota"
+	"github.com/serroba/web-demo-go/internal/quota/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	links := quota.Counter{Subject: quota.WorkspaceSubject("team-a"), Kind: quota.KindActiveLinks}
+	monthly := quota.Counter{
+		Subject: quota.WorkspaceSubject("team-a"),
+		Kind:    quota.KindMonthlyCreations,
+		Period:  "2026-10",
+	}
+
+	t.Run("counts reservations and releases", func(t *testing.T) {
+		s := store.NewMemory()
+
+		require.NoError(t, s.Reserve(ctx, []quota.Reservation{{Counter: links}, {Counter: monthly}}))
+		require.NoError(t, s.Reserve(ctx, []quota.Reservation{{Counter: links}}))
+		require.NoError(t, s.Release(ctx, []quota.Counter{links}))
+
+		used, err := s.Get(ctx, links)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), used)
+
+		used, err = s.Get(ctx, monthly)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), used)
+	})
+
+	t.Run("reserves all counters or none", func(t *testing.T) {
+		s := store.NewMemory()
+		require.NoError(t, s.Reserve(ctx, []quota.Reservation{{Counter: monthly, Limit: 1}}))
+
+		err := s.Reserve(ctx, []quota.Reservation{{Counter: links, Limit: 5}, {Counter: monthly, Limit: 1}})
+
+		var exceeded *quota.ExceededError
+
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, monthly, exceeded.Counter)
+
+		used, err := s.Get(ctx, links)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), used)
+	})
+
+	t.Run("never releases below zero", func(t *testing.T) {
+		s := store.NewMemory()
+
+		require.NoError(t, s.Release(ctx, []quota.Counter{links}))
+
+		used, err := s.Get(ctx, links)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), used)
+	})
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/serroba/web-demo-go/internal/quota"
+)
+
+// Postgres is a PostgreSQL implementation of quota.Store, and the source of truth for usage.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgres creates a new PostgreSQL-backed quota store.
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+func (p *Postgres) Get(ctx context.Context, counter quota.Counter) (int64, error) {
+	query := `SELECT used FROM quota_usage WHERE subject = $1 AND kind = $2 AND period = $3`
+
+	var used int64
+
+	err := p.pool.QueryRow(ctx, query, string(counter.Subject), string(counter.Kind), counter.Period).Scan(&used)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+
+	return used, err
+}
+
+// Reserve increments the counters in one transaction. The conditional upsert only
+// matches rows below their limit, so concurrent reservations cannot overshoot it.
+func (p *Postgres) Reserve(ctx context.Context, reservations []quota.Reservation) error {
+	query := `
+		INSERT INTO quota_usage (subject, kind, period, used)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (subject, kind, period) DO UPDATE SET used = quota_usage.used + 1
+		WHERE $4 = 0 OR quota_usage.used < $4
+		RETURNING used
+	`
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		for _, r := range reservations {
+			var used int64
+
+			err := tx.QueryRow(ctx, query, string(r.Subject), string(r.Kind), r.Period, r.Limit).Scan(&used)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return &quota.ExceededError{Reservation: r}
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (p *Postgres) Release(ctx context.Context, counters []quota.Counter) error {
+	query := `
+		UPDATE quota_usage SET used = used - 1
+		WHERE subject = $1 AND kind = $2 AND period = $3 AND used > 0
+	`
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		for _, c := range counters {
+			if _, err := tx.Exec(ctx, query, string(c.Subject), string(c.Kind), c.Period); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Compile-time check.
+var _ quota.Store = (*Postgres)(nil)
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/serroba/web-demo-go/internal/quota"
+)
+
+// RedisCache wraps a quota.Store with a Redis fast path.
+//
+// Usage is read from Redis when cached, and reservations against a counter
+// known to be at its limit are rejected without reaching the underlying store.
+// Writes always go to the underlying store, which stays the source of truth.
+type RedisCache struct {
+	store  quota.Store
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisCache creates a new Redis-cached quota store decorator.
+func NewRedisCache(store quota.Store, client *redis.Client, ttl time.Duration) *RedisCache {
+	return &RedisCache{
+		store:  store,
+		client: client,
+		prefix: "quota:",
+		ttl:    ttl,
+	}
+}
+
+// Get returns the value of a counter, checking cache first.
+func (r *RedisCache) Get(ctx context.Context, counter quota.Counter) (int64, error) {
+	if used, err := r.client.Get(ctx, r.key(counter)).Int64(); err == nil {
+		return used, nil
+	}
+
+	used, err := r.store.Get(ctx, counter)
+	if err != nil {
+		return 0, err
+	}
+
+	r.client.Set(ctx, r.key(counter), used, r.ttl)
+
+	return used, nil
+}
+
+// Reserve rejects reservations whose cached counter is at its limit,
+// and otherwise reserves in the underlying store.
+func (r *RedisCache) Reserve(ctx context.Context, reservations []quota.Reservation) error {
+	for _, res := range reservations {
+		if res.Limit == 0 {
+			continue
+		}
+
+		if used, err := r.client.Get(ctx, r.key(res.Counter)).Int64(); err == nil && used >= res.Limit {
+			return &quota.ExceededError{Reservation: res}
+		}
+	}
+
+	err := r.store.Reserve(ctx, reservations)
+
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		// Remember the counter is full so further reservations fail fast
+		r.client.Set(ctx, r.key(exceeded.Counter), exceeded.Limit, r.ttl)
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, countersOf(reservations))
+
+	return nil
+}
+
+// Release releases in the underlying store and invalidates the cached counters.
+func (r *RedisCache) Release(ctx context.Context, counters []quota.Counter) error {
+	if err := r.store.Release(ctx, counters); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, counters)
+
+	return nil
+}
+
+func (r *RedisCache) invalidate(ctx context.Context, counters []quota.Counter) {
+	keys := make([]string, 0, len(counters))
+	for _, c := range counters {
+		keys = append(keys, r.key(c))
+	}
+
+	r.client.Del(ctx, keys...)
+}
+
+func (r *RedisCache) key(c quota.Counter) string {
+	return r.prefix + string(c.Subject) + ":" + string(c.Kind) + ":" + c.Period
+}
+
+func countersOf(reservations []quota.Reservation) []quota.Counter {
+	counters := make([]quota.Counter, 0, len(reservations))
+	for _, r := range reservations {
+		counters = append(counters, r.Counter)
+	}
+
+	return counters
+}
+
+// Compile-time check.
+var _ quota.Store = (*RedisCache)(nil)
@@ -0,0 +1,53 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header is the HTTP header carrying the request ID.
+const Header = "X-Request-ID"
+
+// maxLength bounds request IDs supplied by clients.
+const maxLength = 128
+
+type requestIDKey struct{}
+
+// New generates a random request ID.
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether a client-supplied request ID can be used as is:
+// non-empty, bounded in length and made of printable ASCII.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := range len(id) {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewContext returns a context carrying the request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID of the context, or an empty string outside of requests.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+
+	return ""
+}
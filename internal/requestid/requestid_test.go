@@ -0,0 +1,31 @@
+package requestid_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	id := requestid.New()
+
+	assert.Len(t, id, 32)
+	assert.True(t, requestid.Valid(id))
+	assert.NotEqual(t, id, requestid.New())
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, requestid.Valid("req-123"))
+	assert.False(t, requestid.Valid(""))
+	assert.False(t, requestid.Valid("has space"))
+	assert.False(t, requestid.Valid("line\nbreak"))
+	assert.False(t, requestid.Valid(strings.Repeat("a", 129)))
+}
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, requestid.FromContext(context.Background()))
+	assert.Equal(t, "req-123", requestid.FromContext(requestid.NewContext(context.Background(), "req-123")))
+}
@@ -0,0 +1,117 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// batchSize is the number of tombstones purged per query.
+const batchSize = 100
+
+// Tombstones finds deleted links.
+type Tombstones interface {
+	// ExpiredTombstones returns the codes of up to limit links deleted before deletedBefore.
+	ExpiredTombstones(ctx context.Context, deletedBefore time.Time, limit int) ([]shortener.Code, error)
+}
+
+// Purger permanently removes deleted links once their restore window has passed.
+type Purger struct {
+	tombstones Tombstones
+	repo       shortener.Repository
+	events     analytics.EventDeleter
+	window     time.Duration
+	logger     *zap.Logger
+}
+
+// NewPurger creates a new purger of links deleted longer than window ago.
+// Links are removed through repo, so its decorators see every purge. If events
+// is not nil, the analytics events of purged links are removed too.
+func NewPurger(
+	tombstones Tombstones,
+	repo shortener.Repository,
+	events analytics.EventDeleter,
+	window time.Duration,
+	logger *zap.Logger,
+) *Purger {
+	return &Purger{
+		tombstones: tombstones,
+		repo:       repo,
+		events:     events,
+		window:     window,
+		logger:     logger,
+	}
+}
+
+// Purge removes every expired tombstone and returns how many it removed.
+// Links can only be restored within the window, so purging cannot race a restore.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	deletedBefore := time.Now().Add(-p.window)
+	purged := 0
+
+	for {
+		codes, err := p.tombstones.ExpiredTombstones(ctx, deletedBefore, batchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, code := range codes {
+			if err := p.purge(ctx, code); err != nil {
+				return purged, err
+			}
+
+			purged++
+		}
+
+		if len(codes) < batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// Run purges expired tombstones every interval until ctx is done.
+// Failures are logged and retried on the next tick.
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := p.Purge(ctx)
+			if err != nil && ctx.Err() == nil {
+				p.logger.Error("failed to purge deleted links", zap.Int("purged", purged), zap.Error(err))
+
+				continue
+			}
+
+			if purged > 0 {
+				p.logger.Info("purged deleted links", zap.Int("purged", purged))
+			}
+		}
+	}
+}
+
+// purge removes one link. Its events go first: a link whose events could not
+// be removed stays in the trash, to be retried.
+func (p *Purger) purge(ctx context.Context, code shortener.Code) error {
+	if p.events != nil {
+		if err := p.events.DeleteEvents(ctx, string(code)); err != nil {
+			return err
+		}
+	}
+
+	err := p.repo.Delete(ctx, code)
+	if errors.Is(err, shortener.ErrNotFound) {
+		// Purged concurrently by another instance
+		return nil
+	}
+
+	return err
+}
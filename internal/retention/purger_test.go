@@ -0,0 +1,153 @@
+package retention_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/retention"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type recordingEvents struct {
+	deleted []string
+	err     error
+}
+
+func (r *recordingEvents) DeleteEvents(_ context.Context, code string) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.deleted = append(r.deleted, code)
+
+	return nil
+}
+
+type failingTombstones struct{}
+
+func (failingTombstones) ExpiredTombstones(_ context.Context, _ time.Time, _ int) ([]shortener.Code, error) {
+	return nil, assert.AnError
+}
+
+// staleTombstones reports codes that are no longer stored, as a replica lagging behind would.
+type staleTombstones struct {
+	codes []shortener.Code
+}
+
+func (s *staleTombstones) ExpiredTombstones(_ context.Context, _ time.Time, _ int) ([]shortener.Code, error) {
+	codes := s.codes
+	s.codes = nil
+
+	return codes, nil
+}
+
+func TestPurger(t *testing.T) {
+	ctx := context.Background()
+
+	save := func(t *testing.T, repo *store.MemoryStore, code string, deletedAgo time.Duration) {
+		t.Helper()
+
+		link := &shortener.ShortURL{Code: shortener.Code(code), OriginalURL: "https://example.com"}
+		if deletedAgo > 0 {
+			deletedAt := time.Now().Add(-deletedAgo)
+			link.DeletedAt = &deletedAt
+		}
+
+		require.NoError(t, repo.Save(ctx, link))
+	}
+
+	exists := func(repo *store.MemoryStore, code string) bool {
+		_, err := repo.GetByCode(ctx, shortener.Code(code))
+
+		return err == nil
+	}
+
+	t.Run("purges links deleted before the window", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		save(t, repo, "live", 0)
+		save(t, repo, "recent", time.Hour)
+		save(t, repo, "expired", 48*time.Hour)
+
+		events := &recordingEvents{}
+		purger := retention.NewPurger(repo, repo, events, 24*time.Hour, zap.NewNop())
+
+		purged, err := purger.Purge(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		assert.True(t, exists(repo, "live"))
+		assert.True(t, exists(repo, "recent"))
+		assert.False(t, exists(repo, "expired"))
+		assert.Equal(t, []string{"expired"}, events.deleted)
+	})
+
+	t.Run("purges in batches", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		for n := range 250 {
+			save(t, repo, fmt.Sprintf("code%d", n), 48*time.Hour)
+		}
+
+		purger := retention.NewPurger(repo, repo, nil, 24*time.Hour, zap.NewNop())
+
+		purged, err := purger.Purge(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 250, purged)
+	})
+
+	t.Run("keeps links whose events could not be deleted", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		save(t, repo, "expired", 48*time.Hour)
+
+		purger := retention.NewPurger(repo, repo, &recordingEvents{err: assert.AnError}, 24*time.Hour, zap.NewNop())
+
+		_, err := purger.Purge(ctx)
+
+		require.ErrorIs(t, err, assert.AnError)
+		assert.True(t, exists(repo, "expired"))
+	})
+
+	t.Run("skips links already purged", func(t *testing.T) {
+		tombstones := &staleTombstones{codes: []shortener.Code{"gone"}}
+		purger := retention.NewPurger(tombstones, store.NewMemoryStore(), nil, time.Hour, zap.NewNop())
+
+		purged, err := purger.Purge(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+	})
+
+	t.Run("returns tombstone lookup errors", func(t *testing.T) {
+		purger := retention.NewPurger(failingTombstones{}, store.NewMemoryStore(), nil, time.Hour, zap.NewNop())
+
+		_, err := purger.Purge(ctx)
+
+		require.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("runs until cancelled", func(t *testing.T) {
+		repo := store.NewMemoryStore()
+		save(t, repo, "expired", 48*time.Hour)
+
+		purger := retention.NewPurger(repo, repo, nil, 24*time.Hour, zap.NewNop())
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+
+		go func() {
+			purger.Run(runCtx, time.Millisecond)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return !exists(repo, "expired") }, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+	})
+}
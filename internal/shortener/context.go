@@ -0,0 +1,19 @@
+package shortener
+
+import "context"
+
+type latestReadsKey struct{}
+
+// ContextWithLatestReads returns a context whose lookups skip caches and read
+// replicas, reading links from their store. Changes based on a link read it
+// this way, so a stale copy cannot undo changes made since it was cached.
+func ContextWithLatestReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, latestReadsKey{}, true)
+}
+
+// LatestReadsFromContext reports whether lookups of the context read links from their store.
+func LatestReadsFromContext(ctx context.Context) bool {
+	latest, _ := ctx.Value(latestReadsKey{}).(bool)
+
+	return latest
+}
@@ -0,0 +1,91 @@
+package shortener
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Cursor identifies a position in a listing ordered by (CreatedAt, Code), newest first.
+type Cursor struct {
+	CreatedAt time.Time
+	Code      Code
+}
+
+// ListQuery filters and paginates a listing of short URLs.
+// Zero values mean "no filter".
+type ListQuery struct {
+	CreatedFrom time.Time // inclusive lower bound on CreatedAt
+	CreatedTo   time.Time // exclusive upper bound on CreatedAt
+	Tag         string
+	Domain      string // destination host, compared case-insensitively
+	Strategy    string
+	URLHash     URLHash // hash of an equivalent destination
+	Search      string  // case-insensitive substring of the URL or title
+	OwnerID     string
+	WorkspaceID string
+	After       *Cursor
+	Limit       int
+}
+
+// Lister lists short URLs. It is implemented by stores that can enumerate
+// their contents efficiently and is not part of Repository, so cache layers
+// do not need to support it.
+type Lister interface {
+	List(ctx context.Context, query ListQuery) ([]*ShortURL, error)
+}
+
+// Matches reports whether a short URL satisfies the query filters.
+// Deleted links never match. The cursor and limit are not considered.
+func (q ListQuery) Matches(s *ShortURL) bool {
+	switch {
+	case s.Deleted():
+		return false
+	case !q.CreatedFrom.IsZero() && s.CreatedAt.Before(q.CreatedFrom):
+		return false
+	case !q.CreatedTo.IsZero() && !s.CreatedAt.Before(q.CreatedTo):
+		return false
+	case q.Tag != "" && !slices.Contains(s.Tags, q.Tag):
+		return false
+	case q.Domain != "" && !strings.EqualFold(DestinationDomain(s.OriginalURL), q.Domain):
+		return false
+	case q.Strategy != "" && s.Strategy != q.Strategy:
+		return false
+	case q.URLHash != "" && s.URLHash != q.URLHash:
+		return false
+	case q.Search != "" && !containsFold(s.OriginalURL, q.Search) && !containsFold(s.Title, q.Search):
+		return false
+	case q.OwnerID != "" && s.OwnerID != q.OwnerID:
+		return false
+	case q.WorkspaceID != "" && s.WorkspaceID != q.WorkspaceID:
+		return false
+	}
+
+	return true
+}
+
+// Precedes reports whether s sorts before the cursor position in a newest-first listing,
+// i.e. whether it belongs on a page that starts after the cursor.
+func (c Cursor) Precedes(s *ShortURL) bool {
+	if s.CreatedAt.Equal(c.CreatedAt) {
+		return s.Code < c.Code
+	}
+
+	return s.CreatedAt.Before(c.CreatedAt)
+}
+
+// DestinationDomain returns the lowercased host of a URL without its port.
+func DestinationDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
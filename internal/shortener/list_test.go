@@ -0,0 +1,78 @@
+package shortener_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListQuery_Matches(t *testing.T) {
+	now := time.Now()
+	link := &shortener.ShortURL{
+		Code:        "abc123",
+		OriginalURL: "https://Docs.Example.com:8443/guide",
+		URLHash:     "somehash",
+		Strategy:    shortener.StrategyHash,
+		OwnerID:     "alice",
+		WorkspaceID: "team-a",
+		CreatedAt:   now,
+		Metadata:    shortener.Metadata{Title: "Getting Started", Tags: []string{"docs"}},
+	}
+
+	tests := []struct {
+		name    string
+		query   shortener.ListQuery
+		matches bool
+	}{
+		{name: "empty query", query: shortener.ListQuery{}, matches: true},
+		{name: "created from inclusive", query: shortener.ListQuery{CreatedFrom: now}, matches: true},
+		{name: "created from after", query: shortener.ListQuery{CreatedFrom: now.Add(time.Second)}, matches: false},
+		{name: "created to exclusive", query: shortener.ListQuery{CreatedTo: now}, matches: false},
+		{name: "created to after", query: shortener.ListQuery{CreatedTo: now.Add(time.Second)}, matches: true},
+		{name: "tag present", query: shortener.ListQuery{Tag: "docs"}, matches: true},
+		{name: "tag absent", query: shortener.ListQuery{Tag: "blog"}, matches: false},
+		{name: "domain ignores case and port", query: shortener.ListQuery{Domain: "docs.example.COM"}, matches: true},
+		{name: "other domain", query: shortener.ListQuery{Domain: "example.com"}, matches: false},
+		{name: "strategy", query: shortener.ListQuery{Strategy: shortener.StrategyHash}, matches: true},
+		{name: "other strategy", query: shortener.ListQuery{Strategy: shortener.StrategyToken}, matches: false},
+		{name: "url hash", query: shortener.ListQuery{URLHash: "somehash"}, matches: true},
+		{name: "other url hash", query: shortener.ListQuery{URLHash: "otherhash"}, matches: false},
+		{name: "search in url", query: shortener.ListQuery{Search: "GUIDE"}, matches: true},
+		{name: "search in title", query: shortener.ListQuery{Search: "started"}, matches: true},
+		{name: "search miss", query: shortener.ListQuery{Search: "pricing"}, matches: false},
+		{name: "owner", query: shortener.ListQuery{OwnerID: "alice"}, matches: true},
+		{name: "other owner", query: shortener.ListQuery{OwnerID: "bob"}, matches: false},
+		{name: "workspace", query: shortener.ListQuery{WorkspaceID: "team-a"}, matches: true},
+		{name: "other workspace", query: shortener.ListQuery{WorkspaceID: "team-b"}, matches: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, tt.query.Matches(link))
+		})
+	}
+
+	t.Run("deleted links never match", func(t *testing.T) {
+		deleted := *link
+		deleted.DeletedAt = &now
+
+		assert.False(t, shortener.ListQuery{}.Matches(&deleted))
+	})
+}
+
+func TestCursor_Precedes(t *testing.T) {
+	now := time.Now()
+	cursor := shortener.Cursor{CreatedAt: now, Code: "mmm"}
+
+	assert.True(t, cursor.Precedes(&shortener.ShortURL{Code: "zzz", CreatedAt: now.Add(-time.Second)}))
+	assert.False(t, cursor.Precedes(&shortener.ShortURL{Code: "aaa", CreatedAt: now.Add(time.Second)}))
+	assert.True(t, cursor.Precedes(&shortener.ShortURL{Code: "aaa", CreatedAt: now}))
+	assert.False(t, cursor.Precedes(&shortener.ShortURL{Code: "mmm", CreatedAt: now}))
+}
+
+func TestDestinationDomain(t *testing.T) {
+	assert.Equal(t, "example.com", shortener.DestinationDomain("HTTPS://Example.COM:443/path"))
+	assert.Empty(t, shortener.DestinationDomain("://invalid"))
+}
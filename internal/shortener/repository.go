@@ -5,12 +5,32 @@ import (
 	"errors"
 )
 
-// ErrNotFound is returned when a short URL is not found.
-var ErrNotFound = errors.New("short url not found")
+var (
+	// ErrNotFound is returned when a short URL is not found.
+	ErrNotFound = errors.New("short url not found")
+	// ErrDuplicate is returned when a hash-strategy short URL cannot be made live
+	// because another live link of its workspace already deduplicates its URL.
+	ErrDuplicate = errors.New("an equivalent hash-strategy link already exists")
+)
 
 // Repository defines the interface for short URL storage operations.
 type Repository interface {
 	Save(ctx context.Context, shortURL *ShortURL) error
+	// SaveOrGet saves a hash-strategy short URL unless a live link of its workspace
+	// already deduplicates its URL hash, in which case it returns that link and
+	// saves nothing; created reports which happened. It is atomic, so concurrent
+	// creations for one URL all end up with the same link.
+	SaveOrGet(ctx context.Context, shortURL *ShortURL) (stored *ShortURL, created bool, err error)
+	// GetByCode returns the short URL for a code, including deleted ones.
 	GetByCode(ctx context.Context, code Code) (*ShortURL, error)
-	GetByHash(ctx context.Context, hash URLHash) (*ShortURL, error)
+	// GetByHash finds the live short URL for a URL hash within a workspace;
+	// hash-strategy deduplication never crosses workspaces or returns deleted links.
+	GetByHash(ctx context.Context, workspaceID string, hash URLHash) (*ShortURL, error)
+	// Update replaces the stored fields of an existing short URL. Links are deleted
+	// and restored by updating DeletedAt.
+	// It returns ErrNotFound if no short URL exists for the code, and ErrDuplicate
+	// if a restored hash-strategy link has meanwhile been recreated.
+	Update(ctx context.Context, shortURL *ShortURL) error
+	// Delete permanently removes a short URL. It returns ErrNotFound if no short URL exists for the code.
+	Delete(ctx context.Context, code Code) error
 }
@@ -0,0 +1,56 @@
+package shortener
+
+import (
+	"strings"
+	"sync"
+)
+
+// ReservedWords is a registry of words that cannot be used as codes, such as
+// the first path segments of API routes, which links served at the root would
+// otherwise shadow. Words are matched case-insensitively.
+type ReservedWords struct {
+	mu    sync.RWMutex
+	words map[string]struct{}
+}
+
+// NewReservedWords creates a new registry holding words.
+func NewReservedWords(words ...string) *ReservedWords {
+	r := &ReservedWords{words: make(map[string]struct{})}
+	r.Add(words...)
+
+	return r
+}
+
+// Add reserves words. Empty words are ignored.
+func (r *ReservedWords) Add(words ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			r.words[w] = struct{}{}
+		}
+	}
+}
+
+// Reserved reports whether code is a reserved word.
+func (r *ReservedWords) Reserved(code string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.words[strings.ToLower(code)]
+
+	return ok
+}
+
+// Generator wraps generate so it never returns a reserved word; reserved codes
+// are discarded and generated again.
+func (r *ReservedWords) Generator(generate CodeGenerator) CodeGenerator {
+	return func() string {
+		for {
+			if code := generate(); !r.Reserved(code) {
+				return code
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package shortener_test
+
+import (
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedWords(t *testing.T) {
+	t.Run("matches words case-insensitively", func(t *testing.T) {
+		reserved := shortener.NewReservedWords("docs", " Shorten ", "")
+
+		assert.True(t, reserved.Reserved("docs"))
+		assert.True(t, reserved.Reserved("DOCS"))
+		assert.True(t, reserved.Reserved("shorten"))
+		assert.False(t, reserved.Reserved("abc123"))
+		assert.False(t, reserved.Reserved(""))
+	})
+
+	t.Run("adds words later", func(t *testing.T) {
+		reserved := shortener.NewReservedWords()
+		reserved.Add("health")
+
+		assert.True(t, reserved.Reserved("health"))
+	})
+
+	t.Run("generator skips reserved codes", func(t *testing.T) {
+		reserved := shortener.NewReservedWords("docs", "links")
+		codes := []string{"docs", "Links", "abc123"}
+		calls := 0
+
+		generate := reserved.Generator(func() string {
+			code := codes[calls]
+			calls++
+
+			return code
+		})
+
+		assert.Equal(t, "abc123", generate())
+		assert.Equal(t, 3, calls)
+	})
+}
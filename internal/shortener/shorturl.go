@@ -12,6 +12,53 @@ type URLHash string
 type ShortURL struct {
 	Code        Code
 	OriginalURL string
-	URLHash     URLHash // empty for token strategy, populated for hash strategy
+	URLHash     URLHash // hash of the normalized URL, empty if it could not be normalized
+	Strategy    string  // name of the strategy that created the short URL
+	OwnerID     string  // empty for links created anonymously
+	WorkspaceID string  // tenant the link belongs to
 	CreatedAt   time.Time
+	DisabledAt  *time.Time // set when an admin takes the link down
+	DeletedAt   *time.Time // set when the link is deleted; it is purged once the restore window passes
+	Metadata
+}
+
+// Deduplicated reports whether the link is the one hash-strategy creations of
+// its URL return, and so the one GetByHash finds.
+func (s *ShortURL) Deduplicated() bool {
+	return s.Strategy == StrategyHash && s.URLHash != ""
+}
+
+// Disabled reports whether the link has been taken down.
+func (s *ShortURL) Disabled() bool {
+	return s.DisabledAt != nil
+}
+
+// Deleted reports whether the link is a tombstone awaiting restore or purge.
+func (s *ShortURL) Deleted() bool {
+	return s.DeletedAt != nil
+}
+
+// Limits of the user supplied metadata of links, checked by the API and imports.
+// The request types of the API repeat them in their validation tags.
+const (
+	MaxTitleLength = 512
+	MaxNotesLength = 2000
+	MaxTags        = 20
+)
+
+// Metadata holds the descriptive attributes of a link.
+// Title, Tags and Notes are user supplied; Description and ImageURL are
+// filled in from the destination page when it is fetched.
+type Metadata struct {
+	Title       string
+	Description string
+	ImageURL    string
+	Tags        []string
+	Notes       string
+}
+
+// NeedsEnrichment reports whether any field that can be discovered from the
+// destination page is still empty.
+func (m Metadata) NeedsEnrichment() bool {
+	return m.Title == "" || m.Description == "" || m.ImageURL == ""
 }
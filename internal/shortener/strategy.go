@@ -8,7 +8,45 @@ import (
 
 // Strategy defines the interface for URL shortening strategies.
 type Strategy interface {
-	Shorten(ctx context.Context, url string) (*ShortURL, error)
+	Shorten(ctx context.Context, url string, opts ...Option) (*ShortURL, error)
+}
+
+// Strategy names recorded in ShortURL.Strategy.
+const (
+	StrategyToken = "token"
+	StrategyHash  = "hash"
+	StrategyWords = "words"
+)
+
+// Option customizes a short URL before it is saved.
+type Option func(*ShortURL)
+
+// WithMetadata attaches user supplied metadata to a new short URL.
+func WithMetadata(meta Metadata) Option {
+	return func(s *ShortURL) {
+		s.Metadata = meta
+	}
+}
+
+// WithOwner records the owner of a new short URL.
+func WithOwner(ownerID string) Option {
+	return func(s *ShortURL) {
+		s.OwnerID = ownerID
+	}
+}
+
+// WithWorkspace records the workspace a new short URL belongs to.
+// Hash-strategy deduplication is scoped to this workspace.
+func WithWorkspace(workspaceID string) Option {
+	return func(s *ShortURL) {
+		s.WorkspaceID = workspaceID
+	}
+}
+
+func applyOptions(shortURL *ShortURL, opts []Option) {
+	for _, opt := range opts {
+		opt(shortURL)
+	}
 }
 
 // CodeGenerator generates unique short codes.
@@ -18,6 +56,7 @@ type CodeGenerator func() string
 type TokenStrategy struct {
 	store        Repository
 	generateCode CodeGenerator
+	name         string
 }
 
 // NewTokenStrategy creates a new token-based shortening strategy.
@@ -25,17 +64,36 @@ func NewTokenStrategy(store Repository, generator CodeGenerator) *TokenStrategy
 	return &TokenStrategy{
 		store:        store,
 		generateCode: generator,
+		name:         StrategyToken,
 	}
 }
 
-func (s *TokenStrategy) Shorten(ctx context.Context, url string) (*ShortURL, error) {
+// NewWordStrategy creates a token strategy whose links are recorded as
+// StrategyWords, for use with a generator of human-readable codes.
+func NewWordStrategy(store Repository, generator CodeGenerator) *TokenStrategy {
+	return &TokenStrategy{
+		store:        store,
+		generateCode: generator,
+		name:         StrategyWords,
+	}
+}
+
+// Shorten creates a new short URL. Its URL hash is recorded for reverse lookups
+// only; unlike with HashStrategy, equivalent URLs still get new codes.
+func (s *TokenStrategy) Shorten(ctx context.Context, url string, opts ...Option) (*ShortURL, error) {
+	// A URL that cannot be normalized is shortened all the same, without a hash
+	hash, _ := HashOf(url)
+
 	shortURL := &ShortURL{
 		Code:        Code(s.generateCode()),
 		OriginalURL: url,
-		URLHash:     "",
+		URLHash:     hash,
+		Strategy:    s.name,
 		CreatedAt:   time.Now(),
 	}
 
+	applyOptions(shortURL, opts)
+
 	if err := s.store.Save(ctx, shortURL); err != nil {
 		return nil, err
 	}
@@ -57,15 +115,24 @@ func NewHashStrategy(store Repository, generator CodeGenerator) *HashStrategy {
 	}
 }
 
-func (s *HashStrategy) Shorten(ctx context.Context, rawURL string) (*ShortURL, error) {
-	normalizedURL, err := NormalizeURL(rawURL)
+// Shorten returns the existing short URL for an equivalent URL, if any.
+// Options only apply when a new short URL is created.
+func (s *HashStrategy) Shorten(ctx context.Context, rawURL string, opts ...Option) (*ShortURL, error) {
+	hash, err := HashOf(rawURL)
 	if err != nil {
 		return nil, err
 	}
 
-	urlHash := URLHash(HashURL(normalizedURL))
+	shortURL := &ShortURL{
+		OriginalURL: rawURL,
+		URLHash:     hash,
+		Strategy:    StrategyHash,
+		CreatedAt:   time.Now(),
+	}
 
-	existing, err := s.store.GetByHash(ctx, urlHash)
+	applyOptions(shortURL, opts)
+
+	existing, err := s.store.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
 	if err == nil {
 		return existing, nil
 	}
@@ -74,16 +141,13 @@ func (s *HashStrategy) Shorten(ctx context.Context, rawURL string) (*ShortURL, e
 		return nil, err
 	}
 
-	shortURL := &ShortURL{
-		Code:        Code(s.generateCode()),
-		OriginalURL: rawURL,
-		URLHash:     urlHash,
-		CreatedAt:   time.Now(),
-	}
+	// A concurrent request for the same URL may have created it since
+	shortURL.Code = Code(s.generateCode())
 
-	if err = s.store.Save(ctx, shortURL); err != nil {
+	stored, _, err := s.store.SaveOrGet(ctx, shortURL)
+	if err != nil {
 		return nil, err
 	}
 
-	return shortURL, nil
+	return stored, nil
 }
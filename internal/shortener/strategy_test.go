@@ -14,8 +14,11 @@ const testNewCode = "newcode"
 
 type mockRepository struct {
 	saveFunc      func(ctx context.Context, shortURL *shortener.ShortURL) error
+	saveOrGetFunc func(ctx context.Context, shortURL *shortener.ShortURL) (*shortener.ShortURL, bool, error)
 	getByCodeFunc func(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error)
-	getByHashFunc func(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error)
+	getByHashFunc func(ctx context.Context, workspaceID string, hash shortener.URLHash) (*shortener.ShortURL, error)
+	updateFunc    func(ctx context.Context, shortURL *shortener.ShortURL) error
+	deleteFunc    func(ctx context.Context, code shortener.Code) error
 }
 
 func (m *mockRepository) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
@@ -26,6 +29,21 @@ func (m *mockRepository) Save(ctx context.Context, shortURL *shortener.ShortURL)
 	return nil
 }
 
+// SaveOrGet defaults to saving with Save.
+func (m *mockRepository) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	if m.saveOrGetFunc != nil {
+		return m.saveOrGetFunc(ctx, shortURL)
+	}
+
+	if err := m.Save(ctx, shortURL); err != nil {
+		return nil, false, err
+	}
+
+	return shortURL, true, nil
+}
+
 func (m *mockRepository) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
 	if m.getByCodeFunc != nil {
 		return m.getByCodeFunc(ctx, code)
@@ -34,14 +52,32 @@ func (m *mockRepository) GetByCode(ctx context.Context, code shortener.Code) (*s
 	return nil, shortener.ErrNotFound
 }
 
-func (m *mockRepository) GetByHash(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
+func (m *mockRepository) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
 	if m.getByHashFunc != nil {
-		return m.getByHashFunc(ctx, hash)
+		return m.getByHashFunc(ctx, workspaceID, hash)
 	}
 
 	return nil, shortener.ErrNotFound
 }
 
+func (m *mockRepository) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	if m.updateFunc != nil {
+		return m.updateFunc(ctx, shortURL)
+	}
+
+	return nil
+}
+
+func (m *mockRepository) Delete(ctx context.Context, code shortener.Code) error {
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, code)
+	}
+
+	return nil
+}
+
 func TestTokenStrategy_Shorten(t *testing.T) {
 	t.Run("generates new code and saves", func(t *testing.T) {
 		var savedURL *shortener.ShortURL
@@ -61,10 +97,23 @@ func TestTokenStrategy_Shorten(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, shortener.Code("abc123"), result.Code)
 		assert.Equal(t, "https://example.com", result.OriginalURL)
-		assert.Empty(t, result.URLHash)
+		assert.Equal(t, shortener.URLHash(shortener.HashURL("https://example.com")), result.URLHash)
+		assert.Equal(t, shortener.StrategyToken, result.Strategy)
 		assert.Equal(t, savedURL, result)
 	})
 
+	t.Run("applies metadata option", func(t *testing.T) {
+		repo := &mockRepository{}
+		generator := func() string { return "abc123" }
+		meta := shortener.Metadata{Title: "Example", Tags: []string{"docs"}, Notes: "internal"}
+
+		strategy := shortener.NewTokenStrategy(repo, generator)
+		result, err := strategy.Shorten(context.Background(), "https://example.com", shortener.WithMetadata(meta))
+
+		require.NoError(t, err)
+		assert.Equal(t, meta, result.Metadata)
+	})
+
 	t.Run("returns error when save fails", func(t *testing.T) {
 		saveErr := errors.New("save failed")
 		repo := &mockRepository{
@@ -82,6 +131,17 @@ func TestTokenStrategy_Shorten(t *testing.T) {
 	})
 }
 
+func TestWordStrategy_Shorten(t *testing.T) {
+	generator := func() string { return "brave-otter-42" }
+
+	strategy := shortener.NewWordStrategy(&mockRepository{}, generator)
+	result, err := strategy.Shorten(context.Background(), "https://example.com")
+
+	require.NoError(t, err)
+	assert.Equal(t, shortener.Code("brave-otter-42"), result.Code)
+	assert.Equal(t, shortener.StrategyWords, result.Strategy)
+}
+
 func TestHashStrategy_Shorten(t *testing.T) {
 	t.Run("returns existing short URL when hash exists", func(t *testing.T) {
 		existing := &shortener.ShortURL{
@@ -90,7 +150,7 @@ func TestHashStrategy_Shorten(t *testing.T) {
 			URLHash:     "somehash",
 		}
 		repo := &mockRepository{
-			getByHashFunc: func(_ context.Context, _ shortener.URLHash) (*shortener.ShortURL, error) {
+			getByHashFunc: func(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
 				return existing, nil
 			},
 		}
@@ -103,11 +163,32 @@ func TestHashStrategy_Shorten(t *testing.T) {
 		assert.Equal(t, existing, result)
 	})
 
+	t.Run("does not apply options to existing short URL", func(t *testing.T) {
+		existing := &shortener.ShortURL{
+			Code:        "existing",
+			OriginalURL: "https://example.com",
+			URLHash:     "somehash",
+		}
+		repo := &mockRepository{
+			getByHashFunc: func(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
+				return existing, nil
+			},
+		}
+		generator := func() string { return testNewCode }
+
+		strategy := shortener.NewHashStrategy(repo, generator)
+		result, err := strategy.Shorten(context.Background(), "https://example.com",
+			shortener.WithMetadata(shortener.Metadata{Title: "Ignored"}))
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Title)
+	})
+
 	t.Run("creates new short URL when hash not found", func(t *testing.T) {
 		var savedURL *shortener.ShortURL
 
 		repo := &mockRepository{
-			getByHashFunc: func(_ context.Context, _ shortener.URLHash) (*shortener.ShortURL, error) {
+			getByHashFunc: func(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
 				return nil, shortener.ErrNotFound
 			},
 			saveFunc: func(_ context.Context, s *shortener.ShortURL) error {
@@ -125,13 +206,34 @@ func TestHashStrategy_Shorten(t *testing.T) {
 		assert.Equal(t, shortener.Code("newcode"), result.Code)
 		assert.Equal(t, "https://example.com", result.OriginalURL)
 		assert.NotEmpty(t, result.URLHash)
+		assert.Equal(t, shortener.StrategyHash, result.Strategy)
 		assert.Equal(t, savedURL, result)
 	})
 
+	t.Run("looks up existing short URLs within the workspace", func(t *testing.T) {
+		var lookedUp string
+
+		repo := &mockRepository{
+			getByHashFunc: func(_ context.Context, workspaceID string, _ shortener.URLHash) (*shortener.ShortURL, error) {
+				lookedUp = workspaceID
+
+				return nil, shortener.ErrNotFound
+			},
+		}
+		generator := func() string { return testNewCode }
+
+		strategy := shortener.NewHashStrategy(repo, generator)
+		result, err := strategy.Shorten(context.Background(), "https://example.com", shortener.WithWorkspace("team-a"))
+
+		require.NoError(t, err)
+		assert.Equal(t, "team-a", lookedUp)
+		assert.Equal(t, "team-a", result.WorkspaceID)
+	})
+
 	t.Run("returns error when GetByHash fails with non-ErrNotFound", func(t *testing.T) {
 		repoErr := errors.New("repository error")
 		repo := &mockRepository{
-			getByHashFunc: func(_ context.Context, _ shortener.URLHash) (*shortener.ShortURL, error) {
+			getByHashFunc: func(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
 				return nil, repoErr
 			},
 		}
@@ -144,10 +246,26 @@ func TestHashStrategy_Shorten(t *testing.T) {
 		assert.ErrorIs(t, err, repoErr)
 	})
 
+	t.Run("returns the link saved by a concurrent request", func(t *testing.T) {
+		winner := &shortener.ShortURL{Code: "winner", OriginalURL: "https://example.com"}
+		repo := &mockRepository{
+			saveOrGetFunc: func(_ context.Context, _ *shortener.ShortURL) (*shortener.ShortURL, bool, error) {
+				return winner, false, nil
+			},
+		}
+		generator := func() string { return testNewCode }
+
+		strategy := shortener.NewHashStrategy(repo, generator)
+		result, err := strategy.Shorten(context.Background(), "https://example.com")
+
+		require.NoError(t, err)
+		assert.Equal(t, winner, result)
+	})
+
 	t.Run("returns error when Save fails", func(t *testing.T) {
 		saveErr := errors.New("save failed")
 		repo := &mockRepository{
-			getByHashFunc: func(_ context.Context, _ shortener.URLHash) (*shortener.ShortURL, error) {
+			getByHashFunc: func(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
 				return nil, shortener.ErrNotFound
 			},
 			saveFunc: func(_ context.Context, _ *shortener.ShortURL) error {
@@ -174,3 +292,17 @@ func TestHashStrategy_Shorten(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestMetadata_NeedsEnrichment(t *testing.T) {
+	t.Run("true when a fetchable field is empty", func(t *testing.T) {
+		meta := shortener.Metadata{Title: "Title", Description: "Description"}
+
+		assert.True(t, meta.NeedsEnrichment())
+	})
+
+	t.Run("false when all fetchable fields are set", func(t *testing.T) {
+		meta := shortener.Metadata{Title: "Title", Description: "Description", ImageURL: "https://example.com/a.png"}
+
+		assert.False(t, meta.NeedsEnrichment())
+	})
+}
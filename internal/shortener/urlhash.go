@@ -41,6 +41,16 @@ func NormalizeURL(rawURL string) (string, error) {
 	return u.String(), nil
 }
 
+// HashOf returns the hash of rawURL once normalized.
+func HashOf(rawURL string) (URLHash, error) {
+	normalizedURL, err := NormalizeURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	return URLHash(HashURL(normalizedURL)), nil
+}
+
 // HashURL computes a SHA256 hash of the normalized URL.
 // Returns the hash as a hex-encoded string.
 func HashURL(normalizedURL string) string {
@@ -151,3 +151,18 @@ func TestNormalizeAndHash_Equivalence(t *testing.T) {
 		}
 	}
 }
+
+func TestHashOf(t *testing.T) {
+	hash, err := shortener.HashOf("https://EXAMPLE.com/path/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := shortener.URLHash(shortener.HashURL("https://example.com/path")); hash != want {
+		t.Errorf("HashOf() = %s, want %s", hash, want)
+	}
+
+	if _, err := shortener.HashOf("://invalid"); err == nil {
+		t.Error("expected error for invalid URL, got nil")
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// batchFlushTimeout bounds a flush, which outlives the requests whose saves it
+// writes.
+const batchFlushTimeout = 10 * time.Second
+
+// BatchRepository is a Repository that can also save many short URLs at once.
+type BatchRepository interface {
+	shortener.Repository
+	// SaveBatch saves short URLs like Save does, all or none of them.
+	SaveBatch(ctx context.Context, shortURLs []*shortener.ShortURL) error
+}
+
+// BatchConfig configures the coalescing of saves.
+type BatchConfig struct {
+	MaxRows int           // saves written together at most
+	MaxWait time.Duration // how long a save waits for others to join it
+}
+
+// BatchingRepository wraps a BatchRepository, coalescing concurrent saves into
+// batches written in one round-trip. A batch is written once it holds MaxRows
+// saves, or MaxWait after its first one. Other operations pass through.
+type BatchingRepository struct {
+	BatchRepository
+
+	config BatchConfig
+
+	mu      sync.Mutex
+	pending []*pendingSave
+	batch   uint64 // incremented whenever pending saves are taken
+}
+
+// pendingSave is a save waiting for its batch to be written.
+type pendingSave struct {
+	ctx      context.Context //nolint:containedctx // checked when the batch is written
+	shortURL *shortener.ShortURL
+	done     chan error
+}
+
+// NewBatchingRepository creates a new batching repository decorator.
+func NewBatchingRepository(repo BatchRepository, config BatchConfig) *BatchingRepository {
+	return &BatchingRepository{BatchRepository: repo, config: config}
+}
+
+// Save queues a short URL for the next batch, and returns once it is written.
+// A save whose ctx ends first is abandoned unless its batch is being written.
+func (b *BatchingRepository) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
+	save := &pendingSave{ctx: ctx, shortURL: shortURL, done: make(chan error, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, save)
+
+	switch {
+	case len(b.pending) >= b.config.MaxRows:
+		saves := b.take()
+		b.mu.Unlock()
+
+		// The batch is written for every save in it, not only this one
+		b.write(context.WithoutCancel(ctx), saves)
+	case len(b.pending) == 1:
+		batch := b.batch
+		b.mu.Unlock()
+
+		time.AfterFunc(b.config.MaxWait, func() { b.flush(context.Background(), batch) })
+	default:
+		b.mu.Unlock()
+	}
+
+	select {
+	case err := <-save.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush writes the pending saves, unless the batch they were waiting for has
+// already been taken.
+func (b *BatchingRepository) flush(ctx context.Context, batch uint64) {
+	b.mu.Lock()
+
+	if b.batch != batch {
+		b.mu.Unlock()
+
+		return
+	}
+
+	saves := b.take()
+	b.mu.Unlock()
+
+	b.write(ctx, saves)
+}
+
+// take returns the pending saves, starting a new batch. b.mu must be held.
+func (b *BatchingRepository) take() []*pendingSave {
+	saves := b.pending
+	b.pending = nil
+	b.batch++
+
+	return saves
+}
+
+// write saves a batch, skipping abandoned saves. If the batch fails, its saves
+// are retried one by one, so a save failing on its own does not fail the others
+// and each caller gets its own error.
+func (b *BatchingRepository) write(ctx context.Context, saves []*pendingSave) {
+	ctx, cancel := context.WithTimeout(ctx, batchFlushTimeout)
+	defer cancel()
+
+	live := make([]*pendingSave, 0, len(saves))
+	shortURLs := make([]*shortener.ShortURL, 0, len(saves))
+
+	for _, save := range saves {
+		if err := save.ctx.Err(); err != nil {
+			save.done <- err
+
+			continue
+		}
+
+		live = append(live, save)
+		shortURLs = append(shortURLs, save.shortURL)
+	}
+
+	if len(live) == 0 {
+		return
+	}
+
+	err := b.SaveBatch(ctx, shortURLs)
+	if err == nil || len(live) == 1 {
+		for _, save := range live {
+			save.done <- err
+		}
+
+		return
+	}
+
+	for _, save := range live {
+		save.done <- b.BatchRepository.Save(ctx, save.shortURL)
+	}
+}
@@ -0,0 +1,150 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchStore is a memory store recording the batches it saves. Batches fail
+// when failBatches is set, and saves of failCode fail on their own.
+type batchStore struct {
+	*store.MemoryStore
+
+	failBatches bool
+	failCode    shortener.Code
+
+	mu      sync.Mutex
+	batches [][]shortener.Code
+}
+
+func (s *batchStore) SaveBatch(ctx context.Context, shortURLs []*shortener.ShortURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := make([]shortener.Code, 0, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		codes = append(codes, shortURL.Code)
+	}
+
+	s.batches = append(s.batches, codes)
+
+	if s.failBatches {
+		return errors.New("batch failed")
+	}
+
+	for _, shortURL := range shortURLs {
+		if err := s.MemoryStore.Save(ctx, shortURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *batchStore) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
+	if shortURL.Code == s.failCode {
+		return errors.New("save failed")
+	}
+
+	return s.MemoryStore.Save(ctx, shortURL)
+}
+
+func (s *batchStore) recorded() [][]shortener.Code {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.batches
+}
+
+// saveAll saves links with the given codes concurrently, returning each save's error.
+func saveAll(repo shortener.Repository, codes ...shortener.Code) map[shortener.Code]error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[shortener.Code]error)
+	)
+
+	for _, code := range codes {
+		wg.Go(func() {
+			err := repo.Save(context.Background(), &shortener.ShortURL{Code: code})
+
+			mu.Lock()
+			errs[code] = err
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+func TestBatchingRepository_Save(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("writes a batch once it is full", func(t *testing.T) {
+		inner := &batchStore{MemoryStore: store.NewMemoryStore()}
+		repo := store.NewBatchingRepository(inner, store.BatchConfig{MaxRows: 3, MaxWait: time.Hour})
+
+		errs := saveAll(repo, "a", "b", "c")
+
+		assert.Equal(t, map[shortener.Code]error{"a": nil, "b": nil, "c": nil}, errs)
+		require.Len(t, inner.recorded(), 1)
+		assert.ElementsMatch(t, []shortener.Code{"a", "b", "c"}, inner.recorded()[0])
+
+		_, err := repo.GetByCode(ctx, "b")
+		require.NoError(t, err)
+	})
+
+	t.Run("writes a partial batch after waiting", func(t *testing.T) {
+		inner := &batchStore{MemoryStore: store.NewMemoryStore()}
+		repo := store.NewBatchingRepository(inner, store.BatchConfig{MaxRows: 100, MaxWait: 10 * time.Millisecond})
+
+		require.NoError(t, repo.Save(ctx, &shortener.ShortURL{Code: "alone"}))
+
+		assert.Equal(t, [][]shortener.Code{{"alone"}}, inner.recorded())
+	})
+
+	t.Run("retries a failed batch one save at a time", func(t *testing.T) {
+		inner := &batchStore{MemoryStore: store.NewMemoryStore(), failBatches: true, failCode: "bad"}
+		repo := store.NewBatchingRepository(inner, store.BatchConfig{MaxRows: 2, MaxWait: time.Hour})
+
+		errs := saveAll(repo, "good", "bad")
+
+		require.NoError(t, errs["good"])
+		require.EqualError(t, errs["bad"], "save failed")
+
+		_, err := repo.GetByCode(ctx, "good")
+		require.NoError(t, err)
+
+		_, err = repo.GetByCode(ctx, "bad")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("skips saves abandoned before their batch is written", func(t *testing.T) {
+		inner := &batchStore{MemoryStore: store.NewMemoryStore()}
+		repo := store.NewBatchingRepository(inner, store.BatchConfig{MaxRows: 100, MaxWait: 10 * time.Millisecond})
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := repo.Save(cancelled, &shortener.ShortURL{Code: "abandoned"})
+		require.ErrorIs(t, err, context.Canceled)
+
+		// Joins the batch of the abandoned save, and waits for it to be written
+		require.NoError(t, repo.Save(ctx, &shortener.ShortURL{Code: "kept"}))
+
+		assert.Equal(t, [][]shortener.Code{{"kept"}}, inner.recorded())
+
+		_, err = repo.GetByCode(ctx, "abandoned")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+}
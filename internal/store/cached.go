@@ -2,22 +2,47 @@ package store
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/cache"
 	"github.com/serroba/web-demo-go/internal/shortener"
+	"golang.org/x/sync/singleflight"
 )
 
+// loadTimeout bounds a load of a code shared by concurrent callers, which
+// outlives any single caller.
+const loadTimeout = 10 * time.Second
+
+// CachedConfig configures a CachedRepository.
+type CachedConfig struct {
+	MissTTL time.Duration // how long codes the store does not hold are cached as missing, not at all if 0
+	// Bus carries changes of links between servers, each evicting them from its
+	// cache while it listens, if not nil.
+	Bus InvalidationBus
+}
+
 // CachedRepository wraps a Repository with an LRU cache for GetByCode lookups.
+// Concurrent misses of a code query the store once.
 type CachedRepository struct {
-	store shortener.Repository
-	cache *cache.LRU
+	store  shortener.Repository
+	cache  *cache.LRU
+	config CachedConfig
+	loads  singleflight.Group
+
+	// evictions counts the evictions of changes made on other servers, which
+	// links loaded before them may predate
+	mu        sync.Mutex
+	evictions uint64
 }
 
 // NewCachedRepository creates a new cached repository decorator.
-func NewCachedRepository(store shortener.Repository, c *cache.LRU) *CachedRepository {
+func NewCachedRepository(store shortener.Repository, c *cache.LRU, config CachedConfig) *CachedRepository {
 	return &CachedRepository{
-		store: store,
-		cache: c,
+		store:  store,
+		cache:  c,
+		config: config,
 	}
 }
 
@@ -29,30 +54,176 @@ func (c *CachedRepository) Save(ctx context.Context, shortURL *shortener.ShortUR
 
 	// Write-through: update cache after successful save
 	c.cache.Set(string(shortURL.Code), shortURL)
+	c.publish(ctx, shortURL.Code)
 
 	return nil
 }
 
-// GetByCode retrieves a short URL by its code, using cache-aside pattern.
+// SaveOrGet saves a short URL unless the store already holds an equivalent one,
+// and caches the link that ends up stored.
+func (c *CachedRepository) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	stored, created, err := c.store.SaveOrGet(ctx, shortURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if created {
+		c.cache.Set(string(stored.Code), stored)
+		c.publish(ctx, stored.Code)
+	} else {
+		// The existing link was read, and may predate a cached write
+		c.cache.Add(string(stored.Code), stored)
+	}
+
+	return stored, created, nil
+}
+
+// GetByCode retrieves a short URL by its code, using cache-aside pattern. Lookups
+// asking for the latest version read the store, leaving the cache to the change
+// that follows them.
 func (c *CachedRepository) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
-	// Check cache first
+	if shortener.LatestReadsFromContext(ctx) {
+		return c.store.GetByCode(ctx, code)
+	}
+
+	// Check cache first, including codes known to be missing
 	if url, ok := c.cache.Get(string(code)); ok {
+		if url == nil {
+			return nil, shortener.ErrNotFound
+		}
+
 		return url, nil
 	}
 
-	// Cache miss - fetch from store
+	// Cache miss - fetch from store, once for concurrent misses
+	return coalesce(ctx, &c.loads, code, c.load)
+}
+
+// load fetches a code from the store and caches the result.
+func (c *CachedRepository) load(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	c.mu.Lock()
+	evictions := c.evictions
+	c.mu.Unlock()
+
 	url, err := c.store.GetByCode(ctx, code)
+
+	// A change evicted from the cache meanwhile may be newer than the result,
+	// which is then not cached
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stale := c.evictions != evictions
+
+	if errors.Is(err, shortener.ErrNotFound) && c.config.MissTTL > 0 && !stale {
+		// Saving the code replaces its entry, so it cannot hide a new link
+		c.cache.AddMissing(string(code), c.config.MissTTL)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	// Populate cache
-	c.cache.Set(string(code), url)
+	// Populate cache, unless a concurrent write already cached a newer version
+	// such as a tombstone: the value read here may predate it
+	if !stale {
+		c.cache.Add(string(code), url)
+	}
 
 	return url, nil
 }
 
 // GetByHash retrieves a short URL by its hash (pass-through, not cached).
-func (c *CachedRepository) GetByHash(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
-	return c.store.GetByHash(ctx, hash)
+func (c *CachedRepository) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
+	return c.store.GetByHash(ctx, workspaceID, hash)
+}
+
+// Update updates a short URL and refreshes the cache.
+// Deleting a link this way caches its tombstone, so reads keep seeing it as deleted.
+func (c *CachedRepository) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	if err := c.store.Update(ctx, shortURL); err != nil {
+		return err
+	}
+
+	c.cache.Set(string(shortURL.Code), shortURL)
+	c.publish(ctx, shortURL.Code)
+
+	return nil
+}
+
+// Delete removes a short URL and evicts it from the cache.
+func (c *CachedRepository) Delete(ctx context.Context, code shortener.Code) error {
+	if err := c.store.Delete(ctx, code); err != nil {
+		return err
+	}
+
+	c.cache.Delete(string(code))
+	c.publish(ctx, code)
+
+	return nil
+}
+
+// Listen evicts the links changed on other servers from the cache until ctx is
+// done, and flushes the cache whenever changes may have been missed. Without a
+// bus, it returns at once.
+func (c *CachedRepository) Listen(ctx context.Context) {
+	if c.config.Bus == nil {
+		return
+	}
+
+	c.config.Bus.Subscribe(ctx, func(code shortener.Code) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.evictions++
+		c.cache.Delete(string(code))
+	}, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.evictions++
+		c.cache.Clear()
+	})
+}
+
+// publish tells the other servers that the link of a code changed. Changes are
+// published once made, on a best-effort basis: a server missing one keeps
+// serving the link it cached.
+func (c *CachedRepository) publish(ctx context.Context, code shortener.Code) {
+	if c.config.Bus != nil {
+		_ = c.config.Bus.Publish(ctx, code)
+	}
+}
+
+// coalesce runs load once for all concurrent callers of a code, each waiting
+// until its own ctx is done. Load runs without the cancellation of the caller
+// that started it, which would fail the others.
+func coalesce(
+	ctx context.Context,
+	group *singleflight.Group,
+	code shortener.Code,
+	load func(context.Context, shortener.Code) (*shortener.ShortURL, error),
+) (*shortener.ShortURL, error) {
+	results := group.DoChan(string(code), func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		return load(loadCtx, code)
+	})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		url, _ := result.Val.(*shortener.ShortURL)
+
+		return url, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
@@ -3,7 +3,10 @@ package store_test
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/cache"
 	"github.com/serroba/web-demo-go/internal/shortener"
@@ -14,8 +17,11 @@ import (
 
 type mockStore struct {
 	saveFunc      func(ctx context.Context, shortURL *shortener.ShortURL) error
+	saveOrGetFunc func(ctx context.Context, shortURL *shortener.ShortURL) (*shortener.ShortURL, bool, error)
 	getByCodeFunc func(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error)
-	getByHashFunc func(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error)
+	getByHashFunc func(ctx context.Context, workspaceID string, hash shortener.URLHash) (*shortener.ShortURL, error)
+	updateFunc    func(ctx context.Context, shortURL *shortener.ShortURL) error
+	deleteFunc    func(ctx context.Context, code shortener.Code) error
 	callCount     int
 }
 
@@ -29,6 +35,18 @@ func (m *mockStore) Save(ctx context.Context, shortURL *shortener.ShortURL) erro
 	return nil
 }
 
+func (m *mockStore) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	m.callCount++
+
+	if m.saveOrGetFunc != nil {
+		return m.saveOrGetFunc(ctx, shortURL)
+	}
+
+	return shortURL, true, nil
+}
+
 func (m *mockStore) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
 	m.callCount++
 
@@ -39,16 +57,67 @@ func (m *mockStore) GetByCode(ctx context.Context, code shortener.Code) (*shorte
 	return nil, shortener.ErrNotFound
 }
 
-func (m *mockStore) GetByHash(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
+func (m *mockStore) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
 	m.callCount++
 
 	if m.getByHashFunc != nil {
-		return m.getByHashFunc(ctx, hash)
+		return m.getByHashFunc(ctx, workspaceID, hash)
 	}
 
 	return nil, shortener.ErrNotFound
 }
 
+func (m *mockStore) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	m.callCount++
+
+	if m.updateFunc != nil {
+		return m.updateFunc(ctx, shortURL)
+	}
+
+	return nil
+}
+
+func (m *mockStore) Delete(ctx context.Context, code shortener.Code) error {
+	m.callCount++
+
+	if m.deleteFunc != nil {
+		return m.deleteFunc(ctx, code)
+	}
+
+	return nil
+}
+
+// gatedStore is a memory store counting lookups by code, which wait until
+// release is closed.
+type gatedStore struct {
+	*store.MemoryStore
+
+	release chan struct{}
+	lookups atomic.Int32
+}
+
+func newGatedStore() *gatedStore {
+	return &gatedStore{MemoryStore: store.NewMemoryStore(), release: make(chan struct{})}
+}
+
+func (g *gatedStore) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	g.lookups.Add(1)
+	<-g.release
+
+	return g.MemoryStore.GetByCode(ctx, code)
+}
+
+// awaitLookup waits until a lookup is waiting for the gate, then leaves other
+// callers time to join it.
+func (g *gatedStore) awaitLookup(t *testing.T) {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return g.lookups.Load() > 0 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+}
+
 func TestCachedRepository_GetByCode(t *testing.T) {
 	t.Run("cache miss fetches from store and caches", func(t *testing.T) {
 		url := &shortener.ShortURL{
@@ -61,7 +130,7 @@ func TestCachedRepository_GetByCode(t *testing.T) {
 			},
 		}
 		lru := cache.New(10)
-		cached := store.NewCachedRepository(mock, lru)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
 
 		// First call - cache miss
 		result, err := cached.GetByCode(context.Background(), "abc123")
@@ -78,6 +147,24 @@ func TestCachedRepository_GetByCode(t *testing.T) {
 		assert.Equal(t, 1, mock.callCount, "store should NOT be called on cache hit")
 	})
 
+	t.Run("latest reads skip the cache", func(t *testing.T) {
+		stored := &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"}
+		mock := &mockStore{
+			getByCodeFunc: func(_ context.Context, _ shortener.Code) (*shortener.ShortURL, error) {
+				return stored, nil
+			},
+		}
+		lru := cache.New(10)
+		lru.Set("abc123", &shortener.ShortURL{Code: "abc123", OriginalURL: "https://stale.example"})
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
+
+		result, err := cached.GetByCode(shortener.ContextWithLatestReads(context.Background()), "abc123")
+
+		require.NoError(t, err)
+		assert.Equal(t, stored, result)
+		assert.Equal(t, 1, mock.callCount)
+	})
+
 	t.Run("cache miss with error does not cache", func(t *testing.T) {
 		storeErr := errors.New("store error")
 		mock := &mockStore{
@@ -86,7 +173,7 @@ func TestCachedRepository_GetByCode(t *testing.T) {
 			},
 		}
 		lru := cache.New(10)
-		cached := store.NewCachedRepository(mock, lru)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
 
 		_, err := cached.GetByCode(context.Background(), "abc123")
 
@@ -94,7 +181,7 @@ func TestCachedRepository_GetByCode(t *testing.T) {
 		assert.Equal(t, 0, lru.Len(), "error should not be cached")
 	})
 
-	t.Run("ErrNotFound is not cached", func(t *testing.T) {
+	t.Run("ErrNotFound is not cached without a miss TTL", func(t *testing.T) {
 		callCount := 0
 		mock := &mockStore{
 			getByCodeFunc: func(_ context.Context, _ shortener.Code) (*shortener.ShortURL, error) {
@@ -104,7 +191,7 @@ func TestCachedRepository_GetByCode(t *testing.T) {
 			},
 		}
 		lru := cache.New(10)
-		cached := store.NewCachedRepository(mock, lru)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
 
 		// First call
 		_, err := cached.GetByCode(context.Background(), "missing")
@@ -116,6 +203,101 @@ func TestCachedRepository_GetByCode(t *testing.T) {
 
 		assert.Equal(t, 2, callCount, "store should be called each time for not found")
 	})
+
+	t.Run("ErrNotFound is cached for the miss TTL until the code is saved", func(t *testing.T) {
+		mock := &mockStore{}
+		lru := cache.New(10)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{MissTTL: time.Minute})
+
+		for range 2 {
+			_, err := cached.GetByCode(context.Background(), "unknown")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+		}
+
+		assert.Equal(t, 1, mock.callCount, "store should be called once for a missing code")
+
+		url := &shortener.ShortURL{Code: "unknown", OriginalURL: "https://example.com"}
+		require.NoError(t, cached.Save(context.Background(), url))
+
+		result, err := cached.GetByCode(context.Background(), "unknown")
+		require.NoError(t, err)
+		assert.Equal(t, url, result)
+	})
+
+	t.Run("missing codes expire", func(t *testing.T) {
+		mock := &mockStore{}
+		cached := store.NewCachedRepository(mock, cache.New(10), store.CachedConfig{MissTTL: time.Millisecond})
+
+		_, err := cached.GetByCode(context.Background(), "unknown")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		time.Sleep(5 * time.Millisecond)
+
+		_, err = cached.GetByCode(context.Background(), "unknown")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, 2, mock.callCount)
+	})
+}
+
+func TestCachedRepository_CoalescesMisses(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("concurrent misses query the store once", func(t *testing.T) {
+		gated := newGatedStore()
+		require.NoError(t, gated.Save(ctx, &shortener.ShortURL{Code: "viral", OriginalURL: "https://example.com"}))
+
+		cached := store.NewCachedRepository(gated, cache.New(10), store.CachedConfig{})
+
+		var wg sync.WaitGroup
+
+		for range 50 {
+			wg.Go(func() {
+				url, err := cached.GetByCode(ctx, "viral")
+				if assert.NoError(t, err) {
+					assert.Equal(t, "https://example.com", url.OriginalURL)
+				}
+			})
+		}
+
+		gated.awaitLookup(t)
+		close(gated.release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), gated.lookups.Load())
+	})
+
+	t.Run("a caller giving up does not fail the others", func(t *testing.T) {
+		gated := newGatedStore()
+		require.NoError(t, gated.Save(ctx, &shortener.ShortURL{Code: "viral", OriginalURL: "https://example.com"}))
+
+		cached := store.NewCachedRepository(gated, cache.New(10), store.CachedConfig{})
+		first, cancel := context.WithCancel(ctx)
+		firstErr := make(chan error, 1)
+
+		go func() {
+			_, err := cached.GetByCode(first, "viral")
+			firstErr <- err
+		}()
+
+		gated.awaitLookup(t)
+
+		second := make(chan *shortener.ShortURL, 1)
+
+		go func() {
+			url, _ := cached.GetByCode(ctx, "viral")
+			second <- url
+		}()
+
+		cancel()
+		require.ErrorIs(t, <-firstErr, context.Canceled)
+
+		close(gated.release)
+
+		url := <-second
+		require.NotNil(t, url)
+		assert.Equal(t, "https://example.com", url.OriginalURL)
+		assert.Equal(t, int32(1), gated.lookups.Load())
+	})
 }
 
 func TestCachedRepository_Save(t *testing.T) {
@@ -126,7 +308,7 @@ func TestCachedRepository_Save(t *testing.T) {
 		}
 		mock := &mockStore{}
 		lru := cache.New(10)
-		cached := store.NewCachedRepository(mock, lru)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
 
 		err := cached.Save(context.Background(), url)
 
@@ -150,7 +332,7 @@ func TestCachedRepository_Save(t *testing.T) {
 			},
 		}
 		lru := cache.New(10)
-		cached := store.NewCachedRepository(mock, lru)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
 
 		url := &shortener.ShortURL{
 			Code:        "abc123",
@@ -169,27 +351,244 @@ func TestCachedRepository_GetByHash(t *testing.T) {
 			Code:        "abc123",
 			OriginalURL: "https://example.com",
 			URLHash:     "hash123",
+			Strategy:    shortener.StrategyHash,
 		}
 		mock := &mockStore{
-			getByHashFunc: func(_ context.Context, _ shortener.URLHash) (*shortener.ShortURL, error) {
+			getByHashFunc: func(_ context.Context, _ string, _ shortener.URLHash) (*shortener.ShortURL, error) {
 				return url, nil
 			},
 		}
 		lru := cache.New(10)
-		cached := store.NewCachedRepository(mock, lru)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
 
 		// First call
-		result, err := cached.GetByHash(context.Background(), "hash123")
+		result, err := cached.GetByHash(context.Background(), "", "hash123")
 
 		require.NoError(t, err)
 		assert.Equal(t, url, result)
 		assert.Equal(t, 1, mock.callCount)
 
 		// Second call - should still hit store (not cached)
-		result, err = cached.GetByHash(context.Background(), "hash123")
+		result, err = cached.GetByHash(context.Background(), "", "hash123")
 
 		require.NoError(t, err)
 		assert.Equal(t, url, result)
 		assert.Equal(t, 2, mock.callCount, "store should be called each time (no caching)")
 	})
 }
+
+func TestCachedRepository_Update(t *testing.T) {
+	t.Run("update refreshes cache", func(t *testing.T) {
+		mock := &mockStore{}
+		lru := cache.New(10)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
+
+		_ = cached.Save(context.Background(), &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"})
+
+		updated := &shortener.ShortURL{
+			Code:        "abc123",
+			OriginalURL: "https://example.com",
+			Metadata:    shortener.Metadata{Title: "Example"},
+		}
+		err := cached.Update(context.Background(), updated)
+
+		require.NoError(t, err)
+
+		mock.callCount = 0
+		result, err := cached.GetByCode(context.Background(), "abc123")
+
+		require.NoError(t, err)
+		assert.Equal(t, updated, result)
+		assert.Equal(t, 0, mock.callCount, "store should not be called (cache hit)")
+	})
+
+	t.Run("stale read cannot resurrect a tombstone", func(t *testing.T) {
+		live := &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"}
+		deletedAt := time.Now()
+		tombstone := &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com", DeletedAt: &deletedAt}
+
+		lru := cache.New(10)
+
+		var cached *store.CachedRepository
+
+		// The read hits the store before the link is deleted, and returns after
+		// the deletion has cached the tombstone.
+		mock := &mockStore{
+			getByCodeFunc: func(ctx context.Context, _ shortener.Code) (*shortener.ShortURL, error) {
+				require.NoError(t, cached.Update(ctx, tombstone))
+
+				return live, nil
+			},
+		}
+		cached = store.NewCachedRepository(mock, lru, store.CachedConfig{})
+
+		stale, err := cached.GetByCode(context.Background(), "abc123")
+		require.NoError(t, err)
+		assert.False(t, stale.Deleted())
+
+		result, err := cached.GetByCode(context.Background(), "abc123")
+
+		require.NoError(t, err)
+		assert.True(t, result.Deleted())
+	})
+
+	t.Run("update error does not touch cache", func(t *testing.T) {
+		mock := &mockStore{
+			updateFunc: func(_ context.Context, _ *shortener.ShortURL) error {
+				return shortener.ErrNotFound
+			},
+		}
+		lru := cache.New(10)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
+
+		err := cached.Update(context.Background(), &shortener.ShortURL{Code: "abc123"})
+
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, 0, lru.Len())
+	})
+}
+
+func TestCachedRepository_Delete(t *testing.T) {
+	t.Run("delete evicts cache", func(t *testing.T) {
+		mock := &mockStore{}
+		lru := cache.New(10)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
+
+		_ = cached.Save(context.Background(), &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"})
+
+		err := cached.Delete(context.Background(), "abc123")
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("delete error keeps cache", func(t *testing.T) {
+		mock := &mockStore{
+			deleteFunc: func(_ context.Context, _ shortener.Code) error {
+				return shortener.ErrNotFound
+			},
+		}
+		lru := cache.New(10)
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{})
+
+		_ = cached.Save(context.Background(), &shortener.ShortURL{Code: "abc123", OriginalURL: "https://example.com"})
+
+		err := cached.Delete(context.Background(), "abc123")
+
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, 1, lru.Len())
+	})
+}
+
+// memoryBus is an InvalidationBus recording published codes, whose subscriber
+// tests call directly.
+type memoryBus struct {
+	mu         sync.Mutex
+	published  []shortener.Code
+	evict      func(shortener.Code)
+	flush      func()
+	subscribed chan struct{}
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{subscribed: make(chan struct{})}
+}
+
+func (b *memoryBus) Publish(_ context.Context, code shortener.Code) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = append(b.published, code)
+
+	return nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, evict func(shortener.Code), flush func()) {
+	b.evict, b.flush = evict, flush
+	close(b.subscribed)
+	<-ctx.Done()
+}
+
+// listen subscribes cached to the bus until the test ends.
+func (b *memoryBus) listen(t *testing.T, cached *store.CachedRepository) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go cached.Listen(ctx)
+	<-b.subscribed
+}
+
+func TestCachedRepository_Invalidation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("changes are published", func(t *testing.T) {
+		bus := newMemoryBus()
+		cached := store.NewCachedRepository(&mockStore{}, cache.New(10), store.CachedConfig{Bus: bus})
+
+		require.NoError(t, cached.Save(ctx, &shortener.ShortURL{Code: "saved"}))
+		_, _, err := cached.SaveOrGet(ctx, &shortener.ShortURL{Code: "created"})
+		require.NoError(t, err)
+		require.NoError(t, cached.Update(ctx, &shortener.ShortURL{Code: "updated"}))
+		require.NoError(t, cached.Delete(ctx, "deleted"))
+
+		assert.Equal(t, []shortener.Code{"saved", "created", "updated", "deleted"}, bus.published)
+	})
+
+	t.Run("changes on other servers are evicted", func(t *testing.T) {
+		mock := &mockStore{
+			getByCodeFunc: func(_ context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+				return &shortener.ShortURL{Code: code}, nil
+			},
+		}
+		lru := cache.New(10)
+		bus := newMemoryBus()
+		cached := store.NewCachedRepository(mock, lru, store.CachedConfig{Bus: bus})
+		bus.listen(t, cached)
+
+		for _, code := range []shortener.Code{"a", "b", "c"} {
+			_, err := cached.GetByCode(ctx, code)
+			require.NoError(t, err)
+		}
+
+		bus.evict("a")
+		assert.Equal(t, 2, lru.Len())
+
+		_, err := cached.GetByCode(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, 4, mock.callCount)
+
+		// Changes may have been missed while disconnected
+		bus.flush()
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("links loaded before an eviction are not cached", func(t *testing.T) {
+		gated := newGatedStore()
+		require.NoError(t, gated.Save(ctx, &shortener.ShortURL{Code: "changing", OriginalURL: "https://old.example"}))
+
+		lru := cache.New(10)
+		bus := newMemoryBus()
+		cached := store.NewCachedRepository(gated, lru, store.CachedConfig{Bus: bus})
+		bus.listen(t, cached)
+
+		loaded := make(chan error, 1)
+
+		go func() {
+			_, err := cached.GetByCode(ctx, "changing")
+			loaded <- err
+		}()
+
+		gated.awaitLookup(t)
+		bus.evict("changing")
+		close(gated.release)
+
+		require.NoError(t, <-loaded)
+		assert.Equal(t, 0, lru.Len())
+	})
+
+	t.Run("listening without a bus returns at once", func(_ *testing.T) {
+		store.NewCachedRepository(&mockStore{}, cache.New(10), store.CachedConfig{}).Listen(ctx)
+	})
+}
@@ -0,0 +1,522 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// Files a FileStore keeps in its directory.
+const (
+	fileSnapshotName = "snapshot.jsonl"
+	fileLogName      = "log.jsonl"
+	fileLockName     = "lock"
+)
+
+// ErrStoreInUse is returned when opening a FileStore whose directory another
+// FileStore, usually of another process, has open.
+var ErrStoreInUse = errors.New("storage directory is in use by another process")
+
+// defaultSnapshotEvery is the number of logged changes after which a FileStore
+// writes a snapshot, unless configured otherwise.
+const defaultSnapshotEvery = 10000
+
+// Operations recorded in the log of a FileStore.
+const (
+	fileOpPut    = "put"
+	fileOpDelete = "delete"
+)
+
+// FileConfig configures a FileStore.
+type FileConfig struct {
+	// Sync flushes the log to disk after every change. Without it, changes
+	// survive a crash of the process but may be lost if the machine fails.
+	Sync bool
+	// SnapshotEvery is the number of changes after which the links are written
+	// to a snapshot and the log is emptied; 0 means 10000.
+	SnapshotEvery int
+}
+
+// FileStore is an implementation of shortener.Repository persisted in a local
+// directory, for single-node deployments. Links are served from a MemoryStore;
+// every change is appended to a log before it is applied, and the log is
+// compacted into a snapshot once it grows long. Opening the store loads the
+// snapshot and replays the log, dropping a last record torn by a crash.
+type FileStore struct {
+	memory *MemoryStore
+	config FileConfig
+	dir    string
+
+	lock   *os.File   // locked while the store is open
+	mu     sync.Mutex // serializes changes, so the log records them in the order they are applied
+	log    *os.File
+	logged int   // records in the log since the last snapshot
+	size   int64 // bytes of complete records in the log
+}
+
+// fileRecord is a line of the log: a link stored, or the code of a link deleted.
+type fileRecord struct {
+	Op   string    `json:"op"`
+	Link *fileLink `json:"link,omitempty"`
+	Code string    `json:"code,omitempty"`
+}
+
+// fileLink is a link as written to the snapshot and the log.
+type fileLink struct {
+	Code        string     `json:"code"`
+	OriginalURL string     `json:"originalUrl"`
+	URLHash     string     `json:"urlHash,omitempty"`
+	Strategy    string     `json:"strategy,omitempty"`
+	OwnerID     string     `json:"ownerId,omitempty"`
+	WorkspaceID string     `json:"workspaceId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DisabledAt  *time.Time `json:"disabledAt,omitempty"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	ImageURL    string     `json:"imageUrl,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+}
+
+// NewFileStore opens the file store in dir, creating the directory if needed,
+// and loads the links it holds. The directory stays locked until Close, so that
+// no other process changes it meanwhile.
+func NewFileStore(dir string, config FileConfig) (*FileStore, error) {
+	if config.SnapshotEvery <= 0 {
+		config.SnapshotEvery = defaultSnapshotEvery
+	}
+
+	f := &FileStore{
+		memory: NewMemoryStore(),
+		config: config,
+		dir:    filepath.Clean(dir),
+	}
+
+	if err := os.MkdirAll(f.dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	lock, err := os.OpenFile(f.path(fileLockName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(lock); err != nil {
+		_ = lock.Close()
+
+		return nil, fmt.Errorf("opening %s: %w", f.dir, err)
+	}
+
+	f.lock = lock
+
+	if err := f.load(); err != nil {
+		_ = lock.Close()
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *FileStore) Save(_ context.Context, shortURL *shortener.ShortURL) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.put(shortURL)
+}
+
+func (f *FileStore) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if shortURL.Deduplicated() {
+		existing, err := f.memory.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
+		if err == nil {
+			return existing, false, nil
+		}
+	}
+
+	if err := f.put(shortURL); err != nil {
+		return nil, false, err
+	}
+
+	return shortURL, true, nil
+}
+
+func (f *FileStore) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	return f.memory.GetByCode(ctx, code)
+}
+
+func (f *FileStore) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
+	return f.memory.GetByHash(ctx, workspaceID, hash)
+}
+
+func (f *FileStore) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.memory.GetByCode(ctx, shortURL.Code); err != nil {
+		return err
+	}
+
+	if live(shortURL) {
+		other, err := f.memory.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
+		if err == nil && other.Code != shortURL.Code {
+			return shortener.ErrDuplicate
+		}
+	}
+
+	return f.put(shortURL)
+}
+
+func (f *FileStore) Delete(ctx context.Context, code shortener.Code) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.memory.GetByCode(ctx, code); err != nil {
+		return err
+	}
+
+	if err := f.append(&fileRecord{Op: fileOpDelete, Code: string(code)}); err != nil {
+		return err
+	}
+
+	f.memory.remove(code)
+	f.compactIfDue()
+
+	return nil
+}
+
+// ExpiredTombstones returns the codes of up to limit links deleted before deletedBefore.
+func (f *FileStore) ExpiredTombstones(
+	ctx context.Context, deletedBefore time.Time, limit int,
+) ([]shortener.Code, error) {
+	return f.memory.ExpiredTombstones(ctx, deletedBefore, limit)
+}
+
+// CountCodes returns the number of codes of each length, other than word codes.
+func (f *FileStore) CountCodes(ctx context.Context) (map[int]int64, error) {
+	return f.memory.CountCodes(ctx)
+}
+
+// List returns short URLs matching the query, newest first.
+func (f *FileStore) List(ctx context.Context, query shortener.ListQuery) ([]*shortener.ShortURL, error) {
+	return f.memory.List(ctx, query)
+}
+
+// Snapshot writes every link to a new snapshot and empties the log.
+func (f *FileStore) Snapshot() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.snapshot()
+}
+
+// Close flushes the log to disk and closes it. Closing the store again does nothing.
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.log == nil {
+		return nil
+	}
+
+	log := f.log
+	f.log = nil
+
+	// The lock is released once the log is closed
+	defer func() { _ = f.lock.Close() }()
+
+	if err := log.Sync(); err != nil {
+		_ = log.Close()
+
+		return err
+	}
+
+	return log.Close()
+}
+
+// put logs and applies the storage of a link. It must be called with mu held.
+func (f *FileStore) put(shortURL *shortener.ShortURL) error {
+	if err := f.append(&fileRecord{Op: fileOpPut, Link: fileLinkOf(shortURL)}); err != nil {
+		return err
+	}
+
+	f.memory.put(shortURL)
+	f.compactIfDue()
+
+	return nil
+}
+
+// append writes a record at the end of the log. It must be called with mu held.
+func (f *FileStore) append(record *fileRecord) error {
+	if f.log == nil {
+		return os.ErrClosed
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	n, err := f.log.Write(append(data, '\n'))
+	if err == nil && f.config.Sync {
+		err = f.log.Sync()
+	}
+
+	if err != nil {
+		// Drop what was written of the record, so that it is not applied on
+		// replay and the next one does not extend it
+		_ = f.log.Truncate(f.size)
+
+		return err
+	}
+
+	f.size += int64(n)
+	f.logged++
+
+	return nil
+}
+
+// compactIfDue writes a snapshot once the log holds enough records. It must be
+// called with mu held, after the last record has been applied. The change is
+// already logged, so a failed snapshot does not fail it and is retried after
+// the next change.
+func (f *FileStore) compactIfDue() {
+	if f.logged >= f.config.SnapshotEvery {
+		_ = f.snapshot()
+	}
+}
+
+// snapshot replaces the snapshot with the current links and empties the log. It
+// must be called with mu held. The log is only emptied once the new snapshot is
+// in place; if a crash prevents it, replaying the log over the snapshot again
+// gives the same links, every record being the last state of its link.
+func (f *FileStore) snapshot() error {
+	path := f.path(fileSnapshotName)
+	tmp := path + ".tmp"
+
+	if err := writeSnapshot(tmp, f.memory.all()); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	if err := syncDir(f.dir); err != nil {
+		return err
+	}
+
+	if err := f.log.Truncate(0); err != nil {
+		return err
+	}
+
+	f.logged = 0
+	f.size = 0
+
+	return nil
+}
+
+// load reads the snapshot, replays the log over it and opens the log for appending.
+func (f *FileStore) load() error {
+	if err := f.readSnapshot(); err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(f.path(fileLogName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	valid, err := f.replay(log)
+	if err == nil {
+		// A record torn by a crash is dropped, so the next one starts on its own line
+		err = log.Truncate(valid)
+	}
+
+	if err != nil {
+		_ = log.Close()
+
+		return err
+	}
+
+	f.log = log
+	f.size = valid
+
+	return nil
+}
+
+// readSnapshot loads the links of the snapshot, if there is one.
+func (f *FileStore) readSnapshot() error {
+	file, err := os.Open(f.path(fileSnapshotName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+
+	for {
+		var link fileLink
+
+		err := dec.Decode(&link)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("read %s: %w", fileSnapshotName, err)
+		}
+
+		f.memory.put(link.shortURL())
+	}
+}
+
+// replay applies the records of the log and returns the size of its complete
+// records. Whatever follows the last newline was torn by a crash.
+func (f *FileStore) replay(log io.Reader) (int64, error) {
+	reader := bufio.NewReader(log)
+
+	var valid int64
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return valid, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		var record fileRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return 0, fmt.Errorf("read %s line %d: %w", fileLogName, line, err)
+		}
+
+		switch {
+		case record.Op == fileOpPut && record.Link != nil:
+			f.memory.put(record.Link.shortURL())
+		case record.Op == fileOpDelete:
+			f.memory.remove(shortener.Code(record.Code))
+		default:
+			return 0, fmt.Errorf("read %s line %d: unknown record %q", fileLogName, line, record.Op)
+		}
+
+		valid += int64(len(data))
+		f.logged++
+	}
+}
+
+func (f *FileStore) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+// writeSnapshot writes links to a new file at path and flushes it to disk.
+func writeSnapshot(path string, links []*shortener.ShortURL) error {
+	file, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+
+	for _, shortURL := range links {
+		if err = enc.Encode(fileLinkOf(shortURL)); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// syncDir flushes the entries of a directory to disk, so that a rename in it
+// survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+
+		return err
+	}
+
+	return d.Close()
+}
+
+func fileLinkOf(shortURL *shortener.ShortURL) *fileLink {
+	return &fileLink{
+		Code:        string(shortURL.Code),
+		OriginalURL: shortURL.OriginalURL,
+		URLHash:     string(shortURL.URLHash),
+		Strategy:    shortURL.Strategy,
+		OwnerID:     shortURL.OwnerID,
+		WorkspaceID: shortURL.WorkspaceID,
+		CreatedAt:   shortURL.CreatedAt,
+		DisabledAt:  shortURL.DisabledAt,
+		DeletedAt:   shortURL.DeletedAt,
+		Title:       shortURL.Title,
+		Description: shortURL.Description,
+		ImageURL:    shortURL.ImageURL,
+		Tags:        shortURL.Tags,
+		Notes:       shortURL.Notes,
+	}
+}
+
+func (l *fileLink) shortURL() *shortener.ShortURL {
+	return &shortener.ShortURL{
+		Code:        shortener.Code(l.Code),
+		OriginalURL: l.OriginalURL,
+		URLHash:     shortener.URLHash(l.URLHash),
+		Strategy:    l.Strategy,
+		OwnerID:     l.OwnerID,
+		WorkspaceID: l.WorkspaceID,
+		CreatedAt:   l.CreatedAt,
+		DisabledAt:  l.DisabledAt,
+		DeletedAt:   l.DeletedAt,
+		Metadata: shortener.Metadata{
+			Title:       l.Title,
+			Description: l.Description,
+			ImageURL:    l.ImageURL,
+			Tags:        l.Tags,
+			Notes:       l.Notes,
+		},
+	}
+}
@@ -0,0 +1,196 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openFileStore(t *testing.T, dir string) *store.FileStore {
+	t.Helper()
+
+	s, err := store.NewFileStore(dir, store.FileConfig{Sync: true})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s := openFileStore(t, dir)
+	require.NoError(t, s.Save(ctx, &shortener.ShortURL{
+		Code:        "kept",
+		OriginalURL: "https://example.com",
+		URLHash:     "somehash",
+		Strategy:    shortener.StrategyHash,
+		WorkspaceID: "team-a",
+		CreatedAt:   createdAt,
+		Metadata:    shortener.Metadata{Title: "Example", Tags: []string{"docs"}},
+	}))
+	require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: "edited", OriginalURL: "https://old.example"}))
+	require.NoError(t, s.Update(ctx, &shortener.ShortURL{Code: "edited", OriginalURL: "https://new.example"}))
+	require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: "gone"}))
+	require.NoError(t, s.Delete(ctx, "gone"))
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+
+	reopened := openFileStore(t, dir)
+
+	kept, err := reopened.GetByHash(ctx, "team-a", "somehash")
+	require.NoError(t, err)
+	assert.Equal(t, shortener.Code("kept"), kept.Code)
+	assert.Equal(t, createdAt, kept.CreatedAt)
+	assert.Equal(t, shortener.Metadata{Title: "Example", Tags: []string{"docs"}}, kept.Metadata)
+
+	edited, err := reopened.GetByCode(ctx, "edited")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example", edited.OriginalURL)
+
+	_, err = reopened.GetByCode(ctx, "gone")
+	require.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+func TestFileStore_Lock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file stores are not locked on windows")
+	}
+
+	dir := t.TempDir()
+	s := openFileStore(t, dir)
+
+	_, err := store.NewFileStore(dir, store.FileConfig{})
+	require.ErrorIs(t, err, store.ErrStoreInUse)
+
+	require.NoError(t, s.Close())
+
+	reopened, err := store.NewFileStore(dir, store.FileConfig{})
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+}
+
+func TestFileStore_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := store.NewFileStore(dir, store.FileConfig{SnapshotEvery: 10})
+	require.NoError(t, err)
+
+	for i := range 25 {
+		require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: shortener.Code(fmt.Sprintf("code%02d", i))}))
+	}
+
+	require.NoError(t, s.Close())
+
+	// Two snapshots were taken, leaving the last five changes in the log
+	assert.Equal(t, 5, countLines(t, filepath.Join(dir, "log.jsonl")))
+	assert.Equal(t, 20, countLines(t, filepath.Join(dir, "snapshot.jsonl")))
+
+	reopened := openFileStore(t, dir)
+
+	counts, err := reopened.CountCodes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{6: 25}, counts)
+}
+
+func TestFileStore_Recovery(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("drops a record torn by a crash", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s := openFileStore(t, dir)
+		require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: "first"}))
+		require.NoError(t, s.Close())
+
+		appendToLog(t, dir, `{"op":"put","link":{"code":"torn"`)
+
+		reopened := openFileStore(t, dir)
+		require.NoError(t, reopened.Save(ctx, &shortener.ShortURL{Code: "second"}))
+		require.NoError(t, reopened.Close())
+
+		recovered := openFileStore(t, dir)
+
+		_, err := recovered.GetByCode(ctx, "torn")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		for _, code := range []shortener.Code{"first", "second"} {
+			_, err := recovered.GetByCode(ctx, code)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("replays a log that was not emptied after a snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+
+		s := openFileStore(t, dir)
+		require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: "moved", OriginalURL: "https://old.example"}))
+		require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: "gone"}))
+		require.NoError(t, s.Update(ctx, &shortener.ShortURL{Code: "moved", OriginalURL: "https://new.example"}))
+		require.NoError(t, s.Delete(ctx, "gone"))
+		require.NoError(t, s.Close())
+
+		log, err := os.ReadFile(filepath.Join(dir, "log.jsonl"))
+		require.NoError(t, err)
+
+		s = openFileStore(t, dir)
+		require.NoError(t, s.Snapshot())
+		require.NoError(t, s.Close())
+
+		// A crash after the snapshot was renamed into place, before the log was emptied
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "log.jsonl"), log, 0o600))
+
+		recovered := openFileStore(t, dir)
+
+		moved, err := recovered.GetByCode(ctx, "moved")
+		require.NoError(t, err)
+		assert.Equal(t, "https://new.example", moved.OriginalURL)
+
+		_, err = recovered.GetByCode(ctx, "gone")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("refuses a corrupt record before the end of the log", func(t *testing.T) {
+		dir := t.TempDir()
+
+		appendToLog(t, dir, "not json\n"+`{"op":"put","link":{"code":"after"}}`+"\n")
+
+		_, err := store.NewFileStore(dir, store.FileConfig{})
+
+		require.ErrorContains(t, err, "line 1")
+	})
+}
+
+func appendToLog(t *testing.T, dir, data string) {
+	t.Helper()
+
+	f, err := os.OpenFile(filepath.Join(dir, "log.jsonl"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	require.NoError(t, err)
+
+	return bytes.Count(data, []byte("\n"))
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package store
+
+import "os"
+
+// lockFile does not lock files on systems without flock: a directory must not
+// be shared by processes there.
+func lockFile(_ *os.File) error {
+	return nil
+}
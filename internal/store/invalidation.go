@@ -0,0 +1,225 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// invalidationChannel is the Redis channel and PostgreSQL notification channel
+// carrying invalidations.
+const invalidationChannel = "link_invalidations"
+
+// A bus losing its subscription waits invalidationRetry before reconnecting,
+// doubling the wait after each failed attempt up to invalidationMaxRetry.
+const (
+	invalidationRetry    = time.Second
+	invalidationMaxRetry = time.Minute
+)
+
+// InvalidationBus carries the codes of changed links between servers, so that
+// each evicts them from its own cache. Codes published while a server is not
+// subscribed are lost to it, so it is told to flush its cache on every
+// (re)connection.
+type InvalidationBus interface {
+	// Publish tells the other servers that the link of a code changed.
+	Publish(ctx context.Context, code shortener.Code) error
+	// Subscribe calls evict with the codes published by other servers, and flush
+	// whenever it subscribes, until ctx is done.
+	Subscribe(ctx context.Context, evict func(shortener.Code), flush func())
+}
+
+// invalidation is the message of a changed link, naming the bus it was
+// published on so that the server changing it skips its own messages.
+type invalidation struct {
+	Origin string         `json:"origin"`
+	Code   shortener.Code `json:"code"`
+}
+
+// encodeInvalidation returns the message published by origin for a code.
+func encodeInvalidation(origin string, code shortener.Code) string {
+	payload, _ := json.Marshal(invalidation{Origin: origin, Code: code})
+
+	return string(payload)
+}
+
+// decodeInvalidation returns the code of a message, and whether another bus
+// than origin published it.
+func decodeInvalidation(origin, payload string) (shortener.Code, bool) {
+	var msg invalidation
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return "", false
+	}
+
+	return msg.Code, msg.Origin != origin && msg.Code != ""
+}
+
+// RedisInvalidationBus is an InvalidationBus on a Redis pub/sub channel.
+type RedisInvalidationBus struct {
+	client *redis.Client
+	origin string
+	logger *zap.Logger
+}
+
+// NewRedisInvalidationBus creates a bus publishing on the Redis server of client.
+func NewRedisInvalidationBus(client *redis.Client, logger *zap.Logger) *RedisInvalidationBus {
+	return &RedisInvalidationBus{client: client, origin: uuid.NewString(), logger: logger}
+}
+
+func (r *RedisInvalidationBus) Publish(ctx context.Context, code shortener.Code) error {
+	return r.client.Publish(ctx, invalidationChannel, encodeInvalidation(r.origin, code)).Err()
+}
+
+// Subscribe receives the codes published on the channel. The client reconnects
+// and subscribes again after failures, confirming each subscription with a
+// message that flushes the cache.
+func (r *RedisInvalidationBus) Subscribe(ctx context.Context, evict func(shortener.Code), flush func()) {
+	pubsub := r.client.Subscribe(ctx, invalidationChannel)
+	defer func() { _ = pubsub.Close() }()
+
+	retry := newRetry(r.logger)
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			if !retry.wait(ctx, err) {
+				return
+			}
+
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			retry.reset()
+			flush()
+		case *redis.Message:
+			if code, ok := decodeInvalidation(r.origin, msg.Payload); ok {
+				evict(code)
+			}
+		}
+	}
+}
+
+// PostgresInvalidationBus is an InvalidationBus on PostgreSQL notifications,
+// for deployments without Redis.
+type PostgresInvalidationBus struct {
+	pool   *pgxpool.Pool
+	origin string
+	logger *zap.Logger
+}
+
+// NewPostgresInvalidationBus creates a bus notifying through the database of
+// pool. Subscriptions listen on a connection of their own, outside the pool.
+func NewPostgresInvalidationBus(pool *pgxpool.Pool, logger *zap.Logger) *PostgresInvalidationBus {
+	return &PostgresInvalidationBus{pool: pool, origin: uuid.NewString(), logger: logger}
+}
+
+func (p *PostgresInvalidationBus) Publish(ctx context.Context, code shortener.Code) error {
+	_, err := p.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, invalidationChannel, encodeInvalidation(p.origin, code))
+
+	return err
+}
+
+// Subscribe listens for notifications, connecting again after failures.
+func (p *PostgresInvalidationBus) Subscribe(ctx context.Context, evict func(shortener.Code), flush func()) {
+	retry := newRetry(p.logger)
+
+	for {
+		err := p.listen(ctx, evict, func() {
+			retry.reset()
+			flush()
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !retry.wait(ctx, err) {
+			return
+		}
+	}
+}
+
+// listen connects and receives notifications until the connection fails.
+func (p *PostgresInvalidationBus) listen(ctx context.Context, evict func(shortener.Code), flush func()) error {
+	conn, err := pgx.ConnectConfig(ctx, p.pool.Config().ConnConfig)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close(context.WithoutCancel(ctx)) }()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{invalidationChannel}.Sanitize()); err != nil {
+		return err
+	}
+
+	flush()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		if code, ok := decodeInvalidation(p.origin, notification.Payload); ok {
+			evict(code)
+		}
+	}
+}
+
+// retry spaces out the reconnections of a bus, and their warnings, with an
+// exponential backoff reset by every subscription.
+type retry struct {
+	delay  time.Duration
+	logger *zap.Logger
+}
+
+func newRetry(logger *zap.Logger) *retry {
+	return &retry{delay: invalidationRetry, logger: logger}
+}
+
+// wait logs the failure of a subscription, then waits before the next attempt,
+// and reports whether ctx was still running after it.
+func (r *retry) wait(ctx context.Context, err error) bool {
+	r.logger.Warn("invalidations interrupted", zap.Error(err), zap.Duration("retry", r.delay))
+
+	delay := r.delay
+	r.delay = min(2*r.delay, invalidationMaxRetry)
+
+	return sleepContext(ctx, delay)
+}
+
+// reset shortens the wait after the next failure back to invalidationRetry.
+func (r *retry) reset() {
+	r.delay = invalidationRetry
+}
+
+// sleepContext waits for d, and reports whether ctx was still running after it.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Compile-time checks.
+var (
+	_ InvalidationBus = (*RedisInvalidationBus)(nil)
+	_ InvalidationBus = (*PostgresInvalidationBus)(nil)
+)
@@ -0,0 +1,86 @@
+//go:build integration
+
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testInvalidations checks that codes published on one bus reach a subscriber
+// of another, which skips its own.
+func testInvalidations(t *testing.T, publisher, subscriber store.InvalidationBus) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evicted := make(chan shortener.Code, 10)
+	flushed := make(chan struct{}, 10)
+
+	go subscriber.Subscribe(ctx, func(code shortener.Code) {
+		evicted <- code
+	}, func() {
+		flushed <- struct{}{}
+	})
+
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber did not connect")
+	}
+
+	require.NoError(t, subscriber.Publish(ctx, "invalidown"))
+	require.NoError(t, publisher.Publish(ctx, "invalidother"))
+
+	select {
+	case code := <-evicted:
+		assert.Equal(t, shortener.Code("invalidother"), code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalidation not received")
+	}
+}
+
+func TestRedisInvalidationBusIntegration(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: getRedisAddr(),
+	})
+	defer client.Close()
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+
+	testInvalidations(t,
+		store.NewRedisInvalidationBus(client, zap.NewNop()),
+		store.NewRedisInvalidationBus(client, zap.NewNop()),
+	)
+}
+
+func TestPostgresInvalidationBusIntegration(t *testing.T) {
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, getDatabaseURL())
+	if err != nil {
+		t.Skipf("PostgreSQL not available: %v", err)
+	}
+	defer pool.Close()
+
+	if err := pool.Ping(ctx); err != nil {
+		t.Skipf("PostgreSQL not available: %v", err)
+	}
+
+	testInvalidations(t,
+		store.NewPostgresInvalidationBus(pool, zap.NewNop()),
+		store.NewPostgresInvalidationBus(pool, zap.NewNop()),
+	)
+}
@@ -2,23 +2,32 @@ package store
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/shortener"
 )
 
+// hashKey indexes live hash-strategy links; hashes are unique per workspace.
+type hashKey struct {
+	workspaceID string
+	hash        shortener.URLHash
+}
+
 // MemoryStore is an in-memory implementation of shortener.Repository.
 type MemoryStore struct {
 	mu     sync.RWMutex
 	urls   map[shortener.Code]*shortener.ShortURL // code -> entity
-	hashes map[shortener.URLHash]shortener.Code   // urlHash -> code (index for hash lookups)
+	hashes map[hashKey]shortener.Code             // (workspace, urlHash) -> code (index for hash lookups)
 }
 
 // NewMemoryStore creates a new in-memory URL store.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		urls:   make(map[shortener.Code]*shortener.ShortURL),
-		hashes: make(map[shortener.URLHash]shortener.Code),
+		hashes: make(map[hashKey]shortener.Code),
 	}
 }
 
@@ -27,13 +36,44 @@ func (m *MemoryStore) Save(_ context.Context, shortURL *shortener.ShortURL) erro
 	defer m.mu.Unlock()
 
 	m.urls[shortURL.Code] = shortURL
+	m.index(shortURL)
+
+	return nil
+}
+
+// SaveIfAbsent stores a short URL unless its code is taken, and reports whether it did.
+func (m *MemoryStore) SaveIfAbsent(_ context.Context, shortURL *shortener.ShortURL) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// Index by hash if present (for hash strategy)
-	if shortURL.URLHash != "" {
-		m.hashes[shortURL.URLHash] = shortURL.Code
+	if _, ok := m.urls[shortURL.Code]; ok {
+		return false, nil
 	}
 
-	return nil
+	if _, ok := m.hashes[hashKeyOf(shortURL)]; ok && live(shortURL) {
+		return false, shortener.ErrDuplicate
+	}
+
+	m.urls[shortURL.Code] = shortURL
+	m.index(shortURL)
+
+	return true, nil
+}
+
+func (m *MemoryStore) SaveOrGet(
+	_ context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if code, ok := m.hashes[hashKeyOf(shortURL)]; ok && shortURL.Deduplicated() {
+		return m.urls[code], false, nil
+	}
+
+	m.urls[shortURL.Code] = shortURL
+	m.index(shortURL)
+
+	return shortURL, true, nil
 }
 
 func (m *MemoryStore) GetByCode(_ context.Context, code shortener.Code) (*shortener.ShortURL, error) {
@@ -48,19 +88,197 @@ func (m *MemoryStore) GetByCode(_ context.Context, code shortener.Code) (*shorte
 	return shortURL, nil
 }
 
-func (m *MemoryStore) GetByHash(_ context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
+func (m *MemoryStore) GetByHash(
+	_ context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	code, ok := m.hashes[hash]
+	code, ok := m.hashes[hashKey{workspaceID: workspaceID, hash: hash}]
 	if !ok {
 		return nil, shortener.ErrNotFound
 	}
 
-	shortURL, ok := m.urls[code]
+	return m.urls[code], nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, shortURL *shortener.ShortURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.urls[shortURL.Code]
 	if !ok {
-		return nil, shortener.ErrNotFound
+		return shortener.ErrNotFound
 	}
 
-	return shortURL, nil
+	if code, ok := m.hashes[hashKeyOf(shortURL)]; ok && code != shortURL.Code && live(shortURL) {
+		return shortener.ErrDuplicate
+	}
+
+	m.unindex(existing)
+	m.urls[shortURL.Code] = shortURL
+	m.index(shortURL)
+
+	return nil
+}
+
+// ExpiredTombstones returns the codes of up to limit links deleted before deletedBefore.
+func (m *MemoryStore) ExpiredTombstones(
+	_ context.Context, deletedBefore time.Time, limit int,
+) ([]shortener.Code, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var codes []shortener.Code
+
+	for code, shortURL := range m.urls {
+		if len(codes) == limit {
+			break
+		}
+
+		if shortURL.Deleted() && shortURL.DeletedAt.Before(deletedBefore) {
+			codes = append(codes, code)
+		}
+	}
+
+	return codes, nil
+}
+
+// CountCodes returns the number of codes of each length, other than word codes.
+func (m *MemoryStore) CountCodes(_ context.Context) (map[int]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[int]int64)
+
+	for code, shortURL := range m.urls {
+		if shortURL.Strategy != shortener.StrategyWords {
+			counts[len(code)]++
+		}
+	}
+
+	return counts, nil
+}
+
+// List returns short URLs matching the query, newest first.
+func (m *MemoryStore) List(_ context.Context, query shortener.ListQuery) ([]*shortener.ShortURL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matches []*shortener.ShortURL
+
+	for _, shortURL := range m.urls {
+		if !query.Matches(shortURL) {
+			continue
+		}
+
+		if query.After != nil && !query.After.Precedes(shortURL) {
+			continue
+		}
+
+		matches = append(matches, shortURL)
+	}
+
+	return firstPage(matches, query.Limit), nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, code shortener.Code) error {
+	if !m.remove(code) {
+		return shortener.ErrNotFound
+	}
+
+	return nil
+}
+
+// Walk calls fn with every link, deleted ones included, until fn fails.
+func (m *MemoryStore) Walk(_ context.Context, fn func(*shortener.ShortURL) error) error {
+	for _, shortURL := range m.all() {
+		if err := fn(shortURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// put stores a link in place of any previous version, moving its hash index.
+func (m *MemoryStore) put(shortURL *shortener.ShortURL) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.urls[shortURL.Code]; ok {
+		m.unindex(existing)
+	}
+
+	m.urls[shortURL.Code] = shortURL
+	m.index(shortURL)
+}
+
+// remove deletes a link and reports whether it existed.
+func (m *MemoryStore) remove(code shortener.Code) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.urls[code]
+	if !ok {
+		return false
+	}
+
+	delete(m.urls, code)
+	m.unindex(existing)
+
+	return true
+}
+
+// all returns every link, deleted ones included.
+func (m *MemoryStore) all() []*shortener.ShortURL {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]*shortener.ShortURL, 0, len(m.urls))
+	for _, shortURL := range m.urls {
+		links = append(links, shortURL)
+	}
+
+	return links
+}
+
+// index indexes a live hash-strategy link by its hash. It must be called with mu held.
+func (m *MemoryStore) index(shortURL *shortener.ShortURL) {
+	if live(shortURL) {
+		m.hashes[hashKeyOf(shortURL)] = shortURL.Code
+	}
+}
+
+// unindex removes a link from the hash index. It must be called with mu held.
+func (m *MemoryStore) unindex(shortURL *shortener.ShortURL) {
+	if key := hashKeyOf(shortURL); shortURL.Deduplicated() && m.hashes[key] == shortURL.Code {
+		delete(m.hashes, key)
+	}
+}
+
+// live reports whether a link deduplicates its hash: a hash-strategy link that is not deleted.
+func live(shortURL *shortener.ShortURL) bool {
+	return shortURL.Deduplicated() && !shortURL.Deleted()
+}
+
+func hashKeyOf(shortURL *shortener.ShortURL) hashKey {
+	return hashKey{workspaceID: shortURL.WorkspaceID, hash: shortURL.URLHash}
+}
+
+// firstPage sorts links newest first and keeps up to limit of them, all if limit is 0.
+func firstPage(matches []*shortener.ShortURL, limit int) []*shortener.ShortURL {
+	slices.SortFunc(matches, func(a, b *shortener.ShortURL) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(string(b.Code), string(a.Code))
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches
 }
@@ -2,7 +2,11 @@ package store_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/serroba/web-demo-go/internal/shortener"
 	"github.com/serroba/web-demo-go/internal/store"
@@ -10,108 +14,493 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestMemoryStore_Save(t *testing.T) {
-	t.Run("saves short url successfully", func(t *testing.T) {
-		s := store.NewMemoryStore()
+// linkStore is the behaviour shared by the stores holding their links in memory.
+type linkStore interface {
+	shortener.Repository
+	shortener.Lister
+	ExpiredTombstones(ctx context.Context, deletedBefore time.Time, limit int) ([]shortener.Code, error)
+	CountCodes(ctx context.Context) (map[int]int64, error)
+}
+
+// eachStore runs test against the memory store and the file store, which must
+// behave the same.
+func eachStore(t *testing.T, test func(t *testing.T, newStore func() linkStore)) {
+	t.Helper()
+
+	t.Run("memory", func(t *testing.T) {
+		test(t, func() linkStore { return store.NewMemoryStore() })
+	})
+
+	t.Run("file", func(t *testing.T) {
+		test(t, func() linkStore { return openFileStore(t, t.TempDir()) })
+	})
+}
+
+func TestStores_Save(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		t.Run("saves short url successfully", func(t *testing.T) {
+			s := newStore()
 
-		err := s.Save(context.Background(), &shortener.ShortURL{
-			Code:        "abc123",
-			OriginalURL: "https://example.com",
+			err := s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+			})
+
+			require.NoError(t, err)
+
+			// Verify it can be retrieved
+			shortURL, err := s.GetByCode(context.Background(), "abc123")
+
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com", shortURL.OriginalURL)
 		})
 
-		require.NoError(t, err)
+		t.Run("indexes by hash when present", func(t *testing.T) {
+			s := newStore()
 
-		// Verify it can be retrieved
-		shortURL, err := s.GetByCode(context.Background(), "abc123")
+			err := s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+				URLHash:     "somehash",
+				Strategy:    shortener.StrategyHash,
+			})
 
-		require.NoError(t, err)
-		assert.Equal(t, "https://example.com", shortURL.OriginalURL)
-	})
+			require.NoError(t, err)
 
-	t.Run("indexes by hash when present", func(t *testing.T) {
-		s := store.NewMemoryStore()
+			// Verify it can be retrieved by hash
+			shortURL, err := s.GetByHash(context.Background(), "", "somehash")
 
-		err := s.Save(context.Background(), &shortener.ShortURL{
-			Code:        "abc123",
-			OriginalURL: "https://example.com",
-			URLHash:     "somehash",
+			require.NoError(t, err)
+			assert.Equal(t, shortener.Code("abc123"), shortURL.Code)
 		})
 
-		require.NoError(t, err)
+		t.Run("overwrites existing url", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+			})
 
-		// Verify it can be retrieved by hash
-		shortURL, err := s.GetByHash(context.Background(), "somehash")
+			err := s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://other.com",
+			})
 
-		require.NoError(t, err)
-		assert.Equal(t, shortener.Code("abc123"), shortURL.Code)
+			require.NoError(t, err)
+
+			shortURL, _ := s.GetByCode(context.Background(), "abc123")
+
+			assert.Equal(t, "https://other.com", shortURL.OriginalURL)
+		})
 	})
+}
+
+func TestStores_SaveOrGet(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		hashLink := func(code string) *shortener.ShortURL {
+			return &shortener.ShortURL{Code: shortener.Code(code), URLHash: "somehash", Strategy: shortener.StrategyHash}
+		}
+
+		t.Run("returns the live link holding the hash", func(t *testing.T) {
+			s := newStore()
+
+			stored, created, err := s.SaveOrGet(context.Background(), hashLink("first"))
+			require.NoError(t, err)
+			assert.True(t, created)
+			assert.Equal(t, shortener.Code("first"), stored.Code)
+
+			stored, created, err = s.SaveOrGet(context.Background(), hashLink("second"))
+			require.NoError(t, err)
+			assert.False(t, created)
+			assert.Equal(t, shortener.Code("first"), stored.Code)
 
-	t.Run("overwrites existing url", func(t *testing.T) {
-		s := store.NewMemoryStore()
-		_ = s.Save(context.Background(), &shortener.ShortURL{
-			Code:        "abc123",
-			OriginalURL: "https://example.com",
+			_, err = s.GetByCode(context.Background(), "second")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
 		})
 
-		err := s.Save(context.Background(), &shortener.ShortURL{
-			Code:        "abc123",
-			OriginalURL: "https://other.com",
+		t.Run("saves over a deleted link with the same hash", func(t *testing.T) {
+			s := newStore()
+			deleted := hashLink("first")
+			deletedAt := time.Now()
+			deleted.DeletedAt = &deletedAt
+			require.NoError(t, s.Save(context.Background(), deleted))
+
+			stored, created, err := s.SaveOrGet(context.Background(), hashLink("second"))
+
+			require.NoError(t, err)
+			assert.True(t, created)
+			assert.Equal(t, shortener.Code("second"), stored.Code)
 		})
 
-		require.NoError(t, err)
+		t.Run("creates a single link under concurrency", func(t *testing.T) {
+			s := newStore()
+
+			var (
+				wg      sync.WaitGroup
+				created atomic.Int32
+			)
 
-		shortURL, _ := s.GetByCode(context.Background(), "abc123")
+			for i := range 20 {
+				wg.Go(func() {
+					_, ok, err := s.SaveOrGet(context.Background(), hashLink(fmt.Sprintf("code%d", i)))
+					assert.NoError(t, err)
 
-		assert.Equal(t, "https://other.com", shortURL.OriginalURL)
+					if ok {
+						created.Add(1)
+					}
+				})
+			}
+
+			wg.Wait()
+
+			assert.Equal(t, int32(1), created.Load())
+		})
 	})
 }
 
-func TestMemoryStore_GetByCode(t *testing.T) {
-	t.Run("returns short url when found", func(t *testing.T) {
-		s := store.NewMemoryStore()
-		_ = s.Save(context.Background(), &shortener.ShortURL{
-			Code:        "abc123",
-			OriginalURL: "https://example.com",
+func TestMemoryStore_SaveIfAbsent(t *testing.T) {
+	ctx := context.Background()
+	hashLink := func(code string) *shortener.ShortURL {
+		return &shortener.ShortURL{Code: shortener.Code(code), URLHash: "somehash", Strategy: shortener.StrategyHash}
+	}
+
+	s := store.NewMemoryStore()
+
+	saved, err := s.SaveIfAbsent(ctx, hashLink("first"))
+	require.NoError(t, err)
+	assert.True(t, saved)
+
+	taken := hashLink("first")
+	taken.OriginalURL = "https://example.com"
+	saved, err = s.SaveIfAbsent(ctx, taken)
+	require.NoError(t, err)
+	assert.False(t, saved)
+
+	got, err := s.GetByCode(ctx, "first")
+	require.NoError(t, err)
+	assert.Empty(t, got.OriginalURL, "the stored link is kept")
+
+	_, err = s.SaveIfAbsent(ctx, hashLink("second"))
+	require.ErrorIs(t, err, shortener.ErrDuplicate)
+}
+
+func TestStores_GetByCode(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		t.Run("returns short url when found", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+			})
+
+			shortURL, err := s.GetByCode(context.Background(), "abc123")
+
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com", shortURL.OriginalURL)
 		})
 
-		shortURL, err := s.GetByCode(context.Background(), "abc123")
+		t.Run("returns ErrNotFound when code does not exist", func(t *testing.T) {
+			s := newStore()
 
-		require.NoError(t, err)
-		assert.Equal(t, "https://example.com", shortURL.OriginalURL)
+			shortURL, err := s.GetByCode(context.Background(), "notfound")
+
+			assert.Nil(t, shortURL)
+			assert.ErrorIs(t, err, shortener.ErrNotFound)
+		})
+	})
+}
+
+func TestStores_GetByHash(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		t.Run("returns short url when hash exists", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+				URLHash:     "somehash",
+				Strategy:    shortener.StrategyHash,
+			})
+
+			shortURL, err := s.GetByHash(context.Background(), "", "somehash")
+
+			require.NoError(t, err)
+			assert.Equal(t, shortener.Code("abc123"), shortURL.Code)
+			assert.Equal(t, "https://example.com", shortURL.OriginalURL)
+		})
+
+		t.Run("scopes hashes to the workspace", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "a", URLHash: "somehash", Strategy: shortener.StrategyHash, WorkspaceID: "team-a",
+			})
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "b", URLHash: "somehash", Strategy: shortener.StrategyHash, WorkspaceID: "team-b",
+			})
+
+			shortURL, err := s.GetByHash(context.Background(), "team-b", "somehash")
+
+			require.NoError(t, err)
+			assert.Equal(t, shortener.Code("b"), shortURL.Code)
+
+			_, err = s.GetByHash(context.Background(), "team-c", "somehash")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+		})
+
+		t.Run("skips deleted links", func(t *testing.T) {
+			s := newStore()
+			deletedAt := time.Now()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "a", URLHash: "somehash", Strategy: shortener.StrategyHash, DeletedAt: &deletedAt,
+			})
+
+			_, err := s.GetByHash(context.Background(), "", "somehash")
+
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+		})
+
+		t.Run("skips token links", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "a", URLHash: "somehash", Strategy: shortener.StrategyToken,
+			})
+
+			_, err := s.GetByHash(context.Background(), "", "somehash")
+
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+		})
+
+		t.Run("returns ErrNotFound when hash does not exist", func(t *testing.T) {
+			s := newStore()
+
+			shortURL, err := s.GetByHash(context.Background(), "", "nonexistent")
+
+			assert.Nil(t, shortURL)
+			assert.ErrorIs(t, err, shortener.ErrNotFound)
+		})
 	})
+}
+
+func TestStores_Update(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		t.Run("replaces stored fields", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+			})
+
+			err := s.Update(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+				Metadata:    shortener.Metadata{Title: "Example"},
+			})
 
-	t.Run("returns ErrNotFound when code does not exist", func(t *testing.T) {
-		s := store.NewMemoryStore()
+			require.NoError(t, err)
+
+			shortURL, _ := s.GetByCode(context.Background(), "abc123")
+			assert.Equal(t, "Example", shortURL.Title)
+		})
+
+		t.Run("moves hash index when hash changes", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+				URLHash:     "oldhash",
+				Strategy:    shortener.StrategyHash,
+			})
+
+			err := s.Update(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://other.com",
+				URLHash:     "newhash",
+				Strategy:    shortener.StrategyHash,
+			})
+
+			require.NoError(t, err)
+
+			_, err = s.GetByHash(context.Background(), "", "oldhash")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+
+			shortURL, err := s.GetByHash(context.Background(), "", "newhash")
+			require.NoError(t, err)
+			assert.Equal(t, "https://other.com", shortURL.OriginalURL)
+		})
 
-		shortURL, err := s.GetByCode(context.Background(), "notfound")
+		t.Run("keeps the hash index of another link", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "hash1", URLHash: "samehash", Strategy: shortener.StrategyHash,
+			})
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "token1", URLHash: "samehash", Strategy: shortener.StrategyToken,
+			})
 
-		assert.Nil(t, shortURL)
-		assert.ErrorIs(t, err, shortener.ErrNotFound)
+			err := s.Update(context.Background(), &shortener.ShortURL{
+				Code: "token1", URLHash: "otherhash", Strategy: shortener.StrategyToken,
+			})
+			require.NoError(t, err)
+			require.NoError(t, s.Delete(context.Background(), "token1"))
+
+			shortURL, err := s.GetByHash(context.Background(), "", "samehash")
+			require.NoError(t, err)
+			assert.Equal(t, shortener.Code("hash1"), shortURL.Code)
+		})
+
+		t.Run("returns ErrDuplicate when restoring over a live hash link", func(t *testing.T) {
+			s := newStore()
+			deletedAt := time.Now()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "old", URLHash: "samehash", Strategy: shortener.StrategyHash, DeletedAt: &deletedAt,
+			})
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code: "new", URLHash: "samehash", Strategy: shortener.StrategyHash,
+			})
+
+			err := s.Update(context.Background(), &shortener.ShortURL{
+				Code: "old", URLHash: "samehash", Strategy: shortener.StrategyHash,
+			})
+
+			require.ErrorIs(t, err, shortener.ErrDuplicate)
+
+			shortURL, err := s.GetByHash(context.Background(), "", "samehash")
+			require.NoError(t, err)
+			assert.Equal(t, shortener.Code("new"), shortURL.Code)
+		})
+
+		t.Run("returns ErrNotFound when code does not exist", func(t *testing.T) {
+			s := newStore()
+
+			err := s.Update(context.Background(), &shortener.ShortURL{Code: "missing"})
+
+			assert.ErrorIs(t, err, shortener.ErrNotFound)
+		})
 	})
 }
 
-func TestMemoryStore_GetByHash(t *testing.T) {
-	t.Run("returns short url when hash exists", func(t *testing.T) {
-		s := store.NewMemoryStore()
-		_ = s.Save(context.Background(), &shortener.ShortURL{
-			Code:        "abc123",
-			OriginalURL: "https://example.com",
-			URLHash:     "somehash",
+func TestStores_Delete(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		t.Run("removes link and hash index", func(t *testing.T) {
+			s := newStore()
+			_ = s.Save(context.Background(), &shortener.ShortURL{
+				Code:        "abc123",
+				OriginalURL: "https://example.com",
+				URLHash:     "hash",
+				Strategy:    shortener.StrategyHash,
+			})
+
+			err := s.Delete(context.Background(), "abc123")
+
+			require.NoError(t, err)
+
+			_, err = s.GetByCode(context.Background(), "abc123")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+
+			_, err = s.GetByHash(context.Background(), "", "hash")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
 		})
 
-		shortURL, err := s.GetByHash(context.Background(), "somehash")
+		t.Run("returns ErrNotFound when code does not exist", func(t *testing.T) {
+			err := newStore().Delete(context.Background(), "missing")
+
+			assert.ErrorIs(t, err, shortener.ErrNotFound)
+		})
+	})
+}
+
+func TestStores_ExpiredTombstones(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		s := newStore()
+		now := time.Now()
+		old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "live"})
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "recent", DeletedAt: &recent})
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "old1", DeletedAt: &old})
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "old2", DeletedAt: &old})
+
+		codes, err := s.ExpiredTombstones(context.Background(), now.Add(-24*time.Hour), 10)
+
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []shortener.Code{"old1", "old2"}, codes)
+
+		codes, err = s.ExpiredTombstones(context.Background(), now.Add(-24*time.Hour), 1)
+
+		require.NoError(t, err)
+		assert.Len(t, codes, 1)
+	})
+}
+
+func TestStores_CountCodes(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		s := newStore()
+		deletedAt := time.Now()
+
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "abc", Strategy: shortener.StrategyToken})
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "def", Strategy: shortener.StrategyHash})
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "ghij", DeletedAt: &deletedAt})
+		_ = s.Save(context.Background(), &shortener.ShortURL{Code: "brave-otter-42", Strategy: shortener.StrategyWords})
+
+		counts, err := s.CountCodes(context.Background())
 
 		require.NoError(t, err)
-		assert.Equal(t, shortener.Code("abc123"), shortURL.Code)
-		assert.Equal(t, "https://example.com", shortURL.OriginalURL)
+		assert.Equal(t, map[int]int64{3: 2, 4: 1}, counts)
 	})
+}
+
+func TestStores_List(t *testing.T) {
+	eachStore(t, func(t *testing.T, newStore func() linkStore) {
+		ctx := context.Background()
+		base := time.Now()
+		s := newStore()
 
-	t.Run("returns ErrNotFound when hash does not exist", func(t *testing.T) {
-		s := store.NewMemoryStore()
+		for i, code := range []shortener.Code{"a1", "a2", "a3", "a4"} {
+			_ = s.Save(ctx, &shortener.ShortURL{
+				Code:        code,
+				OriginalURL: "https://example.com/" + string(code),
+				CreatedAt:   base.Add(time.Duration(i) * time.Minute),
+				Metadata:    shortener.Metadata{Tags: []string{"tag-" + string(code)}},
+			})
+		}
 
-		shortURL, err := s.GetByHash(context.Background(), "nonexistent")
+		t.Run("returns newest first with limit", func(t *testing.T) {
+			urls, err := s.List(ctx, shortener.ListQuery{Limit: 2})
 
-		assert.Nil(t, shortURL)
-		assert.ErrorIs(t, err, shortener.ErrNotFound)
+			require.NoError(t, err)
+			require.Len(t, urls, 2)
+			assert.Equal(t, shortener.Code("a4"), urls[0].Code)
+			assert.Equal(t, shortener.Code("a3"), urls[1].Code)
+		})
+
+		t.Run("continues after cursor", func(t *testing.T) {
+			urls, err := s.List(ctx, shortener.ListQuery{
+				After: &shortener.Cursor{CreatedAt: base.Add(2 * time.Minute), Code: "a3"},
+			})
+
+			require.NoError(t, err)
+			require.Len(t, urls, 2)
+			assert.Equal(t, shortener.Code("a2"), urls[0].Code)
+			assert.Equal(t, shortener.Code("a1"), urls[1].Code)
+		})
+
+		t.Run("orders by code when timestamps tie", func(t *testing.T) {
+			tied := newStore()
+			_ = tied.Save(ctx, &shortener.ShortURL{Code: "b", CreatedAt: base})
+			_ = tied.Save(ctx, &shortener.ShortURL{Code: "c", CreatedAt: base})
+
+			urls, err := tied.List(ctx, shortener.ListQuery{})
+
+			require.NoError(t, err)
+			require.Len(t, urls, 2)
+			assert.Equal(t, shortener.Code("c"), urls[0].Code)
+		})
+
+		t.Run("applies filters", func(t *testing.T) {
+			urls, err := s.List(ctx, shortener.ListQuery{Tag: "tag-a2"})
+
+			require.NoError(t, err)
+			require.Len(t, urls, 1)
+			assert.Equal(t, shortener.Code("a2"), urls[0].Code)
+		})
 	})
 }
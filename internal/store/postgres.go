@@ -3,15 +3,48 @@ package store
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/serroba/web-demo-go/internal/shortener"
 )
 
+// shortURLColumns lists the columns read by scanShortURL, in scan order.
+const shortURLColumns = `code, original_url, url_hash, strategy, COALESCE(owner_id, ''), workspace_id, created_at,
+	disabled_at, deleted_at, COALESCE(title, ''), COALESCE(description, ''), COALESCE(image_url, ''), tags,
+	COALESCE(notes, '')`
+
+// insertColumns lists the columns set by insertArgs, in argument order.
+const insertColumns = `INSERT INTO short_urls (
+		code, original_url, url_hash, strategy, owner_id, workspace_id, created_at, disabled_at, deleted_at,
+		title, description, image_url, tags, notes
+	)`
+
+// insertShortURL inserts a short URL from insertArgs; callers add the conflict clause.
+const insertShortURL = insertColumns + `
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+
+// maxInsertRows bounds the rows of one multi-row insert, keeping its arguments
+// within the 65535 parameters PostgreSQL accepts per statement.
+const maxInsertRows = 1000
+
+// saveOrGetAttempts bounds the retries of SaveOrGet when the link it conflicts
+// with keeps being deleted before it can be read.
+const saveOrGetAttempts = 3
+
+// uniqueViolation is the PostgreSQL error code for a duplicate key.
+const uniqueViolation = "23505"
+
+var errSaveOrGetContention = errors.New("hash-strategy link kept changing while saving")
+
 // PostgresStore is a PostgreSQL implementation of shortener.Repository.
 type PostgresStore struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	replicas *Replicas // nil when every query runs on the primary
 }
 
 // NewPostgresStore creates a new PostgreSQL-backed URL store.
@@ -19,71 +52,454 @@ func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
 	return &PostgresStore{pool: pool}
 }
 
+// NewReplicatedPostgresStore creates a PostgreSQL-backed URL store whose
+// lookups by code and hash run on read replicas. Writes, listings and the
+// lookups behind them stay on the primary.
+func NewReplicatedPostgresStore(pool *pgxpool.Pool, replicas *Replicas) *PostgresStore {
+	return &PostgresStore{pool: pool, replicas: replicas}
+}
+
 func (p *PostgresStore) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
-	query := `
-		INSERT INTO short_urls (code, original_url, url_hash, created_at)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (code) DO NOTHING
+	_, err := p.SaveIfAbsent(ctx, shortURL)
+
+	return err
+}
+
+// SaveIfAbsent inserts a short URL unless its code is taken, and reports whether it did.
+func (p *PostgresStore) SaveIfAbsent(ctx context.Context, shortURL *shortener.ShortURL) (bool, error) {
+	tag, err := p.pool.Exec(ctx, insertShortURL+` ON CONFLICT (code) DO NOTHING`, insertArgs(shortURL)...)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		// Conflicts on the code are skipped, so only the index on live
+		// hash-strategy links can be violated
+		return false, shortener.ErrDuplicate
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// SaveBatch inserts short URLs with multi-row inserts in a single transaction.
+// Like Save, it skips codes that are already taken.
+func (p *PostgresStore) SaveBatch(ctx context.Context, shortURLs []*shortener.ShortURL) error {
+	batch := &pgx.Batch{}
+
+	for start := 0; start < len(shortURLs); start += maxInsertRows {
+		rows := shortURLs[start:min(start+maxInsertRows, len(shortURLs))]
+
+		var (
+			values []string
+			args   []any
+		)
+
+		for _, shortURL := range rows {
+			row := insertArgs(shortURL)
+			placeholders := make([]string, len(row))
+
+			for n := range row {
+				placeholders[n] = "$" + strconv.Itoa(len(args)+n+1)
+			}
+
+			args = append(args, row...)
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		}
+
+		batch.Queue(insertColumns+` VALUES `+strings.Join(values, ", ")+` ON CONFLICT (code) DO NOTHING`, args...)
+	}
+
+	// Statements of a batch run in one implicit transaction
+	return p.pool.SendBatch(ctx, batch).Close()
+}
+
+// SaveOrGet inserts a hash-strategy short URL unless the unique index on live
+// hash-strategy links already holds its hash, in which case the link holding
+// it is returned.
+func (p *PostgresStore) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	query := insertShortURL + `
+		ON CONFLICT (workspace_id, url_hash) WHERE strategy = 'hash' AND deleted_at IS NULL DO NOTHING
+		RETURNING code
 	`
 
-	_, err := p.pool.Exec(ctx, query,
-		string(shortURL.Code),
-		shortURL.OriginalURL,
-		nullableString(shortURL.URLHash),
-		shortURL.CreatedAt,
-	)
+	for range saveOrGetAttempts {
+		var code string
 
-	return err
+		err := p.pool.QueryRow(ctx, query, insertArgs(shortURL)...).Scan(&code)
+		if err == nil {
+			return shortURL, true, nil
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, err
+		}
+
+		// The conflicting link is read in a new statement, which sees it even if it
+		// was committed while the insert ran
+		existing, err := getByHash(ctx, p.pool, shortURL.WorkspaceID, shortURL.URLHash)
+		if err == nil {
+			return existing, false, nil
+		}
+
+		// Otherwise it was deleted in between, and the insert can be retried
+		if !errors.Is(err, shortener.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	return nil, false, errSaveOrGetContention
 }
 
 func (p *PostgresStore) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	return p.lookup(ctx, func(db Querier) (*shortener.ShortURL, error) {
+		query := `SELECT ` + shortURLColumns + ` FROM short_urls WHERE code = $1`
+
+		return scanShortURL(db.QueryRow(ctx, query, string(code)))
+	})
+}
+
+func (p *PostgresStore) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
+	return p.lookup(ctx, func(db Querier) (*shortener.ShortURL, error) {
+		return getByHash(ctx, db, workspaceID, hash)
+	})
+}
+
+// Update replaces every column of a link, like the other stores replace the
+// whole link.
+func (p *PostgresStore) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
 	query := `
-		SELECT code, original_url, url_hash, created_at
-		FROM short_urls
+		UPDATE short_urls
+		SET original_url = $2, url_hash = $3, strategy = $4, owner_id = $5, workspace_id = $6, created_at = $7,
+			disabled_at = $8, deleted_at = $9, title = $10, description = $11, image_url = $12, tags = $13, notes = $14
 		WHERE code = $1
 	`
 
-	var url shortener.ShortURL
+	tag, err := p.pool.Exec(ctx, query, insertArgs(shortURL)...)
 
-	var urlHash *string
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		// Only the index on live hash-strategy links can be violated by an update
+		return shortener.ErrDuplicate
+	}
 
-	err := p.pool.QueryRow(ctx, query, string(code)).Scan(
-		&url.Code,
-		&url.OriginalURL,
-		&urlHash,
-		&url.CreatedAt,
-	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, shortener.ErrNotFound
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return shortener.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) Delete(ctx context.Context, code shortener.Code) error {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM short_urls WHERE code = $1`, string(code))
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return shortener.ErrNotFound
+	}
+
+	return nil
+}
+
+// ExpiredTombstones returns the codes of up to limit links deleted before deletedBefore.
+func (p *PostgresStore) ExpiredTombstones(
+	ctx context.Context, deletedBefore time.Time, limit int,
+) ([]shortener.Code, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT code FROM short_urls
+		WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`, deletedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]shortener.Code, 0, len(codes))
+	for _, c := range codes {
+		result = append(result, shortener.Code(c))
+	}
+
+	return result, nil
+}
+
+// CountCodes returns the number of codes of each length, other than word codes.
+func (p *PostgresStore) CountCodes(ctx context.Context) (map[int]int64, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT char_length(code), COUNT(*) FROM short_urls
+		WHERE strategy <> $1
+		GROUP BY 1
+	`, shortener.StrategyWords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int64)
+
+	for rows.Next() {
+		var (
+			length int
+			count  int64
+		)
+
+		if err := rows.Scan(&length, &count); err != nil {
+			return nil, err
 		}
 
+		counts[length] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// unhashedLink is a link found by BackfillURLHashes.
+type unhashedLink struct {
+	Code        string
+	OriginalURL string
+}
+
+// BackfillURLHashes records the URL hash of links saved without one, such as token
+// links created before they had one, and returns how many it updated. Links whose
+// URL cannot be normalized are left without a hash.
+func (p *PostgresStore) BackfillURLHashes(ctx context.Context) (int, error) {
+	const batchSize = 500
+
+	var (
+		after   string
+		updated int
+	)
+
+	for {
+		rows, err := p.pool.Query(ctx, `
+			SELECT code, original_url FROM short_urls
+			WHERE url_hash IS NULL AND code > $1
+			ORDER BY code
+			LIMIT $2
+		`, after, batchSize)
+		if err != nil {
+			return updated, err
+		}
+
+		links, err := pgx.CollectRows(rows, pgx.RowToStructByPos[unhashedLink])
+		if err != nil {
+			return updated, err
+		}
+
+		batch := &pgx.Batch{}
+
+		for _, link := range links {
+			after = link.Code
+
+			if hash, err := shortener.HashOf(link.OriginalURL); err == nil {
+				batch.Queue(`UPDATE short_urls SET url_hash = $2 WHERE code = $1 AND url_hash IS NULL`,
+					link.Code, string(hash))
+			}
+		}
+
+		if err := p.pool.SendBatch(ctx, batch).Close(); err != nil {
+			return updated, err
+		}
+
+		updated += batch.Len()
+
+		if len(links) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// Walk calls fn with every link, deleted ones included, in code order until fn
+// fails. Links are read in pages, so fn may change the links it is given.
+func (p *PostgresStore) Walk(ctx context.Context, fn func(*shortener.ShortURL) error) error {
+	const batchSize = 500
+
+	var after string
+
+	for {
+		rows, err := p.pool.Query(ctx, `SELECT `+shortURLColumns+` FROM short_urls
+			WHERE code > $1
+			ORDER BY code
+			LIMIT $2
+		`, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*shortener.ShortURL, error) {
+			return scanShortURL(row)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			after = string(link.Code)
+
+			if err := fn(link); err != nil {
+				return err
+			}
+		}
+
+		if len(links) < batchSize {
+			return nil
+		}
+	}
+}
+
+// List returns short URLs matching the query, newest first, using keyset pagination on (created_at, code).
+func (p *PostgresStore) List(ctx context.Context, query shortener.ListQuery) ([]*shortener.ShortURL, error) {
+	sql, args := buildListQuery(query)
+
+	rows, err := p.pool.Query(ctx, sql, args...)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	if urlHash != nil {
-		url.URLHash = shortener.URLHash(*urlHash)
+	var urls []*shortener.ShortURL
+
+	for rows.Next() {
+		url, err := scanShortURL(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, url)
 	}
 
-	return &url, nil
+	return urls, rows.Err()
 }
 
-func (p *PostgresStore) GetByHash(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
-	query := `
-		SELECT code, original_url, url_hash, created_at
-		FROM short_urls
-		WHERE url_hash = $1
-	`
+// buildListQuery translates a ListQuery into SQL with positional arguments.
+func buildListQuery(query shortener.ListQuery) (string, []any) {
+	var (
+		conditions = []string{"deleted_at IS NULL"}
+		args       []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if !query.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(query.CreatedFrom))
+	}
+
+	if !query.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(query.CreatedTo))
+	}
+
+	if query.Tag != "" {
+		conditions = append(conditions, arg(query.Tag)+" = ANY(tags)")
+	}
 
+	if query.Domain != "" {
+		conditions = append(conditions, "domain = "+arg(strings.ToLower(query.Domain)))
+	}
+
+	if query.Strategy != "" {
+		conditions = append(conditions, "strategy = "+arg(query.Strategy))
+	}
+
+	if query.URLHash != "" {
+		conditions = append(conditions, "url_hash = "+arg(string(query.URLHash)))
+	}
+
+	if query.Search != "" {
+		pattern := arg("%" + escapeLike(query.Search) + "%")
+		conditions = append(conditions, "(original_url ILIKE "+pattern+" OR title ILIKE "+pattern+")")
+	}
+
+	if query.OwnerID != "" {
+		conditions = append(conditions, "owner_id = "+arg(query.OwnerID))
+	}
+
+	if query.WorkspaceID != "" {
+		conditions = append(conditions, "workspace_id = "+arg(query.WorkspaceID))
+	}
+
+	if query.After != nil {
+		conditions = append(conditions,
+			"(created_at, code) < ("+arg(query.After.CreatedAt)+", "+arg(string(query.After.Code))+")")
+	}
+
+	sql := `SELECT ` + shortURLColumns + ` FROM short_urls WHERE ` + strings.Join(conditions, " AND ")
+
+	sql += " ORDER BY created_at DESC, code DESC"
+
+	if query.Limit > 0 {
+		sql += " LIMIT " + arg(query.Limit)
+	}
+
+	return sql, args
+}
+
+// lookup runs a lookup on a replica if there are some, on the primary otherwise.
+func (p *PostgresStore) lookup(
+	ctx context.Context, lookup func(Querier) (*shortener.ShortURL, error),
+) (*shortener.ShortURL, error) {
+	if p.replicas == nil {
+		return lookup(p.pool)
+	}
+
+	return p.replicas.Lookup(ctx, p.pool, lookup)
+}
+
+// getByHash returns the live hash-strategy link of a URL hash.
+func getByHash(
+	ctx context.Context, db Querier, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
+	query := `SELECT ` + shortURLColumns + ` FROM short_urls
+		WHERE workspace_id = $1 AND url_hash = $2 AND strategy = $3 AND deleted_at IS NULL`
+
+	return scanShortURL(db.QueryRow(ctx, query, workspaceID, string(hash), shortener.StrategyHash))
+}
+
+// escapeLike escapes LIKE wildcards so the term is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// scanShortURL scans a row selected with shortURLColumns.
+func scanShortURL(row pgx.Row) (*shortener.ShortURL, error) {
 	var url shortener.ShortURL
 
 	var urlHash *string
 
-	err := p.pool.QueryRow(ctx, query, string(hash)).Scan(
+	err := row.Scan(
 		&url.Code,
 		&url.OriginalURL,
 		&urlHash,
+		&url.Strategy,
+		&url.OwnerID,
+		&url.WorkspaceID,
 		&url.CreatedAt,
+		&url.DisabledAt,
+		&url.DeletedAt,
+		&url.Title,
+		&url.Description,
+		&url.ImageURL,
+		&url.Tags,
+		&url.Notes,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -100,6 +516,26 @@ func (p *PostgresStore) GetByHash(ctx context.Context, hash shortener.URLHash) (
 	return &url, nil
 }
 
+// insertArgs returns the arguments of insertShortURL, and of Update, for a short URL.
+func insertArgs(shortURL *shortener.ShortURL) []any {
+	return []any{
+		string(shortURL.Code),
+		shortURL.OriginalURL,
+		nullableString(shortURL.URLHash),
+		strategyOrDefault(shortURL.Strategy),
+		nullableText(shortURL.OwnerID),
+		shortURL.WorkspaceID,
+		shortURL.CreatedAt,
+		shortURL.DisabledAt,
+		shortURL.DeletedAt,
+		nullableText(shortURL.Title),
+		nullableText(shortURL.Description),
+		nullableText(shortURL.ImageURL),
+		tagsOrEmpty(shortURL.Tags),
+		nullableText(shortURL.Notes),
+	}
+}
+
 func nullableString(s shortener.URLHash) *string {
 	if s == "" {
 		return nil
@@ -109,3 +545,27 @@ func nullableString(s shortener.URLHash) *string {
 
 	return &str
 }
+
+func nullableText(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func strategyOrDefault(strategy string) string {
+	if strategy == "" {
+		return shortener.StrategyToken
+	}
+
+	return strategy
+}
+
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+
+	return tags
+}
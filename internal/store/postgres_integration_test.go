@@ -4,7 +4,11 @@ package store_test
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/serroba/web-demo-go/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func getDatabaseURL() string {
@@ -61,17 +66,23 @@ func TestPostgresStoreIntegration(t *testing.T) {
 			Code:        shortener.Code("pghashcode1"),
 			OriginalURL: "https://example.com/hashed",
 			URLHash:     shortener.URLHash("pgabc123hash"),
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
 			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
 		}
 
 		err := s.Save(ctx, shortURL)
 		require.NoError(t, err)
 
-		got, err := s.GetByHash(ctx, shortURL.URLHash)
+		got, err := s.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
 		require.NoError(t, err)
 		assert.Equal(t, shortURL.OriginalURL, got.OriginalURL)
 		assert.Equal(t, shortURL.Code, got.Code)
 		assert.Equal(t, shortURL.URLHash, got.URLHash)
+		assert.Equal(t, "team-a", got.WorkspaceID)
+
+		_, err = s.GetByHash(ctx, "team-b", shortURL.URLHash)
+		require.ErrorIs(t, err, shortener.ErrNotFound)
 
 		// Cleanup
 		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE code = $1", string(shortURL.Code))
@@ -97,6 +108,10 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		err = s.Save(ctx, second)
 		require.NoError(t, err)
 
+		saved, err := s.SaveIfAbsent(ctx, second)
+		require.NoError(t, err)
+		assert.False(t, saved)
+
 		// First value should be preserved
 		got, _ := s.GetByCode(ctx, code)
 		assert.Equal(t, "https://old.com", got.OriginalURL)
@@ -105,6 +120,299 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE code = $1", string(code))
 	})
 
+	t.Run("saves batches with one insert and walks them", func(t *testing.T) {
+		createdAt := time.Now().UTC().Truncate(time.Microsecond)
+		require.NoError(t, s.Save(ctx, &shortener.ShortURL{Code: "pgbatch0", OriginalURL: "https://old.com"}))
+
+		err := s.SaveBatch(ctx, []*shortener.ShortURL{
+			{Code: "pgbatch0", OriginalURL: "https://new.com"},
+			{Code: "pgbatch1", OriginalURL: "https://one.com", CreatedAt: createdAt,
+				Metadata: shortener.Metadata{Tags: []string{"batch"}}},
+			{Code: "pgbatch2", OriginalURL: "https://two.com", CreatedAt: createdAt},
+		})
+		require.NoError(t, err)
+
+		// Taken codes are skipped, like Save does
+		got, err := s.GetByCode(ctx, "pgbatch0")
+		require.NoError(t, err)
+		assert.Equal(t, "https://old.com", got.OriginalURL)
+
+		got, err = s.GetByCode(ctx, "pgbatch1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://one.com", got.OriginalURL)
+		assert.Equal(t, []string{"batch"}, got.Tags)
+		assert.Equal(t, createdAt, got.CreatedAt.UTC())
+
+		_, err = s.GetByCode(ctx, "pgbatch2")
+		require.NoError(t, err)
+
+		var walked []shortener.Code
+
+		require.NoError(t, s.Walk(ctx, func(link *shortener.ShortURL) error {
+			if strings.HasPrefix(string(link.Code), "pgbatch") {
+				walked = append(walked, link.Code)
+			}
+
+			return nil
+		}))
+		assert.Equal(t, []shortener.Code{"pgbatch0", "pgbatch1", "pgbatch2"}, walked)
+
+		// Cleanup
+		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE code LIKE 'pgbatch%'")
+	})
+
+	t.Run("save or get keeps one live hash link", func(t *testing.T) {
+		hashLink := func(code string) *shortener.ShortURL {
+			return &shortener.ShortURL{
+				Code:        shortener.Code(code),
+				OriginalURL: "https://example.com/dedup",
+				URLHash:     shortener.URLHash("pgdeduphash"),
+				Strategy:    shortener.StrategyHash,
+				WorkspaceID: "team-a",
+				CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+			}
+		}
+
+		var (
+			wg      sync.WaitGroup
+			created atomic.Int32
+		)
+
+		for i := range 10 {
+			wg.Go(func() {
+				stored, ok, err := s.SaveOrGet(ctx, hashLink(fmt.Sprintf("pgdedup%d", i)))
+				assert.NoError(t, err)
+				assert.NotNil(t, stored)
+
+				if ok {
+					created.Add(1)
+				}
+			})
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, int32(1), created.Load())
+
+		// Restoring a deleted duplicate conflicts with the live link
+		deletedAt := time.Now().UTC().Truncate(time.Microsecond)
+		deleted := hashLink("pgdedupold")
+		deleted.DeletedAt = &deletedAt
+		require.NoError(t, s.Save(ctx, deleted))
+
+		deleted.DeletedAt = nil
+		require.ErrorIs(t, s.Update(ctx, deleted), shortener.ErrDuplicate)
+
+		// Saving another live link conflicts with it too
+		require.ErrorIs(t, s.Save(ctx, hashLink("pgdedupnew")), shortener.ErrDuplicate)
+
+		// Cleanup
+		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE url_hash = $1", "pgdeduphash")
+	})
+
+	t.Run("update stores metadata", func(t *testing.T) {
+		shortURL := &shortener.ShortURL{
+			Code:        shortener.Code("pgupdate1"),
+			OriginalURL: "https://example.com",
+			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		}
+		require.NoError(t, s.Save(ctx, shortURL))
+
+		shortURL.Metadata = shortener.Metadata{
+			Title:       "Example",
+			Description: "An example page",
+			Tags:        []string{"docs", "team"},
+		}
+		require.NoError(t, s.Update(ctx, shortURL))
+
+		got, err := s.GetByCode(ctx, shortURL.Code)
+		require.NoError(t, err)
+		assert.Equal(t, shortURL.Metadata, got.Metadata)
+
+		// Cleanup
+		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE code = $1", string(shortURL.Code))
+	})
+
+	t.Run("update replaces every field", func(t *testing.T) {
+		shortURL := &shortener.ShortURL{
+			Code:        shortener.Code("pgreplace1"),
+			OriginalURL: "https://example.com",
+			Strategy:    shortener.StrategyToken,
+			OwnerID:     "alice",
+			WorkspaceID: "team-a",
+			CreatedAt:   time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond),
+			Metadata:    shortener.Metadata{Title: "Old", Notes: "old notes"},
+		}
+		require.NoError(t, s.Save(ctx, shortURL))
+
+		replaced := &shortener.ShortURL{
+			Code:        shortURL.Code,
+			OriginalURL: "https://example.org",
+			URLHash:     "pgreplacehash",
+			Strategy:    shortener.StrategyHash,
+			OwnerID:     "bob",
+			WorkspaceID: "team-b",
+			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+			Metadata:    shortener.Metadata{Tags: []string{}},
+		}
+		require.NoError(t, s.Update(ctx, replaced))
+
+		got, err := s.GetByCode(ctx, shortURL.Code)
+		require.NoError(t, err)
+		assert.Equal(t, replaced.OriginalURL, got.OriginalURL)
+		assert.Equal(t, replaced.Strategy, got.Strategy)
+		assert.Equal(t, replaced.OwnerID, got.OwnerID)
+		assert.Equal(t, replaced.WorkspaceID, got.WorkspaceID)
+		assert.True(t, replaced.CreatedAt.Equal(got.CreatedAt))
+		assert.Equal(t, replaced.Metadata, got.Metadata)
+
+		require.NoError(t, s.Delete(ctx, shortURL.Code))
+	})
+
+	t.Run("deleted links are kept as tombstones until purged", func(t *testing.T) {
+		shortURL := &shortener.ShortURL{
+			Code:        shortener.Code("pgtombstone1"),
+			OriginalURL: "https://example.com/tombstone",
+			URLHash:     "pgtombstonehash",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		}
+		require.NoError(t, s.Save(ctx, shortURL))
+
+		deletedAt := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Microsecond)
+		shortURL.DeletedAt = &deletedAt
+		require.NoError(t, s.Update(ctx, shortURL))
+
+		got, err := s.GetByCode(ctx, shortURL.Code)
+		require.NoError(t, err)
+		require.NotNil(t, got.DeletedAt)
+		assert.True(t, deletedAt.Equal(*got.DeletedAt))
+
+		_, err = s.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		codes, err := s.ExpiredTombstones(ctx, time.Now().Add(-24*time.Hour), 100)
+		require.NoError(t, err)
+		assert.Contains(t, codes, shortURL.Code)
+
+		require.NoError(t, s.Delete(ctx, shortURL.Code))
+	})
+
+	t.Run("counts codes by length", func(t *testing.T) {
+		before, err := s.CountCodes(ctx)
+		require.NoError(t, err)
+
+		for _, shortURL := range []*shortener.ShortURL{
+			{Code: "pgcount01234567890", OriginalURL: "https://example.com", Strategy: shortener.StrategyToken},
+			{Code: "pgcount-words-12345", OriginalURL: "https://example.com", Strategy: shortener.StrategyWords},
+		} {
+			require.NoError(t, s.Save(ctx, shortURL))
+			t.Cleanup(func() { _ = s.Delete(ctx, shortURL.Code) })
+		}
+
+		after, err := s.CountCodes(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before[18]+1, after[18])
+		assert.Equal(t, before[19], after[19])
+	})
+
+	t.Run("backfills and looks up url hashes", func(t *testing.T) {
+		shortURL := &shortener.ShortURL{
+			Code:        "pgbackfill",
+			OriginalURL: "https://Backfill.Example.org/page/",
+			Strategy:    shortener.StrategyToken,
+			CreatedAt:   time.Now(),
+		}
+		require.NoError(t, s.Save(ctx, shortURL))
+		t.Cleanup(func() { _ = s.Delete(ctx, shortURL.Code) })
+
+		updated, err := s.BackfillURLHashes(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, updated, 1)
+
+		hash, err := shortener.HashOf("https://backfill.example.org/page")
+		require.NoError(t, err)
+
+		urls, err := s.List(ctx, shortener.ListQuery{URLHash: hash})
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, shortURL.Code, urls[0].Code)
+
+		// Token links never deduplicate
+		_, err = s.GetByHash(ctx, shortURL.WorkspaceID, hash)
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("update non-existent returns ErrNotFound", func(t *testing.T) {
+		err := s.Update(ctx, &shortener.ShortURL{Code: "pgnonexistent"})
+
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("list filters and paginates", func(t *testing.T) {
+		base := time.Now().UTC().Truncate(time.Microsecond)
+		codes := []shortener.Code{"pglist1", "pglist2", "pglist3"}
+
+		for i, code := range codes {
+			require.NoError(t, s.Save(ctx, &shortener.ShortURL{
+				Code:        code,
+				OriginalURL: "https://List.Example.org/page",
+				Strategy:    shortener.StrategyToken,
+				CreatedAt:   base.Add(time.Duration(i) * time.Second),
+				Metadata:    shortener.Metadata{Title: "Listing 100% done", Tags: []string{"pglist"}},
+			}))
+		}
+
+		page, err := s.List(ctx, shortener.ListQuery{Tag: "pglist", Domain: "list.example.org", Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, codes[2], page[0].Code)
+
+		rest, err := s.List(ctx, shortener.ListQuery{
+			Tag:    "pglist",
+			Search: "100%",
+			After:  &shortener.Cursor{CreatedAt: page[1].CreatedAt, Code: page[1].Code},
+		})
+		require.NoError(t, err)
+		require.Len(t, rest, 1)
+		assert.Equal(t, codes[0], rest[0].Code)
+
+		// Cleanup
+		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE 'pglist' = ANY(tags)")
+	})
+
+	t.Run("looks links up on replicas", func(t *testing.T) {
+		// The primary stands in for a replica: its lag is always 0
+		replicas := store.NewReplicas(time.Second, zap.NewNop(), pool)
+		replicas.Check(ctx)
+
+		replicated := store.NewReplicatedPostgresStore(pool, replicas)
+		shortURL := &shortener.ShortURL{
+			Code:        "pgreplica1",
+			OriginalURL: "https://example.com/replica",
+			URLHash:     "pgreplicahash",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "default",
+			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		}
+		require.NoError(t, replicated.Save(ctx, shortURL))
+
+		got, err := replicated.GetByCode(ctx, shortURL.Code)
+		require.NoError(t, err)
+		assert.Equal(t, shortURL.OriginalURL, got.OriginalURL)
+
+		got, err = replicated.GetByHash(ctx, "default", "pgreplicahash")
+		require.NoError(t, err)
+		assert.Equal(t, shortURL.Code, got.Code)
+
+		_, err = replicated.GetByCode(ctx, "pgreplicamissing")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		// Cleanup
+		_, _ = pool.Exec(ctx, "DELETE FROM short_urls WHERE code = $1", string(shortURL.Code))
+	})
+
 	t.Run("get non-existent returns ErrNotFound", func(t *testing.T) {
 		got, err := s.GetByCode(ctx, "pgnonexistent")
 
@@ -113,7 +421,7 @@ func TestPostgresStoreIntegration(t *testing.T) {
 	})
 
 	t.Run("get by hash non-existent returns ErrNotFound", func(t *testing.T) {
-		got, err := s.GetByHash(ctx, "pgnonexistenthash")
+		got, err := s.GetByHash(ctx, "default", "pgnonexistenthash")
 
 		assert.Nil(t, got)
 		assert.ErrorIs(t, err, shortener.ErrNotFound)
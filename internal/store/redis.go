@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"strconv"
 	"time"
@@ -10,11 +11,14 @@ import (
 	"github.com/serroba/web-demo-go/internal/shortener"
 )
 
+// scanBatchSize is the number of keys asked for with each SCAN.
+const scanBatchSize = 500
+
 // RedisStore is a Redis implementation of shortener.Repository.
 type RedisStore struct {
 	client  *redis.Client
 	prefix  string // "url:" prefix for code->entity (stored as Redis hash)
-	hashKey string // "url_hashes" for urlHash->code lookup
+	hashKey string // "url_hashes:" prefix for per-workspace urlHash->code lookup
 }
 
 // NewRedisStore creates a new Redis-backed URL store.
@@ -22,7 +26,7 @@ func NewRedisStore(client *redis.Client) *RedisStore {
 	return &RedisStore{
 		client:  client,
 		prefix:  "url:",
-		hashKey: "url_hashes",
+		hashKey: "url_hashes:",
 	}
 }
 
@@ -30,16 +34,11 @@ func (r *RedisStore) Save(ctx context.Context, shortURL *shortener.ShortURL) err
 	pipe := r.client.Pipeline()
 
 	// Store entity as Redis hash
-	pipe.HSet(ctx, r.prefix+string(shortURL.Code), map[string]interface{}{
-		"code":         string(shortURL.Code),
-		"original_url": shortURL.OriginalURL,
-		"url_hash":     string(shortURL.URLHash),
-		"created_at":   shortURL.CreatedAt.UnixNano(),
-	})
+	pipe.HSet(ctx, r.prefix+string(shortURL.Code), shortURLFields(shortURL))
 
-	// Index by hash if present (for hash strategy)
-	if shortURL.URLHash != "" {
-		pipe.HSet(ctx, r.hashKey, string(shortURL.URLHash), string(shortURL.Code))
+	// Index by hash for hash-strategy deduplication
+	if live(shortURL) {
+		pipe.HSet(ctx, r.hashKey+shortURL.WorkspaceID, string(shortURL.URLHash), string(shortURL.Code))
 	}
 
 	_, err := pipe.Exec(ctx)
@@ -47,6 +46,109 @@ func (r *RedisStore) Save(ctx context.Context, shortURL *shortener.ShortURL) err
 	return err
 }
 
+// saveOrGetScript saves a link and indexes it by its hash, unless the index
+// already points at a live link that still has the hash, whose code it returns
+// instead. KEYS[1] is the hash index and KEYS[2] the link; ARGV[1] is the hash,
+// ARGV[2] the code, ARGV[3] the key prefix of links and ARGV[4] the workspace,
+// followed by field/value pairs.
+var saveOrGetScript = redis.NewScript(`
+local existing = redis.call('HGET', KEYS[1], ARGV[1])
+if existing then
+	local link = redis.call('HMGET', ARGV[3] .. existing, 'url_hash', 'workspace_id', 'deleted_at')
+	if link[1] == ARGV[1] and link[2] == ARGV[4] and link[3] == '' then
+		return existing
+	end
+end
+redis.call('HSET', KEYS[2], unpack(ARGV, 5))
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return ARGV[2]
+`)
+
+func (r *RedisStore) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	if !live(shortURL) {
+		if err := r.Save(ctx, shortURL); err != nil {
+			return nil, false, err
+		}
+
+		return shortURL, true, nil
+	}
+
+	args := []any{string(shortURL.URLHash), string(shortURL.Code), r.prefix, shortURL.WorkspaceID}
+	for field, value := range shortURLFields(shortURL) {
+		args = append(args, field, value)
+	}
+
+	keys := []string{r.hashKey + shortURL.WorkspaceID, r.prefix + string(shortURL.Code)}
+
+	code, err := saveOrGetScript.Run(ctx, r.client, keys, args...).Text()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if code == string(shortURL.Code) {
+		return shortURL, true, nil
+	}
+
+	existing, err := r.GetByCode(ctx, shortener.Code(code))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return existing, false, nil
+}
+
+// saveIfAbsentScript saves a link unless its code is taken, and indexes it by
+// its hash if it is live, returning 1 if it saved it, 0 if the code is taken and
+// -1 if another live link still has the hash. KEYS[1] is the hash index and
+// KEYS[2] the link; ARGV[1] is the hash, ARGV[2] the code, ARGV[3] the key
+// prefix of links, ARGV[4] the workspace and ARGV[5] 1 for a live link,
+// followed by field/value pairs.
+var saveIfAbsentScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+if ARGV[5] == '1' then
+	local existing = redis.call('HGET', KEYS[1], ARGV[1])
+	if existing then
+		local link = redis.call('HMGET', ARGV[3] .. existing, 'url_hash', 'workspace_id', 'deleted_at')
+		if link[1] == ARGV[1] and link[2] == ARGV[4] and link[3] == '' then
+			return -1
+		end
+	end
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+redis.call('HSET', KEYS[2], unpack(ARGV, 6))
+return 1
+`)
+
+// SaveIfAbsent stores a short URL unless its code is taken, and reports whether it did.
+func (r *RedisStore) SaveIfAbsent(ctx context.Context, shortURL *shortener.ShortURL) (bool, error) {
+	isLive := "0"
+	if live(shortURL) {
+		isLive = "1"
+	}
+
+	args := []any{string(shortURL.URLHash), string(shortURL.Code), r.prefix, shortURL.WorkspaceID, isLive}
+	for field, value := range shortURLFields(shortURL) {
+		args = append(args, field, value)
+	}
+
+	keys := []string{r.hashKey + shortURL.WorkspaceID, r.prefix + string(shortURL.Code)}
+
+	saved, err := saveIfAbsentScript.Run(ctx, r.client, keys, args...).Int()
+
+	switch {
+	case err != nil:
+		return false, err
+	case saved < 0:
+		return false, shortener.ErrDuplicate
+	}
+
+	return saved == 1, nil
+}
+
 func (r *RedisStore) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
 	result, err := r.client.HGetAll(ctx, r.prefix+string(code)).Result()
 	if err != nil {
@@ -57,31 +159,309 @@ func (r *RedisStore) GetByCode(ctx context.Context, code shortener.Code) (*short
 		return nil, shortener.ErrNotFound
 	}
 
+	return parseShortURL(result), nil
+}
+
+func (r *RedisStore) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
+	code, err := r.client.HGet(ctx, r.hashKey+workspaceID, string(hash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, shortener.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	shortURL, err := r.GetByCode(ctx, shortener.Code(code))
+	if err != nil {
+		return nil, err
+	}
+
+	// The index may outlive the link it pointed at, or its hash
+	if !indexedBy(shortURL, workspaceID, hash) {
+		return nil, shortener.ErrNotFound
+	}
+
+	return shortURL, nil
+}
+
+// Update replaces a short URL, and moves it in the hash index when its hash,
+// workspace or deletion changes.
+func (r *RedisStore) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	existing, err := r.GetByCode(ctx, shortURL.Code)
+	if err != nil {
+		return err
+	}
+
+	if live(shortURL) {
+		if err := r.checkDuplicate(ctx, shortURL); err != nil {
+			return err
+		}
+	}
+
+	if existing.Deduplicated() && (!live(shortURL) || hashKeyOf(existing) != hashKeyOf(shortURL)) {
+		if err := unindex(ctx, r.client, r.hashKey, existing); err != nil {
+			return err
+		}
+	}
+
+	return r.Save(ctx, shortURL)
+}
+
+func (r *RedisStore) Delete(ctx context.Context, code shortener.Code) error {
+	existing, err := r.GetByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.client.Del(ctx, r.prefix+string(code)).Err(); err != nil {
+		return err
+	}
+
+	return unindex(ctx, r.client, r.hashKey, existing)
+}
+
+// List returns short URLs matching the query, newest first. Redis has no
+// secondary indexes on links, so every link is scanned.
+func (r *RedisStore) List(ctx context.Context, query shortener.ListQuery) ([]*shortener.ShortURL, error) {
+	var matches []*shortener.ShortURL
+
+	err := r.scan(ctx, func(shortURL *shortener.ShortURL) bool {
+		if query.Matches(shortURL) && (query.After == nil || query.After.Precedes(shortURL)) {
+			matches = append(matches, shortURL)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return firstPage(matches, query.Limit), nil
+}
+
+// ExpiredTombstones returns the codes of up to limit links deleted before deletedBefore.
+func (r *RedisStore) ExpiredTombstones(
+	ctx context.Context, deletedBefore time.Time, limit int,
+) ([]shortener.Code, error) {
+	var codes []shortener.Code
+
+	err := r.scan(ctx, func(shortURL *shortener.ShortURL) bool {
+		if shortURL.Deleted() && shortURL.DeletedAt.Before(deletedBefore) {
+			codes = append(codes, shortURL.Code)
+		}
+
+		return len(codes) < limit
+	})
+
+	return codes, err
+}
+
+// CountCodes returns the number of codes of each length, other than word codes.
+func (r *RedisStore) CountCodes(ctx context.Context) (map[int]int64, error) {
+	counts := make(map[int]int64)
+
+	err := r.scan(ctx, func(shortURL *shortener.ShortURL) bool {
+		if shortURL.Strategy != shortener.StrategyWords {
+			counts[len(shortURL.Code)]++
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Walk calls fn with every link, deleted ones included, until fn fails.
+func (r *RedisStore) Walk(ctx context.Context, fn func(*shortener.ShortURL) error) error {
+	var walkErr error
+
+	err := r.scan(ctx, func(shortURL *shortener.ShortURL) bool {
+		walkErr = fn(shortURL)
+
+		return walkErr == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return walkErr
+}
+
+// scan calls fn with every stored link, a batch of keys at a time, until fn returns false.
+func (r *RedisStore) scan(ctx context.Context, fn func(*shortener.ShortURL) bool) error {
+	var cursor uint64
+
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, r.prefix+"*", scanBatchSize).Result()
+		if err != nil {
+			return err
+		}
+
+		pipe := r.client.Pipeline()
+
+		cmds := make([]*redis.MapStringStringCmd, len(keys))
+		for n, key := range keys {
+			cmds[n] = pipe.HGetAll(ctx, key)
+		}
+
+		if len(keys) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		for _, cmd := range cmds {
+			// A link deleted since the key was scanned has no fields left
+			if fields := cmd.Val(); len(fields) > 0 && !fn(parseShortURL(fields)) {
+				return nil
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+
+		cursor = next
+	}
+}
+
+// checkDuplicate returns shortener.ErrDuplicate if another live link holds the
+// hash of shortURL.
+func (r *RedisStore) checkDuplicate(ctx context.Context, shortURL *shortener.ShortURL) error {
+	code, err := r.client.HGet(ctx, r.hashKey+shortURL.WorkspaceID, string(shortURL.URLHash)).Result()
+	if errors.Is(err, redis.Nil) || code == string(shortURL.Code) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	other, err := r.GetByCode(ctx, shortener.Code(code))
+	if errors.Is(err, shortener.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if indexedBy(other, shortURL.WorkspaceID, shortURL.URLHash) {
+		return shortener.ErrDuplicate
+	}
+
+	return nil
+}
+
+// indexedBy reports whether a link found through the hash index of a workspace
+// still deduplicates that hash there.
+func indexedBy(shortURL *shortener.ShortURL, workspaceID string, hash shortener.URLHash) bool {
+	return live(shortURL) && shortURL.WorkspaceID == workspaceID && shortURL.URLHash == hash
+}
+
+// unindexScript removes a hash index entry only if it still points at the code,
+// leaving the entry of a link that took the hash since. KEYS[1] is the hash
+// index; ARGV[1] is the hash and ARGV[2] the code.
+var unindexScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// unindex removes a link from the hash index under hashKey, the key prefix of
+// the hash indexes of each workspace.
+func unindex(ctx context.Context, client *redis.Client, hashKey string, shortURL *shortener.ShortURL) error {
+	if !shortURL.Deduplicated() {
+		return nil
+	}
+
+	keys := []string{hashKey + shortURL.WorkspaceID}
+
+	return unindexScript.Run(ctx, client, keys, string(shortURL.URLHash), string(shortURL.Code)).Err()
+}
+
+// shortURLFields converts a short URL into Redis hash fields.
+func shortURLFields(url *shortener.ShortURL) map[string]interface{} {
+	tags, _ := json.Marshal(url.Tags)
+
+	return map[string]interface{}{
+		"code":         string(url.Code),
+		"original_url": url.OriginalURL,
+		"url_hash":     string(url.URLHash),
+		"strategy":     url.Strategy,
+		"owner_id":     url.OwnerID,
+		"workspace_id": url.WorkspaceID,
+		"created_at":   url.CreatedAt.UnixNano(),
+		"disabled_at":  formatNanos(url.DisabledAt),
+		"deleted_at":   formatNanos(url.DeletedAt),
+		"title":        url.Title,
+		"description":  url.Description,
+		"image_url":    url.ImageURL,
+		"tags":         string(tags),
+		"notes":        url.Notes,
+	}
+}
+
+// parseShortURL converts Redis hash fields written by shortURLFields back into a short URL.
+func parseShortURL(fields map[string]string) *shortener.ShortURL {
 	var createdAt time.Time
 
-	if ts, ok := result["created_at"]; ok {
+	if ts, ok := fields["created_at"]; ok {
 		if nanos, err := strconv.ParseInt(ts, 10, 64); err == nil {
 			createdAt = time.Unix(0, nanos)
 		}
 	}
 
+	var tags []string
+
+	if raw := fields["tags"]; raw != "" {
+		_ = json.Unmarshal([]byte(raw), &tags)
+	}
+
 	return &shortener.ShortURL{
-		Code:        shortener.Code(result["code"]),
-		OriginalURL: result["original_url"],
-		URLHash:     shortener.URLHash(result["url_hash"]),
+		Code:        shortener.Code(fields["code"]),
+		OriginalURL: fields["original_url"],
+		URLHash:     shortener.URLHash(fields["url_hash"]),
+		Strategy:    fields["strategy"],
+		OwnerID:     fields["owner_id"],
+		WorkspaceID: fields["workspace_id"],
 		CreatedAt:   createdAt,
-	}, nil
+		DisabledAt:  parseNanos(fields["disabled_at"]),
+		DeletedAt:   parseNanos(fields["deleted_at"]),
+		Metadata: shortener.Metadata{
+			Title:       fields["title"],
+			Description: fields["description"],
+			ImageURL:    fields["image_url"],
+			Tags:        tags,
+			Notes:       fields["notes"],
+		},
+	}
 }
 
-func (r *RedisStore) GetByHash(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
-	code, err := r.client.HGet(ctx, r.hashKey, string(hash)).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil, shortener.ErrNotFound
-		}
+// formatNanos encodes an optional timestamp as Unix nanoseconds, or an empty string when unset.
+func formatNanos(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
 
-		return nil, err
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// parseNanos decodes a timestamp written by formatNanos.
+func parseNanos(field string) *time.Time {
+	nanos, err := strconv.ParseInt(field, 10, 64)
+	if field == "" || err != nil {
+		return nil
 	}
 
-	return r.GetByCode(ctx, shortener.Code(code))
+	t := time.Unix(0, nanos)
+
+	return &t
 }
@@ -2,32 +2,96 @@ package store
 
 import (
 	"context"
-	"strconv"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/serroba/web-demo-go/internal/shortener"
+	"golang.org/x/sync/singleflight"
 )
 
+// refillLockTTL bounds how long a refill lock outlives a server that crashed
+// while holding it.
+const refillLockTTL = 5 * time.Second
+
+// refillPollInterval is how often a server waiting for another to refill a code
+// checks the cache.
+const refillPollInterval = 10 * time.Millisecond
+
+// fetchCostWeight is the weight of the moving average of the time the store
+// takes to fetch a link: each fetch moves it by 1/fetchCostWeight of the gap.
+const fetchCostWeight = 8
+
+// RedisCacheConfig configures a RedisCacheRepository.
+type RedisCacheConfig struct {
+	TTL     time.Duration // how long links are cached, forever if 0
+	MissTTL time.Duration // how long codes the store does not hold are cached as missing, not at all if 0
+	// LockWait enables refill locks: a server missing a code first takes its
+	// lock, and servers finding it taken wait up to LockWait for the holder to
+	// cache it before querying the store themselves.
+	LockWait time.Duration
+	// RefreshBeta enables early refreshes of links cached with a TTL, not at
+	// all if 0: a hit refreshes its link in the background with a probability
+	// rising as its expiry nears, earlier the longer the store takes to fetch
+	// a link and the larger RefreshBeta.
+	RefreshBeta float64
+	// Refreshes bounds the early refreshes running at once, none if 0. Hits
+	// that would start another skip it, leaving the link to expire if none
+	// refreshes it.
+	Refreshes int
+}
+
+// RefreshStats counts the early refreshes of a RedisCacheRepository.
+type RefreshStats struct {
+	Refreshed uint64 // links fetched again and cached for another TTL
+	Failed    uint64 // refreshes that could not fetch or cache their link
+	Dropped   uint64 // refreshes skipped while Refreshes were running
+}
+
 // RedisCacheRepository wraps a Repository with Redis caching for reads.
+// Concurrent misses of a code on a server query the store once.
+//
+// Early refreshes follow XFetch: a hit with ttl left refreshes its link when
+// ttl < -cost * RefreshBeta * ln(rand), where cost is the average time this
+// server took to fetch a link, so a hot link is usually refreshed once before
+// it expires, and its readers never wait for the store.
 type RedisCacheRepository struct {
-	store   shortener.Repository
-	client  *redis.Client
-	prefix  string
-	hashKey string
-	ttl     time.Duration
+	store      shortener.Repository
+	client     *redis.Client
+	prefix     string
+	hashKey    string
+	missPrefix string
+	lockPrefix string
+	config     RedisCacheConfig
+	refills    singleflight.Group
+
+	fetchCost    atomic.Int64 // nanoseconds
+	refreshSlots chan struct{}
+	refreshing   sync.Map // codes being refreshed
+	running      sync.WaitGroup
+	refreshed    atomic.Uint64
+	failed       atomic.Uint64
+	dropped      atomic.Uint64
 }
 
 // NewRedisCacheRepository creates a new Redis-cached repository decorator.
 func NewRedisCacheRepository(
-	store shortener.Repository, client *redis.Client, ttl time.Duration,
+	store shortener.Repository, client *redis.Client, config RedisCacheConfig,
 ) *RedisCacheRepository {
 	return &RedisCacheRepository{
-		store:   store,
-		client:  client,
-		prefix:  "url:",
-		hashKey: "url_hashes",
-		ttl:     ttl,
+		store:        store,
+		client:       client,
+		prefix:       "url:",
+		hashKey:      "url_hashes:",
+		missPrefix:   "url_missing:",
+		lockPrefix:   "url_lock:",
+		config:       config,
+		refreshSlots: make(chan struct{}, max(config.Refreshes, 0)),
 	}
 }
 
@@ -43,48 +107,305 @@ func (r *RedisCacheRepository) Save(ctx context.Context, shortURL *shortener.Sho
 	return nil
 }
 
-// GetByCode retrieves a short URL by its code, checking cache first.
+// SaveOrGet saves a short URL in the underlying store unless it already holds
+// an equivalent one, and caches the link that ends up stored.
+func (r *RedisCacheRepository) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	stored, created, err := r.store.SaveOrGet(ctx, shortURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if created {
+		r.cacheURL(ctx, stored)
+	} else {
+		r.cacheURLIfAbsent(ctx, stored)
+	}
+
+	return stored, created, nil
+}
+
+// GetByCode retrieves a short URL by its code, checking cache first unless the
+// context asks for the latest version.
 func (r *RedisCacheRepository) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
-	// Check cache first
-	if url, err := r.getFromCache(ctx, code); err == nil {
-		return url, nil
+	if shortener.LatestReadsFromContext(ctx) {
+		return r.store.GetByCode(ctx, code)
+	}
+
+	// Check cache first, including codes known to be missing
+	if url, found, err := r.lookup(ctx, code); found {
+		return url, err
+	}
+
+	// Cache miss - refill from store, once for concurrent misses
+	return coalesce(ctx, &r.refills, code, r.refill)
+}
+
+// lookup returns the cached link of a code, or ErrNotFound if the code is
+// cached as missing. It reports whether either is cached.
+func (r *RedisCacheRepository) lookup(ctx context.Context, code shortener.Code) (*shortener.ShortURL, bool, error) {
+	pipe := r.client.Pipeline()
+	fields := pipe.HGetAll(ctx, r.prefix+string(code))
+	ttl := pipe.PTTL(ctx, r.prefix+string(code))
+	missing := pipe.Exists(ctx, r.missPrefix+string(code))
+
+	// An unavailable cache misses, leaving the store to answer
+	_, err := pipe.Exec(ctx)
+
+	switch {
+	case err == nil && len(fields.Val()) > 0:
+		r.refreshEarly(code, ttl.Val())
+
+		return parseShortURL(fields.Val()), true, nil
+	case err == nil && missing.Val() > 0:
+		return nil, true, shortener.ErrNotFound
+	default:
+		return nil, false, nil
+	}
+}
+
+// refill fetches a code from the store and caches the result. With refill
+// locks, it first waits for a server holding the lock of the code to do so.
+func (r *RedisCacheRepository) refill(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	if r.config.LockWait > 0 {
+		token := uuid.NewString()
+		lockKey := r.lockPrefix + string(code)
+
+		locked, err := r.client.SetNX(ctx, lockKey, token, refillLockTTL).Result()
+		if err == nil && !locked {
+			if url, found, err := r.awaitRefill(ctx, code); found {
+				return url, err
+			}
+		}
+
+		if locked {
+			defer func() { _ = unlockScript.Run(ctx, r.client, []string{lockKey}, token).Err() }()
+		}
+	}
+
+	url, err := r.fetch(ctx, code)
+	if errors.Is(err, shortener.ErrNotFound) && r.config.MissTTL > 0 {
+		r.cacheMissing(ctx, code)
 	}
 
-	// Cache miss - fetch from store
-	url, err := r.store.GetByCode(ctx, code)
 	if err != nil {
 		return nil, err
 	}
 
-	// Populate cache
-	r.cacheURL(ctx, url)
+	// Populate cache, unless a concurrent write already cached a newer version
+	r.cacheURLIfAbsent(ctx, url)
 
 	return url, nil
 }
 
-// GetByHash retrieves a short URL by its hash, checking cache first.
-func (r *RedisCacheRepository) GetByHash(ctx context.Context, hash shortener.URLHash) (*shortener.ShortURL, error) {
+// awaitRefill waits up to LockWait for another server to cache a code.
+func (r *RedisCacheRepository) awaitRefill(
+	ctx context.Context, code shortener.Code,
+) (*shortener.ShortURL, bool, error) {
+	timeout := time.NewTimer(r.config.LockWait)
+	defer timeout.Stop()
+
+	ticker := time.NewTicker(refillPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		case <-timeout.C:
+			return nil, false, nil
+		case <-ticker.C:
+			if url, found, err := r.lookup(ctx, code); found {
+				return url, true, err
+			}
+		}
+	}
+}
+
+// unlockScript releases a refill lock only if it is still held with the token
+// in ARGV[1], and not by a server that took it after it expired.
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// fetch fetches a code from the store, and updates the average time it takes.
+func (r *RedisCacheRepository) fetch(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	start := time.Now()
+	url, err := r.store.GetByCode(ctx, code)
+	elapsed := int64(time.Since(start))
+
+	// Concurrent fetches may overwrite each other's update, which an average
+	// can afford
+	cost := r.fetchCost.Load()
+	if cost == 0 {
+		r.fetchCost.Store(elapsed)
+	} else {
+		r.fetchCost.Store(cost + (elapsed-cost)/fetchCostWeight)
+	}
+
+	return url, err
+}
+
+// refreshEarly starts a background refresh of a link cached for ttl more, if
+// XFetch draws one. A server yet to fetch a link has no cost to scale ttl by,
+// and leaves refreshes to the others.
+func (r *RedisCacheRepository) refreshEarly(code shortener.Code, ttl time.Duration) {
+	cost := r.fetchCost.Load()
+	if r.config.RefreshBeta <= 0 || cap(r.refreshSlots) == 0 || ttl <= 0 || cost <= 0 {
+		return
+	}
+
+	draw := -float64(cost) * r.config.RefreshBeta * math.Log(rand.Float64()) //nolint:gosec // only spreads refreshes
+	if float64(ttl) > draw {
+		return
+	}
+
+	if _, running := r.refreshing.LoadOrStore(code, struct{}{}); running {
+		return
+	}
+
+	select {
+	case r.refreshSlots <- struct{}{}:
+	default:
+		r.refreshing.Delete(code)
+		r.dropped.Add(1)
+
+		return
+	}
+
+	r.running.Go(func() {
+		defer func() {
+			<-r.refreshSlots
+
+			r.refreshing.Delete(code)
+		}()
+
+		r.refresh(code, ttl)
+	})
+}
+
+// refreshScript caches a link for another TTL, only if its cached entry was not
+// written since it was looked up with ARGV[1] milliseconds left: writes reset the
+// TTL, and deletions remove the entry, either of which the refreshed link may
+// predate. ARGV[2] is the TTL in milliseconds, followed by field/value pairs.
+var refreshScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 or ttl > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 3))
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// refresh fetches a link looked up with ttl left, and caches it for another TTL.
+// It outlives the lookup that started it.
+func (r *RedisCacheRepository) refresh(code shortener.Code, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+
+	url, err := r.fetch(ctx, code)
+	if err != nil {
+		r.failed.Add(1)
+
+		return
+	}
+
+	args := []any{ttl.Milliseconds(), r.config.TTL.Milliseconds()}
+	for field, value := range shortURLFields(url) {
+		args = append(args, field, value)
+	}
+
+	refreshed, err := refreshScript.Run(ctx, r.client, []string{r.prefix + string(code)}, args...).Int()
+	if err != nil {
+		r.failed.Add(1)
+
+		return
+	}
+
+	if refreshed == 1 {
+		r.refreshed.Add(1)
+	}
+}
+
+// RefreshStats returns the counts of early refreshes since the repository was created.
+func (r *RedisCacheRepository) RefreshStats() RefreshStats {
+	return RefreshStats{
+		Refreshed: r.refreshed.Load(),
+		Failed:    r.failed.Load(),
+		Dropped:   r.dropped.Load(),
+	}
+}
+
+// GetByHash retrieves a short URL by its hash within a workspace, checking cache first.
+func (r *RedisCacheRepository) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
 	// Check hash index cache first
-	code, err := r.client.HGet(ctx, r.hashKey, string(hash)).Result()
+	code, err := r.client.HGet(ctx, r.hashKey+workspaceID, string(hash)).Result()
 	if err == nil {
-		// Found code in hash index, try to get the full URL from cache
-		if url, err := r.getFromCache(ctx, shortener.Code(code)); err == nil {
+		// Found code in hash index, try to get the full URL from cache.
+		// A stale index entry may point at a tombstone, which never deduplicates,
+		// or at a link whose destination or workspace changed since.
+		if url, err := r.getFromCache(ctx, shortener.Code(code)); err == nil && indexedBy(url, workspaceID, hash) {
 			return url, nil
 		}
 	}
 
 	// Cache miss - fetch from store
-	url, err := r.store.GetByHash(ctx, hash)
+	url, err := r.store.GetByHash(ctx, workspaceID, hash)
 	if err != nil {
 		return nil, err
 	}
 
 	// Populate cache
-	r.cacheURL(ctx, url)
+	r.cacheURLIfAbsent(ctx, url)
 
 	return url, nil
 }
 
+// Update updates a short URL in the underlying store and refreshes the cache.
+// Deleting a link this way caches its tombstone, so reads keep seeing it as deleted.
+// A link moved to another hash or workspace leaves its old index entry.
+func (r *RedisCacheRepository) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	before, err := r.store.GetByCode(shortener.ContextWithLatestReads(ctx), shortURL.Code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.store.Update(ctx, shortURL); err != nil {
+		return err
+	}
+
+	if hashKeyOf(before) != hashKeyOf(shortURL) {
+		_ = unindex(ctx, r.client, r.hashKey, before)
+	}
+
+	r.cacheURL(ctx, shortURL)
+
+	return nil
+}
+
+// Delete removes a short URL from the underlying store and evicts it from the cache.
+func (r *RedisCacheRepository) Delete(ctx context.Context, code shortener.Code) error {
+	existing, err := r.store.GetByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	if err := r.store.Delete(ctx, code); err != nil {
+		return err
+	}
+
+	r.evict(ctx, existing)
+
+	return nil
+}
+
 func (r *RedisCacheRepository) getFromCache(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
 	result, err := r.client.HGetAll(ctx, r.prefix+string(code)).Result()
 	if err != nil {
@@ -95,47 +416,108 @@ func (r *RedisCacheRepository) getFromCache(ctx context.Context, code shortener.
 		return nil, shortener.ErrNotFound
 	}
 
-	var createdAt time.Time
+	return parseShortURL(result), nil
+}
 
-	if ts, ok := result["created_at"]; ok {
-		if nanos, err := strconv.ParseInt(ts, 10, 64); err == nil {
-			createdAt = time.Unix(0, nanos)
-		}
+func (r *RedisCacheRepository) cacheURL(ctx context.Context, url *shortener.ShortURL) {
+	pipe := r.client.Pipeline()
+	key := r.prefix + string(url.Code)
+
+	pipe.HSet(ctx, key, shortURLFields(url))
+	pipe.Del(ctx, r.missPrefix+string(url.Code))
+
+	if r.config.TTL > 0 {
+		pipe.Expire(ctx, key, r.config.TTL)
 	}
 
-	return &shortener.ShortURL{
-		Code:        shortener.Code(result["code"]),
-		OriginalURL: result["original_url"],
-		URLHash:     shortener.URLHash(result["url_hash"]),
-		CreatedAt:   createdAt,
-	}, nil
+	r.indexHash(ctx, pipe, url)
+
+	_, _ = pipe.Exec(ctx)
 }
 
-func (r *RedisCacheRepository) cacheURL(ctx context.Context, url *shortener.ShortURL) {
+// cacheIfAbsentScript writes a URL hash only if the key does not exist, so a read
+// that raced with a write cannot replace the written version, such as a tombstone,
+// with the stale one it read. ARGV[1] is the TTL in milliseconds, followed by field/value pairs.
+var cacheIfAbsentScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+if tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// cacheURLIfAbsent caches a URL read from the store without overwriting a cached
+// version. A cached version was written along with its miss mark removal and
+// hash index, which are only written again for a URL the script stored.
+func (r *RedisCacheRepository) cacheURLIfAbsent(ctx context.Context, url *shortener.ShortURL) {
+	args := []any{r.config.TTL.Milliseconds()}
+	for field, value := range shortURLFields(url) {
+		args = append(args, field, value)
+	}
+
+	stored, err := cacheIfAbsentScript.Run(ctx, r.client, []string{r.prefix + string(url.Code)}, args...).Int()
+	if err != nil || stored == 0 {
+		return
+	}
+
 	pipe := r.client.Pipeline()
-	key := r.prefix + string(url.Code)
+	pipe.Del(ctx, r.missPrefix+string(url.Code))
+	r.indexHash(ctx, pipe, url)
+	_, _ = pipe.Exec(ctx)
+}
 
-	pipe.HSet(ctx, key, map[string]interface{}{
-		"code":         string(url.Code),
-		"original_url": url.OriginalURL,
-		"url_hash":     string(url.URLHash),
-		"created_at":   url.CreatedAt.UnixNano(),
-	})
+// cacheMissingScript marks a code as missing only if its URL is not cached, so
+// a read that raced with a save cannot hide the link saved. KEYS are the URL
+// and missing keys, ARGV[1] the TTL in milliseconds.
+var cacheMissingScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('SET', KEYS[2], 1, 'PX', ARGV[1])
+return 1
+`)
+
+// cacheMissing caches a code the store does not hold as missing for MissTTL.
+// Saving the code removes the mark.
+func (r *RedisCacheRepository) cacheMissing(ctx context.Context, code shortener.Code) {
+	keys := []string{r.prefix + string(code), r.missPrefix + string(code)}
+	_ = cacheMissingScript.Run(ctx, r.client, keys, r.config.MissTTL.Milliseconds()).Err()
+}
 
-	if r.ttl > 0 {
-		pipe.Expire(ctx, key, r.ttl)
+// indexHash indexes a live hash-strategy URL by its hash; tombstones are removed from the index.
+func (r *RedisCacheRepository) indexHash(ctx context.Context, pipe redis.Pipeliner, url *shortener.ShortURL) {
+	if !url.Deduplicated() {
+		return
 	}
 
-	// Index by hash if present
-	if url.URLHash != "" {
-		pipe.HSet(ctx, r.hashKey, string(url.URLHash), string(url.Code))
+	if url.Deleted() {
+		// Scripts may not be loaded in a pipeline, so this one is sent whole
+		unindexScript.Eval(ctx, pipe, []string{r.hashKey + url.WorkspaceID}, string(url.URLHash), string(url.Code))
+
+		return
+	}
+
+	pipe.HSet(ctx, r.hashKey+url.WorkspaceID, string(url.URLHash), string(url.Code))
+}
+
+func (r *RedisCacheRepository) evict(ctx context.Context, url *shortener.ShortURL) {
+	pipe := r.client.Pipeline()
+	pipe.Del(ctx, r.prefix+string(url.Code))
+
+	if url.Deduplicated() {
+		unindexScript.Eval(ctx, pipe, []string{r.hashKey + url.WorkspaceID}, string(url.URLHash), string(url.Code))
 	}
 
 	_, _ = pipe.Exec(ctx)
 }
 
-// Shutdown is a no-op for RedisCacheRepository (client managed externally).
+// Shutdown waits for running early refreshes; the client is managed externally.
 func (r *RedisCacheRepository) Shutdown() error {
+	r.running.Wait()
+
 	return nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/serroba/web-demo-go/internal/shortener"
@@ -57,20 +58,82 @@ func TestRedisStoreIntegration(t *testing.T) {
 			Code:        "hashcode123",
 			OriginalURL: "https://example.com/hashed",
 			URLHash:     "abc123hash",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
 		}
 
 		err := s.Save(ctx, shortURL)
 		require.NoError(t, err)
 
-		got, err := s.GetByHash(ctx, shortURL.URLHash)
+		got, err := s.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
 		require.NoError(t, err)
 		assert.Equal(t, shortURL.OriginalURL, got.OriginalURL)
 		assert.Equal(t, shortURL.Code, got.Code)
 		assert.Equal(t, shortURL.URLHash, got.URLHash)
+		assert.Equal(t, "team-a", got.WorkspaceID)
+
+		_, err = s.GetByHash(ctx, "team-b", shortURL.URLHash)
+		require.ErrorIs(t, err, shortener.ErrNotFound)
 
 		// Cleanup
 		client.Del(ctx, "url:"+string(shortURL.Code))
-		client.HDel(ctx, "url_hashes", string(shortURL.URLHash))
+		client.HDel(ctx, "url_hashes:team-a", string(shortURL.URLHash))
+	})
+
+	t.Run("save or get returns the live hash link", func(t *testing.T) {
+		hashLink := func(code string) *shortener.ShortURL {
+			return &shortener.ShortURL{
+				Code:        shortener.Code(code),
+				OriginalURL: "https://example.com/dedup",
+				URLHash:     "deduphash",
+				Strategy:    shortener.StrategyHash,
+				WorkspaceID: "team-a",
+			}
+		}
+
+		_, created, err := s.SaveOrGet(ctx, hashLink("dedup1"))
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		stored, created, err := s.SaveOrGet(ctx, hashLink("dedup2"))
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, shortener.Code("dedup1"), stored.Code)
+
+		// Cleanup
+		client.Del(ctx, "url:dedup1", "url:dedup2")
+		client.HDel(ctx, "url_hashes:team-a", "deduphash")
+	})
+
+	t.Run("save if absent keeps the stored link", func(t *testing.T) {
+		link := func(code, url string) *shortener.ShortURL {
+			return &shortener.ShortURL{
+				Code:        shortener.Code(code),
+				OriginalURL: url,
+				URLHash:     "absenthash",
+				Strategy:    shortener.StrategyHash,
+				WorkspaceID: "team-a",
+			}
+		}
+
+		saved, err := s.SaveIfAbsent(ctx, link("absent1", "https://old.com"))
+		require.NoError(t, err)
+		assert.True(t, saved)
+
+		saved, err = s.SaveIfAbsent(ctx, link("absent1", "https://new.com"))
+		require.NoError(t, err)
+		assert.False(t, saved)
+
+		got, err := s.GetByCode(ctx, "absent1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://old.com", got.OriginalURL)
+
+		_, err = s.SaveIfAbsent(ctx, link("absent2", "https://old.com"))
+		require.ErrorIs(t, err, shortener.ErrDuplicate)
+
+		// Cleanup
+		client.Del(ctx, "url:absent1", "url:absent2")
+		client.HDel(ctx, "url_hashes:team-a", "absenthash")
 	})
 
 	t.Run("overwrite existing url", func(t *testing.T) {
@@ -87,6 +150,280 @@ func TestRedisStoreIntegration(t *testing.T) {
 		client.Del(ctx, "url:"+string(code))
 	})
 
+	t.Run("updates move links in the hash index", func(t *testing.T) {
+		link := &shortener.ShortURL{
+			Code:        "movedhash1",
+			OriginalURL: "https://example.com/old",
+			URLHash:     "movedhashold",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		}
+		require.NoError(t, s.Save(ctx, link))
+
+		moved := *link
+		moved.OriginalURL = "https://example.com/new"
+		moved.URLHash = "movedhashnew"
+		require.NoError(t, s.Update(ctx, &moved))
+
+		_, err := s.GetByHash(ctx, "team-a", "movedhashold")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+		require.ErrorIs(t, client.HGet(ctx, "url_hashes:team-a", "movedhashold").Err(), redis.Nil)
+
+		got, err := s.GetByHash(ctx, "team-a", "movedhashnew")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", got.OriginalURL)
+
+		// A new link may take the old hash
+		_, created, err := s.SaveOrGet(ctx, &shortener.ShortURL{
+			Code:        "movedhash2",
+			OriginalURL: "https://example.com/old",
+			URLHash:     "movedhashold",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		})
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		// Cleanup
+		client.Del(ctx, "url:movedhash1", "url:movedhash2")
+		client.HDel(ctx, "url_hashes:team-a", "movedhashold", "movedhashnew")
+	})
+
+	t.Run("get by hash ignores index entries of links that moved", func(t *testing.T) {
+		require.NoError(t, s.Save(ctx, &shortener.ShortURL{
+			Code:        "stalehash1",
+			OriginalURL: "https://example.com/new",
+			URLHash:     "stalehashnew",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		}))
+
+		// Entries left behind before updates removed them
+		client.HSet(ctx, "url_hashes:team-a", "stalehashold", "stalehash1")
+		client.HSet(ctx, "url_hashes:team-b", "stalehashnew", "stalehash1")
+
+		_, err := s.GetByHash(ctx, "team-a", "stalehashold")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		_, err = s.GetByHash(ctx, "team-b", "stalehashnew")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		// Cleanup
+		client.Del(ctx, "url:stalehash1")
+		client.HDel(ctx, "url_hashes:team-a", "stalehashold", "stalehashnew")
+		client.HDel(ctx, "url_hashes:team-b", "stalehashnew")
+	})
+
+	t.Run("lists, counts and finds expired tombstones by scanning", func(t *testing.T) {
+		before, err := s.CountCodes(ctx)
+		require.NoError(t, err)
+
+		base := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		deletedAt := base.Add(time.Hour)
+
+		for n, code := range []shortener.Code{"scan01", "scan02", "scan03"} {
+			link := &shortener.ShortURL{
+				Code:        code,
+				OriginalURL: "https://example.com",
+				Strategy:    shortener.StrategyToken,
+				WorkspaceID: "scan-team",
+				CreatedAt:   base.Add(time.Duration(n) * time.Minute),
+			}
+			if code == "scan03" {
+				link.DeletedAt = &deletedAt
+			}
+
+			require.NoError(t, s.Save(ctx, link))
+		}
+
+		listed, err := s.List(ctx, shortener.ListQuery{WorkspaceID: "scan-team", Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, shortener.Code("scan02"), listed[0].Code)
+
+		after, err := s.CountCodes(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before[6]+3, after[6])
+
+		expired, err := s.ExpiredTombstones(ctx, base.Add(2*time.Hour), 10)
+		require.NoError(t, err)
+		assert.Contains(t, expired, shortener.Code("scan03"))
+		assert.NotContains(t, expired, shortener.Code("scan01"))
+
+		walked := make(map[shortener.Code]bool)
+		require.NoError(t, s.Walk(ctx, func(link *shortener.ShortURL) error {
+			walked[link.Code] = true
+
+			return nil
+		}))
+		assert.True(t, walked["scan01"] && walked["scan03"], "walks deleted links too")
+
+		// Cleanup
+		client.Del(ctx, "url:scan01", "url:scan02", "url:scan03")
+	})
+
+	t.Run("cache remembers missing codes until they are saved", func(t *testing.T) {
+		mock := &mockStore{}
+		cached := store.NewRedisCacheRepository(mock, client, store.RedisCacheConfig{TTL: time.Minute, MissTTL: time.Minute})
+
+		for range 2 {
+			_, err := cached.GetByCode(ctx, "cachemiss1")
+			require.ErrorIs(t, err, shortener.ErrNotFound)
+		}
+
+		assert.Equal(t, 1, mock.callCount, "store should be called once for a missing code")
+
+		require.NoError(t, cached.Save(ctx, &shortener.ShortURL{Code: "cachemiss1", OriginalURL: "https://example.com"}))
+
+		got, err := cached.GetByCode(ctx, "cachemiss1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", got.OriginalURL)
+
+		// Cleanup
+		client.Del(ctx, "url:cachemiss1", "url_missing:cachemiss1")
+	})
+
+	t.Run("stale reads leave the cached version and its index alone", func(t *testing.T) {
+		links := store.NewMemoryStore()
+		cached := store.NewRedisCacheRepository(links, client, store.RedisCacheConfig{TTL: time.Minute})
+		link := &shortener.ShortURL{
+			Code:        "cachestale1",
+			OriginalURL: "https://example.com/stale",
+			URLHash:     "cachestalehash",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		}
+		require.NoError(t, cached.Save(ctx, link))
+
+		deletedAt := time.Now()
+		deleted := *link
+		deleted.DeletedAt = &deletedAt
+		require.NoError(t, cached.Update(ctx, &deleted))
+
+		// The store still answers with the live version, as a lagging replica would
+		require.NoError(t, links.Save(ctx, link))
+
+		_, err := cached.GetByHash(ctx, "team-a", "cachestalehash")
+		require.NoError(t, err)
+
+		err = client.HGet(ctx, "url_hashes:team-a", "cachestalehash").Err()
+		require.ErrorIs(t, err, redis.Nil, "a stale read must not index the hash again")
+
+		got, err := cached.GetByCode(ctx, "cachestale1")
+		require.NoError(t, err)
+		assert.True(t, got.Deleted())
+
+		// Cleanup
+		client.Del(ctx, "url:cachestale1")
+	})
+
+	t.Run("cache moves updated links in the hash index", func(t *testing.T) {
+		links := store.NewMemoryStore()
+		cached := store.NewRedisCacheRepository(links, client, store.RedisCacheConfig{TTL: time.Minute})
+		link := &shortener.ShortURL{
+			Code:        "cachemoved1",
+			OriginalURL: "https://example.com/old",
+			URLHash:     "cachemovedold",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		}
+		require.NoError(t, cached.Save(ctx, link))
+
+		moved := *link
+		moved.OriginalURL = "https://example.com/new"
+		moved.URLHash = "cachemovednew"
+		require.NoError(t, cached.Update(ctx, &moved))
+
+		require.ErrorIs(t, client.HGet(ctx, "url_hashes:team-a", "cachemovedold").Err(), redis.Nil)
+
+		_, err := cached.GetByHash(ctx, "team-a", "cachemovedold")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		// An entry left behind does not lead to the moved link either
+		client.HSet(ctx, "url_hashes:team-a", "cachemovedold", "cachemoved1")
+
+		_, err = cached.GetByHash(ctx, "team-a", "cachemovedold")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+
+		got, err := cached.GetByHash(ctx, "team-a", "cachemovednew")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", got.OriginalURL)
+
+		// Cleanup
+		client.Del(ctx, "url:cachemoved1")
+		client.HDel(ctx, "url_hashes:team-a", "cachemovedold", "cachemovednew")
+	})
+
+	t.Run("refill lock lets one instance query the store", func(t *testing.T) {
+		gated := newGatedStore()
+		require.NoError(t, gated.Save(ctx, &shortener.ShortURL{Code: "cachelock1", OriginalURL: "https://example.com"}))
+
+		config := store.RedisCacheConfig{TTL: time.Minute, LockWait: 2 * time.Second}
+		first := store.NewRedisCacheRepository(gated, client, config)
+		second := store.NewRedisCacheRepository(gated, client, config)
+		results := make(chan *shortener.ShortURL, 2)
+
+		go func() {
+			got, _ := first.GetByCode(ctx, "cachelock1")
+			results <- got
+		}()
+
+		gated.awaitLookup(t)
+
+		go func() {
+			got, _ := second.GetByCode(ctx, "cachelock1")
+			results <- got
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		close(gated.release)
+
+		for range 2 {
+			got := <-results
+			require.NotNil(t, got)
+			assert.Equal(t, "https://example.com", got.OriginalURL)
+		}
+
+		assert.Equal(t, int32(1), gated.lookups.Load(), "store should be called by the lock holder only")
+
+		// Cleanup
+		client.Del(ctx, "url:cachelock1", "url_lock:cachelock1")
+	})
+
+	t.Run("cache refreshes links before they expire", func(t *testing.T) {
+		links := newGatedStore()
+		close(links.release)
+		require.NoError(t, links.Save(ctx, &shortener.ShortURL{Code: "cacherefresh1", OriginalURL: "https://old.example"}))
+
+		// A huge beta draws a refresh on every hit
+		cached := store.NewRedisCacheRepository(links, client, store.RedisCacheConfig{
+			TTL:         time.Minute,
+			RefreshBeta: 1e15,
+			Refreshes:   1,
+		})
+
+		_, err := cached.GetByCode(ctx, "cacherefresh1")
+		require.NoError(t, err)
+
+		// Changed behind the cache, which keeps serving the old link until refreshed
+		require.NoError(t, links.Update(ctx, &shortener.ShortURL{Code: "cacherefresh1", OriginalURL: "https://new.example"}))
+
+		got, err := cached.GetByCode(ctx, "cacherefresh1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://old.example", got.OriginalURL)
+
+		require.NoError(t, cached.Shutdown())
+		assert.Equal(t, store.RefreshStats{Refreshed: 1}, cached.RefreshStats())
+
+		got, err = cached.GetByCode(ctx, "cacherefresh1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://new.example", got.OriginalURL)
+
+		// Cleanup
+		require.NoError(t, cached.Shutdown())
+		client.Del(ctx, "url:cacherefresh1")
+	})
+
 	t.Run("get non-existent returns ErrNotFound", func(t *testing.T) {
 		got, err := s.GetByCode(ctx, "nonexistent")
 
@@ -95,7 +432,7 @@ func TestRedisStoreIntegration(t *testing.T) {
 	})
 
 	t.Run("get by hash non-existent returns ErrNotFound", func(t *testing.T) {
-		got, err := s.GetByHash(ctx, "nonexistenthash")
+		got, err := s.GetByHash(ctx, "default", "nonexistenthash")
 
 		assert.Nil(t, got)
 		assert.ErrorIs(t, err, shortener.ErrNotFound)
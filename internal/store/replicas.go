@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// replicaLagQuery returns how many seconds a replica lags behind its primary:
+// 0 when it has replayed everything it received, or on the primary itself.
+const replicaLagQuery = `
+	SELECT COALESCE(CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END, 0)::float8`
+
+// Querier runs single-row queries, on the primary or on a replica.
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Replicas routes link lookups to PostgreSQL read replicas in turn, skipping
+// those that fail or lag more than maxLag behind the primary. Replicas are
+// taken back into rotation by Check once they have caught up.
+type Replicas struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+	logger   *zap.Logger
+}
+
+// replica is a read replica and whether it currently serves lookups.
+type replica struct {
+	name    string
+	db      Querier
+	healthy atomic.Bool
+}
+
+// NewReplicas creates a router over replicas, which start in rotation. Replicas
+// are named by their position in logs.
+func NewReplicas(maxLag time.Duration, logger *zap.Logger, replicas ...Querier) *Replicas {
+	r := &Replicas{maxLag: maxLag, logger: logger}
+
+	for n, db := range replicas {
+		rep := &replica{name: "replica-" + strconv.Itoa(n), db: db}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+
+	return r
+}
+
+// Check measures the lag of every replica, taking out of rotation those that
+// fail to answer or lag more than maxLag, and back those that have recovered.
+func (r *Replicas) Check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		lagging, err := replicaLag(ctx, rep.db)
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err != nil:
+			r.setHealthy(rep, false, zap.Error(err))
+		case lagging > r.maxLag:
+			r.setHealthy(rep, false, zap.Duration("lag", lagging))
+		default:
+			r.setHealthy(rep, true, zap.Duration("lag", lagging))
+		}
+	}
+}
+
+// Run checks the replicas every interval until ctx is cancelled.
+func (r *Replicas) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Lookup runs a lookup on the next replica in rotation, or on the primary if
+// none is. A link missing from a replica may have been created within its lag,
+// so misses are looked up again on the primary while the replica has changes
+// left to replay; misses of a replica that has caught up, most of them being
+// unknown codes, stay off the primary. Lookups of a replica that fails are run
+// on the primary too, and the replica is taken out of rotation until the next
+// check. Lookups asking for the latest version always run on the primary.
+func (r *Replicas) Lookup(
+	ctx context.Context, primary Querier, lookup func(Querier) (*shortener.ShortURL, error),
+) (*shortener.ShortURL, error) {
+	if shortener.LatestReadsFromContext(ctx) {
+		return lookup(primary)
+	}
+
+	rep := r.pick()
+	if rep == nil {
+		return lookup(primary)
+	}
+
+	shortURL, err := lookup(rep.db)
+	if err == nil {
+		return shortURL, nil
+	}
+
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	if errors.Is(err, shortener.ErrNotFound) {
+		lagging, lagErr := replicaLag(ctx, rep.db)
+		if lagErr == nil && lagging == 0 {
+			return nil, err
+		}
+
+		err = lagErr
+	}
+
+	if err != nil {
+		r.setHealthy(rep, false, zap.Error(err))
+	}
+
+	return lookup(primary)
+}
+
+// replicaLag returns how far a replica lags behind its primary, 0 when it has
+// replayed all the changes it received.
+func replicaLag(ctx context.Context, db Querier) (time.Duration, error) {
+	var lag float64
+
+	err := db.QueryRow(ctx, replicaLagQuery).Scan(&lag)
+
+	return time.Duration(lag * float64(time.Second)), err
+}
+
+// pick returns the next replica in rotation, or nil if none is healthy.
+func (r *Replicas) pick() *replica {
+	count := uint64(len(r.replicas))
+
+	for range count {
+		rep := r.replicas[r.next.Add(1)%count]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+
+	return nil
+}
+
+// setHealthy puts a replica in or out of rotation, logging changes.
+func (r *Replicas) setHealthy(rep *replica, healthy bool, reason zap.Field) {
+	if rep.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	if healthy {
+		r.logger.Info("replica back in rotation", zap.String("replica", rep.name), reason)
+	} else {
+		r.logger.Warn("replica out of rotation", zap.String("replica", rep.name), reason)
+	}
+}
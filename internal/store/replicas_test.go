@@ -0,0 +1,174 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeDB answers lag queries with its lag, or err when set.
+type fakeDB struct {
+	name string
+	lag  float64
+	err  error
+}
+
+func (f *fakeDB) QueryRow(_ context.Context, _ string, _ ...any) pgx.Row {
+	return fakeRow{db: f}
+}
+
+type fakeRow struct {
+	db *fakeDB
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.db.err != nil {
+		return r.db.err
+	}
+
+	*dest[0].(*float64) = r.db.lag
+
+	return nil
+}
+
+// links returns a lookup finding the given codes on each database, and records
+// the databases it ran on.
+func links(found map[string]bool, visited *[]string) func(store.Querier) (*shortener.ShortURL, error) {
+	return func(db store.Querier) (*shortener.ShortURL, error) {
+		f := db.(*fakeDB)
+		*visited = append(*visited, f.name)
+
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		if !found[f.name] {
+			return nil, shortener.ErrNotFound
+		}
+
+		return &shortener.ShortURL{Code: "abc"}, nil
+	}
+}
+
+func TestReplicas_Lookup(t *testing.T) {
+	ctx := context.Background()
+	primary := &fakeDB{name: "primary"}
+
+	t.Run("rotates over replicas", func(t *testing.T) {
+		r := store.NewReplicas(time.Second, zap.NewNop(), &fakeDB{name: "r0"}, &fakeDB{name: "r1"})
+		found := map[string]bool{"r0": true, "r1": true}
+
+		var visited []string
+
+		for range 4 {
+			_, err := r.Lookup(ctx, primary, links(found, &visited))
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []string{"r1", "r0", "r1", "r0"}, visited)
+	})
+
+	t.Run("looks up misses on the primary while replicas replay", func(t *testing.T) {
+		replaying := &fakeDB{name: "r0", lag: 0.5}
+		r := store.NewReplicas(time.Second, zap.NewNop(), replaying)
+
+		var visited []string
+
+		shortURL, err := r.Lookup(ctx, primary, links(map[string]bool{"primary": true}, &visited))
+
+		require.NoError(t, err)
+		assert.Equal(t, shortener.Code("abc"), shortURL.Code)
+		assert.Equal(t, []string{"r0", "primary"}, visited)
+
+		visited = nil
+		_, err = r.Lookup(ctx, primary, links(nil, &visited))
+
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, []string{"r0", "primary"}, visited)
+
+		// Once caught up, the replica has every link of the primary
+		replaying.lag = 0
+		visited = nil
+		_, err = r.Lookup(ctx, primary, links(nil, &visited))
+
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, []string{"r0"}, visited)
+	})
+
+	t.Run("looks up the latest version on the primary", func(t *testing.T) {
+		r := store.NewReplicas(time.Second, zap.NewNop(), &fakeDB{name: "r0"})
+		found := map[string]bool{"primary": true, "r0": true}
+
+		var visited []string
+
+		_, err := r.Lookup(shortener.ContextWithLatestReads(ctx), primary, links(found, &visited))
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"primary"}, visited)
+	})
+
+	t.Run("takes failing replicas out of rotation", func(t *testing.T) {
+		failing := &fakeDB{name: "r0", err: errors.New("connection refused")}
+		r := store.NewReplicas(time.Second, zap.NewNop(), failing)
+		found := map[string]bool{"primary": true, "r0": true}
+
+		var visited []string
+
+		_, err := r.Lookup(ctx, primary, links(found, &visited))
+		require.NoError(t, err)
+
+		_, err = r.Lookup(ctx, primary, links(found, &visited))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"r0", "primary", "primary"}, visited)
+
+		// The next check finds the replica answering again
+		failing.err = nil
+		r.Check(ctx)
+
+		visited = nil
+		_, err = r.Lookup(ctx, primary, links(found, &visited))
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"r0"}, visited)
+	})
+}
+
+func TestReplicas_Check(t *testing.T) {
+	ctx := context.Background()
+	primary := &fakeDB{name: "primary"}
+	lagging := &fakeDB{name: "r0", lag: 30}
+	r := store.NewReplicas(5*time.Second, zap.NewNop(), lagging, &fakeDB{name: "r1", lag: 1})
+	found := map[string]bool{"r0": true, "r1": true}
+
+	r.Check(ctx)
+
+	var visited []string
+
+	for range 3 {
+		_, err := r.Lookup(ctx, primary, links(found, &visited))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"r1", "r1", "r1"}, visited)
+
+	lagging.lag = 0
+	r.Check(ctx)
+
+	visited = nil
+
+	for range 2 {
+		_, err := r.Lookup(ctx, primary, links(found, &visited))
+		require.NoError(t, err)
+	}
+
+	assert.ElementsMatch(t, []string{"r0", "r1"}, visited)
+}
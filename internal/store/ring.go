@@ -0,0 +1,69 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// ringVirtualNodes is the number of points each shard has on a Ring. More
+// points spread keys more evenly; changing it moves most keys.
+const ringVirtualNodes = 160
+
+// ErrNoShards is returned when creating a Ring, or a ShardedRepository, without shards.
+var ErrNoShards = errors.New("at least one shard is required")
+
+// Ring places keys on shards by consistent hashing. Each shard owns the arcs of
+// the ring that end at its points, so adding a shard only moves the keys of the
+// arcs it takes over, about a share of the keys of every existing shard.
+type Ring struct {
+	points []ringPoint
+}
+
+// ringPoint is a point of a Ring, owned by a shard.
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// NewRing creates a ring over shards numbered from 0. Shards keep their keys
+// when shards are added after them.
+func NewRing(shards int) (*Ring, error) {
+	if shards < 1 {
+		return nil, ErrNoShards
+	}
+
+	points := make([]ringPoint, 0, shards*ringVirtualNodes)
+
+	for shard := range shards {
+		for node := range ringVirtualNodes {
+			key := "shard-" + strconv.Itoa(shard) + "-" + strconv.Itoa(node)
+			points = append(points, ringPoint{hash: xxhash.Sum64String(key), shard: shard})
+		}
+	}
+
+	slices.SortFunc(points, func(a, b ringPoint) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), cmp.Compare(a.shard, b.shard))
+	})
+
+	return &Ring{points: points}, nil
+}
+
+// Locate returns the shard owning key: that of the first point at or after its
+// hash, wrapping around the ring.
+func (r *Ring) Locate(key string) int {
+	hash := xxhash.Sum64String(key)
+
+	n, _ := slices.BinarySearchFunc(r.points, hash, func(p ringPoint, hash uint64) int {
+		return cmp.Compare(p.hash, hash)
+	})
+
+	if n == len(r.points) {
+		n = 0
+	}
+
+	return r.points[n].shard
+}
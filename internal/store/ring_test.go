@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRing(t *testing.T, shards int) *store.Ring {
+	t.Helper()
+
+	ring, err := store.NewRing(shards)
+	require.NoError(t, err)
+
+	return ring
+}
+
+func TestRing_RequiresShards(t *testing.T) {
+	for _, shards := range []int{0, -1} {
+		_, err := store.NewRing(shards)
+		require.ErrorIs(t, err, store.ErrNoShards)
+	}
+
+	_, err := store.NewShardedRepository(nil, store.ShardConfig{})
+	require.ErrorIs(t, err, store.ErrNoShards)
+}
+
+func TestRing_Locate(t *testing.T) {
+	const keys = 10000
+
+	three := newRing(t, 3)
+	four := newRing(t, 4)
+
+	perShard := make(map[int]int)
+	moved := 0
+
+	for n := range keys {
+		key := "code" + strconv.Itoa(n)
+		before, after := three.Locate(key), four.Locate(key)
+
+		assert.Equal(t, before, three.Locate(key), "locating is deterministic")
+
+		perShard[before]++
+
+		if before != after {
+			moved++
+
+			assert.Equal(t, 3, after, "keys only move to the added shard")
+		}
+	}
+
+	for shard := range 3 {
+		assert.InDelta(t, keys/3, perShard[shard], keys/10, "shard %d", shard)
+	}
+
+	assert.InDelta(t, keys/4, moved, keys/10)
+}
@@ -0,0 +1,336 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// Shard is a store holding the links of some of the codes of a ShardedRepository.
+type Shard interface {
+	shortener.Repository
+	shortener.Lister
+	ExpiredTombstones(ctx context.Context, deletedBefore time.Time, limit int) ([]shortener.Code, error)
+	CountCodes(ctx context.Context) (map[int]int64, error)
+	// SaveIfAbsent stores a link unless its code is taken, and reports whether
+	// it did. Like Save, it fails with shortener.ErrDuplicate if the link is a
+	// live hash-strategy link whose URL another one deduplicates.
+	SaveIfAbsent(ctx context.Context, shortURL *shortener.ShortURL) (bool, error)
+	// Walk calls fn with every link, deleted ones included, until fn fails.
+	Walk(ctx context.Context, fn func(*shortener.ShortURL) error) error
+}
+
+// ShardConfig configures a ShardedRepository.
+type ShardConfig struct {
+	// Resharding looks up links missing from the shard of their code on the
+	// other shards, where they stay until Rebalance has moved them.
+	Resharding bool
+}
+
+// ShardedRepository spreads links across shards by the consistent hash of their
+// code, so lookups and changes of a link go to a single shard. Lookups by URL
+// hash, listings and counts are gathered from every shard.
+//
+// Creations of a hash-strategy link look for an equivalent link on every shard
+// first, then rely on the shard of the new code to deduplicate atomically, so
+// concurrent creations of one URL can leave a live duplicate on another shard.
+// Both links work, and lookups by hash return the oldest.
+type ShardedRepository struct {
+	shards []Shard
+	ring   *Ring
+	config ShardConfig
+}
+
+// NewShardedRepository creates a repository over shards, of which there must be
+// at least one. Shards may be added at the end of the list, after which
+// Rebalance moves the links they now own.
+func NewShardedRepository(shards []Shard, config ShardConfig) (*ShardedRepository, error) {
+	ring, err := NewRing(len(shards))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShardedRepository{shards: shards, ring: ring, config: config}, nil
+}
+
+func (s *ShardedRepository) Save(ctx context.Context, shortURL *shortener.ShortURL) error {
+	return s.shardOf(shortURL.Code).Save(ctx, shortURL)
+}
+
+func (s *ShardedRepository) SaveOrGet(
+	ctx context.Context, shortURL *shortener.ShortURL,
+) (*shortener.ShortURL, bool, error) {
+	if shortURL.Deduplicated() {
+		existing, err := s.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
+		if err == nil {
+			return existing, false, nil
+		}
+
+		if !errors.Is(err, shortener.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	return s.shardOf(shortURL.Code).SaveOrGet(ctx, shortURL)
+}
+
+func (s *ShardedRepository) GetByCode(ctx context.Context, code shortener.Code) (*shortener.ShortURL, error) {
+	var shortURL *shortener.ShortURL
+
+	err := s.onShardOf(code, func(shard Shard) error {
+		var err error
+
+		shortURL, err = shard.GetByCode(ctx, code)
+
+		return err
+	})
+
+	return shortURL, err
+}
+
+// GetByHash returns the oldest live link of a URL hash found on any shard.
+func (s *ShardedRepository) GetByHash(
+	ctx context.Context, workspaceID string, hash shortener.URLHash,
+) (*shortener.ShortURL, error) {
+	var found *shortener.ShortURL
+
+	err := s.gather(func(shard Shard) (func(), error) {
+		shortURL, err := shard.GetByHash(ctx, workspaceID, hash)
+		if errors.Is(err, shortener.ErrNotFound) {
+			return func() {}, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func() {
+			if found == nil || shortURL.CreatedAt.Before(found.CreatedAt) {
+				found = shortURL
+			}
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, shortener.ErrNotFound
+	}
+
+	return found, nil
+}
+
+func (s *ShardedRepository) Update(ctx context.Context, shortURL *shortener.ShortURL) error {
+	return s.onShardOf(shortURL.Code, func(shard Shard) error {
+		return shard.Update(ctx, shortURL)
+	})
+}
+
+func (s *ShardedRepository) Delete(ctx context.Context, code shortener.Code) error {
+	return s.onShardOf(code, func(shard Shard) error {
+		return shard.Delete(ctx, code)
+	})
+}
+
+// List merges the pages of every shard, each newest first, into one page.
+func (s *ShardedRepository) List(ctx context.Context, query shortener.ListQuery) ([]*shortener.ShortURL, error) {
+	var urls []*shortener.ShortURL
+
+	err := s.gather(func(shard Shard) (func(), error) {
+		page, err := shard.List(ctx, query)
+
+		return func() { urls = append(urls, page...) }, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return firstPage(urls, query.Limit), nil
+}
+
+// ExpiredTombstones returns the codes of up to limit links deleted before
+// deletedBefore, from any shards.
+func (s *ShardedRepository) ExpiredTombstones(
+	ctx context.Context, deletedBefore time.Time, limit int,
+) ([]shortener.Code, error) {
+	var codes []shortener.Code
+
+	err := s.gather(func(shard Shard) (func(), error) {
+		found, err := shard.ExpiredTombstones(ctx, deletedBefore, limit)
+
+		return func() { codes = append(codes, found...) }, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(codes) > limit {
+		codes = codes[:limit]
+	}
+
+	return codes, nil
+}
+
+// CountCodes returns the number of codes of each length on all shards.
+func (s *ShardedRepository) CountCodes(ctx context.Context) (map[int]int64, error) {
+	counts := make(map[int]int64)
+
+	err := s.gather(func(shard Shard) (func(), error) {
+		found, err := shard.CountCodes(ctx)
+
+		return func() {
+			for length, count := range found {
+				counts[length] += count
+			}
+		}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// RebalanceStats counts the links moved by Rebalance.
+type RebalanceStats struct {
+	// Moved counts the links saved on the shard of their code.
+	Moved int
+	// Demoted counts the moved hash-strategy links turned into token-strategy
+	// links, because another link already deduplicated their URL on their shard.
+	Demoted int
+}
+
+// Rebalance moves every link stored on another shard than that of its code, as
+// happens to some links when shards are added. A link is saved on its new shard
+// before being deleted from its old one, so Rebalance can be run again after
+// being interrupted. A link already on its new shard was moved by the
+// interrupted run and may have changed since, so it is kept over the old copy.
+func (s *ShardedRepository) Rebalance(ctx context.Context) (RebalanceStats, error) {
+	var stats RebalanceStats
+
+	for n, shard := range s.shards {
+		err := shard.Walk(ctx, func(shortURL *shortener.ShortURL) error {
+			target := s.ring.Locate(string(shortURL.Code))
+			if target == n {
+				return nil
+			}
+
+			demoted, err := s.moveTo(ctx, s.shards[target], shortURL)
+			if err != nil {
+				return fmt.Errorf("moving %s to shard %d: %w", shortURL.Code, target, err)
+			}
+
+			if err := shard.Delete(ctx, shortURL.Code); err != nil {
+				return fmt.Errorf("removing %s from shard %d: %w", shortURL.Code, n, err)
+			}
+
+			stats.Moved++
+
+			if demoted {
+				stats.Demoted++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// moveTo saves a link on a shard unless the shard already holds its code, and
+// reports whether it was demoted. Live hash-strategy links of a URL may have
+// been created on two shards, of which only one can deduplicate it: a moved link
+// whose URL is already deduplicated on the shard is saved as a token-strategy
+// link instead, which keeps its code working.
+func (s *ShardedRepository) moveTo(ctx context.Context, shard Shard, shortURL *shortener.ShortURL) (bool, error) {
+	if !shortURL.Deduplicated() || shortURL.Deleted() {
+		_, err := shard.SaveIfAbsent(ctx, shortURL)
+
+		return false, err
+	}
+
+	existing, err := shard.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
+
+	switch {
+	case errors.Is(err, shortener.ErrNotFound), err == nil && existing.Code == shortURL.Code:
+		// A link deduplicating the URL can still be created meanwhile
+		if _, err := shard.SaveIfAbsent(ctx, shortURL); !errors.Is(err, shortener.ErrDuplicate) {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	}
+
+	demoted := *shortURL
+	demoted.Strategy = shortener.StrategyToken
+
+	saved, err := shard.SaveIfAbsent(ctx, &demoted)
+
+	return saved, err
+}
+
+// shardOf returns the shard of a code.
+func (s *ShardedRepository) shardOf(code shortener.Code) Shard {
+	return s.shards[s.ring.Locate(string(code))]
+}
+
+// onShardOf runs op on the shard of a code. While resharding, a code missing
+// from its shard may not have been moved yet, so op is run on the other
+// shards until one of them holds it.
+func (s *ShardedRepository) onShardOf(code shortener.Code, op func(Shard) error) error {
+	home := s.ring.Locate(string(code))
+
+	err := op(s.shards[home])
+	if !s.config.Resharding || !errors.Is(err, shortener.ErrNotFound) {
+		return err
+	}
+
+	for n, shard := range s.shards {
+		if n == home {
+			continue
+		}
+
+		if err = op(shard); !errors.Is(err, shortener.ErrNotFound) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// gather runs query on every shard concurrently. The functions it returns are
+// run one at a time to merge the results, once all shards have answered
+// without error.
+func (s *ShardedRepository) gather(query func(Shard) (func(), error)) error {
+	var (
+		wg     sync.WaitGroup
+		merges = make([]func(), len(s.shards))
+		errs   = make([]error, len(s.shards))
+	)
+
+	for n, shard := range s.shards {
+		wg.Go(func() {
+			merges[n], errs[n] = query(shard)
+		})
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, merge := range merges {
+		merge()
+	}
+
+	return nil
+}
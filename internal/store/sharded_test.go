@@ -0,0 +1,320 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newShards returns count memory stores, and the same stores as shards.
+func newShards(count int) ([]*store.MemoryStore, []store.Shard) {
+	stores := make([]*store.MemoryStore, count)
+	shards := make([]store.Shard, count)
+
+	for n := range count {
+		stores[n] = store.NewMemoryStore()
+		shards[n] = stores[n]
+	}
+
+	return stores, shards
+}
+
+// holders returns the indexes of the stores holding a code.
+func holders(t *testing.T, stores []*store.MemoryStore, code shortener.Code) []int {
+	t.Helper()
+
+	var found []int
+
+	for n, s := range stores {
+		if _, err := s.GetByCode(context.Background(), code); err == nil {
+			found = append(found, n)
+		}
+	}
+
+	return found
+}
+
+func newSharded(t *testing.T, shards []store.Shard, config store.ShardConfig) *store.ShardedRepository {
+	t.Helper()
+
+	repo, err := store.NewShardedRepository(shards, config)
+	require.NoError(t, err)
+
+	return repo
+}
+
+func shardedCode(n int) shortener.Code {
+	return shortener.Code(fmt.Sprintf("code%03d", n))
+}
+
+func TestShardedRepository_Placement(t *testing.T) {
+	ctx := context.Background()
+	stores, shards := newShards(3)
+	repo := newSharded(t, shards, store.ShardConfig{})
+	ring := newRing(t, 3)
+
+	for n := range 60 {
+		code := shardedCode(n)
+		require.NoError(t, repo.Save(ctx, &shortener.ShortURL{Code: code, OriginalURL: "https://old.example"}))
+
+		assert.Equal(t, []int{ring.Locate(string(code))}, holders(t, stores, code))
+	}
+
+	for n := range 3 {
+		links, err := stores[n].List(ctx, shortener.ListQuery{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, links, "shard %d", n)
+	}
+
+	require.NoError(t, repo.Update(ctx, &shortener.ShortURL{Code: "code007", OriginalURL: "https://new.example"}))
+
+	updated, err := repo.GetByCode(ctx, "code007")
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example", updated.OriginalURL)
+
+	require.NoError(t, repo.Delete(ctx, "code007"))
+	assert.Empty(t, holders(t, stores, "code007"))
+	require.ErrorIs(t, repo.Delete(ctx, "code007"), shortener.ErrNotFound)
+}
+
+func TestShardedRepository_GatheredQueries(t *testing.T) {
+	ctx := context.Background()
+	_, shards := newShards(3)
+	repo := newSharded(t, shards, store.ShardConfig{})
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	deletedAt := createdAt.Add(time.Hour)
+
+	for n := range 20 {
+		shortURL := &shortener.ShortURL{Code: shardedCode(n), CreatedAt: createdAt.Add(time.Duration(n) * time.Minute)}
+		if n%5 == 0 {
+			shortURL.DeletedAt = &deletedAt
+		}
+
+		require.NoError(t, repo.Save(ctx, shortURL))
+	}
+
+	t.Run("lists pages merged from every shard", func(t *testing.T) {
+		var codes []shortener.Code
+
+		query := shortener.ListQuery{Limit: 3}
+
+		for {
+			page, err := repo.List(ctx, query)
+			require.NoError(t, err)
+
+			for _, shortURL := range page {
+				codes = append(codes, shortURL.Code)
+			}
+
+			if len(page) < query.Limit {
+				break
+			}
+
+			last := page[len(page)-1]
+			query.After = &shortener.Cursor{CreatedAt: last.CreatedAt, Code: last.Code}
+		}
+
+		var want []shortener.Code
+
+		for n := 19; n >= 0; n-- {
+			if n%5 != 0 {
+				want = append(want, shardedCode(n))
+			}
+		}
+
+		assert.Equal(t, want, codes)
+	})
+
+	t.Run("counts codes on every shard", func(t *testing.T) {
+		counts, err := repo.CountCodes(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, map[int]int64{7: 20}, counts)
+	})
+
+	t.Run("finds tombstones on every shard", func(t *testing.T) {
+		codes, err := repo.ExpiredTombstones(ctx, deletedAt.Add(time.Second), 10)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []shortener.Code{"code000", "code005", "code010", "code015"}, codes)
+
+		codes, err = repo.ExpiredTombstones(ctx, deletedAt.Add(time.Second), 2)
+		require.NoError(t, err)
+		assert.Len(t, codes, 2)
+	})
+}
+
+func TestShardedRepository_Hashes(t *testing.T) {
+	ctx := context.Background()
+	stores, shards := newShards(3)
+	repo := newSharded(t, shards, store.ShardConfig{})
+	ring := newRing(t, 3)
+
+	hashLink := func(code shortener.Code) *shortener.ShortURL {
+		return &shortener.ShortURL{
+			Code:        code,
+			OriginalURL: "https://example.com",
+			URLHash:     "somehash",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		}
+	}
+
+	// Two codes living on different shards
+	first := shardedCode(0)
+	second := shardedCode(1)
+
+	for n := 2; ring.Locate(string(second)) == ring.Locate(string(first)); n++ {
+		second = shardedCode(n)
+	}
+
+	stored, created, err := repo.SaveOrGet(ctx, hashLink(first))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, first, stored.Code)
+
+	stored, created, err = repo.SaveOrGet(ctx, hashLink(second))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first, stored.Code)
+	assert.Empty(t, holders(t, stores, second))
+
+	found, err := repo.GetByHash(ctx, "team-a", "somehash")
+	require.NoError(t, err)
+	assert.Equal(t, first, found.Code)
+
+	_, err = repo.GetByHash(ctx, "team-b", "somehash")
+	require.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+func TestShardedRepository_Rebalance(t *testing.T) {
+	ctx := context.Background()
+	stores, shards := newShards(3)
+	before := newSharded(t, shards[:2], store.ShardConfig{})
+
+	for n := range 100 {
+		require.NoError(t, before.Save(ctx, &shortener.ShortURL{Code: shardedCode(n)}))
+	}
+
+	// A third shard is added, which owns some codes before they are moved to it
+	ring := newRing(t, 3)
+	owned := 0
+
+	for n := range 100 {
+		if ring.Locate(string(shardedCode(n))) == 2 {
+			owned++
+		}
+	}
+
+	require.NotZero(t, owned)
+
+	_, err := newSharded(t, shards, store.ShardConfig{}).GetByCode(ctx, firstOwnedBy(ring, 2))
+	require.ErrorIs(t, err, shortener.ErrNotFound)
+
+	after := newSharded(t, shards, store.ShardConfig{Resharding: true})
+
+	for n := range 100 {
+		_, err := after.GetByCode(ctx, shardedCode(n))
+		require.NoError(t, err, "resharding looks codes up on every shard")
+	}
+
+	stats, err := after.Rebalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, store.RebalanceStats{Moved: owned}, stats)
+
+	for n := range 100 {
+		code := shardedCode(n)
+		assert.Equal(t, []int{ring.Locate(string(code))}, holders(t, stores, code))
+	}
+
+	stats, err = after.Rebalance(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, stats)
+}
+
+func TestShardedRepository_RebalanceResumed(t *testing.T) {
+	ctx := context.Background()
+	stores, shards := newShards(2)
+	code := firstOwnedBy(newRing(t, 2), 0)
+
+	// An interrupted run saved the link on its shard without deleting the old
+	// copy, and the link was taken down since
+	disabledAt := time.Now()
+	require.NoError(t, stores[1].Save(ctx, &shortener.ShortURL{Code: code, OriginalURL: "https://example.com"}))
+	require.NoError(t, stores[0].Save(ctx, &shortener.ShortURL{
+		Code:        code,
+		OriginalURL: "https://example.com",
+		DisabledAt:  &disabledAt,
+	}))
+
+	repo := newSharded(t, shards, store.ShardConfig{Resharding: true})
+
+	stats, err := repo.Rebalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, store.RebalanceStats{Moved: 1}, stats)
+	assert.Equal(t, []int{0}, holders(t, stores, code))
+
+	got, err := repo.GetByCode(ctx, code)
+	require.NoError(t, err)
+	assert.True(t, got.Disabled(), "the old copy must not undo changes made since the move")
+}
+
+func TestShardedRepository_RebalanceDuplicates(t *testing.T) {
+	ctx := context.Background()
+	stores, shards := newShards(2)
+	ring := newRing(t, 2)
+
+	var owned []shortener.Code
+
+	for n := 0; len(owned) < 2; n++ {
+		if ring.Locate(string(shardedCode(n))) == 0 {
+			owned = append(owned, shardedCode(n))
+		}
+	}
+
+	hashLink := func(code shortener.Code) *shortener.ShortURL {
+		return &shortener.ShortURL{
+			Code:        code,
+			OriginalURL: "https://example.com",
+			URLHash:     "somehash",
+			Strategy:    shortener.StrategyHash,
+			WorkspaceID: "team-a",
+		}
+	}
+
+	// Concurrent creations left a live hash link of one URL on each shard, one of
+	// them on the wrong shard
+	kept, moved := owned[0], owned[1]
+	require.NoError(t, stores[0].Save(ctx, hashLink(kept)))
+	require.NoError(t, stores[1].Save(ctx, hashLink(moved)))
+
+	repo := newSharded(t, shards, store.ShardConfig{})
+
+	stats, err := repo.Rebalance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, store.RebalanceStats{Moved: 1, Demoted: 1}, stats)
+	assert.Equal(t, []int{0}, holders(t, stores, moved))
+
+	found, err := repo.GetByHash(ctx, "team-a", "somehash")
+	require.NoError(t, err)
+	assert.Equal(t, kept, found.Code)
+
+	demoted, err := repo.GetByCode(ctx, moved)
+	require.NoError(t, err)
+	assert.Equal(t, shortener.StrategyToken, demoted.Strategy)
+	assert.Equal(t, "https://example.com", demoted.OriginalURL)
+}
+
+// firstOwnedBy returns the first test code owned by a shard.
+func firstOwnedBy(ring *store.Ring, shard int) shortener.Code {
+	for n := 0; ; n++ {
+		if ring.Locate(string(shardedCode(n))) == shard {
+			return shardedCode(n)
+		}
+	}
+}
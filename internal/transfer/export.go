@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"context"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// exportPageSize is the number of links listed per query while exporting.
+const exportPageSize = 500
+
+// Export writes the links matching query to enc, newest first, and returns how
+// many it wrote. Deleted links are not exported. The cursor and limit of query
+// are ignored; links are listed page by page and flushed after each page, so
+// the export streams however many links there are.
+func Export(ctx context.Context, lister shortener.Lister, query shortener.ListQuery, enc Encoder) (int, error) {
+	query.After = nil
+	query.Limit = exportPageSize
+	exported := 0
+
+	for {
+		links, err := lister.List(ctx, query)
+		if err != nil {
+			return exported, err
+		}
+
+		for _, link := range links {
+			if err := enc.Encode(RecordOf(link)); err != nil {
+				return exported, err
+			}
+
+			exported++
+		}
+
+		if err := enc.Flush(); err != nil {
+			return exported, err
+		}
+
+		if len(links) < exportPageSize {
+			return exported, nil
+		}
+
+		last := links[len(links)-1]
+		query.After = &shortener.Cursor{CreatedAt: last.CreatedAt, Code: last.Code}
+	}
+}
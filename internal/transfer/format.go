@@ -0,0 +1,353 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// Format is a serialization of links for export and import.
+type Format string
+
+const (
+	// FormatCSV is comma-separated values with a header row naming the columns.
+	FormatCSV Format = "csv"
+	// FormatJSONL is one JSON object per line.
+	FormatJSONL Format = "jsonl"
+)
+
+// tagSeparator joins the tags of a link in a single CSV cell.
+const tagSeparator = "|"
+
+var (
+	// ErrUnknownFormat is returned for a format other than csv or jsonl.
+	ErrUnknownFormat = errors.New("unknown format: must be csv or jsonl")
+	// ErrMalformed is returned by Decode for a row that cannot be parsed.
+	// Decoding can continue with the next row.
+	ErrMalformed = errors.New("malformed row")
+	// ErrInvalidHeader is returned when the header of a CSV cannot be read or
+	// lacks a required column.
+	ErrInvalidHeader = errors.New("invalid CSV header")
+)
+
+// columns are the CSV columns in export order. Imports match columns by their
+// header name, so they may come in any order and unknown ones are ignored.
+var columns = []string{
+	"code", "originalUrl", "strategy", "ownerId", "workspaceId", "createdAt", "disabledAt",
+	"title", "description", "imageUrl", "tags", "notes",
+}
+
+// requiredColumns must be present in the header of an imported CSV.
+var requiredColumns = []string{"code", "originalUrl"}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv"
+	}
+
+	return "application/jsonl"
+}
+
+// Record is a link as exported and imported. The URL hash is not part of it;
+// it is recomputed from the destination on import.
+type Record struct {
+	Code        string     `json:"code"`
+	OriginalURL string     `json:"originalUrl"`
+	Strategy    string     `json:"strategy,omitempty"`
+	OwnerID     string     `json:"ownerId,omitempty"`
+	WorkspaceID string     `json:"workspaceId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DisabledAt  *time.Time `json:"disabledAt,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	ImageURL    string     `json:"imageUrl,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+}
+
+// RecordOf returns the record of a link.
+func RecordOf(s *shortener.ShortURL) *Record {
+	return &Record{
+		Code:        string(s.Code),
+		OriginalURL: s.OriginalURL,
+		Strategy:    s.Strategy,
+		OwnerID:     s.OwnerID,
+		WorkspaceID: s.WorkspaceID,
+		CreatedAt:   s.CreatedAt,
+		DisabledAt:  s.DisabledAt,
+		Title:       s.Title,
+		Description: s.Description,
+		ImageURL:    s.ImageURL,
+		Tags:        s.Tags,
+		Notes:       s.Notes,
+	}
+}
+
+// Encoder writes records in a format.
+type Encoder interface {
+	Encode(record *Record) error
+	// Flush writes any buffered records, and the CSV header if nothing was encoded.
+	Flush() error
+}
+
+// NewEncoder returns an encoder writing format to w.
+func NewEncoder(w io.Writer, format Format) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlEncoder{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// Decoder reads records in a format.
+type Decoder interface {
+	// Decode reads the next record. It returns io.EOF at the end of the input,
+	// and an error wrapping ErrMalformed for a row that cannot be parsed.
+	Decode() (*Record, error)
+	// Line returns the line the last row read starts on.
+	Line() int
+}
+
+// NewDecoder returns a decoder reading format from r.
+func NewDecoder(r io.Reader, format Format) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		return &csvDecoder{r: reader}, nil
+	case FormatJSONL:
+		return &jsonlDecoder{r: bufio.NewReader(r)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvEncoder) Encode(record *Record) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	return e.w.Write([]string{
+		record.Code,
+		record.OriginalURL,
+		record.Strategy,
+		record.OwnerID,
+		record.WorkspaceID,
+		formatTime(&record.CreatedAt),
+		formatTime(record.DisabledAt),
+		record.Title,
+		record.Description,
+		record.ImageURL,
+		strings.Join(record.Tags, tagSeparator),
+		record.Notes,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+
+	e.headerWritten = true
+
+	return e.w.Write(columns)
+}
+
+type csvDecoder struct {
+	r      *csv.Reader
+	header map[string]int
+	line   int
+}
+
+func (d *csvDecoder) Decode() (*Record, error) {
+	if d.header == nil {
+		if err := d.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	row, err := d.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			d.line = parseErr.StartLine
+
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, parseErr.Err)
+		}
+
+		return nil, err
+	}
+
+	d.line, _ = d.r.FieldPos(0)
+
+	return d.record(row)
+}
+
+// record builds the record of a row from the columns named in the header.
+func (d *csvDecoder) record(row []string) (*Record, error) {
+	field := func(name string) string {
+		if i, ok := d.header[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+
+		return ""
+	}
+
+	record := &Record{
+		Code:        field("code"),
+		OriginalURL: field("originalUrl"),
+		Strategy:    field("strategy"),
+		OwnerID:     field("ownerId"),
+		WorkspaceID: field("workspaceId"),
+		Title:       field("title"),
+		Description: field("description"),
+		ImageURL:    field("imageUrl"),
+		Notes:       field("notes"),
+	}
+
+	for tag := range strings.SplitSeq(field("tags"), tagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			record.Tags = append(record.Tags, tag)
+		}
+	}
+
+	var err error
+
+	if record.CreatedAt, err = parseTime(field("createdAt")); err != nil {
+		return nil, fmt.Errorf("%w: createdAt: %w", ErrMalformed, err)
+	}
+
+	disabledAt, err := parseTime(field("disabledAt"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: disabledAt: %w", ErrMalformed, err)
+	}
+
+	if !disabledAt.IsZero() {
+		record.DisabledAt = &disabledAt
+	}
+
+	return record, nil
+}
+
+func (d *csvDecoder) Line() int {
+	return d.line
+}
+
+// readHeader maps the column names of the header row to their position.
+func (d *csvDecoder) readHeader() error {
+	names, err := d.r.Read()
+	if errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	d.header = make(map[string]int, len(names))
+	for i, name := range names {
+		// Spreadsheets may start the file with a byte order mark
+		d.header[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+
+	for _, name := range requiredColumns {
+		if _, ok := d.header[name]; !ok {
+			return fmt.Errorf("%w: missing column %s", ErrInvalidHeader, name)
+		}
+	}
+
+	return nil
+}
+
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(record *Record) error {
+	return e.enc.Encode(record)
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return nil
+}
+
+type jsonlDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (d *jsonlDecoder) Decode() (*Record, error) {
+	for {
+		data, err := d.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return nil, err
+		}
+
+		d.line++
+
+		// Blank lines, such as a trailing one, are not rows
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+
+		record.Tags = slices.DeleteFunc(record.Tags, func(tag string) bool { return tag == "" })
+
+		return &record, nil
+	}
+}
+
+func (d *jsonlDecoder) Line() int {
+	return d.line
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseTime parses an RFC 3339 time; an empty string is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,157 @@
+package transfer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/transfer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAll(t *testing.T, dec transfer.Decoder) ([]*transfer.Record, []int) {
+	t.Helper()
+
+	var (
+		records   []*transfer.Record
+		malformed []int
+	)
+
+	for {
+		record, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return records, malformed
+		}
+
+		if errors.Is(err, transfer.ErrMalformed) {
+			malformed = append(malformed, dec.Line())
+
+			continue
+		}
+
+		require.NoError(t, err)
+
+		records = append(records, record)
+	}
+}
+
+func TestFormats_RoundTrip(t *testing.T) {
+	disabledAt := time.Date(2026, 2, 3, 4, 5, 6, 7000, time.UTC)
+	link := &shortener.ShortURL{
+		Code:        "abc123",
+		OriginalURL: "https://example.com/a,b",
+		Strategy:    shortener.StrategyHash,
+		OwnerID:     "alice",
+		WorkspaceID: "team-a",
+		CreatedAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		DisabledAt:  &disabledAt,
+		Metadata: shortener.Metadata{
+			Title: "Example \"quoted\"",
+			Tags:  []string{"docs", "launch"},
+			Notes: "line one\nline two",
+		},
+	}
+
+	for _, format := range []transfer.Format{transfer.FormatCSV, transfer.FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			enc, err := transfer.NewEncoder(&buf, format)
+			require.NoError(t, err)
+			require.NoError(t, enc.Encode(transfer.RecordOf(link)))
+			require.NoError(t, enc.Flush())
+
+			dec, err := transfer.NewDecoder(&buf, format)
+			require.NoError(t, err)
+
+			records, malformed := decodeAll(t, dec)
+
+			require.Empty(t, malformed)
+			require.Len(t, records, 1)
+			assert.Equal(t, transfer.RecordOf(link), records[0])
+		})
+	}
+}
+
+func TestCSVDecoder(t *testing.T) {
+	t.Run("matches columns by name and ignores unknown ones", func(t *testing.T) {
+		input := "\ufeffalias,originalUrl,code,tags\n" +
+			"x,https://example.com,abc,a| b |\n"
+
+		dec, err := transfer.NewDecoder(strings.NewReader(input), transfer.FormatCSV)
+		require.NoError(t, err)
+
+		records, _ := decodeAll(t, dec)
+
+		require.Len(t, records, 1)
+		assert.Equal(t, "abc", records[0].Code)
+		assert.Equal(t, "https://example.com", records[0].OriginalURL)
+		assert.Equal(t, []string{"a", "b"}, records[0].Tags)
+		assert.True(t, records[0].CreatedAt.IsZero())
+	})
+
+	t.Run("reports malformed rows and goes on", func(t *testing.T) {
+		input := "code,originalUrl,createdAt\n" +
+			"abc,https://example.com,yesterday\n" +
+			"def,https://example.com,2026-01-02T03:04:05Z\n"
+
+		dec, err := transfer.NewDecoder(strings.NewReader(input), transfer.FormatCSV)
+		require.NoError(t, err)
+
+		records, malformed := decodeAll(t, dec)
+
+		assert.Equal(t, []int{2}, malformed)
+		require.Len(t, records, 1)
+		assert.Equal(t, "def", records[0].Code)
+	})
+
+	t.Run("requires the code and originalUrl columns", func(t *testing.T) {
+		dec, err := transfer.NewDecoder(strings.NewReader("code,url\nabc,https://example.com\n"), transfer.FormatCSV)
+		require.NoError(t, err)
+
+		_, err = dec.Decode()
+
+		require.ErrorIs(t, err, transfer.ErrInvalidHeader)
+	})
+
+	t.Run("writes the header of an empty export", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		enc, err := transfer.NewEncoder(&buf, transfer.FormatCSV)
+		require.NoError(t, err)
+		require.NoError(t, enc.Flush())
+
+		assert.True(t, strings.HasPrefix(buf.String(), "code,originalUrl,"))
+	})
+}
+
+func TestJSONLDecoder(t *testing.T) {
+	input := `{"code":"abc","originalUrl":"https://example.com","extra":1}` + "\n" +
+		"\n" +
+		"{not json\n" +
+		`{"code":"def","originalUrl":"https://example.com"}`
+
+	dec, err := transfer.NewDecoder(strings.NewReader(input), transfer.FormatJSONL)
+	require.NoError(t, err)
+
+	records, malformed := decodeAll(t, dec)
+
+	assert.Equal(t, []int{3}, malformed)
+	require.Len(t, records, 2)
+	assert.Equal(t, "abc", records[0].Code)
+	assert.Equal(t, "def", records[1].Code)
+	assert.Equal(t, 4, dec.Line())
+}
+
+func TestNewEncoder_UnknownFormat(t *testing.T) {
+	_, err := transfer.NewEncoder(io.Discard, "xml")
+	require.ErrorIs(t, err, transfer.ErrUnknownFormat)
+
+	_, err = transfer.NewDecoder(strings.NewReader(""), "xml")
+	require.ErrorIs(t, err, transfer.ErrUnknownFormat)
+}
@@ -0,0 +1,467 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	"github.com/serroba/web-demo-go/internal/checkcode"
+	"github.com/serroba/web-demo-go/internal/messaging"
+	"github.com/serroba/web-demo-go/internal/quota"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"go.uber.org/zap"
+)
+
+// maxCodeLength bounds the codes of imported links, which the API never takes
+// from clients.
+const maxCodeLength = 64
+
+// progressInterval is the number of rows between progress reports.
+const progressInterval = 1000
+
+// maxReportedErrors bounds the row errors kept in a report; later ones are only counted.
+const maxReportedErrors = 100
+
+// ConflictPolicy decides what an import does with a link whose code is taken.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the stored link and skips the imported one.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the stored link with the imported one. Links of
+	// another workspace and deleted links are not replaced, failing the row.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictFail stops the import at the first taken code.
+	ConflictFail ConflictPolicy = "fail"
+)
+
+var (
+	// ErrConflict is returned when the ConflictFail policy stops an import.
+	ErrConflict = errors.New("a link with this code already exists")
+	// ErrUnknownConflictPolicy is returned for a policy other than skip, overwrite or fail.
+	ErrUnknownConflictPolicy = errors.New("unknown conflict policy: must be skip, overwrite or fail")
+
+	errInvalid = errors.New("invalid link")
+)
+
+// codePattern matches the characters codes of every strategy are made of.
+var codePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Options controls an import.
+type Options struct {
+	Conflict ConflictPolicy
+	// DryRun validates every row and reports what would happen without storing anything.
+	DryRun bool
+	// SuppressEvents skips publishing url.created for imported links, which also
+	// skips their metadata enrichment.
+	SuppressEvents bool
+	// Progress, if set, is called with the report so far every progressInterval rows.
+	Progress func(Report)
+}
+
+// Report tallies the rows of an import. In a dry run, it reports what the
+// import would have done.
+type Report struct {
+	Rows        int
+	Created     int
+	Overwritten int
+	Skipped     int
+	// Demoted counts hash-strategy links stored as token links because another
+	// live link of their workspace already deduplicates their URL.
+	Demoted int
+	Failed  int
+	// Errors holds the first maxReportedErrors rows that failed.
+	Errors []RowError
+}
+
+// RowError describes a row that could not be imported.
+type RowError struct {
+	Line    int
+	Code    string
+	Message string
+}
+
+// ConflictError is returned when the ConflictFail policy stops an import.
+type ConflictError struct {
+	Line int
+	Code string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("line %d: link %q already exists", e.Line, e.Code)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// ParseConflictPolicy validates a conflict policy name.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return policy, nil
+	default:
+		return "", ErrUnknownConflictPolicy
+	}
+}
+
+// Importer stores links read from an export or another shortener.
+type Importer struct {
+	repo              shortener.Repository
+	blocklist         *blocklist.Blocklist
+	reserved          *shortener.ReservedWords
+	checkedCodes      checkcode.Scope
+	publishURLCreated messaging.Publish[analytics.URLCreatedEvent]
+	logger            *zap.Logger
+}
+
+// NewImporter creates a new importer. Links are stored through repo, so its
+// decorators enforce quotas and audit imports like any other creation, and they
+// are validated like links created through the API: their destination must be an
+// http(s) URL off the blocklist, and their code must not be a reserved word. Codes
+// of checkedCodes must also pass their check, like generated ones, so that a
+// mistyped code never reaches an imported link.
+func NewImporter(
+	repo shortener.Repository,
+	blocklist *blocklist.Blocklist,
+	reserved *shortener.ReservedWords,
+	checkedCodes checkcode.Scope,
+	publishURLCreated messaging.Publish[analytics.URLCreatedEvent],
+	logger *zap.Logger,
+) *Importer {
+	return &Importer{
+		repo:              repo,
+		blocklist:         blocklist,
+		reserved:          reserved,
+		checkedCodes:      checkedCodes,
+		publishURLCreated: publishURLCreated,
+		logger:            logger,
+	}
+}
+
+// Import stores every row dec reads. Rows that are malformed, invalid or rejected
+// by the repository are counted as failed and the import goes on. It stops with a
+// *ConflictError at the first taken code under ConflictFail, and with the error
+// of the input or store otherwise; rows imported until then are kept.
+func (im *Importer) Import(ctx context.Context, dec Decoder, opts Options) (*Report, error) {
+	report := &Report{}
+
+	// A dry run stores nothing, so links repeated in the input are tracked here
+	var seen map[shortener.Code]*shortener.ShortURL
+	if opts.DryRun {
+		seen = make(map[shortener.Code]*shortener.ShortURL)
+	}
+
+	for {
+		record, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+
+		if err != nil && !errors.Is(err, ErrMalformed) {
+			return report, err
+		}
+
+		report.Rows++
+
+		if err == nil {
+			err = im.importRecord(ctx, record, opts, report, seen)
+		}
+
+		switch {
+		case errors.Is(err, ErrConflict):
+			return report, &ConflictError{Line: dec.Line(), Code: record.Code}
+		case isRowError(err):
+			report.fail(dec.Line(), record, err)
+		case err != nil:
+			return report, err
+		}
+
+		if opts.Progress != nil && report.Rows%progressInterval == 0 {
+			opts.Progress(*report)
+		}
+	}
+}
+
+// importRecord validates and stores a single record, updating report.
+func (im *Importer) importRecord(
+	ctx context.Context, record *Record, opts Options, report *Report, seen map[shortener.Code]*shortener.ShortURL,
+) error {
+	shortURL, err := im.validate(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	existing, err := im.existing(ctx, shortURL, seen)
+	if err != nil && !errors.Is(err, shortener.ErrNotFound) {
+		return err
+	}
+
+	if err == nil {
+		switch opts.Conflict {
+		case ConflictSkip:
+			report.Skipped++
+
+			return nil
+		case ConflictOverwrite:
+			if err := overwritable(existing, shortURL); err != nil {
+				return err
+			}
+
+			if !opts.DryRun {
+				if err := im.repo.Update(ctx, shortURL); err != nil {
+					return err
+				}
+			}
+
+			report.Overwritten++
+
+			return nil
+		default:
+			return ErrConflict
+		}
+	}
+
+	demoted, err := im.create(ctx, shortURL, opts.DryRun)
+	if err != nil {
+		return err
+	}
+
+	report.Created++
+
+	if demoted {
+		report.Demoted++
+	}
+
+	if !opts.DryRun && !opts.SuppressEvents {
+		im.publish(shortURL)
+	}
+
+	return nil
+}
+
+// existing returns the link already holding the code of shortURL, deleted or
+// not, as stored, or ErrNotFound if there is none.
+func (im *Importer) existing(
+	ctx context.Context, shortURL *shortener.ShortURL, seen map[shortener.Code]*shortener.ShortURL,
+) (*shortener.ShortURL, error) {
+	if seen != nil {
+		if prior, ok := seen[shortURL.Code]; ok {
+			return prior, nil
+		}
+
+		seen[shortURL.Code] = shortURL
+	}
+
+	return im.repo.GetByCode(shortener.ContextWithLatestReads(ctx), shortURL.Code)
+}
+
+// overwritable rejects overwrites that would move a link to another workspace,
+// or bring back a deleted one.
+func overwritable(existing, shortURL *shortener.ShortURL) error {
+	switch {
+	case existing.WorkspaceID != shortURL.WorkspaceID:
+		return fmt.Errorf("%w: code %q is taken in another workspace", errInvalid, shortURL.Code)
+	case existing.Deleted():
+		return fmt.Errorf("%w: code %q is taken by a deleted link", errInvalid, shortURL.Code)
+	}
+
+	return nil
+}
+
+// create stores a new link. A live hash-strategy link whose URL another live link
+// of its workspace already deduplicates is stored as a token link instead, so its
+// code keeps resolving; create reports whether that happened.
+func (im *Importer) create(ctx context.Context, shortURL *shortener.ShortURL, dryRun bool) (bool, error) {
+	if !shortURL.Deduplicated() {
+		if dryRun {
+			return false, nil
+		}
+
+		return false, im.repo.Save(ctx, shortURL)
+	}
+
+	if dryRun {
+		_, err := im.repo.GetByHash(ctx, shortURL.WorkspaceID, shortURL.URLHash)
+		if errors.Is(err, shortener.ErrNotFound) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	_, created, err := im.repo.SaveOrGet(ctx, shortURL)
+	if err != nil || created {
+		return false, err
+	}
+
+	shortURL.Strategy = shortener.StrategyToken
+
+	return true, im.repo.Save(ctx, shortURL)
+}
+
+// validate converts a record into a link, applying the limits of the API.
+func (im *Importer) validate(ctx context.Context, record *Record) (*shortener.ShortURL, error) {
+	if err := validateCode(record.Code); err != nil {
+		return nil, err
+	}
+
+	if im.reserved.Reserved(record.Code) {
+		return nil, fmt.Errorf("%w: code %q is reserved", errInvalid, record.Code)
+	}
+
+	if im.checkedCodes.Mistyped(record.Code) {
+		return nil, fmt.Errorf("%w: code %q fails its check character", errInvalid, record.Code)
+	}
+
+	hash, err := validateURL(record.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := im.blocklist.IsBlocked(ctx, record.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if blocked {
+		return nil, fmt.Errorf("%w: destination domain is blocked", errInvalid)
+	}
+
+	if err := validateMetadata(record); err != nil {
+		return nil, err
+	}
+
+	strategy, err := validateStrategy(record.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	shortURL := &shortener.ShortURL{
+		Code:        shortener.Code(record.Code),
+		OriginalURL: record.OriginalURL,
+		URLHash:     hash,
+		Strategy:    strategy,
+		OwnerID:     record.OwnerID,
+		WorkspaceID: record.WorkspaceID,
+		CreatedAt:   record.CreatedAt,
+		DisabledAt:  record.DisabledAt,
+		Metadata: shortener.Metadata{
+			Title:       record.Title,
+			Description: record.Description,
+			ImageURL:    record.ImageURL,
+			Tags:        record.Tags,
+			Notes:       record.Notes,
+		},
+	}
+
+	if shortURL.WorkspaceID == "" {
+		shortURL.WorkspaceID = auth.DefaultWorkspace
+	}
+
+	if shortURL.CreatedAt.IsZero() {
+		shortURL.CreatedAt = time.Now()
+	}
+
+	return shortURL, nil
+}
+
+func (im *Importer) publish(shortURL *shortener.ShortURL) {
+	event := &analytics.URLCreatedEvent{
+		Code:        string(shortURL.Code),
+		OriginalURL: shortURL.OriginalURL,
+		URLHash:     string(shortURL.URLHash),
+		Strategy:    shortURL.Strategy,
+		WorkspaceID: shortURL.WorkspaceID,
+		CreatedAt:   shortURL.CreatedAt,
+	}
+
+	if err := im.publishURLCreated(event); err != nil {
+		im.logger.Error("failed to publish analytics event",
+			zap.String("code", event.Code),
+			zap.Error(err),
+		)
+	}
+}
+
+func validateCode(code string) error {
+	switch {
+	case code == "":
+		return fmt.Errorf("%w: code is required", errInvalid)
+	case len(code) > maxCodeLength:
+		return fmt.Errorf("%w: code is longer than %d characters", errInvalid, maxCodeLength)
+	case !codePattern.MatchString(code):
+		return fmt.Errorf("%w: code may only contain letters, digits, '-' and '_'", errInvalid)
+	}
+
+	return nil
+}
+
+// validateURL checks that rawURL is an absolute http(s) URL and returns its hash.
+func validateURL(rawURL string) (shortener.URLHash, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w: originalUrl must be an absolute http or https URL", errInvalid)
+	}
+
+	return shortener.HashOf(rawURL)
+}
+
+// validateStrategy checks a strategy name; links without one are token links.
+func validateStrategy(strategy string) (string, error) {
+	switch strategy {
+	case "":
+		return shortener.StrategyToken, nil
+	case shortener.StrategyToken, shortener.StrategyHash, shortener.StrategyWords:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("%w: unknown strategy %q", errInvalid, strategy)
+	}
+}
+
+func validateMetadata(record *Record) error {
+	switch {
+	case utf8.RuneCountInString(record.Title) > shortener.MaxTitleLength:
+		return fmt.Errorf("%w: title is longer than %d characters", errInvalid, shortener.MaxTitleLength)
+	case utf8.RuneCountInString(record.Notes) > shortener.MaxNotesLength:
+		return fmt.Errorf("%w: notes are longer than %d characters", errInvalid, shortener.MaxNotesLength)
+	case len(record.Tags) > shortener.MaxTags:
+		return fmt.Errorf("%w: more than %d tags", errInvalid, shortener.MaxTags)
+	}
+
+	return nil
+}
+
+// isRowError reports whether err concerns a single row, which fails without
+// stopping the import.
+func isRowError(err error) bool {
+	var exceeded *quota.ExceededError
+
+	return errors.Is(err, ErrMalformed) ||
+		errors.Is(err, errInvalid) ||
+		errors.Is(err, shortener.ErrDuplicate) ||
+		errors.As(err, &exceeded)
+}
+
+func (r *Report) fail(line int, record *Record, err error) {
+	r.Failed++
+
+	if len(r.Errors) >= maxReportedErrors {
+		return
+	}
+
+	rowErr := RowError{Line: line, Message: err.Error()}
+	if record != nil {
+		rowErr.Code = record.Code
+	}
+
+	r.Errors = append(r.Errors, rowErr)
+}
@@ -0,0 +1,320 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/serroba/web-demo-go/internal/analytics"
+	"github.com/serroba/web-demo-go/internal/auth"
+	"github.com/serroba/web-demo-go/internal/blocklist"
+	blockliststore "github.com/serroba/web-demo-go/internal/blocklist/store"
+	"github.com/serroba/web-demo-go/internal/checkcode"
+	"github.com/serroba/web-demo-go/internal/shortener"
+	"github.com/serroba/web-demo-go/internal/store"
+	"github.com/serroba/web-demo-go/internal/transfer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type importFixture struct {
+	store    *store.MemoryStore
+	importer *transfer.Importer
+	events   []*analytics.URLCreatedEvent
+}
+
+func newImportFixture(t *testing.T) *importFixture {
+	t.Helper()
+
+	f := &importFixture{store: store.NewMemoryStore()}
+
+	blocked := blocklist.New(blockliststore.NewMemory())
+	_, err := blocked.Add(context.Background(), &blocklist.Entry{Domain: "blocked.example"})
+	require.NoError(t, err)
+
+	publish := func(e *analytics.URLCreatedEvent) error {
+		f.events = append(f.events, e)
+
+		return nil
+	}
+
+	f.importer = transfer.NewImporter(f.store, blocked, shortener.NewReservedWords("links"), checkcode.Scope{}, publish,
+		zap.NewNop())
+
+	return f
+}
+
+func (f *importFixture) importJSONL(t *testing.T, input string, opts transfer.Options) (*transfer.Report, error) {
+	t.Helper()
+
+	dec, err := transfer.NewDecoder(strings.NewReader(input), transfer.FormatJSONL)
+	require.NoError(t, err)
+
+	return f.importer.Import(context.Background(), dec, opts)
+}
+
+func (f *importFixture) seed(t *testing.T, code, originalURL, strategy string) {
+	t.Helper()
+
+	hash, err := shortener.HashOf(originalURL)
+	require.NoError(t, err)
+
+	err = f.store.Save(context.Background(), &shortener.ShortURL{
+		Code:        shortener.Code(code),
+		OriginalURL: originalURL,
+		URLHash:     hash,
+		Strategy:    strategy,
+		WorkspaceID: auth.DefaultWorkspace,
+		CreatedAt:   time.Now(),
+	})
+	require.NoError(t, err)
+}
+
+func TestImporter_Import(t *testing.T) {
+	t.Run("stores links and publishes their creation", func(t *testing.T) {
+		f := newImportFixture(t)
+
+		report, err := f.importJSONL(t,
+			`{"code":"abc","originalUrl":"https://example.com","strategy":"hash","workspaceId":"team-a",`+
+				`"createdAt":"2025-01-02T03:04:05Z","tags":["docs"]}`+"\n"+
+				`{"code":"def","originalUrl":"https://example.org"}`,
+			transfer.Options{})
+
+		require.NoError(t, err)
+		assert.Equal(t, transfer.Report{Rows: 2, Created: 2}, *report)
+
+		stored, err := f.store.GetByHash(context.Background(), "team-a", shortener.URLHash(
+			shortener.HashURL("https://example.com")))
+		require.NoError(t, err)
+		assert.Equal(t, shortener.Code("abc"), stored.Code)
+		assert.Equal(t, []string{"docs"}, stored.Tags)
+		assert.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), stored.CreatedAt)
+
+		stored, err = f.store.GetByCode(context.Background(), "def")
+		require.NoError(t, err)
+		assert.Equal(t, shortener.StrategyToken, stored.Strategy)
+		assert.Equal(t, auth.DefaultWorkspace, stored.WorkspaceID)
+
+		require.Len(t, f.events, 2)
+		assert.Equal(t, "abc", f.events[0].Code)
+	})
+
+	t.Run("suppresses events", func(t *testing.T) {
+		f := newImportFixture(t)
+
+		_, err := f.importJSONL(t, `{"code":"abc","originalUrl":"https://example.com"}`,
+			transfer.Options{SuppressEvents: true})
+
+		require.NoError(t, err)
+		assert.Empty(t, f.events)
+	})
+
+	t.Run("reports invalid rows and goes on", func(t *testing.T) {
+		f := newImportFixture(t)
+
+		report, err := f.importJSONL(t, strings.Join([]string{
+			`{"code":"","originalUrl":"https://example.com"}`,
+			`{"code":"a/b","originalUrl":"https://example.com"}`,
+			`{"code":"links","originalUrl":"https://example.com"}`,
+			`{"code":"abc","originalUrl":"ftp://example.com"}`,
+			`{"code":"abc","originalUrl":"https://blocked.example/page"}`,
+			`{"code":"abc","originalUrl":"https://example.com","strategy":"random"}`,
+			`{"code":"abc","originalUrl":"https://example.com","title":"` + strings.Repeat("t", 513) + `"}`,
+			`not json`,
+			`{"code":"ok","originalUrl":"https://example.com"}`,
+		}, "\n"), transfer.Options{})
+
+		require.NoError(t, err)
+		assert.Equal(t, 9, report.Rows)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 8, report.Failed)
+		require.Len(t, report.Errors, 8)
+		assert.Equal(t, 3, report.Errors[2].Line)
+		assert.Equal(t, "links", report.Errors[2].Code)
+		assert.Contains(t, report.Errors[4].Message, "blocked")
+	})
+
+	t.Run("checks codes of the checked code alphabet", func(t *testing.T) {
+		f := newImportFixture(t)
+		f.importer = transfer.NewImporter(f.store, blocklist.New(blockliststore.NewMemory()),
+			shortener.NewReservedWords(), checkcode.Scope{Enabled: true, LegacyLength: 8},
+			func(*analytics.URLCreatedEvent) error { return nil }, zap.NewNop())
+
+		generate, err := checkcode.New(9)
+		require.NoError(t, err)
+
+		// fx3kp7qa and fx3kp7qab only use the alphabet of checked codes but fail
+		// their check, the first being as short as codes made before checked codes
+		report, err := f.importJSONL(t, strings.Join([]string{
+			`{"code":"` + generate() + `","originalUrl":"https://example.com"}`,
+			`{"code":"fx3kp7qa","originalUrl":"https://example.com"}`,
+			`{"code":"fx3kp7qab","originalUrl":"https://example.com"}`,
+			`{"code":"brave-otter-42","originalUrl":"https://example.com"}`,
+			`{"code":"Legacy01","originalUrl":"https://example.com"}`,
+		}, "\n"), transfer.Options{})
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, report.Created)
+		require.Len(t, report.Errors, 1)
+		assert.Equal(t, "fx3kp7qab", report.Errors[0].Code)
+		assert.Contains(t, report.Errors[0].Message, "check character")
+	})
+
+	t.Run("applies the conflict policy to taken codes", func(t *testing.T) {
+		input := `{"code":"abc","originalUrl":"https://example.org","title":"Imported"}` + "\n" +
+			`{"code":"new","originalUrl":"https://example.org"}`
+
+		f := newImportFixture(t)
+		f.seed(t, "abc", "https://example.com", shortener.StrategyToken)
+
+		report, err := f.importJSONL(t, input, transfer.Options{Conflict: transfer.ConflictSkip})
+		require.NoError(t, err)
+		assert.Equal(t, transfer.Report{Rows: 2, Created: 1, Skipped: 1}, *report)
+
+		stored, _ := f.store.GetByCode(context.Background(), "abc")
+		assert.Equal(t, "https://example.com", stored.OriginalURL)
+
+		f = newImportFixture(t)
+		f.seed(t, "abc", "https://example.com", shortener.StrategyToken)
+
+		report, err = f.importJSONL(t, input, transfer.Options{Conflict: transfer.ConflictOverwrite})
+		require.NoError(t, err)
+		assert.Equal(t, transfer.Report{Rows: 2, Created: 1, Overwritten: 1}, *report)
+
+		stored, _ = f.store.GetByCode(context.Background(), "abc")
+		assert.Equal(t, "https://example.org", stored.OriginalURL)
+		assert.Equal(t, "Imported", stored.Title)
+
+		f = newImportFixture(t)
+		f.seed(t, "abc", "https://example.com", shortener.StrategyToken)
+
+		report, err = f.importJSONL(t, input, transfer.Options{Conflict: transfer.ConflictFail})
+
+		var conflict *transfer.ConflictError
+
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, 1, conflict.Line)
+		assert.Equal(t, "abc", conflict.Code)
+		assert.Equal(t, 0, report.Created)
+
+		_, err = f.store.GetByCode(context.Background(), "new")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("overwrites links of the same workspace only", func(t *testing.T) {
+		f := newImportFixture(t)
+		f.seed(t, "abc", "https://example.com", shortener.StrategyToken)
+		f.seed(t, "gone", "https://example.com", shortener.StrategyToken)
+
+		gone, err := f.store.GetByCode(context.Background(), "gone")
+		require.NoError(t, err)
+
+		deleted := *gone
+		deletedAt := time.Now()
+		deleted.DeletedAt = &deletedAt
+		require.NoError(t, f.store.Update(context.Background(), &deleted))
+
+		report, err := f.importJSONL(t, strings.Join([]string{
+			`{"code":"abc","originalUrl":"https://example.org","workspaceId":"team-b"}`,
+			`{"code":"gone","originalUrl":"https://example.org"}`,
+		}, "\n"), transfer.Options{Conflict: transfer.ConflictOverwrite})
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Failed)
+		assert.Contains(t, report.Errors[0].Message, "another workspace")
+		assert.Contains(t, report.Errors[1].Message, "deleted link")
+
+		stored, err := f.store.GetByCode(context.Background(), "abc")
+		require.NoError(t, err)
+		assert.Equal(t, auth.DefaultWorkspace, stored.WorkspaceID)
+		assert.Equal(t, "https://example.com", stored.OriginalURL)
+
+		stored, err = f.store.GetByCode(context.Background(), "gone")
+		require.NoError(t, err)
+		assert.True(t, stored.Deleted())
+	})
+
+	t.Run("stores duplicate hash links as token links", func(t *testing.T) {
+		f := newImportFixture(t)
+		f.seed(t, "first", "https://example.com", shortener.StrategyHash)
+
+		report, err := f.importJSONL(t, `{"code":"second","originalUrl":"https://example.com","strategy":"hash"}`,
+			transfer.Options{})
+
+		require.NoError(t, err)
+		assert.Equal(t, transfer.Report{Rows: 1, Created: 1, Demoted: 1}, *report)
+
+		stored, err := f.store.GetByCode(context.Background(), "second")
+		require.NoError(t, err)
+		assert.Equal(t, shortener.StrategyToken, stored.Strategy)
+	})
+
+	t.Run("dry runs store nothing", func(t *testing.T) {
+		f := newImportFixture(t)
+		f.seed(t, "first", "https://example.com", shortener.StrategyHash)
+
+		report, err := f.importJSONL(t, strings.Join([]string{
+			`{"code":"second","originalUrl":"https://example.com","strategy":"hash"}`,
+			`{"code":"first","originalUrl":"https://example.org"}`,
+			`{"code":"third","originalUrl":"https://example.org"}`,
+			`{"code":"third","originalUrl":"https://example.org"}`,
+		}, "\n"), transfer.Options{DryRun: true, Conflict: transfer.ConflictSkip})
+
+		require.NoError(t, err)
+		assert.Equal(t, transfer.Report{Rows: 4, Created: 2, Demoted: 1, Skipped: 2}, *report)
+		assert.Empty(t, f.events)
+
+		_, err = f.store.GetByCode(context.Background(), "second")
+		require.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("reports progress", func(t *testing.T) {
+		f := newImportFixture(t)
+
+		var input strings.Builder
+		for i := range 2500 {
+			fmt.Fprintf(&input, `{"code":"code%d","originalUrl":"https://example.com"}`+"\n", i)
+		}
+
+		var progress []int
+
+		_, err := f.importJSONL(t, input.String(), transfer.Options{
+			SuppressEvents: true,
+			Progress:       func(r transfer.Report) { progress = append(progress, r.Rows) },
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{1000, 2000}, progress)
+	})
+}
+
+func TestExport(t *testing.T) {
+	s := store.NewMemoryStore()
+	base := time.Now()
+
+	for i := range 1200 {
+		err := s.Save(context.Background(), &shortener.ShortURL{
+			Code:        shortener.Code(fmt.Sprintf("code%d", i)),
+			OriginalURL: "https://example.com",
+			WorkspaceID: []string{"team-a", "team-b"}[i%2],
+			CreatedAt:   base.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+
+	enc, err := transfer.NewEncoder(&buf, transfer.FormatJSONL)
+	require.NoError(t, err)
+
+	exported, err := transfer.Export(context.Background(), s, shortener.ListQuery{WorkspaceID: "team-a"}, enc)
+
+	require.NoError(t, err)
+	assert.Equal(t, 600, exported)
+	assert.Equal(t, 600, strings.Count(buf.String(), "\n"))
+	assert.NotContains(t, buf.String(), "team-b")
+}
@@ -0,0 +1,143 @@
+package wordcode
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/serroba/web-demo-go/internal/shortener"
+)
+
+// Bounds of the code shape; they keep codes within the code column.
+const (
+	MaxWords  = 4
+	MaxDigits = 6
+)
+
+var (
+	//go:embed words/adjectives.txt
+	adjectivesFile string
+	//go:embed words/nouns.txt
+	nounsFile string
+	//go:embed words/blocked.txt
+	blockedFile string
+
+	adjectives = parseList(adjectivesFile)
+	nouns      = parseList(nounsFile)
+	blocked    = parseBlocked(blockedFile)
+)
+
+// ErrInvalidShape is returned for a word or digit count outside the supported bounds.
+var ErrInvalidShape = errors.New("invalid word code shape")
+
+// New returns a generator of memorable codes, which are easier to read aloud than
+// random tokens. Codes are made of words-1 adjectives and a noun followed by a
+// number of the given digits, such as brave-otter-42 for 2 words and 2 digits.
+// Codes whose words read badly together are never returned.
+func New(words, digits int) (shortener.CodeGenerator, error) {
+	if words < 1 || words > MaxWords || digits < 0 || digits > MaxDigits {
+		return nil, fmt.Errorf("%w: %d words and %d digits", ErrInvalidShape, words, digits)
+	}
+
+	return func() string {
+		for {
+			if parts := generate(words); !offensive(parts) {
+				return format(parts, digits)
+			}
+		}
+	}, nil
+}
+
+// Keyspace returns the number of distinct codes New generates for words and
+// digits, before offensive combinations are filtered out.
+func Keyspace(words, digits int) uint64 {
+	size := uint64(len(nouns))
+
+	for range words - 1 {
+		size *= uint64(len(adjectives))
+	}
+
+	for range digits {
+		size *= 10
+	}
+
+	return size
+}
+
+// generate picks words-1 adjectives followed by a noun.
+func generate(words int) []string {
+	parts := make([]string, 0, words)
+
+	for range words - 1 {
+		parts = append(parts, adjectives[intn(len(adjectives))])
+	}
+
+	return append(parts, nouns[intn(len(nouns))])
+}
+
+// offensive reports whether a word, or a pair of adjacent words, is blocked.
+func offensive(parts []string) bool {
+	for i, p := range parts {
+		if _, ok := blocked[p]; ok {
+			return true
+		}
+
+		if i > 0 {
+			if _, ok := blocked[parts[i-1]+" "+p]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func format(parts []string, digits int) string {
+	code := strings.Join(parts, "-")
+	if digits == 0 {
+		return code
+	}
+
+	limit := 1
+	for range digits {
+		limit *= 10
+	}
+
+	return fmt.Sprintf("%s-%0*d", code, digits, intn(limit))
+}
+
+// intn returns a uniformly random int in [0, n).
+func intn(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+
+	return int(v.Int64())
+}
+
+// parseList returns the entries of an embedded list, skipping blank lines and comments.
+func parseList(file string) []string {
+	var words []string
+
+	for line := range strings.Lines(file) {
+		if w := strings.TrimSpace(line); w != "" && !strings.HasPrefix(w, "#") {
+			words = append(words, w)
+		}
+	}
+
+	return words
+}
+
+func parseBlocked(file string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, entry := range parseList(file) {
+		set[entry] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,64 @@
+package wordcode_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/serroba/web-demo-go/internal/wordcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("generates words followed by digits", func(t *testing.T) {
+		generate, err := wordcode.New(2, 2)
+		require.NoError(t, err)
+
+		pattern := regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9]{2}$`)
+		for range 100 {
+			assert.Regexp(t, pattern, generate())
+		}
+	})
+
+	t.Run("omits the number without digits", func(t *testing.T) {
+		generate, err := wordcode.New(3, 0)
+		require.NoError(t, err)
+
+		assert.Regexp(t, `^[a-z]+-[a-z]+-[a-z]+$`, generate())
+	})
+
+	t.Run("fits the code column", func(t *testing.T) {
+		generate, err := wordcode.New(wordcode.MaxWords, wordcode.MaxDigits)
+		require.NoError(t, err)
+
+		for range 100 {
+			assert.LessOrEqual(t, len(generate()), 64)
+		}
+	})
+
+	t.Run("never generates blocked combinations", func(t *testing.T) {
+		generate, err := wordcode.New(2, 0)
+		require.NoError(t, err)
+
+		// Each blocked pair would come up a few times in this many codes if unfiltered
+		for range 100_000 {
+			code := generate()
+			assert.NotContains(t, []string{"cheery-cherry", "silky-peach", "spicy-cherry", "spicy-peach"}, code)
+		}
+	})
+
+	t.Run("rejects unsupported shapes", func(t *testing.T) {
+		for _, shape := range [][2]int{{0, 2}, {wordcode.MaxWords + 1, 2}, {2, -1}, {2, wordcode.MaxDigits + 1}} {
+			_, err := wordcode.New(shape[0], shape[1])
+			require.ErrorIs(t, err, wordcode.ErrInvalidShape)
+		}
+	})
+}
+
+func TestKeyspace(t *testing.T) {
+	nouns := wordcode.Keyspace(1, 0)
+
+	assert.Greater(t, nouns, uint64(100))
+	assert.Equal(t, nouns*100, wordcode.Keyspace(1, 2))
+	assert.Greater(t, wordcode.Keyspace(3, 2), wordcode.Keyspace(2, 2))
+}
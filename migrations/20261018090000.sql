@@ -0,0 +1,7 @@
+-- Descriptive link metadata (user supplied or fetched from the destination page)
+ALTER TABLE short_urls
+    ADD COLUMN title       TEXT,
+    ADD COLUMN description TEXT,
+    ADD COLUMN image_url   TEXT,
+    ADD COLUMN tags        TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN notes       TEXT;
@@ -0,0 +1,21 @@
+-- Strategy that created each link (hash-strategy links are the ones with a url_hash)
+ALTER TABLE short_urls ADD COLUMN strategy VARCHAR(16) NOT NULL DEFAULT 'token';
+UPDATE short_urls SET strategy = 'hash' WHERE url_hash IS NOT NULL;
+
+-- Destination host, derived from the URL for domain filters
+ALTER TABLE short_urls ADD COLUMN domain TEXT GENERATED ALWAYS AS (
+    substring(lower(original_url) FROM '^[a-z][a-z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)')
+) STORED;
+
+-- Keyset pagination on (created_at, code), newest first
+CREATE INDEX idx_short_urls_created ON short_urls (created_at DESC, code DESC);
+
+-- Listing filters
+CREATE INDEX idx_short_urls_tags ON short_urls USING GIN (tags);
+CREATE INDEX idx_short_urls_domain ON short_urls (domain, created_at DESC);
+CREATE INDEX idx_short_urls_strategy ON short_urls (strategy, created_at DESC);
+
+-- Free-text search on URL and title
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_short_urls_url_trgm ON short_urls USING GIN (original_url gin_trgm_ops);
+CREATE INDEX idx_short_urls_title_trgm ON short_urls USING GIN (title gin_trgm_ops);
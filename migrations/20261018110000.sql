@@ -0,0 +1,15 @@
+-- Owner of each link; anonymous links have no owner
+ALTER TABLE short_urls ADD COLUMN owner_id TEXT;
+CREATE INDEX idx_short_urls_owner ON short_urls (owner_id, created_at DESC) WHERE owner_id IS NOT NULL;
+
+-- API keys; only the SHA-256 of the secret is stored
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_owner ON api_keys (owner_id);
@@ -0,0 +1,13 @@
+-- Roles granted to each API key; existing keys keep the ability to manage their own links
+ALTER TABLE api_keys ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{creator}';
+
+-- Admin takedowns
+ALTER TABLE short_urls ADD COLUMN disabled_at TIMESTAMPTZ;
+
+-- Destination domains that links may not point at; subdomains are blocked too
+CREATE TABLE blocked_domains (
+    domain TEXT PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,30 @@
+-- Workspaces are the tenant boundary for links, API keys and analytics
+CREATE TABLE workspaces (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO workspaces (id, name) VALUES ('default', 'Default');
+
+-- Per-workspace roles of SSO users
+CREATE TABLE workspace_members (
+    workspace_id TEXT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{creator}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+-- Existing links, keys and events belong to the default workspace
+ALTER TABLE short_urls ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default' REFERENCES workspaces (id);
+ALTER TABLE url_created_events ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE url_accessed_events ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+
+-- Hash-strategy deduplication is scoped per workspace
+DROP INDEX idx_short_urls_url_hash;
+CREATE INDEX idx_short_urls_url_hash ON short_urls (workspace_id, url_hash) WHERE url_hash IS NOT NULL;
+
+-- Listing a workspace's links, newest first
+CREATE INDEX idx_short_urls_workspace ON short_urls (workspace_id, created_at DESC, code DESC);
@@ -0,0 +1,14 @@
+-- Usage counters enforcing per-workspace and per-API-key quotas on link creation
+CREATE TABLE quota_usage (
+    subject TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    period TEXT NOT NULL DEFAULT '',
+    used BIGINT NOT NULL DEFAULT 0 CHECK (used >= 0),
+    PRIMARY KEY (subject, kind, period)
+);
+
+-- Existing links count towards the active links of their workspace
+INSERT INTO quota_usage (subject, kind, period, used)
+SELECT 'workspace:' || workspace_id, 'activeLinks', '', COUNT(*)
+FROM short_urls
+GROUP BY workspace_id;
@@ -0,0 +1,27 @@
+-- Append-only audit trail of link changes and admin actions
+CREATE TABLE link_audit (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL,
+    workspace_id TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    key_id TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- History of a link, newest first
+CREATE INDEX idx_link_audit_target ON link_audit (target, id DESC);
+
+-- Entries can be added but never changed or removed
+CREATE FUNCTION link_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'link_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER link_audit_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON link_audit
+    FOR EACH STATEMENT EXECUTE FUNCTION link_audit_append_only();
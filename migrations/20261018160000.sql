@@ -0,0 +1,4 @@
+-- Soft delete: deleted links are kept as tombstones until the retention job purges them
+ALTER TABLE short_urls ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_short_urls_deleted_at ON short_urls (deleted_at) WHERE deleted_at IS NOT NULL;
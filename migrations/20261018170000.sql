@@ -0,0 +1,4 @@
+-- Room for human-readable codes such as brave-otter-42
+ALTER TABLE short_urls ALTER COLUMN code TYPE VARCHAR(64);
+ALTER TABLE url_created_events ALTER COLUMN code TYPE VARCHAR(64);
+ALTER TABLE url_accessed_events ALTER COLUMN code TYPE VARCHAR(64);
@@ -0,0 +1,17 @@
+-- Keep only the oldest live hash link per destination deduplicating; the rest
+-- become plain token links so the unique index below can be built
+UPDATE short_urls AS s
+SET strategy = 'token'
+FROM short_urls AS k
+WHERE s.strategy = 'hash'
+  AND k.strategy = 'hash'
+  AND s.deleted_at IS NULL
+  AND k.deleted_at IS NULL
+  AND s.url_hash <> ''
+  AND s.workspace_id = k.workspace_id
+  AND s.url_hash = k.url_hash
+  AND (k.created_at, k.code) < (s.created_at, s.code);
+
+-- At most one live hash link per destination, enforced under concurrency
+CREATE UNIQUE INDEX idx_short_urls_dedup ON short_urls (workspace_id, url_hash)
+    WHERE strategy = 'hash' AND deleted_at IS NULL;